@@ -1,29 +1,48 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"expvar"
+	"flag"
 	"log"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/Nucleussss/hikayat-forum/auth/db"
 	"github.com/Nucleussss/hikayat-forum/auth/internal/delivery/grpc"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/jwtkeys"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/mailer"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/passkey"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/password"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/ratelimit"
 	"github.com/Nucleussss/hikayat-forum/auth/internal/repository/postgres"
 	"github.com/Nucleussss/hikayat-forum/auth/internal/service"
+	"github.com/Nucleussss/hikayat-forum/auth/pkg/config"
 
 	authpb "github.com/Nucleussss/hikayat-proto/gen/go/auth/v1"
 )
 
 func main() {
+	configFile := flag.String("config", os.Getenv("AUTH_CONFIG_FILE"), "path of an optional YAML configuration file")
+	maintenanceOnce := flag.Bool("maintenance-once", false, "run the retention maintenance once and exit instead of serving")
+	maintenanceDryRun := flag.Bool("maintenance-dry-run", false, "report what the retention maintenance would delete without deleting anything")
+	flag.Parse()
 
 	log.Println("Starting Auth Service")
 
-	// load configurations from .env file
-	// cfg := config.LoadConfig()
+	// load the configuration from the defaults, the YAML file, .env and the environment
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		log.Fatalf("Error loading configuration: %v", err)
+	}
 
 	// initiate database connection
-	connStr := db.ConnectionString()
+	connStr := db.ConnectionString(cfg.DB)
 	dbConn, err := db.InitDB(connStr)
 	if err != nil {
 		log.Fatalf("Error initializing database: %v", err)
@@ -35,26 +54,209 @@ func main() {
 		}
 	}()
 
-	// initiate user repository using the PostgreSQL database connection
+	// background workers stop when the service shuts down
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// initiate repositories using the PostgreSQL database connection
 	userRepo := postgres.NewUserRepository(dbConn)
+	sessionRepo := postgres.NewSessionRepository(dbConn)
+	revocationRepo := postgres.NewRevocationRepository(dbConn)
+	passwordResetRepo := postgres.NewPasswordResetRepository(dbConn)
+	emailChangeRepo := postgres.NewEmailChangeRepository(dbConn)
+	mfaRepo := postgres.NewMFARepository(dbConn)
+	passkeyRepo := postgres.NewPasskeyRepository(dbConn)
+	loginThrottleRepo := postgres.NewLoginThrottleRepository(dbConn)
+	roleRepo := postgres.NewRoleRepository(dbConn)
+	permissionRepo := postgres.NewPermissionRepository(dbConn)
+	signingKeyRepo := postgres.NewSigningKeyRepository(dbConn)
+	auditRepo := postgres.NewAuditRepository(dbConn)
+	suspensionRepo := postgres.NewSuspensionRepository(dbConn)
+	maintenanceRepo := postgres.NewMaintenanceRepository(dbConn)
+
+	// load the key audit checkpoints are signed with, when one is configured
+	var checkpointKey *jwtkeys.Key
+	if cfg.Audit.CheckpointKeyFile != "" {
+		checkpointKey, err = jwtkeys.LoadKeyFile(cfg.Audit.CheckpointKeyFile)
+		if err != nil {
+			log.Fatalf("Error loading audit checkpoint key: %v", err)
+		}
+		if !checkpointKey.CanSign() {
+			log.Fatalf("Audit checkpoint key %s is not a private key", cfg.Audit.CheckpointKeyFile)
+		}
+	}
+
+	// prune rows past their retention, either once from the command line or periodically, pruned
+	// audit events leave a checkpoint at the new chain anchor
+	maintenance := service.NewMaintenance(maintenanceRepo, auditRepo, sessionRepo, passwordResetRepo, mfaRepo, passkeyRepo, checkpointKey,
+		service.NewMaintenanceConfig(cfg.Maintenance, *maintenanceDryRun))
+	if *maintenanceOnce {
+		report, err := maintenance.Run(ctx)
+		if err != nil {
+			log.Fatalf("Error running maintenance: %v", err)
+		}
+		service.LogMaintenanceReport(report)
+		return
+	}
+	maintenance.Start(ctx)
+
+	// audit events are written in the background, the queued ones are flushed before the database closes
+	auditRecorder := service.NewAuditRecorder(auditRepo, 1024, time.Second)
+	defer auditRecorder.Close()
+
+	// make the configured user admin, so a fresh deployment can manage roles through the admin RPCs
+	if cfg.Security.BootstrapAdminEmail != "" {
+		if err := service.BootstrapAdmin(ctx, userRepo, roleRepo, auditRecorder, cfg.Security.BootstrapAdminEmail); err != nil {
+			log.Printf("Error bootstrapping admin: %v", err)
+		}
+	}
+
+	// sign checkpoints of the audit hash chain when a checkpoint key is configured
+	if checkpointKey != nil {
+		service.NewAuditCheckpointer(ctx, auditRepo, checkpointKey, cfg.Audit.CheckpointInterval)
+	}
+
+	// initiate the revocation store, revocations are cached in memory and refreshed periodically
+	revocations, err := service.NewRevocationStore(ctx, revocationRepo, cfg.JWT.AccessTTL, 30*time.Second)
+	if err != nil {
+		log.Fatalf("Error initializing revocation store: %v", err)
+	}
+
+	// initiate the mailer, the transport is smtp, file or log
+	mailSender, err := mailer.New(mailer.Config{
+		Transport:    cfg.Mail.Transport,
+		From:         cfg.Mail.From,
+		SMTPHost:     cfg.Mail.SMTPHost,
+		SMTPPort:     cfg.Mail.SMTPPort,
+		SMTPUsername: cfg.Mail.SMTPUsername,
+		SMTPPassword: cfg.Mail.SMTPPassword,
+		DropDir:      cfg.Mail.DropDir,
+	})
+	if err != nil {
+		log.Fatalf("Error initializing mailer: %v", err)
+	}
+
+	// load the mail templates, a template directory overrides the templates shipped with the service
+	templatesFS := service.DefaultMailTemplatesFS()
+	if cfg.Mail.TemplateDir != "" {
+		templatesFS = os.DirFS(cfg.Mail.TemplateDir)
+	}
+	mailTemplates, err := service.NewMailTemplates(templatesFS, cfg.Mail.DefaultLocale)
+	if err != nil {
+		log.Fatalf("Error loading mail templates: %v", err)
+	}
+
+	// initiate the WebAuthn relying party, passkeys are bound to the RP ID and only accepted
+	// from the configured origins
+	relyingParty, err := passkey.NewRelyingParty(passkey.Config{
+		RPID:          cfg.WebAuthn.RPID,
+		RPDisplayName: cfg.WebAuthn.RPName,
+		RPOrigins:     cfg.WebAuthn.RPOrigins,
+		Timeout:       cfg.WebAuthn.CeremonyTTL,
+	})
+	if err != nil {
+		log.Fatalf("Error initializing webauthn relying party: %v", err)
+	}
+
+	// initiate the password hasher, new hashes are argon2id with the configured costs and pepper
+	pepper, err := password.LoadPepper(cfg.Password.PepperFile)
+	if err != nil {
+		log.Fatalf("Error loading password pepper: %v", err)
+	}
+	passwordHasher, err := password.NewHasher(password.Params{
+		Memory:      uint32(cfg.Password.Argon2Memory),
+		Iterations:  uint32(cfg.Password.Argon2Iterations),
+		Parallelism: uint8(cfg.Password.Argon2Parallelism),
+	}, pepper)
+	if err != nil {
+		log.Fatalf("Error initializing password hasher: %v", err)
+	}
+
+	// load the token keys, tokens are signed with the signing key file or, without one, HS256 with the
+	// JWT secret until the first rotation stores a key in the database
+	staticKeys, err := jwtkeys.LoadSet(cfg.JWT.SigningKeyFile, cfg.JWT.VerifyKeyFiles, cfg.JWT.Secret)
+	if err != nil {
+		log.Fatalf("Error loading token keys: %v", err)
+	}
+	keyManagerConfig, err := service.NewKeyManagerConfig(cfg.JWT)
+	if err != nil {
+		log.Fatalf("Error loading key rotation config: %v", err)
+	}
+	tokenKeys, err := service.NewKeyManager(ctx, signingKeyRepo, staticKeys, keyManagerConfig, 30*time.Second)
+	if err != nil {
+		log.Fatalf("Error initializing key manager: %v", err)
+	}
+	expvar.Publish("jwt_signing_keys", expvar.Func(func() any { return tokenKeys.Metrics() }))
 
 	// initiate service layer
-	authService := service.NewAuthService(userRepo)
+	authService := service.NewAuthService(
+		userRepo,
+		sessionRepo,
+		passwordResetRepo,
+		emailChangeRepo,
+		mfaRepo,
+		passkeyRepo,
+		loginThrottleRepo,
+		roleRepo,
+		permissionRepo,
+		auditRepo,
+		suspensionRepo,
+		relyingParty,
+		passwordHasher,
+		tokenKeys,
+		service.NewLoginThrottleConfig(cfg.Security),
+		revocations,
+		mailSender,
+		mailTemplates,
+		auditRecorder,
+		cfg,
+	)
 
 	// initiate auth handler
 	authHandler := grpc.NewAuthHandler(authService)
 
-	grpcServer := grpc.NewServer()
+	// backend services authenticate to the service-only RPCs with their registered credentials
+	serviceClients, err := config.ParseServiceClients(cfg.Security.ServiceClients)
+	if err != nil {
+		log.Fatalf("Error loading service clients: %v", err)
+	}
+
+	// forwarded client addresses are only honored from the proxies in front of the service
+	trustedProxies, err := config.ParseTrustedProxies(cfg.Server.TrustedProxies)
+	if err != nil {
+		log.Fatalf("Error loading trusted proxies: %v", err)
+	}
+
+	// requests are rate limited per the configured table, buckets are kept in process
+	grpcServer := grpc.NewServer(tokenKeys, cfg.JWT, revocations, permissionRepo, serviceClients, trustedProxies, cfg.RateLimits, ratelimit.NewMemoryStore())
 
 	// register gRPC server with reflection for easy discovery and access
 	authpb.RegisterAuthServiceServer(grpcServer, authHandler)
 
 	// start gRPC server on the specified port
-	lis, err := net.Listen("tcp", ":"+os.Getenv("AUTH_GRPC_PORT"))
+	lis, err := net.Listen("tcp", ":"+cfg.Server.GRPCPort)
 	if err != nil {
-		log.Fatalf("failed to listen on port %s : %v", os.Getenv("AUTH_GRPC_PORT"), err)
+		log.Fatalf("failed to listen on port %s : %v", cfg.Server.GRPCPort, err)
+	}
+	log.Printf("Starting gRPC server at %s\n", cfg.Server.GRPCPort)
+
+	// serve the JWKS document over HTTP for verifiers that do not speak gRPC
+	httpMux := http.NewServeMux()
+	httpMux.Handle("/.well-known/jwks.json", jwtkeys.Handler(tokenKeys))
+	httpServer := &http.Server{
+		Addr:              ":" + cfg.Server.HTTPPort,
+		Handler:           httpMux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	// serve the key ages among the other expvar metrics on the internal listener only
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("/debug/vars", expvar.Handler())
+	metricsServer := &http.Server{
+		Addr:              cfg.Server.MetricsAddr,
+		Handler:           metricsMux,
+		ReadHeaderTimeout: 5 * time.Second,
 	}
-	log.Printf("Starting gRPC server at %s\n", os.Getenv("AUTH_GRPC_PORT"))
 
 	// gracefull shutdown setup
 	sigChan := make(chan os.Signal, 1)
@@ -73,11 +275,38 @@ func main() {
 		}
 	}()
 
+	// start HTTP server in a separate goroutine
+	go func() {
+		log.Printf("Starting HTTP server at %s\n", cfg.Server.HTTPPort)
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("http server stopped with error: %v", err)
+		}
+	}()
+
+	// start the metrics server in a separate goroutine, unless it is disabled
+	if cfg.Server.MetricsAddr != "" {
+		go func() {
+			log.Printf("Starting metrics server at %s\n", cfg.Server.MetricsAddr)
+			if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Printf("metrics server stopped with error: %v", err)
+			}
+		}()
+	}
+
 	// wait for shutdown signal
 	<-sigChan
 	log.Println("Received shutdown signal, stopping gRPC server...")
 
 	// graceful shutdown setup
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer shutdownCancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error stopping HTTP server: %v", err)
+	}
+	if err := metricsServer.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error stopping metrics server: %v", err)
+	}
+
 	grpcServer.GracefulStop()
 	log.Printf("GRPC Server stopped gracefully\n")
 
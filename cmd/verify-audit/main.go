@@ -0,0 +1,224 @@
+// Command verify-audit checks that the audit log was not tampered with. It walks the hash chain of
+// audit_logs and reports the first broken link, checking the chain against the signed checkpoints
+// stored in the database and, with -checkpoints, against previously exported ones. The chain
+// starts at the anchor left by pruning, which needs a checkpoint of its own. With -archives the
+// pruned events are read from the maintenance archives and verified too. With -export it writes
+// the stored checkpoints as JSON, to be kept outside the database.
+//
+// It exits with status 1 when the chain is broken and 2 when it could not be verified.
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Nucleussss/hikayat-forum/auth/db"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/auditlog"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/jwtkeys"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/repository"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/repository/postgres"
+	"github.com/Nucleussss/hikayat-forum/auth/pkg/config"
+)
+
+func main() {
+	configFile := flag.String("config", os.Getenv("AUTH_CONFIG_FILE"), "path of an optional YAML configuration file")
+	keyFiles := flag.String("keys", "", "comma separated PEM files of the keys checkpoints are verified with, defaults to the configured checkpoint key")
+	checkpointsFile := flag.String("checkpoints", "", "JSON file of exported checkpoints to check the chain against")
+	exportFile := flag.String("export", "", "write the stored checkpoints as JSON to this file")
+	archives := flag.String("archives", "", "comma separated audit archive files or directories of them, verified in front of the stored chain")
+	flag.Parse()
+
+	// the report goes to stdout, progress and errors to stderr
+	log.SetOutput(os.Stderr)
+
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		log.Printf("Error loading configuration: %v", err)
+		os.Exit(2)
+	}
+
+	dbConn, err := db.InitDB(db.ConnectionString(cfg.DB))
+	if err != nil {
+		log.Printf("Error initializing database: %v", err)
+		os.Exit(2)
+	}
+
+	code := run(context.Background(), postgres.NewAuditRepository(dbConn), cfg, *keyFiles, *checkpointsFile, *exportFile, *archives)
+	dbConn.Close()
+	os.Exit(code)
+}
+
+func run(ctx context.Context, repo repository.AuditRepository, cfg *config.Config, keyFiles, checkpointsFile, exportFile, archives string) int {
+	checkpoints, err := repo.ListAuditCheckpoints(ctx)
+	if err != nil {
+		log.Printf("Error listing checkpoints: %v", err)
+		return 2
+	}
+
+	if exportFile != "" {
+		if err := exportCheckpoints(exportFile, checkpoints); err != nil {
+			log.Printf("Error exporting checkpoints: %v", err)
+			return 2
+		}
+	}
+
+	if checkpointsFile != "" {
+		exported, err := readCheckpoints(checkpointsFile)
+		if err != nil {
+			log.Printf("Error reading %s: %v", checkpointsFile, err)
+			return 2
+		}
+		checkpoints = append(checkpoints, exported...)
+	}
+
+	if keyFiles == "" {
+		keyFiles = cfg.Audit.CheckpointKeyFile
+	}
+	keys, err := loadKeys(keyFiles)
+	if err != nil {
+		log.Printf("Error loading checkpoint keys: %v", err)
+		return 2
+	}
+	if keys == nil && len(checkpoints) > 0 {
+		log.Printf("No checkpoint key given, checkpoint signatures are not checked")
+	}
+
+	var chain auditlog.ChainReader = repo
+	if archives != "" {
+		archived, err := readArchives(archives)
+		if err != nil {
+			log.Printf("Error reading archives: %v", err)
+			return 2
+		}
+		fmt.Printf("archived events:     %d\n", len(archived))
+		chain = auditlog.NewArchivedChain(repo, archived)
+	}
+
+	report, err := auditlog.VerifyChain(ctx, chain, checkpoints, keys)
+	if err != nil {
+		log.Printf("Error verifying audit chain: %v", err)
+		return 2
+	}
+
+	unchained, err := repo.CountUnchainedAuditEvents(ctx)
+	if err != nil {
+		log.Printf("Error counting unchained events: %v", err)
+		return 2
+	}
+
+	if report.StartSeq > 0 {
+		fmt.Printf("chain starts after:  seq %d (earlier events were pruned and not read from archives)\n", report.StartSeq)
+	}
+	fmt.Printf("events checked:      %d\n", report.Events)
+	fmt.Printf("chain head:          seq %d, hash %s\n", report.HeadSeq, report.HeadHash)
+	fmt.Printf("checkpoints matched: %d of %d (%d cover pruned events)\n", report.Checkpoints, len(checkpoints), report.Pruned)
+	fmt.Printf("unchained events:    %d (written before the chain was introduced)\n", unchained)
+
+	if report.Broken != nil {
+		fmt.Printf("FIRST BROKEN LINK:   %v\n", report.Broken)
+		return 1
+	}
+
+	fmt.Println("audit chain intact")
+	return 0
+}
+
+// loadKeys loads the comma separated key files by key ID, nil when there are none.
+func loadKeys(keyFiles string) (map[string]*jwtkeys.Key, error) {
+	if keyFiles == "" {
+		return nil, nil
+	}
+
+	keys := map[string]*jwtkeys.Key{}
+	for _, path := range strings.Split(keyFiles, ",") {
+		key, err := jwtkeys.LoadKeyFile(strings.TrimSpace(path))
+		if err != nil {
+			return nil, err
+		}
+		keys[key.ID] = key
+	}
+
+	return keys, nil
+}
+
+func exportCheckpoints(path string, checkpoints []*models.AuditCheckpoint) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := auditlog.WriteCheckpoints(file, checkpoints); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+func readCheckpoints(path string) ([]*models.AuditCheckpoint, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return auditlog.ReadCheckpoints(file)
+}
+
+// readArchives reads the events of the comma separated archive files. A directory stands for the
+// archives the maintenance job wrote to it.
+func readArchives(paths string) ([]*models.AuditEvent, error) {
+	var files []string
+	for _, path := range strings.Split(paths, ",") {
+		path = strings.TrimSpace(path)
+
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		matches, err := filepath.Glob(filepath.Join(path, "audit_logs-*.jsonl.gz"))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+
+	var events []*models.AuditEvent
+	for _, path := range files {
+		archived, err := readArchive(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		events = append(events, archived...)
+	}
+
+	return events, nil
+}
+
+func readArchive(path string) ([]*models.AuditEvent, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	compressed, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer compressed.Close()
+
+	return auditlog.ReadArchive(compressed)
+}
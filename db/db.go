@@ -4,9 +4,9 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
-	"os"
 	"time"
 
+	"github.com/Nucleussss/hikayat-forum/auth/pkg/config"
 	_ "github.com/lib/pq"
 )
 
@@ -49,13 +49,7 @@ func InitDB(dbString string) (*sql.DB, error) {
 	return nil, fmt.Errorf("%s failed to connect to database after retries: %w", op, err)
 }
 
-func ConnectionString() string {
-	DBHost := os.Getenv("DB_HOST")
-	DBUser := os.Getenv("DB_USER")
-	DBPass := os.Getenv("DB_PASSWORD")
-	DBname := os.Getenv("DB_NAME")
-	DBPort := os.Getenv("DB_PORT")
-	DBsslMode := os.Getenv("DB_SSL_MODE")
-
-	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s", DBHost, DBUser, DBPass, DBname, DBPort, DBsslMode)
+// ConnectionString builds the lib/pq connection string of the configured database.
+func ConnectionString(cfg config.DBConfig) string {
+	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s", cfg.Host, cfg.User, cfg.Password, cfg.Name, cfg.Port, cfg.SSLMode)
 }
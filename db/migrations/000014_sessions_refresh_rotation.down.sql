@@ -0,0 +1,6 @@
+ALTER TABLE sessions
+    DROP COLUMN revoked_at,
+    DROP COLUMN rotated_at,
+    DROP COLUMN family_id,
+    ALTER COLUMN created_at TYPE TIMESTAMP,
+    ALTER COLUMN expires_at TYPE TIMESTAMP;
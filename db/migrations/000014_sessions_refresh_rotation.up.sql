@@ -0,0 +1,6 @@
+ALTER TABLE sessions
+    ALTER COLUMN expires_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ,
+    ADD COLUMN family_id UUID NOT NULL DEFAULT gen_random_uuid(),
+    ADD COLUMN rotated_at TIMESTAMPTZ,
+    ADD COLUMN revoked_at TIMESTAMPTZ;
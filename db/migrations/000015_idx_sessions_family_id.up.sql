@@ -0,0 +1 @@
+CREATE INDEX idx_sessions_family_id ON sessions(family_id);
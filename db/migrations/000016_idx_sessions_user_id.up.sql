@@ -0,0 +1 @@
+CREATE INDEX idx_sessions_user_id ON sessions(user_id);
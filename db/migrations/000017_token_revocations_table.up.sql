@@ -0,0 +1,7 @@
+CREATE TABLE token_revocations (
+    subject_type VARCHAR(32) NOT NULL,
+    subject_id VARCHAR(255) NOT NULL,
+    revoked_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (subject_type, subject_id)
+);
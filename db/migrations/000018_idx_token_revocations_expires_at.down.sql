@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_token_revocations_expires_at;
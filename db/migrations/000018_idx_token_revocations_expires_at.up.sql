@@ -0,0 +1 @@
+CREATE INDEX idx_token_revocations_expires_at ON token_revocations(expires_at);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_email_changes_user_id;
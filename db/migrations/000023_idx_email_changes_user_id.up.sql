@@ -0,0 +1 @@
+CREATE INDEX idx_email_changes_user_id ON email_changes(user_id);
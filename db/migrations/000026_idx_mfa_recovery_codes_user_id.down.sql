@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_mfa_recovery_codes_user_id;
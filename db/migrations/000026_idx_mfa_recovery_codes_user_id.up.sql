@@ -0,0 +1 @@
+CREATE INDEX idx_mfa_recovery_codes_user_id ON mfa_recovery_codes(user_id);
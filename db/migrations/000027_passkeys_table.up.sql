@@ -0,0 +1,14 @@
+CREATE TABLE passkeys (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    credential_id BYTEA NOT NULL UNIQUE,
+    public_key BYTEA NOT NULL,
+    sign_count BIGINT NOT NULL DEFAULT 0,
+    aaguid BYTEA,
+    transports TEXT[] NOT NULL DEFAULT '{}',
+    flags SMALLINT NOT NULL DEFAULT 0,
+    attestation_type VARCHAR(32) NOT NULL DEFAULT 'none',
+    name VARCHAR(100) NOT NULL,
+    last_used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_passkeys_user_id;
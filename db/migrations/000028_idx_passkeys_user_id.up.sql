@@ -0,0 +1 @@
+CREATE INDEX idx_passkeys_user_id ON passkeys(user_id);
@@ -0,0 +1,9 @@
+CREATE TABLE login_throttles (
+    subject_type VARCHAR(16) NOT NULL,
+    subject_key VARCHAR(255) NOT NULL,
+    failures INTEGER NOT NULL DEFAULT 0,
+    window_started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_failure_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    locked_until TIMESTAMPTZ,
+    PRIMARY KEY (subject_type, subject_key)
+);
@@ -0,0 +1,5 @@
+DELETE FROM roles WHERE role_name IN ('admin', 'moderator');
+
+DELETE FROM permissions WHERE permission_name IN (
+    'users.read', 'users.delete', 'users.unlock', 'roles.manage', 'posts.moderate', 'comments.moderate'
+);
@@ -0,0 +1,22 @@
+INSERT INTO permissions (permission_name, description) VALUES
+    ('users.read', 'View any user account'),
+    ('users.delete', 'Delete any user account'),
+    ('users.unlock', 'Lift login lockouts'),
+    ('roles.manage', 'Create and delete roles, grant permissions and assign roles'),
+    ('posts.moderate', 'Edit, hide and delete posts of other users'),
+    ('comments.moderate', 'Edit, hide and delete comments of other users')
+ON CONFLICT (permission_name) DO NOTHING;
+
+INSERT INTO roles (role_name) VALUES ('admin'), ('moderator')
+ON CONFLICT (role_name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r CROSS JOIN permissions p
+WHERE r.role_name = 'admin'
+ON CONFLICT DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r JOIN permissions p
+    ON p.permission_name IN ('posts.moderate', 'comments.moderate')
+WHERE r.role_name = 'moderator'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,5 @@
+ALTER TABLE sessions
+    DROP COLUMN authz_version;
+
+ALTER TABLE users
+    DROP COLUMN authz_version;
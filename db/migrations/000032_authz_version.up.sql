@@ -0,0 +1,5 @@
+ALTER TABLE users
+    ADD COLUMN authz_version BIGINT NOT NULL DEFAULT 0;
+
+ALTER TABLE sessions
+    ADD COLUMN authz_version BIGINT NOT NULL DEFAULT 0;
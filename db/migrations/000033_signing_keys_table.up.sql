@@ -0,0 +1,8 @@
+CREATE TABLE signing_keys (
+    kid VARCHAR(64) PRIMARY KEY,
+    algorithm VARCHAR(16) NOT NULL,
+    sealed_key BYTEA NOT NULL,
+    activates_at TIMESTAMPTZ NOT NULL,
+    retires_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1 @@
+DELETE FROM permissions WHERE permission_name = 'keys.rotate';
@@ -0,0 +1,8 @@
+INSERT INTO permissions (permission_name, description) VALUES
+    ('keys.rotate', 'Rotate the token signing keys')
+ON CONFLICT (permission_name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r JOIN permissions p ON p.permission_name = 'keys.rotate'
+WHERE r.role_name = 'admin'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,19 @@
+DROP INDEX IF EXISTS idx_audit_logs_created_at;
+DROP INDEX IF EXISTS idx_audit_logs_target_user_id;
+
+ALTER TABLE audit_logs
+    DROP COLUMN metadata,
+    DROP COLUMN user_agent,
+    DROP COLUMN ip_address,
+    DROP COLUMN outcome,
+    DROP COLUMN actor_id,
+    ALTER COLUMN created_at DROP NOT NULL,
+    ALTER COLUMN created_at TYPE TIMESTAMP;
+
+ALTER TABLE audit_logs
+    RENAME COLUMN target_user_id TO user_id;
+
+DELETE FROM audit_logs WHERE user_id IS NOT NULL AND user_id NOT IN (SELECT id FROM users);
+
+ALTER TABLE audit_logs
+    ADD CONSTRAINT audit_logs_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
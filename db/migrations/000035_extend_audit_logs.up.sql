@@ -0,0 +1,18 @@
+-- audit entries have to outlive the users they are about, deleting a user must not delete them
+ALTER TABLE audit_logs
+    DROP CONSTRAINT IF EXISTS audit_logs_user_id_fkey;
+
+ALTER TABLE audit_logs
+    RENAME COLUMN user_id TO target_user_id;
+
+ALTER TABLE audit_logs
+    ADD COLUMN actor_id UUID,
+    ADD COLUMN outcome VARCHAR(16) NOT NULL DEFAULT 'success',
+    ADD COLUMN ip_address VARCHAR(64),
+    ADD COLUMN user_agent TEXT,
+    ADD COLUMN metadata JSONB NOT NULL DEFAULT '{}'::jsonb,
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN created_at SET NOT NULL;
+
+CREATE INDEX idx_audit_logs_target_user_id ON audit_logs (target_user_id, created_at DESC);
+CREATE INDEX idx_audit_logs_created_at ON audit_logs (created_at DESC);
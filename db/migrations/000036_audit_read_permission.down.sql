@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_audit_logs_actor_id;
+DROP INDEX IF EXISTS idx_audit_logs_target_user_id;
+DROP INDEX IF EXISTS idx_audit_logs_created_at;
+CREATE INDEX idx_audit_logs_target_user_id ON audit_logs (target_user_id, created_at DESC);
+CREATE INDEX idx_audit_logs_created_at ON audit_logs (created_at DESC);
+
+DELETE FROM permissions WHERE permission_name = 'audit.read';
@@ -0,0 +1,15 @@
+INSERT INTO permissions (permission_name, description) VALUES
+    ('audit.read', 'Search the audit log')
+ON CONFLICT (permission_name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r JOIN permissions p ON p.permission_name = 'audit.read'
+WHERE r.role_name IN ('admin', 'moderator')
+ON CONFLICT DO NOTHING;
+
+-- audit events are paged by (created_at, id), newest first
+DROP INDEX IF EXISTS idx_audit_logs_created_at;
+DROP INDEX IF EXISTS idx_audit_logs_target_user_id;
+CREATE INDEX idx_audit_logs_created_at ON audit_logs (created_at DESC, id DESC);
+CREATE INDEX idx_audit_logs_target_user_id ON audit_logs (target_user_id, created_at DESC, id DESC);
+CREATE INDEX idx_audit_logs_actor_id ON audit_logs (actor_id, created_at DESC, id DESC);
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS audit_checkpoints;
+
+DROP INDEX IF EXISTS idx_audit_logs_seq;
+
+ALTER TABLE audit_logs
+    DROP COLUMN row_hash,
+    DROP COLUMN prev_hash,
+    DROP COLUMN seq;
@@ -0,0 +1,17 @@
+-- every audit row written from now on is chained: row_hash covers the row together with
+-- prev_hash, the row_hash of the row with the previous seq
+ALTER TABLE audit_logs
+    ADD COLUMN seq BIGINT,
+    ADD COLUMN prev_hash VARCHAR(64),
+    ADD COLUMN row_hash VARCHAR(64);
+
+CREATE UNIQUE INDEX idx_audit_logs_seq ON audit_logs (seq);
+
+CREATE TABLE audit_checkpoints (
+    seq BIGINT PRIMARY KEY,
+    row_hash VARCHAR(64) NOT NULL,
+    signed_at TIMESTAMPTZ NOT NULL,
+    key_id VARCHAR(255) NOT NULL,
+    algorithm VARCHAR(16) NOT NULL,
+    signature TEXT NOT NULL
+);
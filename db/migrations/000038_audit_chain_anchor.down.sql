@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_sessions_expires_at;
+
+DROP TABLE IF EXISTS audit_chain_anchor;
@@ -0,0 +1,9 @@
+-- once the oldest audit rows are archived and pruned, the chain continues from the last pruned row
+CREATE TABLE audit_chain_anchor (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    seq BIGINT NOT NULL,
+    row_hash VARCHAR(64) NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_sessions_expires_at ON sessions(expires_at);
@@ -0,0 +1,6 @@
+DELETE FROM permissions WHERE permission_name = 'users.suspend';
+
+DROP TABLE IF EXISTS user_suspensions;
+
+ALTER TABLE users
+    ALTER COLUMN is_active DROP NOT NULL;
@@ -0,0 +1,31 @@
+-- users.is_active is false while a suspension is in force, the suspensions themselves are kept as history
+UPDATE users SET is_active = TRUE WHERE is_active IS NULL;
+ALTER TABLE users
+    ALTER COLUMN is_active SET NOT NULL;
+
+CREATE TABLE user_suspensions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reason TEXT NOT NULL,
+    suspended_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    -- NULL suspends until the suspension is lifted
+    expires_at TIMESTAMPTZ,
+    lifted_at TIMESTAMPTZ,
+    lifted_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    lift_reason TEXT
+);
+
+CREATE INDEX idx_user_suspensions_user_id ON user_suspensions (user_id, created_at DESC);
+
+-- at most one suspension of a user is in force at a time
+CREATE UNIQUE INDEX idx_user_suspensions_open ON user_suspensions (user_id) WHERE lifted_at IS NULL;
+
+INSERT INTO permissions (permission_name, description) VALUES
+    ('users.suspend', 'Suspend users and lift their suspensions')
+ON CONFLICT (permission_name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r JOIN permissions p ON p.permission_name = 'users.suspend'
+WHERE r.role_name = 'admin'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,11 @@
+-- the mfa challenges handed out by Login, a challenge is claimed while a code is checked and
+-- used up once a code was accepted or too many were wrong
+CREATE TABLE mfa_challenges (
+    token_id VARCHAR(255) PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    failures INT NOT NULL DEFAULT 0,
+    claimed_at TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_mfa_challenges_expires_at ON mfa_challenges(expires_at);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_webauthn_ceremonies_expires_at;
@@ -0,0 +1,2 @@
+-- the maintenance job deletes the ceremonies that were started but never finished
+CREATE INDEX idx_webauthn_ceremonies_expires_at ON webauthn_ceremonies(expires_at);
@@ -0,0 +1,6 @@
+ALTER TABLE password_resets
+    DROP CONSTRAINT IF EXISTS password_resets_user_id_fkey;
+
+ALTER TABLE password_resets
+    ADD CONSTRAINT password_resets_user_id_fkey
+    FOREIGN KEY (user_id) REFERENCES users(id);
@@ -0,0 +1,7 @@
+-- deleting a user must not fail on a reset token they requested, expired ones included
+ALTER TABLE password_resets
+    DROP CONSTRAINT IF EXISTS password_resets_user_id_fkey;
+
+ALTER TABLE password_resets
+    ADD CONSTRAINT password_resets_user_id_fkey
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
@@ -0,0 +1,141 @@
+package auditlog
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/google/uuid"
+)
+
+// archivedEvent is one line of an archive. The chain fields are kept, so archived events can
+// still be verified.
+type archivedEvent struct {
+	ID           uuid.UUID      `json:"id"`
+	Seq          int64          `json:"seq,omitempty"`
+	PrevHash     string         `json:"prev_hash,omitempty"`
+	RowHash      string         `json:"row_hash,omitempty"`
+	Action       string         `json:"action"`
+	Outcome      string         `json:"outcome"`
+	ActorID      *uuid.UUID     `json:"actor_id,omitempty"`
+	TargetUserID *uuid.UUID     `json:"target_user_id,omitempty"`
+	IPAddress    string         `json:"ip_address,omitempty"`
+	UserAgent    string         `json:"user_agent,omitempty"`
+	Metadata     map[string]any `json:"metadata"`
+	CreatedAt    time.Time      `json:"created_at"`
+}
+
+// WriteArchive writes the events as JSON lines, one event per line.
+func WriteArchive(w io.Writer, events []*models.AuditEvent) error {
+	encoder := json.NewEncoder(w)
+	for _, event := range events {
+		if err := encoder.Encode(archivedEvent{
+			ID:           event.ID,
+			Seq:          event.Seq,
+			PrevHash:     event.PrevHash,
+			RowHash:      event.Hash,
+			Action:       event.Action,
+			Outcome:      event.Outcome,
+			ActorID:      event.ActorID,
+			TargetUserID: event.TargetUserID,
+			IPAddress:    event.IPAddress,
+			UserAgent:    event.UserAgent,
+			Metadata:     event.Metadata,
+			CreatedAt:    event.CreatedAt.UTC(),
+		}); err != nil {
+			return fmt.Errorf("failed to archive audit event %s: %w", event.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// ReadArchive reads events written by WriteArchive.
+func ReadArchive(r io.Reader) ([]*models.AuditEvent, error) {
+	var events []*models.AuditEvent
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var archived archivedEvent
+		if err := json.Unmarshal(scanner.Bytes(), &archived); err != nil {
+			return nil, fmt.Errorf("failed to read archived audit event: %w", err)
+		}
+		events = append(events, &models.AuditEvent{
+			ID:           archived.ID,
+			Seq:          archived.Seq,
+			PrevHash:     archived.PrevHash,
+			Hash:         archived.RowHash,
+			Action:       archived.Action,
+			Outcome:      archived.Outcome,
+			ActorID:      archived.ActorID,
+			TargetUserID: archived.TargetUserID,
+			IPAddress:    archived.IPAddress,
+			UserAgent:    archived.UserAgent,
+			Metadata:     archived.Metadata,
+			CreatedAt:    archived.CreatedAt,
+		})
+	}
+
+	return events, scanner.Err()
+}
+
+// archivedChain serves the chained events of the archives before the chain still in the database.
+type archivedChain struct {
+	chain    ChainReader
+	archived []*models.AuditEvent
+}
+
+// NewArchivedChain puts the archived events in front of the chain, so VerifyChain walks the pruned
+// prefix as well and checks it against the checkpoints signed before it was pruned. The walk then
+// starts before the oldest archived event, from the genesis hash when no archive was lost.
+// Unchained events in the archives are ignored.
+func NewArchivedChain(chain ChainReader, archived []*models.AuditEvent) ChainReader {
+	var chained []*models.AuditEvent
+	for _, event := range archived {
+		if event.Seq > 0 {
+			chained = append(chained, event)
+		}
+	}
+	slices.SortFunc(chained, func(a, b *models.AuditEvent) int { return cmp.Compare(a.Seq, b.Seq) })
+
+	return &archivedChain{chain: chain, archived: chained}
+}
+
+func (c *archivedChain) AuditChainAnchor(ctx context.Context) (int64, string, error) {
+	if len(c.archived) == 0 {
+		return c.chain.AuditChainAnchor(ctx)
+	}
+
+	first := c.archived[0]
+
+	return first.Seq - 1, first.PrevHash, nil
+}
+
+func (c *archivedChain) ListAuditChain(ctx context.Context, afterSeq int64, limit int) ([]*models.AuditEvent, error) {
+	var events []*models.AuditEvent
+	for _, event := range c.archived {
+		if event.Seq > afterSeq && len(events) < limit {
+			events = append(events, event)
+		}
+	}
+	if len(events) == limit {
+		return events, nil
+	}
+
+	if len(c.archived) > 0 {
+		afterSeq = max(afterSeq, c.archived[len(c.archived)-1].Seq)
+	}
+	stored, err := c.chain.ListAuditChain(ctx, afterSeq, limit-len(events))
+	if err != nil {
+		return nil, err
+	}
+
+	return append(events, stored...), nil
+}
@@ -0,0 +1,223 @@
+package auditlog_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/auditlog"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/jwtkeys"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeChain serves events the way the repository does, in seq order after a seq.
+type fakeChain []*models.AuditEvent
+
+func (c fakeChain) ListAuditChain(ctx context.Context, afterSeq int64, limit int) ([]*models.AuditEvent, error) {
+	var events []*models.AuditEvent
+	for _, event := range c {
+		if event.Seq > afterSeq && len(events) < limit {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (c fakeChain) AuditChainAnchor(ctx context.Context) (int64, string, error) {
+	return 0, auditlog.GenesisHash, nil
+}
+
+// prunedChain is a chain whose start was pruned up to its anchor.
+type prunedChain struct {
+	fakeChain
+	seq  int64
+	hash string
+}
+
+func (c prunedChain) AuditChainAnchor(ctx context.Context) (int64, string, error) {
+	return c.seq, c.hash, nil
+}
+
+func newChain(t *testing.T, n int) fakeChain {
+	t.Helper()
+
+	target := uuid.New()
+	var events []*models.AuditEvent
+	for i := 0; i < n; i++ {
+		events = append(events, &models.AuditEvent{
+			ID:           uuid.New(),
+			Action:       models.AuditActionLogin,
+			Outcome:      models.AuditOutcomeSuccess,
+			TargetUserID: &target,
+			IPAddress:    "203.0.113.7",
+			Metadata:     map[string]any{"method": "password", "failures": 3},
+			CreatedAt:    time.Now().UTC().Truncate(time.Microsecond),
+		})
+	}
+	require.NoError(t, auditlog.Link(0, auditlog.GenesisHash, events))
+
+	return events
+}
+
+func TestVerifyChainIntact(t *testing.T) {
+	chain := newChain(t, 5)
+
+	report, err := auditlog.VerifyChain(context.Background(), chain, nil, nil)
+	require.NoError(t, err)
+	require.Nil(t, report.Broken)
+	require.EqualValues(t, 5, report.Events)
+	require.EqualValues(t, 5, report.HeadSeq)
+	require.Equal(t, chain[4].Hash, report.HeadHash)
+}
+
+func TestVerifyChainReportsFirstBrokenLink(t *testing.T) {
+	// an edited event
+	chain := newChain(t, 5)
+	chain[2].Outcome = models.AuditOutcomeFailure
+	report, err := auditlog.VerifyChain(context.Background(), chain, nil, nil)
+	require.NoError(t, err)
+	require.NotNil(t, report.Broken)
+	require.EqualValues(t, 3, report.Broken.Seq)
+
+	// a deleted event
+	chain = newChain(t, 5)
+	chain = append(chain[:1], chain[2:]...)
+	report, err = auditlog.VerifyChain(context.Background(), chain, nil, nil)
+	require.NoError(t, err)
+	require.NotNil(t, report.Broken)
+	require.EqualValues(t, 2, report.Broken.Seq)
+
+	// an edited event whose hash was recomputed breaks the link to the next one
+	chain = newChain(t, 5)
+	chain[1].Action = models.AuditActionLogout
+	chain[1].Hash, err = auditlog.RowHash(chain[1])
+	require.NoError(t, err)
+	report, err = auditlog.VerifyChain(context.Background(), chain, nil, nil)
+	require.NoError(t, err)
+	require.NotNil(t, report.Broken)
+	require.EqualValues(t, 3, report.Broken.Seq)
+}
+
+func TestVerifyChainAgainstCheckpoints(t *testing.T) {
+	key, err := jwtkeys.GenerateKey(jwtkeys.AlgorithmEdDSA)
+	require.NoError(t, err)
+	keys := map[string]*jwtkeys.Key{key.ID: key}
+
+	chain := newChain(t, 5)
+	checkpoint, err := auditlog.SignCheckpoint(key, 4, chain[3].Hash, time.Now())
+	require.NoError(t, err)
+
+	report, err := auditlog.VerifyChain(context.Background(), chain, []*models.AuditCheckpoint{checkpoint}, keys)
+	require.NoError(t, err)
+	require.Nil(t, report.Broken)
+	require.Equal(t, 1, report.Checkpoints)
+
+	// cutting off the end of the chain is caught by the checkpoint covering it
+	report, err = auditlog.VerifyChain(context.Background(), chain[:3], []*models.AuditCheckpoint{checkpoint}, keys)
+	require.NoError(t, err)
+	require.NotNil(t, report.Broken)
+	require.EqualValues(t, 4, report.Broken.Seq)
+
+	// so is a rewritten chain that is consistent in itself
+	rewritten := newChain(t, 5)
+	report, err = auditlog.VerifyChain(context.Background(), rewritten, []*models.AuditCheckpoint{checkpoint}, keys)
+	require.NoError(t, err)
+	require.NotNil(t, report.Broken)
+	require.EqualValues(t, 4, report.Broken.Seq)
+
+	// a forged checkpoint is refused
+	checkpoint.RowHash = rewritten[3].Hash
+	_, err = auditlog.VerifyChain(context.Background(), rewritten, []*models.AuditCheckpoint{checkpoint}, keys)
+	require.Error(t, err)
+}
+
+func TestVerifyChainFromAnchor(t *testing.T) {
+	key, err := jwtkeys.GenerateKey(jwtkeys.AlgorithmEdDSA)
+	require.NoError(t, err)
+	keys := map[string]*jwtkeys.Key{key.ID: key}
+
+	chain := newChain(t, 5)
+	pruned, err := auditlog.SignCheckpoint(key, 1, chain[0].Hash, time.Now())
+	require.NoError(t, err)
+	atAnchor, err := auditlog.SignCheckpoint(key, 2, chain[1].Hash, time.Now())
+	require.NoError(t, err)
+	checkpoints := []*models.AuditCheckpoint{pruned, atAnchor}
+
+	// the first two events were archived and deleted
+	remaining := prunedChain{fakeChain: chain[2:], seq: 2, hash: chain[1].Hash}
+	report, err := auditlog.VerifyChain(context.Background(), remaining, checkpoints, keys)
+	require.NoError(t, err)
+	require.Nil(t, report.Broken)
+	require.EqualValues(t, 2, report.StartSeq)
+	require.EqualValues(t, 3, report.Events)
+	require.EqualValues(t, 5, report.HeadSeq)
+	require.Equal(t, 1, report.Checkpoints)
+	require.Equal(t, 1, report.Pruned)
+
+	// an anchor moved to hide deleted events does not match the checkpoint at its seq
+	forged := prunedChain{fakeChain: chain[2:], seq: 2, hash: chain[0].Hash}
+	report, err = auditlog.VerifyChain(context.Background(), forged, checkpoints, keys)
+	require.NoError(t, err)
+	require.NotNil(t, report.Broken)
+	require.EqualValues(t, 2, report.Broken.Seq)
+
+	// events deleted past the anchor are still missing
+	report, err = auditlog.VerifyChain(context.Background(), prunedChain{fakeChain: chain[3:], seq: 2, hash: chain[1].Hash}, checkpoints, keys)
+	require.NoError(t, err)
+	require.NotNil(t, report.Broken)
+	require.EqualValues(t, 3, report.Broken.Seq)
+
+	// an anchor moved along with a deleted prefix has no checkpoint at its seq
+	report, err = auditlog.VerifyChain(context.Background(), prunedChain{fakeChain: chain[3:], seq: 3, hash: chain[2].Hash}, checkpoints, keys)
+	require.NoError(t, err)
+	require.NotNil(t, report.Broken)
+	require.EqualValues(t, 3, report.Broken.Seq)
+	require.Zero(t, report.Events)
+}
+
+func TestVerifyChainWithArchives(t *testing.T) {
+	key, err := jwtkeys.GenerateKey(jwtkeys.AlgorithmEdDSA)
+	require.NoError(t, err)
+	keys := map[string]*jwtkeys.Key{key.ID: key}
+
+	chain := newChain(t, 6)
+	early, err := auditlog.SignCheckpoint(key, 2, chain[1].Hash, time.Now())
+	require.NoError(t, err)
+	atAnchor, err := auditlog.SignCheckpoint(key, 4, chain[3].Hash, time.Now())
+	require.NoError(t, err)
+	checkpoints := []*models.AuditCheckpoint{early, atAnchor}
+
+	// the first four events were pruned in two batches, the archives are read in any order
+	remaining := prunedChain{fakeChain: chain[4:], seq: 4, hash: chain[3].Hash}
+	archived := []*models.AuditEvent{chain[2], chain[3], chain[0], chain[1]}
+
+	report, err := auditlog.VerifyChain(context.Background(), auditlog.NewArchivedChain(remaining, archived), checkpoints, keys)
+	require.NoError(t, err)
+	require.Nil(t, report.Broken)
+	require.Zero(t, report.StartSeq)
+	require.EqualValues(t, 6, report.Events)
+	require.Equal(t, 2, report.Checkpoints)
+	require.Zero(t, report.Pruned)
+
+	// an archived event that was edited is caught, and so is a lost archive
+	edited := *chain[1]
+	edited.Outcome = models.AuditOutcomeFailure
+	report, err = auditlog.VerifyChain(context.Background(), auditlog.NewArchivedChain(remaining, []*models.AuditEvent{chain[0], &edited, chain[2], chain[3]}), checkpoints, keys)
+	require.NoError(t, err)
+	require.NotNil(t, report.Broken)
+	require.EqualValues(t, 2, report.Broken.Seq)
+
+	report, err = auditlog.VerifyChain(context.Background(), auditlog.NewArchivedChain(remaining, []*models.AuditEvent{chain[0], chain[1]}), checkpoints, keys)
+	require.NoError(t, err)
+	require.NotNil(t, report.Broken)
+	require.EqualValues(t, 3, report.Broken.Seq)
+
+	// without the oldest archive the walk starts at the checkpoint the first pruning left behind
+	report, err = auditlog.VerifyChain(context.Background(), auditlog.NewArchivedChain(remaining, []*models.AuditEvent{chain[2], chain[3]}), checkpoints, keys)
+	require.NoError(t, err)
+	require.Nil(t, report.Broken)
+	require.EqualValues(t, 2, report.StartSeq)
+	require.EqualValues(t, 4, report.Events)
+}
@@ -0,0 +1,118 @@
+package auditlog
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/jwtkeys"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+)
+
+// checkpointContext separates checkpoint signatures from anything else signed with the same key.
+const checkpointContext = "hikayat-audit-checkpoint-v1"
+
+// SignCheckpoint signs the chain head with a private key.
+func SignCheckpoint(key *jwtkeys.Key, seq int64, rowHash string, signedAt time.Time) (*models.AuditCheckpoint, error) {
+	if !key.CanSign() {
+		return nil, fmt.Errorf("checkpoint key %s cannot sign", key.ID)
+	}
+
+	checkpoint := &models.AuditCheckpoint{
+		Seq:       seq,
+		RowHash:   rowHash,
+		SignedAt:  signedAt.UTC().Truncate(time.Microsecond),
+		KeyID:     key.ID,
+		Algorithm: key.Algorithm,
+	}
+
+	signature, err := key.SigningMethod().Sign(signingInput(checkpoint), key.SignKey())
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign checkpoint: %w", err)
+	}
+	checkpoint.Signature = base64.RawURLEncoding.EncodeToString(signature)
+
+	return checkpoint, nil
+}
+
+// VerifyCheckpoint checks the signature of a checkpoint against the key it names.
+func VerifyCheckpoint(key *jwtkeys.Key, checkpoint *models.AuditCheckpoint) error {
+	if checkpoint.KeyID != key.ID || checkpoint.Algorithm != key.Algorithm {
+		return fmt.Errorf("checkpoint at seq %d was signed with key %s, not %s", checkpoint.Seq, checkpoint.KeyID, key.ID)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(checkpoint.Signature)
+	if err != nil {
+		return fmt.Errorf("checkpoint at seq %d has a malformed signature", checkpoint.Seq)
+	}
+
+	if err := key.SigningMethod().Verify(signingInput(checkpoint), signature, key.VerifyKey()); err != nil {
+		return fmt.Errorf("checkpoint at seq %d has an invalid signature: %w", checkpoint.Seq, err)
+	}
+
+	return nil
+}
+
+// signingInput is what a checkpoint signature covers, one field per line.
+func signingInput(checkpoint *models.AuditCheckpoint) string {
+	return checkpointContext + "\n" +
+		strconv.FormatInt(checkpoint.Seq, 10) + "\n" +
+		checkpoint.RowHash + "\n" +
+		checkpoint.SignedAt.UTC().Format(time.RFC3339Nano) + "\n" +
+		checkpoint.KeyID + "\n" +
+		checkpoint.Algorithm
+}
+
+// checkpointJSON is the exported form of a checkpoint.
+type checkpointJSON struct {
+	Seq       int64     `json:"seq"`
+	RowHash   string    `json:"row_hash"`
+	SignedAt  time.Time `json:"signed_at"`
+	KeyID     string    `json:"kid"`
+	Algorithm string    `json:"alg"`
+	Signature string    `json:"signature"`
+}
+
+// WriteCheckpoints exports checkpoints as a JSON array.
+func WriteCheckpoints(w io.Writer, checkpoints []*models.AuditCheckpoint) error {
+	exported := make([]checkpointJSON, 0, len(checkpoints))
+	for _, c := range checkpoints {
+		exported = append(exported, checkpointJSON{
+			Seq:       c.Seq,
+			RowHash:   c.RowHash,
+			SignedAt:  c.SignedAt.UTC(),
+			KeyID:     c.KeyID,
+			Algorithm: c.Algorithm,
+			Signature: c.Signature,
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(exported)
+}
+
+// ReadCheckpoints reads checkpoints exported by WriteCheckpoints.
+func ReadCheckpoints(r io.Reader) ([]*models.AuditCheckpoint, error) {
+	var exported []checkpointJSON
+	if err := json.NewDecoder(r).Decode(&exported); err != nil {
+		return nil, fmt.Errorf("failed to read checkpoints: %w", err)
+	}
+
+	checkpoints := make([]*models.AuditCheckpoint, 0, len(exported))
+	for _, c := range exported {
+		checkpoints = append(checkpoints, &models.AuditCheckpoint{
+			Seq:       c.Seq,
+			RowHash:   c.RowHash,
+			SignedAt:  c.SignedAt,
+			KeyID:     c.KeyID,
+			Algorithm: c.Algorithm,
+			Signature: c.Signature,
+		})
+	}
+
+	return checkpoints, nil
+}
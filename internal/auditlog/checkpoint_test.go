@@ -0,0 +1,32 @@
+package auditlog_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/auditlog"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/jwtkeys"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckpointExportRoundTrip(t *testing.T) {
+	key, err := jwtkeys.GenerateKey(jwtkeys.AlgorithmRS256)
+	require.NoError(t, err)
+
+	checkpoint, err := auditlog.SignCheckpoint(key, 42, auditlog.GenesisHash, time.Now())
+	require.NoError(t, err)
+
+	var exported bytes.Buffer
+	require.NoError(t, auditlog.WriteCheckpoints(&exported, []*models.AuditCheckpoint{checkpoint}))
+
+	imported, err := auditlog.ReadCheckpoints(&exported)
+	require.NoError(t, err)
+	require.Len(t, imported, 1)
+	require.NoError(t, auditlog.VerifyCheckpoint(key, imported[0]))
+
+	// a checkpoint moved to another position of the chain no longer verifies
+	imported[0].Seq = 43
+	require.Error(t, auditlog.VerifyCheckpoint(key, imported[0]))
+}
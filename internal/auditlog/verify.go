@@ -0,0 +1,128 @@
+package auditlog
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/jwtkeys"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+)
+
+// verifyBatchSize is the number of events read at a time while walking the chain.
+const verifyBatchSize = 1000
+
+// ChainReader reads the hash chain in seq order, starting after its anchor.
+type ChainReader interface {
+	ListAuditChain(ctx context.Context, afterSeq int64, limit int) ([]*models.AuditEvent, error)
+	// AuditChainAnchor returns the seq and hash of the last pruned event, 0 and GenesisHash when
+	// nothing was pruned.
+	AuditChainAnchor(ctx context.Context) (int64, string, error)
+}
+
+// Report is the outcome of VerifyChain. Broken is nil when the chain holds.
+type Report struct {
+	// Events is the number of events checked before the walk ended or broke.
+	Events int64
+	// StartSeq is the seq of the last pruned event, the walk starts after it.
+	StartSeq int64
+	HeadSeq  int64
+	HeadHash string
+	// Checkpoints is the number of checkpoints the chain was found to match.
+	Checkpoints int
+	// Pruned is the number of checkpoints covering pruned events, which cannot be checked.
+	Pruned int
+	Broken *BrokenLinkError
+}
+
+// VerifyChain walks the whole chain from its anchor and stops at the first broken link. Every
+// checkpoint has to match the event at its seq, a checkpoint past the end of the chain means
+// events were cut off. The anchor itself is only trusted with a checkpoint signed at its seq, as
+// pruning leaves one behind: an anchor without one, or not matching it, is a broken link, since
+// whoever can move the anchor could delete any prefix of the chain. Older checkpoints covered
+// pruned events and are skipped, unless the chain reads the archives too, see NewArchivedChain.
+// With keys, the checkpoint signatures are checked first, keyed by key ID.
+func VerifyChain(ctx context.Context, chain ChainReader, checkpoints []*models.AuditCheckpoint, keys map[string]*jwtkeys.Key) (*Report, error) {
+	startSeq, startHash, err := chain.AuditChainAnchor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{StartSeq: startSeq}
+	anchorSigned := startSeq == 0
+	pending := make(map[int64][]*models.AuditCheckpoint, len(checkpoints))
+	for _, checkpoint := range checkpoints {
+		if keys != nil {
+			key, ok := keys[checkpoint.KeyID]
+			if !ok {
+				return nil, fmt.Errorf("checkpoint at seq %d was signed with unknown key %s", checkpoint.Seq, checkpoint.KeyID)
+			}
+			if err := VerifyCheckpoint(key, checkpoint); err != nil {
+				return nil, err
+			}
+		}
+
+		switch {
+		case checkpoint.Seq < startSeq:
+			report.Pruned++
+		case checkpoint.Seq == startSeq:
+			if checkpoint.RowHash != startHash {
+				report.Broken = &BrokenLinkError{Seq: startSeq, Reason: fmt.Sprintf("the chain anchor does not match the checkpoint signed at %s", checkpoint.SignedAt)}
+				report.HeadSeq, report.HeadHash = startSeq, startHash
+				return report, nil
+			}
+			report.Checkpoints++
+			anchorSigned = true
+		default:
+			pending[checkpoint.Seq] = append(pending[checkpoint.Seq], checkpoint)
+		}
+	}
+
+	if !anchorSigned {
+		report.Broken = &BrokenLinkError{Seq: startSeq, Reason: "the chain anchor has no signed checkpoint at its seq, events before it may have been deleted"}
+		report.HeadSeq, report.HeadHash = startSeq, startHash
+		return report, nil
+	}
+
+	verifier := NewVerifierFrom(startSeq, startHash)
+	for {
+		seq, _ := verifier.Head()
+		events, err := chain.ListAuditChain(ctx, seq, verifyBatchSize)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, event := range events {
+			if broken := verifier.Check(event); broken != nil {
+				report.Broken = broken
+				report.HeadSeq, report.HeadHash = verifier.Head()
+				return report, nil
+			}
+			report.Events++
+
+			for _, checkpoint := range pending[event.Seq] {
+				if checkpoint.RowHash != event.Hash {
+					report.Broken = &BrokenLinkError{Seq: event.Seq, Reason: fmt.Sprintf("does not match the checkpoint signed at %s", checkpoint.SignedAt)}
+					report.HeadSeq, report.HeadHash = verifier.Head()
+					return report, nil
+				}
+				report.Checkpoints++
+			}
+			delete(pending, event.Seq)
+		}
+
+		if len(events) < verifyBatchSize {
+			break
+		}
+	}
+
+	report.HeadSeq, report.HeadHash = verifier.Head()
+
+	// whatever is left was checkpointed past the current end of the chain
+	for seq := range pending {
+		if report.Broken == nil || seq < report.Broken.Seq {
+			report.Broken = &BrokenLinkError{Seq: seq, Reason: fmt.Sprintf("missing, the chain ends at seq %d but a checkpoint covers it", report.HeadSeq)}
+		}
+	}
+
+	return report, nil
+}
@@ -4,5 +4,10 @@ package context
 type contextKey string
 
 const (
-	UserIDContextKey contextKey = "user_id"
+	UserIDContextKey         contextKey = "user_id"
+	SessionIDContextKey      contextKey = "session_id"
+	TokenIDContextKey        contextKey = "token_id"
+	TokenExpiresAtContextKey contextKey = "token_expires_at"
+	ServiceClientContextKey  contextKey = "service_client"
+	ClientInfoContextKey     contextKey = "client_info"
 )
@@ -2,11 +2,17 @@ package grpc
 
 import (
 	"context"
+	"errors"
+	"strings"
+	"time"
 
 	"log"
 
+	contextKey "github.com/Nucleussss/hikayat-forum/auth/internal/context"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
 	"github.com/Nucleussss/hikayat-forum/auth/internal/service"
 	"github.com/Nucleussss/hikayat-forum/auth/pkg/utils"
+	"github.com/google/uuid"
 
 	authpb "github.com/Nucleussss/hikayat-proto/gen/go/auth/v1"
 
@@ -93,15 +99,40 @@ func (h *AuthHandler) Login(ctx context.Context, req *authpb.LoginRequest) (*aut
 
 	// call the authService to login and get the token
 	tokenString, err := h.authService.Login(ctx, req)
+	var throttled *service.ThrottledError
+	if errors.As(err, &throttled) {
+		log.Printf("%s Login throttled for email: %v\n ", op, req.GetEmail())
+		return nil, throttledStatus(throttled)
+	}
+	if errors.Is(err, service.ErrEmailNotVerified) {
+		log.Printf("%s Login refused for unverified email: %v\n ", op, req.GetEmail())
+		return nil, status.Error(codes.FailedPrecondition, "email not verified")
+	}
+	if errors.Is(err, service.ErrAccountSuspended) {
+		log.Printf("%s Login refused for suspended account: %v\n ", op, req.GetEmail())
+		return nil, status.Error(codes.PermissionDenied, "account suspended")
+	}
 	if err != nil {
 		log.Printf("%s Login failed for email: %v\n ", op, req.GetEmail())
 		return nil, status.Error(codes.Unauthenticated, "Login failed")
 	}
 
+	// the password was correct but a second factor is still required
+	if tokenString.MfaRequired {
+		log.Printf("%s MFA required for : %v\n", op, req.GetEmail())
+		return &authpb.LoginResponse{
+			Message:     "MFA required",
+			MfaRequired: true,
+			MfaToken:    tokenString.MfaToken,
+		}, nil
+	}
+
 	// create a login response message
 	response := &authpb.LoginResponse{
-		Message: "Login Successful",
-		Token:   tokenString.Token,
+		Message:      "Login Successful",
+		Token:        tokenString.Token,
+		RefreshToken: tokenString.RefreshToken,
+		ExpiresIn:    tokenString.ExpiresIn,
 	}
 	log.Printf("%s Login successful for : %v\n", op, req.GetEmail())
 	return response, nil
@@ -115,12 +146,6 @@ func (h *AuthHandler) GetUser(ctx context.Context, req *authpb.GetUserRequest) (
 		return nil, status.Error(codes.Internal, "auth service not initialized")
 	}
 
-	// get the user ID from the context
-	if err := utils.EnsureUserAuthorized(ctx, req.GetId()); err != nil {
-		log.Printf("%s user was not autorized. %v", op, err)
-		return nil, status.Error(codes.Internal, err.Error())
-	}
-
 	// validate email format
 	if req.GetId() == "" {
 		return nil, status.Error(codes.InvalidArgument, "Invalid id")
@@ -146,12 +171,6 @@ func (h *AuthHandler) UpdateUserProfile(ctx context.Context, req *authpb.UpdateU
 		return nil, status.Error(codes.Internal, "auth service not initialized")
 	}
 
-	// get the user ID from the context
-	if err := utils.EnsureUserAuthorized(ctx, req.GetId()); err != nil {
-		log.Printf("%s user was not autorized. %v", op, err)
-		return nil, status.Error(codes.Internal, err.Error())
-	}
-
 	// validate user input
 	if req.GetName() == "" {
 		log.Printf("%s name was empty", op)
@@ -182,17 +201,15 @@ func (h *AuthHandler) ChangeUserEmail(ctx context.Context, req *authpb.ChangeUse
 		return nil, status.Error(codes.Internal, "auth service not initialized")
 	}
 
-	// get the user ID from the context
-	if err := utils.EnsureUserAuthorized(ctx, req.GetId()); err != nil {
-		log.Printf("%s user was not autorized. %v", op, err)
-		return nil, status.Error(codes.Internal, err.Error())
-	}
-
 	// validate user input
 	if req.GetEmail() == "" {
 		return nil, status.Error(codes.InvalidArgument, "email is required")
 	}
 
+	if req.GetCurrentPassword() == "" {
+		return nil, status.Error(codes.InvalidArgument, "current password is required")
+	}
+
 	// validate email format
 	if !utils.IsValidEmail(req.GetEmail()) {
 		log.Printf("%s invalid email provided: %s", op, req.GetEmail())
@@ -201,14 +218,27 @@ func (h *AuthHandler) ChangeUserEmail(ctx context.Context, req *authpb.ChangeUse
 
 	// call the service to change user email
 	err := h.authService.ChangeUserEmail(ctx, req)
+	var throttled *service.ThrottledError
+	if errors.As(err, &throttled) {
+		log.Printf("%s Password check throttled for user: %v\n", op, req.GetId())
+		return nil, throttledStatus(throttled)
+	}
+	if errors.Is(err, service.ErrIncorrectPassword) {
+		log.Printf("%s Wrong current password for user: %v\n", op, req.GetId())
+		return nil, status.Error(codes.PermissionDenied, "current password is incorrect")
+	}
+	if errors.Is(err, service.ErrEmailTaken) {
+		log.Printf("%s Email already taken: %v\n", op, req.GetEmail())
+		return nil, status.Error(codes.AlreadyExists, "email address is already taken")
+	}
 	if err != nil {
 		log.Printf("%s failed to change user email: %v", op, err)
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, status.Error(codes.Internal, "failed to change email")
 	}
 
 	// convert the response to protobuf format
 	res := &authpb.ChangeUserEmailResponse{
-		Message: "change user email successful for: " + req.GetId(),
+		Message: "confirmation link sent to the new email address for: " + req.GetId(),
 	}
 
 	return res, err
@@ -222,12 +252,6 @@ func (h *AuthHandler) ChangeUserPassword(ctx context.Context, req *authpb.Change
 		return nil, status.Error(codes.Internal, "auth service not initialized")
 	}
 
-	// get the user ID from the context
-	if err := utils.EnsureUserAuthorized(ctx, req.GetId()); err != nil {
-		log.Printf("%s user was not autorized. %v", op, err)
-		return nil, status.Error(codes.Internal, err.Error())
-	}
-
 	// validate user input
 	if req.GetNewpassword() == "" || req.GetCurrentpassword() == "" {
 		log.Printf("%s failed to change user password due to empty fields", op)
@@ -242,6 +266,11 @@ func (h *AuthHandler) ChangeUserPassword(ctx context.Context, req *authpb.Change
 
 	// call the ChangeUserPassword method of authService
 	err := h.authService.ChangeUserPassword(ctx, req)
+	var throttled *service.ThrottledError
+	if errors.As(err, &throttled) {
+		log.Printf("%s Password check throttled for user: %v\n", op, req.GetId())
+		return nil, throttledStatus(throttled)
+	}
 	if err != nil {
 		log.Printf("%s failed to change user password due to error: %v", op, err)
 		return nil, status.Error(codes.Internal, err.Error())
@@ -262,12 +291,6 @@ func (h *AuthHandler) DeleteUser(ctx context.Context, req *authpb.DeleteUserRequ
 		return nil, status.Error(codes.Internal, "auth service not initialized")
 	}
 
-	// get the user ID from the context
-	if err := utils.EnsureUserAuthorized(ctx, req.GetId()); err != nil {
-		log.Printf("%s user was not autorized. %v", op, err)
-		return nil, status.Error(codes.Internal, err.Error())
-	}
-
 	// call the DeleteUser method of authService
 	err := h.authService.DeleteUser(ctx, req)
 	if err != nil {
@@ -281,3 +304,949 @@ func (h *AuthHandler) DeleteUser(ctx context.Context, req *authpb.DeleteUserRequ
 
 	return res, nil
 }
+
+func (h *AuthHandler) RefreshToken(ctx context.Context, req *authpb.RefreshTokenRequest) (*authpb.RefreshTokenResponse, error) {
+	op := "authHandler.RefreshToken"
+	log.Printf("%s Received refresh token request", op)
+
+	if h.authService == nil {
+		return nil, status.Error(codes.Internal, "auth service not initialized")
+	}
+
+	// validate user input
+	if req.GetRefreshToken() == "" {
+		log.Printf("%s refresh token was empty", op)
+		return nil, status.Error(codes.InvalidArgument, "refresh token is required")
+	}
+
+	// rotate the refresh token and issue a new token pair
+	res, err := h.authService.RefreshToken(ctx, req)
+	if err != nil {
+		log.Printf("%s failed to refresh token: %v", op, err)
+		if errors.Is(err, service.ErrAuthorizationStale) {
+			return nil, status.Error(codes.Unauthenticated, "authorization stale, please log in again")
+		}
+		if errors.Is(err, service.ErrAccountSuspended) {
+			return nil, status.Error(codes.PermissionDenied, "account suspended")
+		}
+		return nil, status.Error(codes.Unauthenticated, "invalid refresh token")
+	}
+
+	return res, nil
+}
+
+func (h *AuthHandler) Logout(ctx context.Context, req *authpb.LogoutRequest) (*authpb.LogoutResponse, error) {
+	op := "authHandler.Logout"
+	log.Printf("%s Received logout request", op)
+
+	if h.authService == nil {
+		return nil, status.Error(codes.Internal, "auth service not initialized")
+	}
+
+	// call the Logout method of authService
+	if err := h.authService.Logout(ctx, req); err != nil {
+		log.Printf("%s failed to logout due to error: %v", op, err)
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	res := &authpb.LogoutResponse{
+		Message: "Logout successful",
+	}
+
+	return res, nil
+}
+
+func (h *AuthHandler) LogoutAllDevices(ctx context.Context, req *authpb.LogoutAllDevicesRequest) (*authpb.LogoutAllDevicesResponse, error) {
+	op := "authHandler.LogoutAllDevices"
+	log.Printf("recieve logout all devices request from client: %s", req.GetId())
+
+	if h.authService == nil {
+		return nil, status.Error(codes.Internal, "auth service not initialized")
+	}
+
+	// call the LogoutAllDevices method of authService
+	if err := h.authService.LogoutAllDevices(ctx, req); err != nil {
+		log.Printf("%s failed to logout all devices due to error: %v", op, err)
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	res := &authpb.LogoutAllDevicesResponse{
+		Message: "Logged out from all devices for user " + req.GetId(),
+	}
+
+	return res, nil
+}
+
+func (h *AuthHandler) ListSessions(ctx context.Context, req *authpb.ListSessionsRequest) (*authpb.ListSessionsResponse, error) {
+	op := "authHandler.ListSessions"
+	log.Printf("recieve list sessions request from client: %s", req.GetId())
+
+	if h.authService == nil {
+		return nil, status.Error(codes.Internal, "auth service not initialized")
+	}
+
+	// call the ListSessions method of authService
+	res, err := h.authService.ListSessions(ctx, req)
+	if err != nil {
+		log.Printf("%s failed to list sessions due to error: %v", op, err)
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return res, nil
+}
+
+func (h *AuthHandler) RevokeSession(ctx context.Context, req *authpb.RevokeSessionRequest) (*authpb.RevokeSessionResponse, error) {
+	op := "authHandler.RevokeSession"
+	log.Printf("recieve revoke session request from client: %s", req.GetId())
+
+	if h.authService == nil {
+		return nil, status.Error(codes.Internal, "auth service not initialized")
+	}
+
+	// validate user input
+	if req.GetSessionId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "session id is required")
+	}
+
+	// call the RevokeSession method of authService
+	if err := h.authService.RevokeSession(ctx, req); err != nil {
+		log.Printf("%s failed to revoke session due to error: %v", op, err)
+		return nil, status.Error(codes.NotFound, "session not found")
+	}
+
+	res := &authpb.RevokeSessionResponse{
+		Message: "Session revoked successfully: " + req.GetSessionId(),
+	}
+
+	return res, nil
+}
+
+func (h *AuthHandler) RequestPasswordReset(ctx context.Context, req *authpb.RequestPasswordResetRequest) (*authpb.RequestPasswordResetResponse, error) {
+	op := "authHandler.RequestPasswordReset"
+	log.Printf("%s Received password reset request", op)
+
+	if h.authService == nil {
+		return nil, status.Error(codes.Internal, "auth service not initialized")
+	}
+
+	// validate email format
+	if !utils.IsValidEmail(req.GetEmail()) {
+		log.Printf("%s Invalid email format\n", op)
+		return nil, status.Error(codes.InvalidArgument, "Invalid input: email invalid")
+	}
+
+	// the response never tells whether the email belongs to an account
+	if err := h.authService.RequestPasswordReset(ctx, req); err != nil {
+		log.Printf("%s failed to request password reset due to error: %v", op, err)
+		return nil, status.Error(codes.Internal, "failed to request password reset")
+	}
+
+	res := &authpb.RequestPasswordResetResponse{
+		Message: "If the email is registered, a password reset link has been sent",
+	}
+
+	return res, nil
+}
+
+func (h *AuthHandler) ConfirmPasswordReset(ctx context.Context, req *authpb.ConfirmPasswordResetRequest) (*authpb.ConfirmPasswordResetResponse, error) {
+	op := "authHandler.ConfirmPasswordReset"
+	log.Printf("%s Received confirm password reset request", op)
+
+	if h.authService == nil {
+		return nil, status.Error(codes.Internal, "auth service not initialized")
+	}
+
+	// validate user input
+	if req.GetToken() == "" {
+		return nil, status.Error(codes.InvalidArgument, "reset token is required")
+	}
+
+	// validate password length
+	if !utils.IsValidPassword(req.GetNewPassword()) {
+		log.Printf("%s failed to reset password due invalid password", op)
+		return nil, status.Error(codes.InvalidArgument, "password must have at least 8 characters")
+	}
+
+	// call the ConfirmPasswordReset method of authService
+	if err := h.authService.ConfirmPasswordReset(ctx, req); err != nil {
+		log.Printf("%s failed to reset password due to error: %v", op, err)
+		return nil, status.Error(codes.InvalidArgument, "invalid or expired reset token")
+	}
+
+	res := &authpb.ConfirmPasswordResetResponse{
+		Message: "Password reset successfully",
+	}
+
+	return res, nil
+}
+
+func (h *AuthHandler) VerifyEmail(ctx context.Context, req *authpb.VerifyEmailRequest) (*authpb.VerifyEmailResponse, error) {
+	op := "authHandler.VerifyEmail"
+	log.Printf("%s Received verify email request", op)
+
+	if h.authService == nil {
+		return nil, status.Error(codes.Internal, "auth service not initialized")
+	}
+
+	// validate user input
+	if req.GetToken() == "" {
+		return nil, status.Error(codes.InvalidArgument, "verification token is required")
+	}
+
+	// call the VerifyEmail method of authService
+	if err := h.authService.VerifyEmail(ctx, req); err != nil {
+		log.Printf("%s failed to verify email due to error: %v", op, err)
+		return nil, status.Error(codes.InvalidArgument, "invalid or expired verification token")
+	}
+
+	res := &authpb.VerifyEmailResponse{
+		Message: "Email verified successfully",
+	}
+
+	return res, nil
+}
+
+func (h *AuthHandler) ResendVerificationEmail(ctx context.Context, req *authpb.ResendVerificationEmailRequest) (*authpb.ResendVerificationEmailResponse, error) {
+	op := "authHandler.ResendVerificationEmail"
+	log.Printf("%s Received resend verification email request", op)
+
+	if h.authService == nil {
+		return nil, status.Error(codes.Internal, "auth service not initialized")
+	}
+
+	// validate email format
+	if !utils.IsValidEmail(req.GetEmail()) {
+		log.Printf("%s Invalid email format\n", op)
+		return nil, status.Error(codes.InvalidArgument, "Invalid input: email invalid")
+	}
+
+	// the response never tells whether the email belongs to an account
+	if err := h.authService.ResendVerificationEmail(ctx, req); err != nil {
+		log.Printf("%s failed to resend verification email due to error: %v", op, err)
+		return nil, status.Error(codes.Internal, "failed to resend verification email")
+	}
+
+	res := &authpb.ResendVerificationEmailResponse{
+		Message: "If the email is registered and not verified yet, a verification link has been sent",
+	}
+
+	return res, nil
+}
+
+func (h *AuthHandler) ConfirmEmailChange(ctx context.Context, req *authpb.ConfirmEmailChangeRequest) (*authpb.ConfirmEmailChangeResponse, error) {
+	op := "authHandler.ConfirmEmailChange"
+	log.Printf("%s Received confirm email change request", op)
+
+	if h.authService == nil {
+		return nil, status.Error(codes.Internal, "auth service not initialized")
+	}
+
+	// validate user input
+	if req.GetToken() == "" {
+		return nil, status.Error(codes.InvalidArgument, "confirmation token is required")
+	}
+
+	// call the ConfirmEmailChange method of authService
+	if err := h.authService.ConfirmEmailChange(ctx, req); err != nil {
+		log.Printf("%s failed to confirm email change due to error: %v", op, err)
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	res := &authpb.ConfirmEmailChangeResponse{
+		Message: "Email changed successfully",
+	}
+
+	return res, nil
+}
+
+func (h *AuthHandler) CancelEmailChange(ctx context.Context, req *authpb.CancelEmailChangeRequest) (*authpb.CancelEmailChangeResponse, error) {
+	op := "authHandler.CancelEmailChange"
+	log.Printf("%s Received cancel email change request", op)
+
+	if h.authService == nil {
+		return nil, status.Error(codes.Internal, "auth service not initialized")
+	}
+
+	// validate user input
+	if req.GetToken() == "" {
+		return nil, status.Error(codes.InvalidArgument, "cancel token is required")
+	}
+
+	// call the CancelEmailChange method of authService
+	if err := h.authService.CancelEmailChange(ctx, req); err != nil {
+		log.Printf("%s failed to cancel email change due to error: %v", op, err)
+		return nil, status.Error(codes.InvalidArgument, "invalid or expired cancel token")
+	}
+
+	res := &authpb.CancelEmailChangeResponse{
+		Message: "Email change cancelled and all sessions logged out",
+	}
+
+	return res, nil
+}
+
+func (h *AuthHandler) BeginTOTPEnrollment(ctx context.Context, req *authpb.BeginTOTPEnrollmentRequest) (*authpb.BeginTOTPEnrollmentResponse, error) {
+	op := "authHandler.BeginTOTPEnrollment"
+	log.Printf("recieve begin totp enrollment request from client: %s", req.GetId())
+
+	if h.authService == nil {
+		return nil, status.Error(codes.Internal, "auth service not initialized")
+	}
+
+	// call the BeginTOTPEnrollment method of authService
+	res, err := h.authService.BeginTOTPEnrollment(ctx, req)
+	if err != nil {
+		log.Printf("%s failed to begin totp enrollment due to error: %v", op, err)
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	return res, nil
+}
+
+func (h *AuthHandler) ConfirmTOTPEnrollment(ctx context.Context, req *authpb.ConfirmTOTPEnrollmentRequest) (*authpb.ConfirmTOTPEnrollmentResponse, error) {
+	op := "authHandler.ConfirmTOTPEnrollment"
+	log.Printf("recieve confirm totp enrollment request from client: %s", req.GetId())
+
+	if h.authService == nil {
+		return nil, status.Error(codes.Internal, "auth service not initialized")
+	}
+
+	// validate user input
+	if req.GetCode() == "" {
+		return nil, status.Error(codes.InvalidArgument, "totp code is required")
+	}
+
+	// call the ConfirmTOTPEnrollment method of authService
+	res, err := h.authService.ConfirmTOTPEnrollment(ctx, req)
+	var throttled *service.ThrottledError
+	if errors.As(err, &throttled) {
+		log.Printf("%s Code check throttled for user: %v\n", op, req.GetId())
+		return nil, throttledStatus(throttled)
+	}
+	if err != nil {
+		log.Printf("%s failed to confirm totp enrollment due to error: %v", op, err)
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return res, nil
+}
+
+func (h *AuthHandler) DisableTOTP(ctx context.Context, req *authpb.DisableTOTPRequest) (*authpb.DisableTOTPResponse, error) {
+	op := "authHandler.DisableTOTP"
+	log.Printf("recieve disable totp request from client: %s", req.GetId())
+
+	if h.authService == nil {
+		return nil, status.Error(codes.Internal, "auth service not initialized")
+	}
+
+	// validate user input
+	if req.GetPassword() == "" || req.GetCode() == "" {
+		return nil, status.Error(codes.InvalidArgument, "password and code are required")
+	}
+
+	// call the DisableTOTP method of authService
+	err := h.authService.DisableTOTP(ctx, req)
+	var throttled *service.ThrottledError
+	if errors.As(err, &throttled) {
+		log.Printf("%s Credential check throttled for user: %v\n", op, req.GetId())
+		return nil, throttledStatus(throttled)
+	}
+	if err != nil {
+		log.Printf("%s failed to disable totp due to error: %v", op, err)
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	res := &authpb.DisableTOTPResponse{
+		Message: "TOTP disabled successfully",
+	}
+
+	return res, nil
+}
+
+func (h *AuthHandler) VerifyMFA(ctx context.Context, req *authpb.VerifyMFARequest) (*authpb.VerifyMFAResponse, error) {
+	op := "authHandler.VerifyMFA"
+	log.Printf("%s Received verify mfa request", op)
+
+	if h.authService == nil {
+		return nil, status.Error(codes.Internal, "auth service not initialized")
+	}
+
+	// validate user input
+	if req.GetMfaToken() == "" || req.GetCode() == "" {
+		return nil, status.Error(codes.InvalidArgument, "mfa token and code are required")
+	}
+
+	// call the VerifyMFA method of authService
+	res, err := h.authService.VerifyMFA(ctx, req)
+	var throttled *service.ThrottledError
+	if errors.As(err, &throttled) {
+		log.Printf("%s MFA throttled\n", op)
+		return nil, throttledStatus(throttled)
+	}
+	if errors.Is(err, service.ErrAccountSuspended) {
+		log.Printf("%s Login refused for suspended account\n", op)
+		return nil, status.Error(codes.PermissionDenied, "account suspended")
+	}
+	if err != nil {
+		log.Printf("%s failed to verify mfa due to error: %v", op, err)
+		return nil, status.Error(codes.Unauthenticated, "invalid mfa token or code")
+	}
+
+	return res, nil
+}
+
+func (h *AuthHandler) BeginPasskeyRegistration(ctx context.Context, req *authpb.BeginPasskeyRegistrationRequest) (*authpb.BeginPasskeyRegistrationResponse, error) {
+	op := "authHandler.BeginPasskeyRegistration"
+	log.Printf("recieve begin passkey registration request from client: %s", req.GetId())
+
+	if h.authService == nil {
+		return nil, status.Error(codes.Internal, "auth service not initialized")
+	}
+
+	// call the BeginPasskeyRegistration method of authService
+	res, err := h.authService.BeginPasskeyRegistration(ctx, req)
+	if err != nil {
+		log.Printf("%s failed to begin passkey registration due to error: %v", op, err)
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return res, nil
+}
+
+func (h *AuthHandler) FinishPasskeyRegistration(ctx context.Context, req *authpb.FinishPasskeyRegistrationRequest) (*authpb.FinishPasskeyRegistrationResponse, error) {
+	op := "authHandler.FinishPasskeyRegistration"
+	log.Printf("recieve finish passkey registration request from client: %s", req.GetId())
+
+	if h.authService == nil {
+		return nil, status.Error(codes.Internal, "auth service not initialized")
+	}
+
+	// validate user input
+	if req.GetCeremonyId() == "" || req.GetCredentialJson() == "" {
+		return nil, status.Error(codes.InvalidArgument, "ceremony id and credential are required")
+	}
+
+	if len(req.GetName()) > 100 {
+		return nil, status.Error(codes.InvalidArgument, "passkey name must be at most 100 characters")
+	}
+
+	// call the FinishPasskeyRegistration method of authService
+	res, err := h.authService.FinishPasskeyRegistration(ctx, req)
+	if err != nil {
+		log.Printf("%s failed to finish passkey registration due to error: %v", op, err)
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return res, nil
+}
+
+func (h *AuthHandler) BeginPasskeyLogin(ctx context.Context, req *authpb.BeginPasskeyLoginRequest) (*authpb.BeginPasskeyLoginResponse, error) {
+	op := "authHandler.BeginPasskeyLogin"
+	log.Printf("%s Received begin passkey login request", op)
+
+	if h.authService == nil {
+		return nil, status.Error(codes.Internal, "auth service not initialized")
+	}
+
+	// call the BeginPasskeyLogin method of authService
+	res, err := h.authService.BeginPasskeyLogin(ctx, req)
+	if err != nil {
+		log.Printf("%s failed to begin passkey login due to error: %v", op, err)
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return res, nil
+}
+
+func (h *AuthHandler) FinishPasskeyLogin(ctx context.Context, req *authpb.FinishPasskeyLoginRequest) (*authpb.FinishPasskeyLoginResponse, error) {
+	op := "authHandler.FinishPasskeyLogin"
+	log.Printf("%s Received finish passkey login request", op)
+
+	if h.authService == nil {
+		return nil, status.Error(codes.Internal, "auth service not initialized")
+	}
+
+	// validate user input
+	if req.GetCeremonyId() == "" || req.GetCredentialJson() == "" {
+		return nil, status.Error(codes.InvalidArgument, "ceremony id and credential are required")
+	}
+
+	// call the FinishPasskeyLogin method of authService
+	res, err := h.authService.FinishPasskeyLogin(ctx, req)
+	var throttled *service.ThrottledError
+	if errors.As(err, &throttled) {
+		log.Printf("%s Passkey login throttled\n", op)
+		return nil, throttledStatus(throttled)
+	}
+	if errors.Is(err, service.ErrEmailNotVerified) {
+		log.Printf("%s Login refused for unverified email\n", op)
+		return nil, status.Error(codes.FailedPrecondition, "email not verified")
+	}
+	if errors.Is(err, service.ErrAccountSuspended) {
+		log.Printf("%s Login refused for suspended account\n", op)
+		return nil, status.Error(codes.PermissionDenied, "account suspended")
+	}
+	if err != nil {
+		log.Printf("%s failed to finish passkey login due to error: %v", op, err)
+		return nil, status.Error(codes.Unauthenticated, "Login failed")
+	}
+
+	return res, nil
+}
+
+func (h *AuthHandler) ListPasskeys(ctx context.Context, req *authpb.ListPasskeysRequest) (*authpb.ListPasskeysResponse, error) {
+	op := "authHandler.ListPasskeys"
+	log.Printf("recieve list passkeys request from client: %s", req.GetId())
+
+	if h.authService == nil {
+		return nil, status.Error(codes.Internal, "auth service not initialized")
+	}
+
+	// call the ListPasskeys method of authService
+	res, err := h.authService.ListPasskeys(ctx, req)
+	if err != nil {
+		log.Printf("%s failed to list passkeys due to error: %v", op, err)
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return res, nil
+}
+
+func (h *AuthHandler) DeletePasskey(ctx context.Context, req *authpb.DeletePasskeyRequest) (*authpb.DeletePasskeyResponse, error) {
+	op := "authHandler.DeletePasskey"
+	log.Printf("recieve delete passkey request from client: %s", req.GetId())
+
+	if h.authService == nil {
+		return nil, status.Error(codes.Internal, "auth service not initialized")
+	}
+
+	// validate user input
+	if req.GetPasskeyId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "passkey id is required")
+	}
+
+	// call the DeletePasskey method of authService
+	if err := h.authService.DeletePasskey(ctx, req); err != nil {
+		log.Printf("%s failed to delete passkey due to error: %v", op, err)
+		return nil, status.Error(codes.NotFound, "passkey not found")
+	}
+
+	res := &authpb.DeletePasskeyResponse{
+		Message: "Passkey deleted successfully: " + req.GetPasskeyId(),
+	}
+
+	return res, nil
+}
+
+func (h *AuthHandler) UnlockAccount(ctx context.Context, req *authpb.UnlockAccountRequest) (*authpb.UnlockAccountResponse, error) {
+	op := "authHandler.UnlockAccount"
+	log.Printf("recieve unlock account request for user: %s", req.GetUserId())
+
+	if h.authService == nil {
+		return nil, status.Error(codes.Internal, "auth service not initialized")
+	}
+
+	// validate user input
+	if req.GetUserId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "user id is required")
+	}
+
+	// call the UnlockAccount method of authService
+	if err := h.authService.UnlockAccount(ctx, req); err != nil {
+		log.Printf("%s failed to unlock account due to error: %v", op, err)
+		return nil, adminStatus(err, codes.NotFound)
+	}
+
+	res := &authpb.UnlockAccountResponse{
+		Message: "Account unlocked successfully: " + req.GetUserId(),
+	}
+
+	return res, nil
+}
+
+func (h *AuthHandler) CreateRole(ctx context.Context, req *authpb.CreateRoleRequest) (*authpb.CreateRoleResponse, error) {
+	op := "authHandler.CreateRole"
+	log.Printf("recieve create role request: %s", req.GetName())
+
+	if h.authService == nil {
+		return nil, status.Error(codes.Internal, "auth service not initialized")
+	}
+
+	// validate user input
+	if req.GetName() == "" {
+		return nil, status.Error(codes.InvalidArgument, "role name is required")
+	}
+
+	// call the CreateRole method of authService
+	res, err := h.authService.CreateRole(ctx, req)
+	if err != nil {
+		log.Printf("%s failed to create role due to error: %v", op, err)
+		return nil, adminStatus(err, codes.InvalidArgument)
+	}
+
+	return res, nil
+}
+
+func (h *AuthHandler) DeleteRole(ctx context.Context, req *authpb.DeleteRoleRequest) (*authpb.DeleteRoleResponse, error) {
+	op := "authHandler.DeleteRole"
+	log.Printf("recieve delete role request: %s", req.GetRoleId())
+
+	if h.authService == nil {
+		return nil, status.Error(codes.Internal, "auth service not initialized")
+	}
+
+	// validate user input
+	if req.GetRoleId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "role id is required")
+	}
+
+	// call the DeleteRole method of authService
+	if err := h.authService.DeleteRole(ctx, req); err != nil {
+		log.Printf("%s failed to delete role due to error: %v", op, err)
+		return nil, adminStatus(err, codes.NotFound)
+	}
+
+	res := &authpb.DeleteRoleResponse{
+		Message: "Role deleted successfully: " + req.GetRoleId(),
+	}
+
+	return res, nil
+}
+
+func (h *AuthHandler) ListRoles(ctx context.Context, req *authpb.ListRolesRequest) (*authpb.ListRolesResponse, error) {
+	op := "authHandler.ListRoles"
+	log.Printf("%s Received list roles request", op)
+
+	if h.authService == nil {
+		return nil, status.Error(codes.Internal, "auth service not initialized")
+	}
+
+	// call the ListRoles method of authService
+	res, err := h.authService.ListRoles(ctx, req)
+	if err != nil {
+		log.Printf("%s failed to list roles due to error: %v", op, err)
+		return nil, adminStatus(err, codes.Internal)
+	}
+
+	return res, nil
+}
+
+func (h *AuthHandler) GrantPermissionToRole(ctx context.Context, req *authpb.GrantPermissionToRoleRequest) (*authpb.GrantPermissionToRoleResponse, error) {
+	op := "authHandler.GrantPermissionToRole"
+	log.Printf("recieve grant permission request: %s to role %s", req.GetPermission(), req.GetRoleId())
+
+	if h.authService == nil {
+		return nil, status.Error(codes.Internal, "auth service not initialized")
+	}
+
+	// validate user input
+	if req.GetRoleId() == "" || req.GetPermission() == "" {
+		return nil, status.Error(codes.InvalidArgument, "role id and permission are required")
+	}
+
+	// call the GrantPermissionToRole method of authService
+	if err := h.authService.GrantPermissionToRole(ctx, req); err != nil {
+		log.Printf("%s failed to grant permission due to error: %v", op, err)
+		return nil, adminStatus(err, codes.NotFound)
+	}
+
+	res := &authpb.GrantPermissionToRoleResponse{
+		Message: "Permission granted successfully: " + req.GetPermission(),
+	}
+
+	return res, nil
+}
+
+func (h *AuthHandler) RevokePermissionFromRole(ctx context.Context, req *authpb.RevokePermissionFromRoleRequest) (*authpb.RevokePermissionFromRoleResponse, error) {
+	op := "authHandler.RevokePermissionFromRole"
+	log.Printf("recieve revoke permission request: %s from role %s", req.GetPermission(), req.GetRoleId())
+
+	if h.authService == nil {
+		return nil, status.Error(codes.Internal, "auth service not initialized")
+	}
+
+	// validate user input
+	if req.GetRoleId() == "" || req.GetPermission() == "" {
+		return nil, status.Error(codes.InvalidArgument, "role id and permission are required")
+	}
+
+	// call the RevokePermissionFromRole method of authService
+	if err := h.authService.RevokePermissionFromRole(ctx, req); err != nil {
+		log.Printf("%s failed to revoke permission due to error: %v", op, err)
+		return nil, adminStatus(err, codes.NotFound)
+	}
+
+	res := &authpb.RevokePermissionFromRoleResponse{
+		Message: "Permission revoked successfully: " + req.GetPermission(),
+	}
+
+	return res, nil
+}
+
+func (h *AuthHandler) AssignRoleToUser(ctx context.Context, req *authpb.AssignRoleToUserRequest) (*authpb.AssignRoleToUserResponse, error) {
+	op := "authHandler.AssignRoleToUser"
+	log.Printf("recieve assign role request: %s to user %s", req.GetRoleId(), req.GetUserId())
+
+	if h.authService == nil {
+		return nil, status.Error(codes.Internal, "auth service not initialized")
+	}
+
+	// validate user input
+	if req.GetUserId() == "" || req.GetRoleId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "user id and role id are required")
+	}
+
+	// call the AssignRoleToUser method of authService
+	if err := h.authService.AssignRoleToUser(ctx, req); err != nil {
+		log.Printf("%s failed to assign role due to error: %v", op, err)
+		return nil, adminStatus(err, codes.NotFound)
+	}
+
+	res := &authpb.AssignRoleToUserResponse{
+		Message: "Role assigned successfully: " + req.GetRoleId(),
+	}
+
+	return res, nil
+}
+
+func (h *AuthHandler) RemoveRoleFromUser(ctx context.Context, req *authpb.RemoveRoleFromUserRequest) (*authpb.RemoveRoleFromUserResponse, error) {
+	op := "authHandler.RemoveRoleFromUser"
+	log.Printf("recieve remove role request: %s from user %s", req.GetRoleId(), req.GetUserId())
+
+	if h.authService == nil {
+		return nil, status.Error(codes.Internal, "auth service not initialized")
+	}
+
+	// validate user input
+	if req.GetUserId() == "" || req.GetRoleId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "user id and role id are required")
+	}
+
+	// call the RemoveRoleFromUser method of authService
+	if err := h.authService.RemoveRoleFromUser(ctx, req); err != nil {
+		log.Printf("%s failed to remove role due to error: %v", op, err)
+		return nil, adminStatus(err, codes.NotFound)
+	}
+
+	res := &authpb.RemoveRoleFromUserResponse{
+		Message: "Role removed successfully: " + req.GetRoleId(),
+	}
+
+	return res, nil
+}
+
+func (h *AuthHandler) ListUserRoles(ctx context.Context, req *authpb.ListUserRolesRequest) (*authpb.ListUserRolesResponse, error) {
+	op := "authHandler.ListUserRoles"
+	log.Printf("recieve list user roles request for user: %s", req.GetUserId())
+
+	if h.authService == nil {
+		return nil, status.Error(codes.Internal, "auth service not initialized")
+	}
+
+	// validate user input
+	if req.GetUserId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "user id is required")
+	}
+
+	// call the ListUserRoles method of authService
+	res, err := h.authService.ListUserRoles(ctx, req)
+	if err != nil {
+		log.Printf("%s failed to list user roles due to error: %v", op, err)
+		return nil, adminStatus(err, codes.Internal)
+	}
+
+	return res, nil
+}
+
+func (h *AuthHandler) GetJWKS(ctx context.Context, req *authpb.GetJWKSRequest) (*authpb.GetJWKSResponse, error) {
+	op := "authHandler.GetJWKS"
+	log.Printf("%s Received get JWKS request", op)
+
+	if h.authService == nil {
+		return nil, status.Error(codes.Internal, "auth service not initialized")
+	}
+
+	// call the GetJWKS method of authService
+	res, err := h.authService.GetJWKS(ctx, req)
+	if err != nil {
+		log.Printf("%s failed to get JWKS due to error: %v", op, err)
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return res, nil
+}
+
+func (h *AuthHandler) RotateSigningKey(ctx context.Context, req *authpb.RotateSigningKeyRequest) (*authpb.RotateSigningKeyResponse, error) {
+	op := "authHandler.RotateSigningKey"
+	log.Printf("%s Received rotate signing key request", op)
+
+	if h.authService == nil {
+		return nil, status.Error(codes.Internal, "auth service not initialized")
+	}
+
+	// call the RotateSigningKey method of authService
+	res, err := h.authService.RotateSigningKey(ctx, req)
+	if err != nil {
+		log.Printf("%s failed to rotate signing key due to error: %v", op, err)
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	return res, nil
+}
+
+func (h *AuthHandler) IntrospectToken(ctx context.Context, req *authpb.IntrospectTokenRequest) (*authpb.IntrospectTokenResponse, error) {
+	op := "authHandler.IntrospectToken"
+	log.Printf("%s Received introspect token request from %v", op, ctx.Value(contextKey.ServiceClientContextKey))
+
+	if h.authService == nil {
+		return nil, status.Error(codes.Internal, "auth service not initialized")
+	}
+
+	// validate user input
+	if req.GetToken() == "" {
+		return nil, status.Error(codes.InvalidArgument, "token is required")
+	}
+
+	// call the IntrospectToken method of authService
+	res, err := h.authService.IntrospectToken(ctx, req)
+	if err != nil {
+		log.Printf("%s failed to introspect token due to error: %v", op, err)
+		return nil, status.Error(codes.Internal, "failed to introspect token")
+	}
+
+	return res, nil
+}
+
+func (h *AuthHandler) ListAuditEvents(ctx context.Context, req *authpb.ListAuditEventsRequest) (*authpb.ListAuditEventsResponse, error) {
+	op := "authHandler.ListAuditEvents"
+	log.Printf("%s Received list audit events request from %v", op, ctx.Value(contextKey.UserIDContextKey))
+
+	if h.authService == nil {
+		return nil, status.Error(codes.Internal, "auth service not initialized")
+	}
+
+	// validate user input
+	if req.GetUserId() != "" {
+		if _, err := uuid.Parse(req.GetUserId()); err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid user id")
+		}
+	}
+	switch req.GetOutcome() {
+	case "", models.AuditOutcomeSuccess, models.AuditOutcomeFailure:
+	default:
+		return nil, status.Error(codes.InvalidArgument, "outcome must be success or failure")
+	}
+	if req.GetSince() != nil && req.GetUntil() != nil && !req.GetSince().AsTime().Before(req.GetUntil().AsTime()) {
+		return nil, status.Error(codes.InvalidArgument, "since must be before until")
+	}
+	if req.GetPageSize() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "page size must not be negative")
+	}
+
+	// call the ListAuditEvents method of authService
+	res, err := h.authService.ListAuditEvents(ctx, req)
+	if err != nil {
+		log.Printf("%s failed to list audit events due to error: %v", op, err)
+		return nil, adminStatus(err, codes.Internal)
+	}
+
+	return res, nil
+}
+
+func (h *AuthHandler) ListMySecurityEvents(ctx context.Context, req *authpb.ListMySecurityEventsRequest) (*authpb.ListMySecurityEventsResponse, error) {
+	op := "authHandler.ListMySecurityEvents"
+	log.Printf("recieve list security events request from client: %s", req.GetId())
+
+	if h.authService == nil {
+		return nil, status.Error(codes.Internal, "auth service not initialized")
+	}
+
+	// validate user input
+	if req.GetPageSize() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "page size must not be negative")
+	}
+
+	// call the ListMySecurityEvents method of authService
+	res, err := h.authService.ListMySecurityEvents(ctx, req)
+	if err != nil {
+		log.Printf("%s failed to list security events due to error: %v", op, err)
+		return nil, adminStatus(err, codes.Internal)
+	}
+
+	return res, nil
+}
+
+func (h *AuthHandler) SuspendUser(ctx context.Context, req *authpb.SuspendUserRequest) (*authpb.SuspendUserResponse, error) {
+	op := "authHandler.SuspendUser"
+	log.Printf("recieve suspend user request for user: %s", req.GetUserId())
+
+	if h.authService == nil {
+		return nil, status.Error(codes.Internal, "auth service not initialized")
+	}
+
+	// validate user input
+	if _, err := uuid.Parse(req.GetUserId()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid user id")
+	}
+	if strings.TrimSpace(req.GetReason()) == "" {
+		return nil, status.Error(codes.InvalidArgument, "reason is required")
+	}
+	if req.GetExpiresAt() != nil && !req.GetExpiresAt().AsTime().After(time.Now()) {
+		return nil, status.Error(codes.InvalidArgument, "expires at must be in the future")
+	}
+
+	// call the SuspendUser method of authService
+	res, err := h.authService.SuspendUser(ctx, req)
+	if err != nil {
+		log.Printf("%s failed to suspend user due to error: %v", op, err)
+		return nil, adminStatus(err, codes.Internal)
+	}
+
+	return res, nil
+}
+
+func (h *AuthHandler) LiftSuspension(ctx context.Context, req *authpb.LiftSuspensionRequest) (*authpb.LiftSuspensionResponse, error) {
+	op := "authHandler.LiftSuspension"
+	log.Printf("recieve lift suspension request for user: %s", req.GetUserId())
+
+	if h.authService == nil {
+		return nil, status.Error(codes.Internal, "auth service not initialized")
+	}
+
+	// validate user input
+	if _, err := uuid.Parse(req.GetUserId()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid user id")
+	}
+
+	// call the LiftSuspension method of authService
+	res, err := h.authService.LiftSuspension(ctx, req)
+	if err != nil {
+		log.Printf("%s failed to lift suspension due to error: %v", op, err)
+		return nil, adminStatus(err, codes.Internal)
+	}
+
+	return res, nil
+}
+
+func (h *AuthHandler) ListUserSuspensions(ctx context.Context, req *authpb.ListUserSuspensionsRequest) (*authpb.ListUserSuspensionsResponse, error) {
+	op := "authHandler.ListUserSuspensions"
+	log.Printf("recieve list suspensions request for user: %s", req.GetUserId())
+
+	if h.authService == nil {
+		return nil, status.Error(codes.Internal, "auth service not initialized")
+	}
+
+	// validate user input
+	if _, err := uuid.Parse(req.GetUserId()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid user id")
+	}
+
+	// call the ListUserSuspensions method of authService
+	res, err := h.authService.ListUserSuspensions(ctx, req)
+	if err != nil {
+		log.Printf("%s failed to list suspensions due to error: %v", op, err)
+		return nil, adminStatus(err, codes.Internal)
+	}
+
+	return res, nil
+}
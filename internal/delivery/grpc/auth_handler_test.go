@@ -0,0 +1,125 @@
+package grpc_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	handler "github.com/Nucleussss/hikayat-forum/auth/internal/delivery/grpc"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/repository"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/service"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	authpb "github.com/Nucleussss/hikayat-proto/gen/go/auth/v1"
+)
+
+// fakeAuthService fails every call it implements with err.
+type fakeAuthService struct {
+	service.AuthService
+	err error
+}
+
+func (s *fakeAuthService) Login(ctx context.Context, req *authpb.LoginRequest) (*authpb.LoginResponse, error) {
+	return nil, s.err
+}
+
+func (s *fakeAuthService) RefreshToken(ctx context.Context, req *authpb.RefreshTokenRequest) (*authpb.RefreshTokenResponse, error) {
+	return nil, s.err
+}
+
+func (s *fakeAuthService) VerifyMFA(ctx context.Context, req *authpb.VerifyMFARequest) (*authpb.VerifyMFAResponse, error) {
+	return nil, s.err
+}
+
+func (s *fakeAuthService) FinishPasskeyLogin(ctx context.Context, req *authpb.FinishPasskeyLoginRequest) (*authpb.FinishPasskeyLoginResponse, error) {
+	return nil, s.err
+}
+
+func (s *fakeAuthService) ChangeUserEmail(ctx context.Context, req *authpb.ChangeUserEmailRequest) error {
+	return s.err
+}
+
+func (s *fakeAuthService) SuspendUser(ctx context.Context, req *authpb.SuspendUserRequest) (*authpb.SuspendUserResponse, error) {
+	return nil, s.err
+}
+
+func TestSuspendedUsersArePermissionDenied(t *testing.T) {
+	ctx := context.Background()
+	h := handler.NewAuthHandler(&fakeAuthService{err: service.ErrAccountSuspended})
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{name: "login", call: func() error {
+			_, err := h.Login(ctx, &authpb.LoginRequest{Email: "spammer@example.com", Password: "correct horse"})
+			return err
+		}},
+		{name: "refresh", call: func() error {
+			_, err := h.RefreshToken(ctx, &authpb.RefreshTokenRequest{RefreshToken: "token"})
+			return err
+		}},
+		{name: "mfa", call: func() error {
+			_, err := h.VerifyMFA(ctx, &authpb.VerifyMFARequest{MfaToken: "token", Code: "123456"})
+			return err
+		}},
+		{name: "passkey", call: func() error {
+			_, err := h.FinishPasskeyLogin(ctx, &authpb.FinishPasskeyLoginRequest{CeremonyId: "ceremony", CredentialJson: "{}"})
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, codes.PermissionDenied, status.Code(tt.call()))
+		})
+	}
+}
+
+func TestSuspendUserErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want codes.Code
+	}{
+		{name: "unknown user", err: repository.ErrUserNotFound, want: codes.NotFound},
+		{name: "self suspension", err: service.ErrSelfSuspension, want: codes.FailedPrecondition},
+		{name: "anything else", err: errors.New("database down"), want: codes.Internal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := handler.NewAuthHandler(&fakeAuthService{err: tt.err})
+
+			_, err := h.SuspendUser(context.Background(), &authpb.SuspendUserRequest{UserId: uuid.NewString(), Reason: "spam"})
+			require.Equal(t, tt.want, status.Code(err))
+		})
+	}
+}
+
+func TestChangeUserEmailErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want codes.Code
+	}{
+		{name: "wrong password", err: service.ErrIncorrectPassword, want: codes.PermissionDenied},
+		{name: "address taken", err: service.ErrEmailTaken, want: codes.AlreadyExists},
+		{name: "throttled", err: &service.ThrottledError{RetryAfter: time.Minute}, want: codes.ResourceExhausted},
+		{name: "anything else", err: errors.New("pq: connection refused"), want: codes.Internal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := handler.NewAuthHandler(&fakeAuthService{err: tt.err})
+
+			_, err := h.ChangeUserEmail(context.Background(), &authpb.ChangeUserEmailRequest{Id: uuid.NewString(), Email: "new@example.com", CurrentPassword: "correct horse"})
+			require.Equal(t, tt.want, status.Code(err))
+			require.NotContains(t, status.Convert(err).Message(), "pq:")
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package grpc
+
+import (
+	"errors"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/repository"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/service"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// throttledStatus maps a ThrottledError to ResourceExhausted, telling the client through a
+// RetryInfo detail when it may try again.
+func throttledStatus(err *service.ThrottledError) error {
+	st := status.New(codes.ResourceExhausted, err.Error())
+
+	detailed, detailErr := st.WithDetails(&errdetails.RetryInfo{
+		RetryDelay: durationpb.New(err.RetryAfter),
+	})
+	if detailErr != nil {
+		return st.Err()
+	}
+
+	return detailed.Err()
+}
+
+// adminStatus maps the errors of the admin RPCs, falling back to the given code for everything else.
+func adminStatus(err error, fallback codes.Code) error {
+	switch {
+	case errors.Is(err, repository.ErrRoleAlreadyExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, service.ErrInvalidPageToken):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, repository.ErrRoleNotFound), errors.Is(err, repository.ErrUserNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, repository.ErrNotSuspended), errors.Is(err, service.ErrSelfSuspension), errors.Is(err, service.ErrProtectedRole):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	default:
+		return status.Error(fallback, err.Error())
+	}
+}
@@ -1,23 +1,33 @@
 package grpc
 
 import (
-	"os"
+	"net/netip"
 
+	"github.com/Nucleussss/hikayat-forum/auth/internal/jwtkeys"
 	"github.com/Nucleussss/hikayat-forum/auth/internal/middleware"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/ratelimit"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/service"
+	"github.com/Nucleussss/hikayat-forum/auth/pkg/config"
 	"google.golang.org/grpc"
 
 	"google.golang.org/grpc/reflection"
 )
 
-func NewServer() *grpc.Server {
+func NewServer(tokenKeys jwtkeys.Set, jwtConfig config.JWTConfig, revocations service.RevocationStore, permissions middleware.PermissionChecker, serviceClients map[string]string, trustedProxies []netip.Prefix, rateLimits []config.RateLimit, rateLimitStore ratelimit.Store) *grpc.Server {
 	// Create gRPC server options slice (if needed)
 	var opts []grpc.ServerOption
 
 	interceptor := []grpc.UnaryServerInterceptor{
 		// Add interceptors/middleware here
 
+		// Resolves the client address first, honoring forwarded headers from trusted proxies only.
+		middleware.ClientInfoInterceptor(trustedProxies),
+
 		// This middleware was not activate bacause hikayat-gateway was already handle it.
-		middleware.AuthInterceptor(os.Getenv("JWT_SECRET_KEY")),
+		middleware.AuthInterceptor(tokenKeys, jwtConfig, revocations, config.MethodPolicies, permissions, serviceClients),
+
+		// Runs after authentication so that per user limits see the user ID.
+		middleware.RateLimitInterceptor(rateLimits, rateLimitStore),
 	}
 
 	// Append interceptors to options slice
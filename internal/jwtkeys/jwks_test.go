@@ -0,0 +1,75 @@
+package jwtkeys_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/jwtkeys"
+	"github.com/stretchr/testify/require"
+)
+
+func writePEM(t *testing.T, blockType string, der []byte) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "key.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600))
+
+	return path
+}
+
+func TestPublicJWKS(t *testing.T) {
+	_, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	edDER, err := x509.MarshalPKCS8PrivateKey(edPrivate)
+	require.NoError(t, err)
+
+	rsaPrivate, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rsaDER, err := x509.MarshalPKIXPublicKey(&rsaPrivate.PublicKey)
+	require.NoError(t, err)
+
+	signing, err := jwtkeys.LoadKeyFile(writePEM(t, "PRIVATE KEY", edDER))
+	require.NoError(t, err)
+	require.True(t, signing.CanSign())
+	require.Equal(t, jwtkeys.AlgorithmEdDSA, signing.Algorithm)
+
+	verifyOnly, err := jwtkeys.LoadKeyFile(writePEM(t, "PUBLIC KEY", rsaDER))
+	require.NoError(t, err)
+	require.False(t, verifyOnly.CanSign())
+	require.Equal(t, jwtkeys.AlgorithmRS256, verifyOnly.Algorithm)
+
+	secret, err := jwtkeys.NewHMACKey(jwtkeys.LegacyHMACKeyID, []byte("test-secret"))
+	require.NoError(t, err)
+
+	set, err := jwtkeys.NewStaticSet(signing, verifyOnly, secret)
+	require.NoError(t, err)
+
+	// a public key cannot sign
+	_, err = jwtkeys.NewStaticSet(verifyOnly)
+	require.Error(t, err)
+
+	rec := httptest.NewRecorder()
+	jwtkeys.Handler(set).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var jwks jwtkeys.JWKS
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &jwks))
+
+	// the shared secret is never published
+	require.Len(t, jwks.Keys, 2)
+	require.Equal(t, signing.ID, jwks.Keys[0].KeyID)
+	require.Equal(t, "OKP", jwks.Keys[0].KeyType)
+	require.Equal(t, "Ed25519", jwks.Keys[0].Curve)
+	require.Equal(t, verifyOnly.ID, jwks.Keys[1].KeyID)
+	require.Equal(t, "RSA", jwks.Keys[1].KeyType)
+	require.Equal(t, "AQAB", jwks.Keys[1].E)
+}
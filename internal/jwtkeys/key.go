@@ -0,0 +1,230 @@
+package jwtkeys
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Algorithms of the keys tokens are signed with, as written to the alg header.
+const (
+	AlgorithmEdDSA = "EdDSA"
+	AlgorithmRS256 = "RS256"
+	AlgorithmHS256 = "HS256"
+)
+
+// minRSABits is the smallest RSA modulus accepted for signing keys.
+const minRSABits = 2048
+
+// Key is a single JWT key. A key loaded from a private key or a secret can sign, a key loaded from
+// a public key can only verify.
+type Key struct {
+	ID        string
+	Algorithm string
+
+	signKey   any
+	verifyKey any
+}
+
+// NewHMACKey wraps a shared secret as an HS256 key. HS256 keys are never published in the JWKS.
+func NewHMACKey(id string, secret []byte) (*Key, error) {
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("empty HS256 secret")
+	}
+
+	return &Key{ID: id, Algorithm: AlgorithmHS256, signKey: secret, verifyKey: secret}, nil
+}
+
+// NewKey wraps an Ed25519 or RSA key. Private keys can sign and verify, public keys only verify.
+// The key ID is the RFC 7638 thumbprint of the public key.
+func NewKey(key any) (*Key, error) {
+	k := &Key{}
+
+	switch key := key.(type) {
+	case ed25519.PrivateKey:
+		k.Algorithm, k.signKey, k.verifyKey = AlgorithmEdDSA, key, key.Public()
+	case ed25519.PublicKey:
+		k.Algorithm, k.verifyKey = AlgorithmEdDSA, key
+	case *rsa.PrivateKey:
+		if key.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("RSA key of %d bits is too short", key.N.BitLen())
+		}
+		k.Algorithm, k.signKey, k.verifyKey = AlgorithmRS256, key, &key.PublicKey
+	case *rsa.PublicKey:
+		k.Algorithm, k.verifyKey = AlgorithmRS256, key
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+
+	id, err := thumbprint(k.verifyKey)
+	if err != nil {
+		return nil, err
+	}
+	k.ID = id
+
+	return k, nil
+}
+
+// LoadKeyFile reads a PEM encoded key. PKCS#8 and PKCS#1 private keys and PKIX public keys are
+// understood.
+func LoadKeyFile(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block in %s", path)
+	}
+
+	var key any
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q in %s", block.Type, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse key in %s: %w", path, err)
+	}
+
+	return NewKey(key)
+}
+
+// GenerateKey creates a new signing key for the algorithm. HS256 keys get a random ID, the others
+// the thumbprint of their public key.
+func GenerateKey(algorithm string) (*Key, error) {
+	switch algorithm {
+	case AlgorithmEdDSA:
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return NewKey(private)
+	case AlgorithmRS256:
+		private, err := rsa.GenerateKey(rand.Reader, minRSABits)
+		if err != nil {
+			return nil, err
+		}
+		return NewKey(private)
+	case AlgorithmHS256:
+		secret := make([]byte, 32)
+		id := make([]byte, 16)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		if _, err := rand.Read(id); err != nil {
+			return nil, err
+		}
+		return NewHMACKey(base64.RawURLEncoding.EncodeToString(id), secret)
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", algorithm)
+	}
+}
+
+// MarshalPrivateKey encodes the private material of a signing key: PKCS#8 DER for Ed25519 and
+// RSA keys, the raw secret for HS256 keys.
+func MarshalPrivateKey(k *Key) ([]byte, error) {
+	if !k.CanSign() {
+		return nil, fmt.Errorf("key %s has no private key", k.ID)
+	}
+
+	if secret, ok := k.signKey.([]byte); ok {
+		return secret, nil
+	}
+
+	return x509.MarshalPKCS8PrivateKey(k.signKey)
+}
+
+// ParsePrivateKey decodes material from MarshalPrivateKey and checks it belongs to the given key ID.
+func ParsePrivateKey(id, algorithm string, data []byte) (*Key, error) {
+	if algorithm == AlgorithmHS256 {
+		return NewHMACKey(id, data)
+	}
+
+	private, err := x509.ParsePKCS8PrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse key %s: %w", id, err)
+	}
+
+	key, err := NewKey(private)
+	if err != nil {
+		return nil, err
+	}
+	if key.ID != id || key.Algorithm != algorithm {
+		return nil, fmt.Errorf("key %s does not match its %s material", id, algorithm)
+	}
+
+	return key, nil
+}
+
+// CanSign reports whether the key holds private material.
+func (k *Key) CanSign() bool {
+	return k.signKey != nil
+}
+
+// SigningMethod returns the JWT signing method of the key's algorithm.
+func (k *Key) SigningMethod() jwt.SigningMethod {
+	return jwt.GetSigningMethod(k.Algorithm)
+}
+
+// SignKey returns the key material tokens are signed with, nil for verify-only keys.
+func (k *Key) SignKey() any {
+	return k.signKey
+}
+
+// VerifyKey returns the key material signatures are checked with.
+func (k *Key) VerifyKey() any {
+	return k.verifyKey
+}
+
+// Public reports whether the key can be published, which excludes shared secrets.
+func (k *Key) Public() bool {
+	return k.Algorithm != AlgorithmHS256
+}
+
+// thumbprint computes the RFC 7638 JWK thumbprint of a public key.
+func thumbprint(key crypto.PublicKey) (string, error) {
+	var members any
+
+	// the required members in lexicographic order, as the RFC demands
+	switch key := key.(type) {
+	case ed25519.PublicKey:
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{"Ed25519", "OKP", base64.RawURLEncoding.EncodeToString(key)}
+	case *rsa.PublicKey:
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{encodeRSAExponent(key.E), "RSA", base64.RawURLEncoding.EncodeToString(key.N.Bytes())}
+	default:
+		return "", fmt.Errorf("unsupported public key type %T", key)
+	}
+
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
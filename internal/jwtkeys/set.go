@@ -0,0 +1,107 @@
+package jwtkeys
+
+import "fmt"
+
+// LegacyHMACKeyID is the kid of the HS256 key derived from the JWT secret. Tokens issued before kid
+// headers were introduced carry no kid and are validated with this key.
+const LegacyHMACKeyID = "hs256"
+
+// Set holds the keys tokens are signed and validated with.
+type Set interface {
+	// SigningKey returns the key new tokens are signed with.
+	SigningKey() (*Key, error)
+	// VerificationKey returns the key a token with the given kid header is validated with.
+	VerificationKey(kid string) (*Key, error)
+	// VerificationKeys returns every key tokens are currently validated with.
+	VerificationKeys() []*Key
+}
+
+// StaticSet is a Set with one signing key and a fixed list of verify-only keys.
+type StaticSet struct {
+	signing *Key
+	keys    []*Key
+}
+
+// NewStaticSet creates a set signing with the given key. The verify-only keys keep tokens of
+// earlier keys valid.
+func NewStaticSet(signing *Key, verifyOnly ...*Key) (*StaticSet, error) {
+	if signing == nil || !signing.CanSign() {
+		return nil, fmt.Errorf("signing key has no private key")
+	}
+
+	set := &StaticSet{signing: signing, keys: []*Key{signing}}
+	for _, key := range verifyOnly {
+		if key.ID == signing.ID {
+			continue
+		}
+		set.keys = append(set.keys, key)
+	}
+
+	return set, nil
+}
+
+// LoadSet builds a key set from files. signingKeyFile names the PEM encoded Ed25519 or RSA
+// private key new tokens are signed with, verifyKeyFiles further PEM keys that are only used for
+// validation. Without a signing key file the set falls back to HS256 with secret; with one, the
+// secret only keeps older HS256 tokens valid.
+func LoadSet(signingKeyFile string, verifyKeyFiles []string, secret string) (*StaticSet, error) {
+	var verifyOnly []*Key
+	for _, path := range verifyKeyFiles {
+		key, err := LoadKeyFile(path)
+		if err != nil {
+			return nil, err
+		}
+		verifyOnly = append(verifyOnly, key)
+	}
+
+	var legacy *Key
+	if secret != "" {
+		key, err := NewHMACKey(LegacyHMACKeyID, []byte(secret))
+		if err != nil {
+			return nil, err
+		}
+		legacy = key
+	}
+
+	if signingKeyFile == "" {
+		if legacy == nil {
+			return nil, fmt.Errorf("neither a signing key file nor a secret is configured")
+		}
+		return NewStaticSet(legacy, verifyOnly...)
+	}
+
+	signing, err := LoadKeyFile(signingKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if legacy != nil {
+		verifyOnly = append(verifyOnly, legacy)
+	}
+
+	return NewStaticSet(signing, verifyOnly...)
+}
+
+// SigningKey returns the key new tokens are signed with.
+func (s *StaticSet) SigningKey() (*Key, error) {
+	return s.signing, nil
+}
+
+// VerificationKey returns the key with the given ID. An empty kid matches the legacy HS256 key.
+func (s *StaticSet) VerificationKey(kid string) (*Key, error) {
+	if kid == "" {
+		kid = LegacyHMACKeyID
+	}
+
+	for _, key := range s.keys {
+		if key.ID == kid {
+			return key, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
+// VerificationKeys returns the signing key followed by the verify-only keys.
+func (s *StaticSet) VerificationKeys() []*Key {
+	return s.keys
+}
@@ -0,0 +1,42 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/service"
+)
+
+type fileMailer struct {
+	dir  string
+	from string
+}
+
+// NewFileMailer returns a Mailer that writes every message as an .eml file into dir,
+// which is handy during development to open the mails in any mail client.
+func NewFileMailer(dir, from string) (service.Mailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create mail drop directory: %w", err)
+	}
+
+	return &fileMailer{dir: dir, from: from}, nil
+}
+
+func (m *fileMailer) Send(ctx context.Context, msg *service.MailMessage) error {
+	op := "fileMailer.Send"
+
+	body, err := buildMessage(m.from, msg)
+	if err != nil {
+		return fmt.Errorf("%s failed to build message: %w", op, err)
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000000000"), randomID())
+	if err := os.WriteFile(filepath.Join(m.dir, name), body, 0o644); err != nil {
+		return fmt.Errorf("%s failed to write message: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,20 @@
+package mailer
+
+import (
+	"context"
+	"log"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/service"
+)
+
+type logMailer struct{}
+
+// NewLogMailer returns a Mailer that only writes messages to the service log.
+func NewLogMailer() service.Mailer {
+	return &logMailer{}
+}
+
+func (m *logMailer) Send(ctx context.Context, msg *service.MailMessage) error {
+	log.Printf("logMailer.Send to: %s subject: %q\n%s", msg.To, msg.Subject, msg.TextBody)
+	return nil
+}
@@ -0,0 +1,121 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/service"
+)
+
+type smtpMailer struct {
+	addr string
+	host string
+	auth smtp.Auth
+	from string
+}
+
+// NewSMTPMailer returns a Mailer that delivers through a plain SMTP server. STARTTLS is used
+// whenever the server offers it, and authentication is skipped when username is empty.
+func NewSMTPMailer(host, port, username, password, from string) service.Mailer {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &smtpMailer{
+		addr: net.JoinHostPort(host, port),
+		host: host,
+		auth: auth,
+		from: from,
+	}
+}
+
+func (m *smtpMailer) Send(ctx context.Context, msg *service.MailMessage) error {
+	op := "smtpMailer.Send"
+
+	body, err := buildMessage(m.from, msg)
+	if err != nil {
+		return fmt.Errorf("%s failed to build message: %w", op, err)
+	}
+
+	// the envelope sender and recipient are the bare addresses
+	from, err := mail.ParseAddress(m.from)
+	if err != nil {
+		return fmt.Errorf("%s invalid sender address: %w", op, err)
+	}
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("%s invalid recipient address: %w", op, err)
+	}
+
+	if err := m.deliver(ctx, from.Address, to.Address, body); err != nil {
+		return fmt.Errorf("%s failed to send mail: %w", op, err)
+	}
+
+	return nil
+}
+
+// deliver runs one SMTP session like smtp.SendMail does, but on a connection bound to ctx: the
+// context's deadline applies to every read and write and cancelling it closes the connection, so
+// a stalled server cannot hold the delivery forever.
+func (m *smtpMailer) deliver(ctx context.Context, from, to string, body []byte) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", m.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return err
+		}
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	client, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return err
+		}
+	}
+
+	if m.auth != nil {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return fmt.Errorf("smtp server does not support AUTH")
+		}
+		if err := client.Auth(m.auth); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
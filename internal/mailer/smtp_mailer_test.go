@@ -0,0 +1,153 @@
+package mailer_test
+
+import (
+	"context"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/mailer"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/service"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSMTPMessage is what the fake server received for one delivery.
+type fakeSMTPMessage struct {
+	from string
+	to   []string
+	data string
+}
+
+// startFakeSMTPServer accepts a single SMTP session and reports the delivered message.
+func startFakeSMTPServer(t *testing.T) (string, <-chan fakeSMTPMessage) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { lis.Close() })
+
+	received := make(chan fakeSMTPMessage, 1)
+
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		tp := textproto.NewConn(conn)
+		var msg fakeSMTPMessage
+
+		tp.PrintfLine("220 localhost fake smtp")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+
+			verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+			switch verb {
+			case "EHLO", "HELO":
+				tp.PrintfLine("250 localhost")
+			case "MAIL":
+				msg.from = line
+				tp.PrintfLine("250 OK")
+			case "RCPT":
+				msg.to = append(msg.to, line)
+				tp.PrintfLine("250 OK")
+			case "DATA":
+				tp.PrintfLine("354 send data")
+				data, err := tp.ReadDotLines()
+				if err != nil {
+					return
+				}
+				msg.data = strings.Join(data, "\n")
+				tp.PrintfLine("250 OK")
+			case "QUIT":
+				tp.PrintfLine("221 bye")
+				received <- msg
+				return
+			default:
+				tp.PrintfLine("250 OK")
+			}
+		}
+	}()
+
+	return lis.Addr().String(), received
+}
+
+func TestSMTPMailerSend(t *testing.T) {
+	addr, received := startFakeSMTPServer(t)
+	host, port, err := net.SplitHostPort(addr)
+	require.NoError(t, err)
+
+	templates, err := service.NewMailTemplates(service.DefaultMailTemplatesFS(), "id")
+	require.NoError(t, err)
+
+	msg, err := templates.Render("password_reset", "en", "user@example.com", map[string]any{
+		"Name":             "Budi",
+		"Link":             "https://hikayat.test/reset?token=abc",
+		"ExpiresInMinutes": 60,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "Reset your Hikayat password", msg.Subject)
+
+	sender, err := mailer.New(mailer.Config{
+		Transport: mailer.TransportSMTP,
+		From:      "Hikayat <no-reply@hikayat.test>",
+		SMTPHost:  host,
+		SMTPPort:  port,
+	})
+	require.NoError(t, err)
+	require.NoError(t, sender.Send(context.Background(), msg))
+
+	got := <-received
+	require.Equal(t, "MAIL FROM:<no-reply@hikayat.test>", strings.SplitN(got.from, " BODY", 2)[0])
+	require.Equal(t, []string{"RCPT TO:<user@example.com>"}, got.to)
+	require.Contains(t, got.data, "Subject: Reset your Hikayat password")
+	require.Contains(t, got.data, "multipart/alternative")
+	require.Contains(t, got.data, "Hi Budi,")
+}
+
+func TestSMTPMailerSendGivesUpOnStalledServer(t *testing.T) {
+	// the server accepts the connection but never sends its greeting
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { lis.Close() })
+
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			return
+		}
+		t.Cleanup(func() { conn.Close() })
+	}()
+
+	host, port, err := net.SplitHostPort(lis.Addr().String())
+	require.NoError(t, err)
+
+	sender, err := mailer.New(mailer.Config{
+		Transport: mailer.TransportSMTP,
+		From:      "Hikayat <no-reply@hikayat.test>",
+		SMTPHost:  host,
+		SMTPPort:  port,
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err = sender.Send(ctx, &service.MailMessage{To: "user@example.com", Subject: "Hi", TextBody: "Hi"})
+	require.Error(t, err)
+	require.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestMailTemplatesFallBackToDefaultLocale(t *testing.T) {
+	templates, err := service.NewMailTemplates(service.DefaultMailTemplatesFS(), "id")
+	require.NoError(t, err)
+
+	msg, err := templates.Render("password_reset", "fr", "user@example.com", map[string]any{"Name": "Budi"})
+	require.NoError(t, err)
+	require.Equal(t, "Atur ulang kata sandi Hikayat kamu", msg.Subject)
+}
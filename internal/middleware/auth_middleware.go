@@ -3,10 +3,12 @@ package middleware
 import (
 	"context"
 	"log"
-	"os"
 	"strings"
 
 	contextKey "github.com/Nucleussss/hikayat-forum/auth/internal/context"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/jwtkeys"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/service"
+	"github.com/Nucleussss/hikayat-forum/auth/pkg/config"
 	"github.com/Nucleussss/hikayat-forum/auth/pkg/utils"
 
 	"google.golang.org/grpc"
@@ -16,22 +18,37 @@ import (
 )
 
 // AuthInterceptor is a gRPC unary server interceptor that provides authentication for incoming requests.
-// It checks if a method is publicly accessible, and if not, it extracts and validates the JWT token
-// from the authorization header. If the token is valid, it extracts the user ID and adds it to the request context
-// before proceeding with the original gRPC handler. If authentication fails at any step,
-// it returns an appropriate unauthorized or invalid argument status error.
-func AuthInterceptor(jwtSecret string) grpc.UnaryServerInterceptor {
+// It looks up the method in the policy registry, lets public methods through and authenticates service-only
+// methods with the Basic client credentials of a registered backend service. For every other method it
+// extracts and validates the JWT token from the authorization header, rejects tokens of suspended users and
+// tokens found in the revocation store and enforces the method policy (self only, a permission, or either)
+// against the user. If the caller is allowed, it adds the user, session and token IDs to the request context
+// before proceeding with the original gRPC handler. If a step fails, it returns an appropriate unauthenticated
+// or permission denied status error.
+func AuthInterceptor(tokenKeys jwtkeys.Set, jwtConfig config.JWTConfig, revocations service.RevocationStore, policies map[string]config.MethodPolicy, permissions PermissionChecker, serviceClients map[string]string) grpc.UnaryServerInterceptor {
 	op := "server.AuthInterceptor"
 	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
 
-		// Define a map of publicly accessible methods that do not require authentication.
-		publicMethod := map[string]bool{
-			// Publicly accessible methods that do not require authentication
-			"/hikayat.forum.v1.AuthService/Register": true,
-			"/hikayat.forum.v1.AuthService/Login":    true,
+		// Look up the authorization rule of the method. Methods without a rule are refused.
+		policy, ok := policies[info.FullMethod]
+		if !ok {
+			log.Printf("%s: no policy for method %s", op, info.FullMethod)
+			return nil, status.Errorf(codes.PermissionDenied, "method is not allowed")
 		}
-		// If the current method is in the publicMethod map, proceed without authentication.
-		if publicMethod[info.FullMethod] {
+		// If the method is public, proceed without authentication.
+		if policy.Access == config.AccessPublic {
+			return handler(ctx, req)
+		}
+
+		// Service-only methods are called by backend services with client credentials, not by users.
+		if policy.Access == config.AccessServiceClient {
+			clientID, err := authenticateServiceClient(ctx, serviceClients)
+			if err != nil {
+				log.Printf("%s: service client not authenticated for %s: %v", op, info.FullMethod, err)
+				return nil, err
+			}
+
+			ctx = context.WithValue(ctx, contextKey.ServiceClientContextKey, clientID)
 			return handler(ctx, req)
 		}
 
@@ -52,13 +69,19 @@ func AuthInterceptor(jwtSecret string) grpc.UnaryServerInterceptor {
 
 		// Extract the JWT token by removing the "Bearer " prefix.
 		token := strings.TrimPrefix(authHeader[0], "Bearer ")
-		// Validate the JWT token using the provided secret key.
-		mapClaims, err := utils.ValidateJWTToken(token, os.Getenv("JWT_SECRET"))
+		// Validate the JWT token against the key set.
+		mapClaims, err := utils.ValidateJWTToken(token, tokenKeys, jwtConfig)
 		if err != nil {
 			log.Printf("%s: %v", op, err)
 			return nil, status.Errorf(codes.Unauthenticated, "invalid token")
 		}
 
+		// Only access tokens authenticate requests, other signed tokens (e.g. email verification) do not.
+		if tokenUse, _ := (*mapClaims)["token_use"].(string); tokenUse != utils.TokenUseAccess {
+			log.Printf("%s: token_use %q is not an access token", op, tokenUse)
+			return nil, status.Errorf(codes.Unauthenticated, "invalid token")
+		}
+
 		// Extract the "user_id" from the token claims.
 		userID, ok := (*mapClaims)["user_id"].(string)
 		// Check if the user_id is present and of the correct type.
@@ -67,8 +90,59 @@ func AuthInterceptor(jwtSecret string) grpc.UnaryServerInterceptor {
 			return nil, status.Errorf(codes.InvalidArgument, "missing user_id in token claims")
 		}
 
-		// Set the extracted user ID into the context for downstream handlers to access.
+		// Extract the token and session identifiers used for revocation.
+		tokenID, _ := (*mapClaims)["jti"].(string)
+		sessionID, _ := (*mapClaims)["sid"].(string)
+		issuedAt, err := mapClaims.GetIssuedAt()
+		if err != nil || issuedAt == nil {
+			log.Printf("%s: missing iat in token claims: %v", op, err)
+			return nil, status.Errorf(codes.Unauthenticated, "invalid token")
+		}
+		expiresAt, err := mapClaims.GetExpirationTime()
+		if err != nil || expiresAt == nil {
+			log.Printf("%s: missing exp in token claims: %v", op, err)
+			return nil, status.Errorf(codes.Unauthenticated, "invalid token")
+		}
+
+		// Reject tokens of suspended users, telling them why.
+		check := service.RevocationCheck{
+			TokenID:   tokenID,
+			SessionID: sessionID,
+			UserID:    userID,
+			IssuedAt:  issuedAt.Time,
+		}
+		suspended, err := revocations.IsSuspended(ctx, check)
+		if err != nil {
+			log.Printf("%s: %v", op, err)
+			return nil, status.Errorf(codes.Internal, "failed to check token revocation")
+		}
+		if suspended {
+			log.Printf("%s: token of suspended user %s presented", op, userID)
+			return nil, status.Errorf(codes.PermissionDenied, "account suspended")
+		}
+
+		// Reject tokens that were revoked directly, through their session or through their user.
+		revoked, err := revocations.IsRevoked(ctx, check)
+		if err != nil {
+			log.Printf("%s: %v", op, err)
+			return nil, status.Errorf(codes.Internal, "failed to check token revocation")
+		}
+		if revoked {
+			log.Printf("%s: revoked token presented for user %s", op, userID)
+			return nil, status.Errorf(codes.Unauthenticated, "token revoked")
+		}
+
+		// Enforce the method policy for the authenticated user.
+		if err := authorize(ctx, policy, req, userID, permissions); err != nil {
+			log.Printf("%s: user %s not authorized for %s: %v", op, userID, info.FullMethod, err)
+			return nil, err
+		}
+
+		// Set the extracted identifiers into the context for downstream handlers to access.
 		ctx = context.WithValue(ctx, contextKey.UserIDContextKey, userID)
+		ctx = context.WithValue(ctx, contextKey.SessionIDContextKey, sessionID)
+		ctx = context.WithValue(ctx, contextKey.TokenIDContextKey, tokenID)
+		ctx = context.WithValue(ctx, contextKey.TokenExpiresAtContextKey, expiresAt.Time)
 		// Proceed with the original handler with the updated context.
 		return handler(ctx, req)
 	}
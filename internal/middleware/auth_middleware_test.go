@@ -0,0 +1,47 @@
+package middleware_test
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	contextKey "github.com/Nucleussss/hikayat-forum/auth/internal/context"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/middleware"
+	"github.com/Nucleussss/hikayat-forum/auth/pkg/config"
+	"github.com/Nucleussss/hikayat-forum/auth/pkg/utils"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestAuthInterceptorServiceClients(t *testing.T) {
+	interceptor := middleware.AuthInterceptor(nil, config.Default().JWT, nil, config.MethodPolicies, nil, map[string]string{
+		"posts": utils.HashToken("posts-secret"),
+	})
+
+	call := func(method, authorization string) (any, error) {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", authorization))
+		return interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req any) (any, error) {
+			return ctx.Value(contextKey.ServiceClientContextKey), nil
+		})
+	}
+	basic := func(clientID, secret string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(clientID+":"+secret))
+	}
+
+	client, err := call("/hikayat.forum.v1.AuthService/IntrospectToken", basic("posts", "posts-secret"))
+	require.NoError(t, err)
+	require.Equal(t, "posts", client)
+
+	_, err = call("/hikayat.forum.v1.AuthService/IntrospectToken", basic("posts", "wrong"))
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = call("/hikayat.forum.v1.AuthService/IntrospectToken", "Bearer user-token")
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// methods without a policy are refused before any credential is looked at
+	_, err = call("/hikayat.forum.v1.AuthService/Unknown", basic("posts", "posts-secret"))
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+}
@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Nucleussss/hikayat-forum/auth/pkg/config"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	authpb "github.com/Nucleussss/hikayat-proto/gen/go/auth/v1"
+)
+
+// fakePermissions grants the listed permissions to every user, or fails when err is set.
+type fakePermissions struct {
+	granted []string
+	err     error
+}
+
+func (p *fakePermissions) UserHasPermission(ctx context.Context, userID uuid.UUID, permissionName string) (bool, error) {
+	if p.err != nil {
+		return false, p.err
+	}
+	for _, granted := range p.granted {
+		if granted == permissionName {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// userAndID is a request with both a user_id and an id field, like a request about a suspension.
+type userAndID struct {
+	userID, id string
+}
+
+func (r userAndID) GetUserId() string { return r.userID }
+func (r userAndID) GetId() string     { return r.id }
+
+func TestTargetUserID(t *testing.T) {
+	tests := []struct {
+		name string
+		req  any
+		want string
+	}{
+		{name: "user_id", req: &authpb.ListUserRolesRequest{UserId: "user"}, want: "user"},
+		{name: "id", req: &authpb.GetUserRequest{Id: "user"}, want: "user"},
+		{name: "user_id wins over id", req: userAndID{userID: "user", id: "suspension"}, want: "user"},
+		{name: "empty user_id does not fall back to id", req: userAndID{id: "suspension"}, want: ""},
+		{name: "no target", req: &authpb.ListRolesRequest{}, want: ""},
+		{name: "nil request", req: nil, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, targetUserID(tt.req))
+		})
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	caller := uuid.NewString()
+	other := uuid.NewString()
+
+	self := config.MethodPolicy{Access: config.AccessSelf}
+	permission := config.MethodPolicy{Access: config.AccessPermission, Permission: "users.read"}
+	selfOrPermission := config.MethodPolicy{Access: config.AccessSelfOrPermission, Permission: "users.read"}
+
+	tests := []struct {
+		name        string
+		policy      config.MethodPolicy
+		req         any
+		callerID    string
+		permissions *fakePermissions
+		want        codes.Code
+	}{
+		{name: "authenticated", policy: config.MethodPolicy{Access: config.AccessAuthenticated}, req: &authpb.ListRolesRequest{}, callerID: caller, want: codes.OK},
+
+		{name: "self on own account", policy: self, req: &authpb.GetUserRequest{Id: caller}, callerID: caller, want: codes.OK},
+		{name: "self on another account", policy: self, req: &authpb.GetUserRequest{Id: other}, callerID: caller, want: codes.PermissionDenied},
+		{name: "self without a target", policy: self, req: &authpb.ListRolesRequest{}, callerID: caller, want: codes.PermissionDenied},
+		{name: "self checks user_id before id", policy: self, req: userAndID{userID: other, id: caller}, callerID: caller, want: codes.PermissionDenied},
+		{name: "self ignores permissions", policy: self, req: &authpb.GetUserRequest{Id: other}, callerID: caller, permissions: &fakePermissions{granted: []string{"users.read"}}, want: codes.PermissionDenied},
+
+		{name: "permission held", policy: permission, req: &authpb.ListRolesRequest{}, callerID: caller, permissions: &fakePermissions{granted: []string{"users.read"}}, want: codes.OK},
+		{name: "permission missing", policy: permission, req: &authpb.ListRolesRequest{}, callerID: caller, permissions: &fakePermissions{granted: []string{"roles.manage"}}, want: codes.PermissionDenied},
+		{name: "permission lookup fails", policy: permission, req: &authpb.ListRolesRequest{}, callerID: caller, permissions: &fakePermissions{err: errors.New("database down")}, want: codes.Internal},
+		{name: "permission with a malformed caller", policy: permission, req: &authpb.ListRolesRequest{}, callerID: "not-a-uuid", permissions: &fakePermissions{}, want: codes.Unauthenticated},
+
+		{name: "self or permission on own account", policy: selfOrPermission, req: &authpb.ListUserRolesRequest{UserId: caller}, callerID: caller, want: codes.OK},
+		{name: "self or permission held", policy: selfOrPermission, req: &authpb.ListUserRolesRequest{UserId: other}, callerID: caller, permissions: &fakePermissions{granted: []string{"users.read"}}, want: codes.OK},
+		{name: "self or permission missing", policy: selfOrPermission, req: &authpb.ListUserRolesRequest{UserId: other}, callerID: caller, permissions: &fakePermissions{}, want: codes.PermissionDenied},
+		{name: "self or permission with both ids", policy: selfOrPermission, req: userAndID{userID: other, id: caller}, callerID: caller, permissions: &fakePermissions{}, want: codes.PermissionDenied},
+
+		// service client methods are authenticated with client credentials, never with a user token
+		{name: "service client", policy: config.MethodPolicy{Access: config.AccessServiceClient}, req: &authpb.ListRolesRequest{}, callerID: caller, permissions: &fakePermissions{granted: []string{"users.read"}}, want: codes.PermissionDenied},
+		{name: "public", policy: config.MethodPolicy{Access: config.AccessPublic}, req: &authpb.ListRolesRequest{}, callerID: caller, want: codes.PermissionDenied},
+		{name: "unknown access", policy: config.MethodPolicy{Access: "everyone"}, req: &authpb.ListRolesRequest{}, callerID: caller, want: codes.PermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var permissions PermissionChecker
+			if tt.permissions != nil {
+				permissions = tt.permissions
+			}
+
+			err := authorize(context.Background(), tt.policy, tt.req, tt.callerID, permissions)
+			require.Equal(t, tt.want, status.Code(err))
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"context"
+	"net/netip"
+
+	contextKey "github.com/Nucleussss/hikayat-forum/auth/internal/context"
+	"github.com/Nucleussss/hikayat-forum/auth/pkg/utils"
+
+	"google.golang.org/grpc"
+)
+
+// ClientInfoInterceptor is a gRPC unary server interceptor that resolves the user agent and IP
+// address of the calling client once and stores them in the context. Forwarded headers are only
+// honored on connections from the trusted proxies. It runs first, so the throttles, rate limits
+// and audit records all see the same client.
+func ClientInfoInterceptor(trustedProxies []netip.Prefix) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		ctx = context.WithValue(ctx, contextKey.ClientInfoContextKey, utils.ResolveClientInfo(ctx, trustedProxies))
+		return handler(ctx, req)
+	}
+}
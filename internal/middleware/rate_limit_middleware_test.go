@@ -0,0 +1,38 @@
+package middleware_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/middleware"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/ratelimit"
+	"github.com/Nucleussss/hikayat-forum/auth/pkg/config"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	authpb "github.com/Nucleussss/hikayat-proto/gen/go/auth/v1"
+)
+
+func TestRateLimitInterceptorCountsPerChallenge(t *testing.T) {
+	method := "/hikayat.forum.v1.AuthService/VerifyMFA"
+	interceptor := middleware.RateLimitInterceptor([]config.RateLimit{
+		{Method: method, Key: config.RateLimitByChallenge, Requests: 1, Per: time.Hour, Burst: 2},
+	}, ratelimit.NewMemoryStore())
+
+	call := func(mfaToken string) error {
+		_, err := interceptor(context.Background(), &authpb.VerifyMFARequest{MfaToken: mfaToken, Code: "123456"}, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req any) (any, error) {
+			return nil, nil
+		})
+		return err
+	}
+
+	// every source IP shares the budget of one challenge
+	require.NoError(t, call("challenge-1"))
+	require.NoError(t, call("challenge-1"))
+	require.Equal(t, codes.ResourceExhausted, status.Code(call("challenge-1")))
+
+	require.NoError(t, call("challenge-2"))
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Outcomes of an audited action.
+const (
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeFailure = "failure"
+)
+
+// Actions written to the audit log.
+const (
+	AuditActionRegister           = "register"
+	AuditActionLogin              = "login"
+	AuditActionLoginLocked        = "login_locked"
+	AuditActionAccountUnlocked    = "account_unlocked"
+	AuditActionPasswordChange     = "password_change"
+	AuditActionPasswordReset      = "password_reset"
+	AuditActionEmailChangeRequest = "email_change_requested"
+	AuditActionEmailChangeConfirm = "email_change_confirmed"
+	AuditActionEmailChangeCancel  = "email_change_cancelled"
+	AuditActionUserDeleted        = "user_deleted"
+	AuditActionRoleCreated        = "role_created"
+	AuditActionRoleDeleted        = "role_deleted"
+	AuditActionRoleAssigned       = "role_assigned"
+	AuditActionRoleRemoved        = "role_removed"
+	AuditActionPermissionGranted  = "permission_granted"
+	AuditActionPermissionRevoked  = "permission_revoked"
+	AuditActionLogout             = "logout"
+	AuditActionSessionRevoked     = "session_revoked"
+	AuditActionAllSessionsRevoked = "all_sessions_revoked"
+	AuditActionTokenReuse         = "refresh_token_reuse"
+	AuditActionSigningKeyRotated  = "signing_key_rotated"
+	AuditActionUserSuspended      = "user_suspended"
+	AuditActionSuspensionLifted   = "suspension_lifted"
+)
+
+// AuditEvent is one entry of the audit log. ActorID is the authenticated caller, TargetUserID the
+// account the action concerned; either is nil when there is none, e.g. for a failed login of an
+// unknown address.
+type AuditEvent struct {
+	ID           uuid.UUID
+	Action       string
+	Outcome      string
+	ActorID      *uuid.UUID
+	TargetUserID *uuid.UUID
+	IPAddress    string
+	UserAgent    string
+	Metadata     map[string]any
+	CreatedAt    time.Time
+
+	// Seq is the position of the event in the hash chain, Hash covers the event and PrevHash, the
+	// Hash of the event before it. Rows written before the chain was introduced have no Seq.
+	Seq      int64
+	PrevHash string
+	Hash     string
+}
+
+// AuditEventFilter selects audit events. Zero fields match every event.
+type AuditEventFilter struct {
+	// UserID matches events the user performed or was the target of.
+	UserID *uuid.UUID
+	// TargetUserID matches only events about the user.
+	TargetUserID *uuid.UUID
+	Actions      []string
+	Outcome      string
+	// Since is inclusive, Until exclusive.
+	Since time.Time
+	Until time.Time
+}
+
+// AuditCursor is the position of the last event of a page. Events are ordered by CreatedAt and
+// then ID, newest first, so the next page starts with the events strictly older than the cursor.
+type AuditCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// AuditCheckpoint is a signed statement that the hash chain had reached Seq with RowHash at
+// SignedAt. Kept outside the database, checkpoints reveal rewritten or truncated chains.
+type AuditCheckpoint struct {
+	Seq       int64
+	RowHash   string
+	SignedAt  time.Time
+	KeyID     string
+	Algorithm string
+	Signature string
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EmailChange is a pending change of a user's email address. The confirmation token goes to the
+// new address and the cancel token to the old one; only their hashes are stored.
+type EmailChange struct {
+	ID               uuid.UUID
+	UserID           uuid.UUID
+	NewEmail         string
+	ConfirmTokenHash string
+	CancelTokenHash  string
+	ExpiresAt        time.Time
+	ConfirmedAt      *time.Time
+	CancelledAt      *time.Time
+	CreatedAt        time.Time
+}
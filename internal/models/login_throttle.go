@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Subjects failed logins are counted against.
+const (
+	LoginThrottleAccount = "account"
+	LoginThrottleIP      = "ip"
+)
+
+// LoginThrottle counts the failed logins of an account or a source IP within the current window.
+// LockedUntil is set once the failures reached the lockout threshold.
+type LoginThrottle struct {
+	SubjectType     string
+	SubjectKey      string
+	Failures        int
+	WindowStartedAt time.Time
+	LastFailureAt   time.Time
+	LockedUntil     *time.Time
+}
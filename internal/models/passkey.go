@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Passkey is a WebAuthn credential registered by a user. SignCount is the last signature
+// counter reported by the authenticator and Flags keeps its raw authenticator data flags.
+type Passkey struct {
+	ID              uuid.UUID
+	UserID          uuid.UUID
+	CredentialID    []byte
+	PublicKey       []byte
+	SignCount       uint32
+	AAGUID          []byte
+	Transports      []string
+	Flags           uint8
+	AttestationType string
+	Name            string
+	LastUsedAt      *time.Time
+	CreatedAt       time.Time
+}
+
+const (
+	WebAuthnCeremonyRegistration = "registration"
+	WebAuthnCeremonyLogin        = "login"
+)
+
+// WebAuthnCeremony keeps the relying party state between the begin and finish step of a
+// registration or login ceremony. UserID is nil for passkey logins, where the user is only
+// known once the authenticator answered.
+type WebAuthnCeremony struct {
+	ID          uuid.UUID
+	UserID      *uuid.UUID
+	Kind        string
+	SessionData []byte
+	ExpiresAt   time.Time
+	CreatedAt   time.Time
+}
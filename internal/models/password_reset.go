@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PasswordReset is an outstanding password reset. Only the hash of the token sent to the user is stored.
+type PasswordReset struct {
+	TokenHash  string
+	UserID     uuid.UUID
+	ExpiredAt  time.Time
+	UpdateTime time.Time
+}
@@ -0,0 +1,31 @@
+package models
+
+import "github.com/google/uuid"
+
+// The roles seeded by the migrations. They cannot be deleted, the service and its clients rely on them.
+const (
+	RoleAdmin     = "admin"
+	RoleModerator = "moderator"
+)
+
+// Role is a named set of permissions that can be assigned to users.
+type Role struct {
+	ID          uuid.UUID
+	Name        string
+	Permissions []string
+}
+
+// Permission is a single capability, such as "posts.moderate", granted through roles.
+type Permission struct {
+	ID          uuid.UUID
+	Name        string
+	Description string
+}
+
+// UserAuthorization is what a user may do, as embedded in their access tokens. Version is bumped
+// whenever the user's roles or the permissions of those roles change.
+type UserAuthorization struct {
+	Roles       []string
+	Permissions []string
+	Version     int64
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session is a single refresh token generation. Every rotation inserts a new
+// row that shares the FamilyID of the login it descends from. AuthzVersion is the
+// authorization version of the user when the tokens of the generation were issued.
+type Session struct {
+	ID           uuid.UUID
+	UserID       uuid.UUID
+	FamilyID     uuid.UUID
+	TokenHash    string
+	UserAgent    string
+	IPAddress    string
+	AuthzVersion int64
+	ExpiresAt    time.Time
+	CreatedAt    time.Time
+	LastSeenAt   time.Time
+	RotatedAt    *time.Time
+	RevokedAt    *time.Time
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// SigningKey is a token signing key as stored in the database. SealedKey is the private key
+// encrypted with the key encryption key, so database access alone does not leak it. The key
+// signs new tokens from ActivatesAt and validates them until RetiresAt.
+type SigningKey struct {
+	ID          string
+	Algorithm   string
+	SealedKey   []byte
+	ActivatesAt time.Time
+	RetiresAt   *time.Time
+	CreatedAt   time.Time
+}
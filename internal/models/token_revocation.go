@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Subject types of a token revocation.
+const (
+	// RevocationSubjectToken revokes a single access token by its jti.
+	RevocationSubjectToken = "token"
+	// RevocationSubjectSession revokes every access token bound to a session family.
+	RevocationSubjectSession = "session"
+	// RevocationSubjectUser revokes every access token of a user issued before RevokedAt.
+	RevocationSubjectUser = "user"
+	// RevocationSubjectSuspension revokes every access token of a suspended user issued before
+	// RevokedAt, and is reported to the caller as a suspension.
+	RevocationSubjectSuspension = "suspension"
+)
+
+type TokenRevocation struct {
+	SubjectType string
+	SubjectID   string
+	RevokedAt   time.Time
+	ExpiresAt   time.Time
+}
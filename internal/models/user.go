@@ -12,6 +12,9 @@ type User struct {
 	Email        string
 	PasswordHash string
 	IsActive     bool
-	CreatedAt    time.Time
-	UpdatedAt    time.Time
+	Locale       string
+
+	EmailVerifiedAt *time.Time
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
 }
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserSuspension is one suspension of a user. It is in force until it expires or is lifted, and
+// kept afterwards as the user's suspension history. A nil ExpiresAt suspends until lifted.
+type UserSuspension struct {
+	ID          uuid.UUID
+	UserID      uuid.UUID
+	Reason      string
+	SuspendedBy *uuid.UUID
+	CreatedAt   time.Time
+	ExpiresAt   *time.Time
+	LiftedAt    *time.Time
+	LiftedBy    *uuid.UUID
+	LiftReason  string
+}
+
+// InForce reports whether the suspension still applies at now.
+func (s *UserSuspension) InForce(now time.Time) bool {
+	return s.LiftedAt == nil && (s.ExpiresAt == nil || now.Before(*s.ExpiresAt))
+}
@@ -0,0 +1,235 @@
+// Package passkey implements the WebAuthn relying party of the auth service. It runs the
+// registration and assertion ceremonies and translates between WebAuthn credentials and
+// models.Passkey; storing ceremonies and passkeys is left to the caller.
+package passkey
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+)
+
+// Config describes the relying party. RPID is the domain passkeys are bound to and
+// RPOrigins the web origins allowed to run the ceremonies.
+type Config struct {
+	RPID          string
+	RPDisplayName string
+	RPOrigins     []string
+	Timeout       time.Duration
+}
+
+// Account is a user together with the passkeys they registered so far.
+type Account struct {
+	UserID      uuid.UUID
+	Name        string
+	DisplayName string
+	Passkeys    []*models.Passkey
+}
+
+// AccountLookup loads the account a passkey login claims to belong to.
+type AccountLookup func(userID uuid.UUID) (*Account, error)
+
+// RelyingParty runs WebAuthn ceremonies. Begin methods return the options for the browser
+// and an opaque session that must be handed back, unchanged, to the matching Finish method.
+type RelyingParty struct {
+	webAuthn *webauthn.WebAuthn
+}
+
+func NewRelyingParty(cfg Config) (*RelyingParty, error) {
+	timeout := webauthn.TimeoutConfig{Enforce: true, Timeout: cfg.Timeout, TimeoutUVD: cfg.Timeout}
+
+	webAuthn, err := webauthn.New(&webauthn.Config{
+		RPID:                  cfg.RPID,
+		RPDisplayName:         cfg.RPDisplayName,
+		RPOrigins:             cfg.RPOrigins,
+		AttestationPreference: protocol.PreferNoAttestation,
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			ResidentKey:        protocol.ResidentKeyRequirementRequired,
+			RequireResidentKey: protocol.ResidentKeyRequired(),
+			UserVerification:   protocol.VerificationRequired,
+		},
+		Timeouts: webauthn.TimeoutsConfig{Login: timeout, Registration: timeout},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid webauthn config: %w", err)
+	}
+
+	return &RelyingParty{webAuthn: webAuthn}, nil
+}
+
+// BeginRegistration starts the registration of a new passkey for the account. Passkeys the
+// account already has are excluded, so an authenticator cannot be registered twice.
+func (rp *RelyingParty) BeginRegistration(account *Account) (options []byte, session []byte, err error) {
+	user := &webAuthnUser{account: account}
+
+	creation, sessionData, err := rp.webAuthn.BeginRegistration(user,
+		webauthn.WithExclusions(webauthn.Credentials(user.WebAuthnCredentials()).CredentialDescriptors()),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return marshalCeremony(creation, sessionData)
+}
+
+// FinishRegistration verifies the authenticator's attestation response and returns the new
+// passkey. The caller still has to name and store it.
+func (rp *RelyingParty) FinishRegistration(account *Account, session []byte, response []byte) (*models.Passkey, error) {
+	var sessionData webauthn.SessionData
+	if err := json.Unmarshal(session, &sessionData); err != nil {
+		return nil, fmt.Errorf("invalid webauthn session: %w", err)
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(response)
+	if err != nil {
+		return nil, fmt.Errorf("invalid registration response: %w", err)
+	}
+
+	credential, err := rp.webAuthn.CreateCredential(&webAuthnUser{account: account}, sessionData, parsed)
+	if err != nil {
+		return nil, fmt.Errorf("registration rejected: %w", err)
+	}
+
+	transports := make([]string, 0, len(credential.Transport))
+	for _, transport := range credential.Transport {
+		transports = append(transports, string(transport))
+	}
+
+	passkey := &models.Passkey{
+		UserID:          account.UserID,
+		CredentialID:    credential.ID,
+		PublicKey:       credential.PublicKey,
+		SignCount:       credential.Authenticator.SignCount,
+		AAGUID:          credential.Authenticator.AAGUID,
+		Transports:      transports,
+		Flags:           uint8(credential.Flags.ProtocolValue()),
+		AttestationType: credential.AttestationType,
+	}
+
+	return passkey, nil
+}
+
+// BeginLogin starts a passkey login. The user is not known yet: the authenticator picks one
+// of its discoverable credentials and reports the user handle in its response. User
+// verification is required, a passkey login stands in for both the password and the second
+// factor, so possessing the authenticator alone must not be enough.
+func (rp *RelyingParty) BeginLogin() (options []byte, session []byte, err error) {
+	assertion, sessionData, err := rp.webAuthn.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return marshalCeremony(assertion, sessionData)
+}
+
+// FinishLogin verifies the authenticator's assertion and returns the account it belongs to
+// together with the passkey used, carrying its new sign count and flags. A sign count that
+// did not increase hints at a cloned authenticator and fails the login.
+func (rp *RelyingParty) FinishLogin(session []byte, response []byte, lookup AccountLookup) (*Account, *models.Passkey, error) {
+	var sessionData webauthn.SessionData
+	if err := json.Unmarshal(session, &sessionData); err != nil {
+		return nil, nil, fmt.Errorf("invalid webauthn session: %w", err)
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(response)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid login response: %w", err)
+	}
+
+	var account *Account
+	handler := func(rawID, userHandle []byte) (webauthn.User, error) {
+		userID, err := uuid.FromBytes(userHandle)
+		if err != nil {
+			return nil, fmt.Errorf("invalid user handle")
+		}
+
+		account, err = lookup(userID)
+		if err != nil {
+			return nil, err
+		}
+
+		return &webAuthnUser{account: account}, nil
+	}
+
+	_, credential, err := rp.webAuthn.ValidatePasskeyLogin(handler, sessionData, parsed)
+	if err != nil {
+		return nil, nil, fmt.Errorf("login rejected: %w", err)
+	}
+
+	if credential.Authenticator.CloneWarning {
+		return nil, nil, fmt.Errorf("login rejected: sign count did not increase, the authenticator may be cloned")
+	}
+
+	for _, passkey := range account.Passkeys {
+		if bytes.Equal(passkey.CredentialID, credential.ID) {
+			passkey.SignCount = credential.Authenticator.SignCount
+			passkey.Flags = uint8(parsed.Response.AuthenticatorData.Flags)
+			return account, passkey, nil
+		}
+	}
+
+	return nil, nil, fmt.Errorf("passkey not found")
+}
+
+func marshalCeremony(options any, sessionData *webauthn.SessionData) ([]byte, []byte, error) {
+	optionsJSON, err := json.Marshal(options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sessionJSON, err := json.Marshal(sessionData)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return optionsJSON, sessionJSON, nil
+}
+
+// webAuthnUser adapts an Account to the webauthn.User interface. The user handle is the
+// raw user id, which lets a passkey login find the account again.
+type webAuthnUser struct {
+	account *Account
+}
+
+func (u *webAuthnUser) WebAuthnID() []byte {
+	id := u.account.UserID
+	return id[:]
+}
+
+func (u *webAuthnUser) WebAuthnName() string {
+	return u.account.Name
+}
+
+func (u *webAuthnUser) WebAuthnDisplayName() string {
+	return u.account.DisplayName
+}
+
+func (u *webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, 0, len(u.account.Passkeys))
+	for _, passkey := range u.account.Passkeys {
+		transports := make([]protocol.AuthenticatorTransport, 0, len(passkey.Transports))
+		for _, transport := range passkey.Transports {
+			transports = append(transports, protocol.AuthenticatorTransport(transport))
+		}
+
+		credentials = append(credentials, webauthn.Credential{
+			ID:              passkey.CredentialID,
+			PublicKey:       passkey.PublicKey,
+			AttestationType: passkey.AttestationType,
+			Transport:       transports,
+			Flags:           webauthn.NewCredentialFlags(protocol.AuthenticatorFlags(passkey.Flags)),
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    passkey.AAGUID,
+				SignCount: passkey.SignCount,
+			},
+		})
+	}
+
+	return credentials
+}
@@ -0,0 +1,267 @@
+package passkey_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/passkey"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testRPID   = "forum.example"
+	testOrigin = "https://forum.example"
+)
+
+// softAuthenticator is a software WebAuthn authenticator holding a single discoverable
+// P-256 credential, enough to drive the relying party through both ceremonies offline.
+type softAuthenticator struct {
+	t            *testing.T
+	origin       string
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	signCount    uint32
+
+	// skipUserVerification leaves the UV flag unset, like a security key used without its PIN
+	skipUserVerification bool
+}
+
+func newSoftAuthenticator(t *testing.T, origin string) *softAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	credentialID := make([]byte, 32)
+	_, err = rand.Read(credentialID)
+	require.NoError(t, err)
+
+	return &softAuthenticator{t: t, origin: origin, key: key, credentialID: credentialID}
+}
+
+// create answers navigator.credentials.create() with a "none" attestation.
+func (a *softAuthenticator) create(options []byte) []byte {
+	var creation struct {
+		PublicKey struct {
+			Challenge string `json:"challenge"`
+			RP        struct {
+				ID string `json:"id"`
+			} `json:"rp"`
+			User struct {
+				ID string `json:"id"`
+			} `json:"user"`
+		} `json:"publicKey"`
+	}
+	require.NoError(a.t, json.Unmarshal(options, &creation))
+
+	userHandle, err := base64.RawURLEncoding.DecodeString(creation.PublicKey.User.ID)
+	require.NoError(a.t, err)
+	a.userHandle = userHandle
+
+	coseKey, err := webauthncbor.Marshal(map[int]any{
+		1:  2,  // kty: EC2
+		3:  -7, // alg: ES256
+		-1: 1,  // crv: P-256
+		-2: a.key.X.FillBytes(make([]byte, 32)),
+		-3: a.key.Y.FillBytes(make([]byte, 32)),
+	})
+	require.NoError(a.t, err)
+
+	authData := a.authenticatorData(creation.PublicKey.RP.ID, 0x41) // UP, AT
+	authData = append(authData, make([]byte, 16)...)                // AAGUID
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.credentialID)))
+	authData = append(authData, a.credentialID...)
+	authData = append(authData, coseKey...)
+
+	attestationObject, err := webauthncbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": authData,
+	})
+	require.NoError(a.t, err)
+
+	return a.marshalResponse(map[string]any{
+		"clientDataJSON":    a.clientData("webauthn.create", creation.PublicKey.Challenge),
+		"attestationObject": base64.RawURLEncoding.EncodeToString(attestationObject),
+		"transports":        []string{"internal"},
+	})
+}
+
+// get answers navigator.credentials.get() with a signed assertion.
+func (a *softAuthenticator) get(options []byte) []byte {
+	var assertion struct {
+		PublicKey struct {
+			Challenge string `json:"challenge"`
+			RPID      string `json:"rpId"`
+		} `json:"publicKey"`
+	}
+	require.NoError(a.t, json.Unmarshal(options, &assertion))
+
+	a.signCount++
+	authData := a.authenticatorData(assertion.PublicKey.RPID, 0x01) // UP
+	clientData := a.clientData("webauthn.get", assertion.PublicKey.Challenge)
+
+	clientDataJSON, err := base64.RawURLEncoding.DecodeString(clientData)
+	require.NoError(a.t, err)
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(authData, clientDataHash[:]...))
+
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	require.NoError(a.t, err)
+
+	return a.marshalResponse(map[string]any{
+		"clientDataJSON":    clientData,
+		"authenticatorData": base64.RawURLEncoding.EncodeToString(authData),
+		"signature":         base64.RawURLEncoding.EncodeToString(signature),
+		"userHandle":        base64.RawURLEncoding.EncodeToString(a.userHandle),
+	})
+}
+
+func (a *softAuthenticator) authenticatorData(rpID string, flags byte) []byte {
+	if !a.skipUserVerification {
+		flags |= 0x04 // UV
+	}
+
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	authData := append(rpIDHash[:], flags)
+	return binary.BigEndian.AppendUint32(authData, a.signCount)
+}
+
+func (a *softAuthenticator) clientData(ceremony, challenge string) string {
+	clientDataJSON, err := json.Marshal(map[string]any{
+		"type":      ceremony,
+		"challenge": challenge,
+		"origin":    a.origin,
+	})
+	require.NoError(a.t, err)
+
+	return base64.RawURLEncoding.EncodeToString(clientDataJSON)
+}
+
+func (a *softAuthenticator) marshalResponse(response map[string]any) []byte {
+	credentialID := base64.RawURLEncoding.EncodeToString(a.credentialID)
+
+	body, err := json.Marshal(map[string]any{
+		"id":       credentialID,
+		"rawId":    credentialID,
+		"type":     "public-key",
+		"response": response,
+	})
+	require.NoError(a.t, err)
+
+	return body
+}
+
+func newTestRelyingParty(t *testing.T) *passkey.RelyingParty {
+	rp, err := passkey.NewRelyingParty(passkey.Config{
+		RPID:          testRPID,
+		RPDisplayName: "Hikayat",
+		RPOrigins:     []string{testOrigin},
+		Timeout:       time.Minute,
+	})
+	require.NoError(t, err)
+
+	return rp
+}
+
+func register(t *testing.T, rp *passkey.RelyingParty, authenticator *softAuthenticator, account *passkey.Account) {
+	options, session, err := rp.BeginRegistration(account)
+	require.NoError(t, err)
+
+	registered, err := rp.FinishRegistration(account, session, authenticator.create(options))
+	require.NoError(t, err)
+	account.Passkeys = append(account.Passkeys, registered)
+}
+
+func TestRelyingParty_RegisterAndLogin(t *testing.T) {
+	rp := newTestRelyingParty(t)
+	authenticator := newSoftAuthenticator(t, testOrigin)
+	account := &passkey.Account{UserID: uuid.New(), Name: "reader@forum.example", DisplayName: "reader"}
+
+	register(t, rp, authenticator, account)
+	require.Equal(t, authenticator.credentialID, account.Passkeys[0].CredentialID)
+	require.Equal(t, "none", account.Passkeys[0].AttestationType)
+	require.Equal(t, []string{"internal"}, account.Passkeys[0].Transports)
+
+	lookup := func(userID uuid.UUID) (*passkey.Account, error) {
+		require.Equal(t, account.UserID, userID)
+		return account, nil
+	}
+
+	for attempt := 1; attempt <= 2; attempt++ {
+		options, session, err := rp.BeginLogin()
+		require.NoError(t, err)
+
+		loggedIn, used, err := rp.FinishLogin(session, authenticator.get(options), lookup)
+		require.NoError(t, err)
+		require.Equal(t, account.UserID, loggedIn.UserID)
+		require.Equal(t, uint32(attempt), used.SignCount)
+	}
+}
+
+func TestRelyingParty_RejectsClonedAuthenticator(t *testing.T) {
+	rp := newTestRelyingParty(t)
+	authenticator := newSoftAuthenticator(t, testOrigin)
+	account := &passkey.Account{UserID: uuid.New(), Name: "reader@forum.example", DisplayName: "reader"}
+	register(t, rp, authenticator, account)
+
+	lookup := func(uuid.UUID) (*passkey.Account, error) { return account, nil }
+
+	options, session, err := rp.BeginLogin()
+	require.NoError(t, err)
+	_, _, err = rp.FinishLogin(session, authenticator.get(options), lookup)
+	require.NoError(t, err)
+
+	// a copy of the key replays the same counter value
+	authenticator.signCount--
+	options, session, err = rp.BeginLogin()
+	require.NoError(t, err)
+	_, _, err = rp.FinishLogin(session, authenticator.get(options), lookup)
+	require.ErrorContains(t, err, "cloned")
+}
+
+func TestRelyingParty_RequiresUserVerification(t *testing.T) {
+	rp := newTestRelyingParty(t)
+	authenticator := newSoftAuthenticator(t, testOrigin)
+	account := &passkey.Account{UserID: uuid.New(), Name: "reader@forum.example", DisplayName: "reader"}
+	register(t, rp, authenticator, account)
+
+	lookup := func(uuid.UUID) (*passkey.Account, error) { return account, nil }
+
+	// a stolen security key proves possession but not the user
+	authenticator.skipUserVerification = true
+	options, session, err := rp.BeginLogin()
+	require.NoError(t, err)
+	_, _, err = rp.FinishLogin(session, authenticator.get(options), lookup)
+	require.ErrorContains(t, err, "login rejected")
+
+	// nor can such a key be registered
+	other := &passkey.Account{UserID: uuid.New(), Name: "writer@forum.example", DisplayName: "writer"}
+	unverified := newSoftAuthenticator(t, testOrigin)
+	unverified.skipUserVerification = true
+
+	options, session, err = rp.BeginRegistration(other)
+	require.NoError(t, err)
+	_, err = rp.FinishRegistration(other, session, unverified.create(options))
+	require.ErrorContains(t, err, "registration rejected")
+}
+
+func TestRelyingParty_RejectsForeignOrigin(t *testing.T) {
+	rp := newTestRelyingParty(t)
+	authenticator := newSoftAuthenticator(t, "https://phishing.example")
+	account := &passkey.Account{UserID: uuid.New(), Name: "reader@forum.example", DisplayName: "reader"}
+
+	options, session, err := rp.BeginRegistration(account)
+	require.NoError(t, err)
+
+	_, err = rp.FinishRegistration(account, session, authenticator.create(options))
+	require.ErrorContains(t, err, "registration rejected")
+}
@@ -0,0 +1,212 @@
+package password
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	saltLength = 16
+	keyLength  = 32
+
+	// minPepperLength is the shortest pepper accepted, shorter secrets add little over the salt.
+	minPepperLength = 16
+)
+
+// Params are the argon2id costs new hashes are made with.
+type Params struct {
+	// Memory in KiB.
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+// Hasher hashes passwords with argon2id, encoded in the PHC string format. bcrypt hashes made
+// before argon2id was introduced are still verified, and reported as needing a rehash.
+//
+// With a pepper the password is keyed with HMAC-SHA256 before it is hashed, so a leaked database
+// alone is not enough to brute force the hashes. Peppered hashes carry a keyid parameter naming
+// the pepper, which lets hashes made before the pepper was configured be verified and upgraded.
+type Hasher struct {
+	params   Params
+	pepper   []byte
+	pepperID string
+}
+
+// NewHasher returns a Hasher making hashes with the given costs. The pepper is optional.
+func NewHasher(params Params, pepper []byte) (*Hasher, error) {
+	if params.Memory == 0 || params.Iterations == 0 || params.Parallelism == 0 {
+		return nil, fmt.Errorf("argon2id costs must be positive")
+	}
+	if params.Memory < 8*uint32(params.Parallelism) {
+		return nil, fmt.Errorf("argon2id memory must be at least 8 KiB per lane")
+	}
+
+	hasher := &Hasher{params: params}
+	if len(pepper) > 0 {
+		if len(pepper) < minPepperLength {
+			return nil, fmt.Errorf("password pepper must be at least %d bytes", minPepperLength)
+		}
+		sum := sha256.Sum256(pepper)
+		hasher.pepper = pepper
+		hasher.pepperID = base64.RawStdEncoding.EncodeToString(sum[:6])
+	}
+
+	return hasher, nil
+}
+
+// LoadPepper reads the pepper from a secret file, ignoring a trailing newline. An empty path
+// means no pepper.
+func LoadPepper(path string) ([]byte, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read password pepper: %w", err)
+	}
+
+	return bytes.TrimRight(data, "\r\n"), nil
+}
+
+// Hash returns the PHC encoded argon2id hash of the password, e.g.
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>.
+func (h *Hasher) Hash(password string) (string, error) {
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey(h.keyed(password, h.pepper), salt, h.params.Iterations, h.params.Memory, h.params.Parallelism, keyLength)
+
+	params := fmt.Sprintf("m=%d,t=%d,p=%d", h.params.Memory, h.params.Iterations, h.params.Parallelism)
+	if h.pepperID != "" {
+		params += ",keyid=" + h.pepperID
+	}
+
+	return fmt.Sprintf("$argon2id$v=%d$%s$%s$%s", argon2.Version, params,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Verify checks the password against an argon2id or bcrypt hash.
+func (h *Hasher) Verify(encoded, password string) (bool, bool, error) {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		return h.verifyArgon2id(encoded, password)
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) || errors.Is(err, bcrypt.ErrPasswordTooLong) {
+			return false, false, nil
+		}
+		if err != nil {
+			return false, false, err
+		}
+		return true, true, nil
+	default:
+		return false, false, fmt.Errorf("unknown password hash format")
+	}
+}
+
+func (h *Hasher) verifyArgon2id(encoded, password string) (bool, bool, error) {
+	hash, err := parseArgon2id(encoded)
+	if err != nil {
+		return false, false, err
+	}
+
+	var pepper []byte
+	switch hash.keyID {
+	case "":
+	case h.pepperID:
+		pepper = h.pepper
+	default:
+		return false, false, fmt.Errorf("password hash was made with an unknown pepper")
+	}
+
+	key := argon2.IDKey(h.keyed(password, pepper), hash.salt, hash.params.Iterations, hash.params.Memory, hash.params.Parallelism, uint32(len(hash.key)))
+	if subtle.ConstantTimeCompare(key, hash.key) != 1 {
+		return false, false, nil
+	}
+
+	needsRehash := hash.params != h.params || len(hash.key) != keyLength || hash.keyID != h.pepperID
+
+	return true, needsRehash, nil
+}
+
+// keyed applies the pepper to the password, or returns it unchanged without one.
+func (h *Hasher) keyed(password string, pepper []byte) []byte {
+	if len(pepper) == 0 {
+		return []byte(password)
+	}
+
+	mac := hmac.New(sha256.New, pepper)
+	mac.Write([]byte(password))
+	return mac.Sum(nil)
+}
+
+// argon2idHash is a decoded PHC argon2id string.
+type argon2idHash struct {
+	params Params
+	keyID  string
+	salt   []byte
+	key    []byte
+}
+
+// parseArgon2id decodes $argon2id$v=19$m=...,t=...,p=...[,keyid=...]$salt$hash.
+func parseArgon2id(encoded string) (*argon2idHash, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, fmt.Errorf("malformed argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, fmt.Errorf("unsupported argon2id version %q", parts[2])
+	}
+
+	hash := &argon2idHash{}
+	for _, param := range strings.Split(parts[3], ",") {
+		name, value, _ := strings.Cut(param, "=")
+
+		var err error
+		switch name {
+		case "m":
+			_, err = fmt.Sscanf(value, "%d", &hash.params.Memory)
+		case "t":
+			_, err = fmt.Sscanf(value, "%d", &hash.params.Iterations)
+		case "p":
+			_, err = fmt.Sscanf(value, "%d", &hash.params.Parallelism)
+		case "keyid":
+			hash.keyID = value
+		default:
+			err = fmt.Errorf("unknown parameter")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid argon2id parameter %q", param)
+		}
+	}
+	if hash.params.Memory == 0 || hash.params.Iterations == 0 || hash.params.Parallelism == 0 {
+		return nil, fmt.Errorf("argon2id hash misses its costs")
+	}
+
+	var err error
+	if hash.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, fmt.Errorf("invalid argon2id salt")
+	}
+	if hash.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(hash.key) == 0 {
+		return nil, fmt.Errorf("invalid argon2id hash")
+	}
+
+	return hash, nil
+}
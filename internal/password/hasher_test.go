@@ -0,0 +1,84 @@
+package password_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/password"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var testParams = password.Params{Memory: 64, Iterations: 1, Parallelism: 1}
+
+func newHasher(t *testing.T, params password.Params, pepper string) *password.Hasher {
+	t.Helper()
+
+	hasher, err := password.NewHasher(params, []byte(pepper))
+	require.NoError(t, err)
+
+	return hasher
+}
+
+func TestHasherRoundTrip(t *testing.T) {
+	hasher := newHasher(t, testParams, "")
+
+	// longer than the 72 bytes bcrypt would have truncated to
+	long := strings.Repeat("correct horse battery staple ", 4)
+	encoded, err := hasher.Hash(long)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(encoded, "$argon2id$v=19$m=64,t=1,p=1$"))
+
+	match, needsRehash, err := hasher.Verify(encoded, long)
+	require.NoError(t, err)
+	require.True(t, match)
+	require.False(t, needsRehash)
+
+	match, _, err = hasher.Verify(encoded, long[:72])
+	require.NoError(t, err)
+	require.False(t, match)
+}
+
+func TestHasherFlagsOutdatedHashes(t *testing.T) {
+	hasher := newHasher(t, testParams, "")
+
+	legacy, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+	match, needsRehash, err := hasher.Verify(string(legacy), "secret")
+	require.NoError(t, err)
+	require.True(t, match)
+	require.True(t, needsRehash)
+
+	cheaper, err := newHasher(t, password.Params{Memory: 32, Iterations: 1, Parallelism: 1}, "").Hash("secret")
+	require.NoError(t, err)
+	match, needsRehash, err = hasher.Verify(cheaper, "secret")
+	require.NoError(t, err)
+	require.True(t, match)
+	require.True(t, needsRehash)
+}
+
+func TestHasherPepper(t *testing.T) {
+	unpeppered, err := newHasher(t, testParams, "").Hash("secret")
+	require.NoError(t, err)
+
+	hasher := newHasher(t, testParams, "a-pepper-of-32-bytes-or-so-12345")
+
+	// hashes made before the pepper was configured still verify and get upgraded
+	match, needsRehash, err := hasher.Verify(unpeppered, "secret")
+	require.NoError(t, err)
+	require.True(t, match)
+	require.True(t, needsRehash)
+
+	peppered, err := hasher.Hash("secret")
+	require.NoError(t, err)
+	require.Contains(t, peppered, ",keyid=")
+
+	match, needsRehash, err = hasher.Verify(peppered, "secret")
+	require.NoError(t, err)
+	require.True(t, match)
+	require.False(t, needsRehash)
+
+	// without the pepper the hash cannot be checked at all
+	_, _, err = newHasher(t, testParams, "another-pepper-of-32-bytes-12345").Verify(peppered, "secret")
+	require.Error(t, err)
+}
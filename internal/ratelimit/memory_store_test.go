@@ -0,0 +1,34 @@
+package ratelimit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/ratelimit"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := ratelimit.NewMemoryStore()
+
+	// one token per hour, bursts of two
+	limit := ratelimit.Limit{Rate: 1.0 / 3600, Burst: 2}
+
+	for i := 0; i < 2; i++ {
+		result, err := store.Take(ctx, "login|ip|10.0.0.1", limit)
+		require.NoError(t, err)
+		require.True(t, result.Allowed)
+	}
+
+	result, err := store.Take(ctx, "login|ip|10.0.0.1", limit)
+	require.NoError(t, err)
+	require.False(t, result.Allowed)
+	require.InDelta(t, time.Hour.Seconds(), result.RetryAfter.Seconds(), 1)
+
+	// other keys have their own bucket
+	result, err = store.Take(ctx, "login|ip|10.0.0.2", limit)
+	require.NoError(t, err)
+	require.True(t, result.Allowed)
+}
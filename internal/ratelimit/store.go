@@ -0,0 +1,26 @@
+// Package ratelimit provides token buckets for the rate limiting interceptor. Buckets live in a
+// Store, so replicas can share them by plugging in a store backed by a shared database or cache.
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Limit describes a token bucket: it holds up to Burst tokens and refills at Rate tokens per second.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Result is the outcome of taking a token. RetryAfter tells a refused caller when the next token is available.
+type Result struct {
+	Allowed    bool
+	RetryAfter time.Duration
+}
+
+// Store keeps the token buckets. Take removes one token from the bucket identified by key,
+// creating a full bucket on first use.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
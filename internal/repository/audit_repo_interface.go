@@ -0,0 +1,22 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+)
+
+type AuditRepository interface {
+	InsertAuditEvents(ctx context.Context, events []*models.AuditEvent) error
+	ListAuditEvents(ctx context.Context, filter models.AuditEventFilter, after *models.AuditCursor, limit int) ([]*models.AuditEvent, error)
+	ListAuditChain(ctx context.Context, afterSeq int64, limit int) ([]*models.AuditEvent, error)
+	CountUnchainedAuditEvents(ctx context.Context) (int64, error)
+	AuditChainHead(ctx context.Context) (int64, string, error)
+	AuditChainAnchor(ctx context.Context) (int64, string, error)
+	CountAuditEventsBefore(ctx context.Context, cutoff time.Time) (int64, error)
+	ListOldestAuditEvents(ctx context.Context, limit int) ([]*models.AuditEvent, error)
+	DeleteAuditEvents(ctx context.Context, events []*models.AuditEvent, anchorCheckpoint *models.AuditCheckpoint) error
+	InsertAuditCheckpoint(ctx context.Context, checkpoint *models.AuditCheckpoint) error
+	ListAuditCheckpoints(ctx context.Context) ([]*models.AuditCheckpoint, error)
+}
@@ -0,0 +1,15 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/google/uuid"
+)
+
+type EmailChangeRepository interface {
+	CreateEmailChange(ctx context.Context, change *models.EmailChange) error
+	ConfirmEmailChange(ctx context.Context, confirmTokenHash string) (*models.EmailChange, error)
+	CancelEmailChange(ctx context.Context, cancelTokenHash string) (*models.EmailChange, error)
+	DeletePendingEmailChanges(ctx context.Context, userID uuid.UUID) error
+}
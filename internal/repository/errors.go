@@ -0,0 +1,19 @@
+package repository
+
+import "errors"
+
+// ErrSessionAlreadyRotated is returned when a refresh token generation was rotated or
+// revoked before the caller could rotate it.
+var ErrSessionAlreadyRotated = errors.New("session already rotated")
+
+// ErrRoleAlreadyExists is returned when a role with the same name already exists.
+var ErrRoleAlreadyExists = errors.New("role already exists")
+
+// ErrRoleNotFound is returned when no role has the given id or name.
+var ErrRoleNotFound = errors.New("role not found")
+
+// ErrUserNotFound is returned when no user has the given id.
+var ErrUserNotFound = errors.New("user not found")
+
+// ErrNotSuspended is returned when a user has no suspension that was not lifted yet.
+var ErrNotSuspended = errors.New("user is not suspended")
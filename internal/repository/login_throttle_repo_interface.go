@@ -0,0 +1,15 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+)
+
+type LoginThrottleRepository interface {
+	FindLoginThrottle(ctx context.Context, subjectType, subjectKey string) (*models.LoginThrottle, error)
+	RecordLoginFailure(ctx context.Context, subjectType, subjectKey string, window time.Duration) (*models.LoginThrottle, error)
+	LockLoginSubject(ctx context.Context, subjectType, subjectKey string, until time.Time) error
+	ResetLoginThrottle(ctx context.Context, subjectType, subjectKey string) error
+}
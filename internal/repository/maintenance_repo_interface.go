@@ -0,0 +1,9 @@
+package repository
+
+import (
+	"context"
+)
+
+type MaintenanceRepository interface {
+	TryMaintenanceLock(ctx context.Context) (release func(), acquired bool, err error)
+}
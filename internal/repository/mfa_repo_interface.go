@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/google/uuid"
+)
+
+type MFARepository interface {
+	SavePendingTOTP(ctx context.Context, userID uuid.UUID, secret string) error
+	FindTOTP(ctx context.Context, userID uuid.UUID) (*models.UserTOTP, error)
+	IsTOTPEnabled(ctx context.Context, userID uuid.UUID) (bool, error)
+	EnableTOTP(ctx context.Context, userID uuid.UUID, step int64, recoveryCodeHashes []string) error
+	UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) error
+	UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) error
+	DeleteTOTP(ctx context.Context, userID uuid.UUID) error
+	ClaimMFAChallenge(ctx context.Context, tokenID string, userID uuid.UUID, expiresAt time.Time, maxFailures int) (bool, error)
+	ReleaseMFAChallenge(ctx context.Context, tokenID string) (int, error)
+	CountExpiredMFAChallenges(ctx context.Context, cutoff time.Time) (int64, error)
+	DeleteExpiredMFAChallenges(ctx context.Context, cutoff time.Time, limit int) (int64, error)
+}
@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/google/uuid"
+)
+
+type PasskeyRepository interface {
+	CreatePasskey(ctx context.Context, passkey *models.Passkey) error
+	ListUserPasskeys(ctx context.Context, userID uuid.UUID) ([]*models.Passkey, error)
+	UpdatePasskeyUsage(ctx context.Context, id uuid.UUID, signCount uint32, flags uint8) error
+	DeleteUserPasskey(ctx context.Context, userID, id uuid.UUID) error
+	CreateCeremony(ctx context.Context, ceremony *models.WebAuthnCeremony) error
+	ConsumeCeremony(ctx context.Context, id uuid.UUID, kind string) (*models.WebAuthnCeremony, error)
+	CountExpiredCeremonies(ctx context.Context, cutoff time.Time) (int64, error)
+	DeleteExpiredCeremonies(ctx context.Context, cutoff time.Time, limit int) (int64, error)
+}
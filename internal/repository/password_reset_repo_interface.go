@@ -0,0 +1,17 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/google/uuid"
+)
+
+type PasswordResetRepository interface {
+	CreatePasswordReset(ctx context.Context, reset *models.PasswordReset) error
+	ConsumePasswordReset(ctx context.Context, tokenHash string) (uuid.UUID, error)
+	DeleteUserPasswordResets(ctx context.Context, userID uuid.UUID) error
+	CountExpiredPasswordResets(ctx context.Context, cutoff time.Time) (int64, error)
+	DeleteExpiredPasswordResets(ctx context.Context, cutoff time.Time, limit int) (int64, error)
+}
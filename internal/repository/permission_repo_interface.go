@@ -0,0 +1,15 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/google/uuid"
+)
+
+type PermissionRepository interface {
+	ListPermissions(ctx context.Context) ([]*models.Permission, error)
+	GrantPermissionToRole(ctx context.Context, roleID uuid.UUID, permissionName string) error
+	RevokePermissionFromRole(ctx context.Context, roleID uuid.UUID, permissionName string) error
+	UserHasPermission(ctx context.Context, userID uuid.UUID, permissionName string) (bool, error)
+}
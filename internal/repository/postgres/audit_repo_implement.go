@@ -0,0 +1,411 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/auditlog"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/repository"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type auditRepo struct {
+	db *sql.DB
+}
+
+func NewAuditRepository(db *sql.DB) repository.AuditRepository {
+	return &auditRepo{db: db}
+}
+
+// auditChainLock is the advisory lock key that serializes appends to the audit hash chain.
+const auditChainLock int64 = 0x61756469745f6c67
+
+// auditEventColumns are the columns scanned by scanAuditEvent, in order.
+const auditEventColumns = `id, action_type, outcome, actor_id, target_user_id, ip_address, user_agent, metadata, created_at, seq, prev_hash, row_hash`
+
+// InsertAuditEvents writes a batch of audit events in one transaction, appending them to the
+// hash chain. Appends are serialized with an advisory lock, so concurrent writers cannot fork the
+// chain. The events' IDs and chain fields are filled in.
+func (r *auditRepo) InsertAuditEvents(ctx context.Context, events []*models.AuditEvent) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, auditChainLock); err != nil {
+		return fmt.Errorf("failed to lock audit chain: %w", err)
+	}
+
+	headSeq, headHash, err := chainHead(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		event.ID = uuid.New()
+		// the hash covers created_at at the precision the column keeps
+		event.CreatedAt = event.CreatedAt.UTC().Truncate(time.Microsecond)
+	}
+	if err := auditlog.Link(headSeq, headHash, events); err != nil {
+		return err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO audit_logs (id, action_type, outcome, actor_id, target_user_id, ip_address, user_agent, metadata, created_at, seq, prev_hash, row_hash)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''), $8, $9, $10, $11, $12)
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, event := range events {
+		metadata := event.Metadata
+		if metadata == nil {
+			metadata = map[string]any{}
+		}
+		encoded, err := json.Marshal(metadata)
+		if err != nil {
+			return fmt.Errorf("failed to encode audit metadata: %w", err)
+		}
+
+		if _, err := stmt.ExecContext(ctx,
+			event.ID,
+			event.Action,
+			event.Outcome,
+			event.ActorID,
+			event.TargetUserID,
+			event.IPAddress,
+			event.UserAgent,
+			encoded,
+			event.CreatedAt,
+			event.Seq,
+			event.PrevHash,
+			event.Hash,
+		); err != nil {
+			return fmt.Errorf("failed to store audit event: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// AuditChainHead returns the seq and hash of the last chained event. Without chained events it is
+// the anchor left by pruning, or 0 and the genesis hash while the chain is empty.
+func (r *auditRepo) AuditChainHead(ctx context.Context) (int64, string, error) {
+	return chainHead(ctx, r.db)
+}
+
+// queryRower is what chainHead needs from either the database or a transaction.
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func chainHead(ctx context.Context, db queryRower) (int64, string, error) {
+	query := `
+		SELECT seq, row_hash
+		FROM audit_logs
+		WHERE seq IS NOT NULL
+		ORDER BY seq DESC
+		LIMIT 1
+	`
+
+	var seq int64
+	var hash string
+	if err := db.QueryRowContext(ctx, query).Scan(&seq, &hash); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return chainAnchor(ctx, db)
+		}
+		return 0, "", fmt.Errorf("failed to find audit chain head: %w", err)
+	}
+
+	return seq, hash, nil
+}
+
+// AuditChainAnchor returns the seq and hash of the last pruned chained event, the chain stored in
+// audit_logs continues from there. It is 0 and the genesis hash until something was pruned.
+func (r *auditRepo) AuditChainAnchor(ctx context.Context) (int64, string, error) {
+	return chainAnchor(ctx, r.db)
+}
+
+func chainAnchor(ctx context.Context, db queryRower) (int64, string, error) {
+	var seq int64
+	var hash string
+	if err := db.QueryRowContext(ctx, `SELECT seq, row_hash FROM audit_chain_anchor`).Scan(&seq, &hash); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, auditlog.GenesisHash, nil
+		}
+		return 0, "", fmt.Errorf("failed to find audit chain anchor: %w", err)
+	}
+
+	return seq, hash, nil
+}
+
+// CountAuditEventsBefore counts the events created before the cutoff.
+func (r *auditRepo) CountAuditEventsBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	var count int64
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM audit_logs WHERE created_at < $1`, cutoff).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count audit events: %w", err)
+	}
+
+	return count, nil
+}
+
+// ListOldestAuditEvents returns up to limit of the oldest events: the unchained ones by creation
+// time while there are any, then the chained ones in seq order.
+func (r *auditRepo) ListOldestAuditEvents(ctx context.Context, limit int) ([]*models.AuditEvent, error) {
+	query := `
+		SELECT ` + auditEventColumns + `
+		FROM audit_logs
+		WHERE seq IS NULL
+		ORDER BY created_at, id
+		LIMIT $1
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list oldest audit events: %w", err)
+	}
+	events, err := scanAuditEvents(rows)
+	rows.Close()
+	if err != nil || len(events) > 0 {
+		return events, err
+	}
+
+	return r.ListAuditChain(ctx, 0, limit)
+}
+
+// DeleteAuditEvents deletes the events. When chained events are among them, the anchor moves to
+// the last one and anchorCheckpoint, signed at that event, is stored in the same transaction, so
+// the remaining chain stays verifiable. Only a prefix of the chain may be deleted.
+func (r *auditRepo) DeleteAuditEvents(ctx context.Context, events []*models.AuditEvent, anchorCheckpoint *models.AuditCheckpoint) error {
+	ids := make([]string, 0, len(events))
+	var anchor *models.AuditEvent
+	for _, event := range events {
+		ids = append(ids, event.ID.String())
+		if event.Seq > 0 && (anchor == nil || event.Seq > anchor.Seq) {
+			anchor = event
+		}
+	}
+
+	if anchor != nil && (anchorCheckpoint == nil || anchorCheckpoint.Seq != anchor.Seq || anchorCheckpoint.RowHash != anchor.Hash) {
+		return fmt.Errorf("pruning the audit chain up to seq %d needs a checkpoint signed at it", anchor.Seq)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM audit_logs WHERE id = ANY($1::uuid[])`, pq.Array(ids)); err != nil {
+		return fmt.Errorf("failed to delete audit events: %w", err)
+	}
+
+	if anchor != nil {
+		if err := insertAuditCheckpoint(ctx, tx, anchorCheckpoint); err != nil {
+			return err
+		}
+
+		query := `
+			INSERT INTO audit_chain_anchor (id, seq, row_hash, updated_at)
+			VALUES (TRUE, $1, $2, NOW())
+			ON CONFLICT (id) DO UPDATE SET seq = EXCLUDED.seq, row_hash = EXCLUDED.row_hash, updated_at = NOW()
+		`
+		if _, err := tx.ExecContext(ctx, query, anchor.Seq, anchor.Hash); err != nil {
+			return fmt.Errorf("failed to move audit chain anchor: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ListAuditEvents returns up to limit events matching the filter, newest first, starting after the
+// cursor when one is given.
+func (r *auditRepo) ListAuditEvents(ctx context.Context, filter models.AuditEventFilter, after *models.AuditCursor, limit int) ([]*models.AuditEvent, error) {
+	var conditions []string
+	var args []any
+	arg := func(value any) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if filter.UserID != nil {
+		placeholder := arg(*filter.UserID)
+		conditions = append(conditions, "(actor_id = "+placeholder+" OR target_user_id = "+placeholder+")")
+	}
+	if filter.TargetUserID != nil {
+		conditions = append(conditions, "target_user_id = "+arg(*filter.TargetUserID))
+	}
+	if len(filter.Actions) > 0 {
+		conditions = append(conditions, "action_type = ANY("+arg(pq.Array(filter.Actions))+")")
+	}
+	if filter.Outcome != "" {
+		conditions = append(conditions, "outcome = "+arg(filter.Outcome))
+	}
+	if !filter.Since.IsZero() {
+		conditions = append(conditions, "created_at >= "+arg(filter.Since))
+	}
+	if !filter.Until.IsZero() {
+		conditions = append(conditions, "created_at < "+arg(filter.Until))
+	}
+	if after != nil {
+		conditions = append(conditions, "(created_at, id) < ("+arg(after.CreatedAt)+", "+arg(after.ID)+")")
+	}
+
+	query := `
+		SELECT ` + auditEventColumns + `
+		FROM audit_logs
+	`
+	if len(conditions) > 0 {
+		query += "WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY created_at DESC, id DESC LIMIT " + arg(limit)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit events: %w", err)
+	}
+	defer rows.Close()
+
+	return scanAuditEvents(rows)
+}
+
+// ListAuditChain returns up to limit chained events with a seq above afterSeq, in seq order.
+func (r *auditRepo) ListAuditChain(ctx context.Context, afterSeq int64, limit int) ([]*models.AuditEvent, error) {
+	query := `
+		SELECT ` + auditEventColumns + `
+		FROM audit_logs
+		WHERE seq > $1
+		ORDER BY seq
+		LIMIT $2
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, afterSeq, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit chain: %w", err)
+	}
+	defer rows.Close()
+
+	return scanAuditEvents(rows)
+}
+
+// CountUnchainedAuditEvents counts the events outside the hash chain. Only rows written before
+// the chain was introduced should be.
+func (r *auditRepo) CountUnchainedAuditEvents(ctx context.Context) (int64, error) {
+	var count int64
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM audit_logs WHERE seq IS NULL`).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count unchained audit events: %w", err)
+	}
+
+	return count, nil
+}
+
+// InsertAuditCheckpoint stores a signed checkpoint. A second checkpoint of the same seq is ignored.
+func (r *auditRepo) InsertAuditCheckpoint(ctx context.Context, checkpoint *models.AuditCheckpoint) error {
+	return insertAuditCheckpoint(ctx, r.db, checkpoint)
+}
+
+func insertAuditCheckpoint(ctx context.Context, db execer, checkpoint *models.AuditCheckpoint) error {
+	query := `
+		INSERT INTO audit_checkpoints (seq, row_hash, signed_at, key_id, algorithm, signature)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (seq) DO NOTHING
+	`
+
+	if _, err := db.ExecContext(ctx, query,
+		checkpoint.Seq,
+		checkpoint.RowHash,
+		checkpoint.SignedAt,
+		checkpoint.KeyID,
+		checkpoint.Algorithm,
+		checkpoint.Signature,
+	); err != nil {
+		return fmt.Errorf("failed to store audit checkpoint: %w", err)
+	}
+
+	return nil
+}
+
+// ListAuditCheckpoints returns every checkpoint in seq order.
+func (r *auditRepo) ListAuditCheckpoints(ctx context.Context) ([]*models.AuditCheckpoint, error) {
+	query := `
+		SELECT seq, row_hash, signed_at, key_id, algorithm, signature
+		FROM audit_checkpoints
+		ORDER BY seq
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit checkpoints: %w", err)
+	}
+	defer rows.Close()
+
+	var checkpoints []*models.AuditCheckpoint
+	for rows.Next() {
+		var checkpoint models.AuditCheckpoint
+		if err := rows.Scan(
+			&checkpoint.Seq,
+			&checkpoint.RowHash,
+			&checkpoint.SignedAt,
+			&checkpoint.KeyID,
+			&checkpoint.Algorithm,
+			&checkpoint.Signature,
+		); err != nil {
+			return nil, err
+		}
+		checkpoints = append(checkpoints, &checkpoint)
+	}
+
+	return checkpoints, rows.Err()
+}
+
+// scanAuditEvents reads rows selected with auditEventColumns.
+func scanAuditEvents(rows *sql.Rows) ([]*models.AuditEvent, error) {
+	var events []*models.AuditEvent
+	for rows.Next() {
+		var event models.AuditEvent
+		var ipAddress, userAgent, prevHash, rowHash sql.NullString
+		var seq sql.NullInt64
+		var metadata []byte
+		if err := rows.Scan(
+			&event.ID,
+			&event.Action,
+			&event.Outcome,
+			&event.ActorID,
+			&event.TargetUserID,
+			&ipAddress,
+			&userAgent,
+			&metadata,
+			&event.CreatedAt,
+			&seq,
+			&prevHash,
+			&rowHash,
+		); err != nil {
+			return nil, err
+		}
+		event.IPAddress = ipAddress.String
+		event.UserAgent = userAgent.String
+		event.Seq = seq.Int64
+		event.PrevHash = prevHash.String
+		event.Hash = rowHash.String
+		if err := json.Unmarshal(metadata, &event.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to decode audit metadata: %w", err)
+		}
+		events = append(events, &event)
+	}
+
+	return events, rows.Err()
+}
@@ -0,0 +1,104 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/repository"
+	"github.com/google/uuid"
+)
+
+type emailChangeRepo struct {
+	db *sql.DB
+}
+
+func NewEmailChangeRepository(db *sql.DB) repository.EmailChangeRepository {
+	return &emailChangeRepo{db: db}
+}
+
+// CreateEmailChange stores a new pending email change.
+func (r *emailChangeRepo) CreateEmailChange(ctx context.Context, change *models.EmailChange) error {
+	query := `
+		INSERT INTO email_changes (user_id, new_email, confirm_token, cancel_token, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query,
+		change.UserID,
+		change.NewEmail,
+		change.ConfirmTokenHash,
+		change.CancelTokenHash,
+		change.ExpiresAt,
+	).Scan(&change.ID, &change.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create email change: %w", err)
+	}
+
+	return nil
+}
+
+// ConfirmEmailChange marks the pending change of the confirmation token as confirmed and returns it.
+func (r *emailChangeRepo) ConfirmEmailChange(ctx context.Context, confirmTokenHash string) (*models.EmailChange, error) {
+	query := `
+		UPDATE email_changes
+		SET confirmed_at = NOW()
+		WHERE confirm_token = $1
+			AND confirmed_at IS NULL
+			AND cancelled_at IS NULL
+			AND expires_at > NOW()
+		RETURNING id, user_id, new_email, confirm_token, cancel_token, expires_at, confirmed_at, cancelled_at, created_at
+	`
+	return r.scanEmailChange(r.db.QueryRowContext(ctx, query, confirmTokenHash))
+}
+
+// CancelEmailChange marks the pending change of the cancel token as cancelled and returns it.
+func (r *emailChangeRepo) CancelEmailChange(ctx context.Context, cancelTokenHash string) (*models.EmailChange, error) {
+	query := `
+		UPDATE email_changes
+		SET cancelled_at = NOW()
+		WHERE cancel_token = $1
+			AND confirmed_at IS NULL
+			AND cancelled_at IS NULL
+			AND expires_at > NOW()
+		RETURNING id, user_id, new_email, confirm_token, cancel_token, expires_at, confirmed_at, cancelled_at, created_at
+	`
+	return r.scanEmailChange(r.db.QueryRowContext(ctx, query, cancelTokenHash))
+}
+
+// DeletePendingEmailChanges removes every email change of the user that is still pending.
+func (r *emailChangeRepo) DeletePendingEmailChanges(ctx context.Context, userID uuid.UUID) error {
+	query := `
+		DELETE FROM email_changes
+		WHERE user_id = $1 AND confirmed_at IS NULL AND cancelled_at IS NULL
+	`
+	_, err := r.db.ExecContext(ctx, query, userID)
+	return err
+}
+
+func (r *emailChangeRepo) scanEmailChange(row *sql.Row) (*models.EmailChange, error) {
+	var change models.EmailChange
+	err := row.Scan(
+		&change.ID,
+		&change.UserID,
+		&change.NewEmail,
+		&change.ConfirmTokenHash,
+		&change.CancelTokenHash,
+		&change.ExpiresAt,
+		&change.ConfirmedAt,
+		&change.CancelledAt,
+		&change.CreatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("email change not found or expired")
+		}
+		return nil, err
+	}
+
+	return &change, nil
+}
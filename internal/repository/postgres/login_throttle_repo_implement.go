@@ -0,0 +1,104 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/repository"
+)
+
+type loginThrottleRepo struct {
+	db *sql.DB
+}
+
+func NewLoginThrottleRepository(db *sql.DB) repository.LoginThrottleRepository {
+	return &loginThrottleRepo{db: db}
+}
+
+// FindLoginThrottle returns the failed login counter of a subject. A subject without recorded
+// failures gets an empty counter rather than an error.
+func (r *loginThrottleRepo) FindLoginThrottle(ctx context.Context, subjectType, subjectKey string) (*models.LoginThrottle, error) {
+	query := `
+		SELECT subject_type, subject_key, failures, window_started_at, last_failure_at, locked_until
+		FROM login_throttles
+		WHERE subject_type = $1 AND subject_key = $2
+	`
+
+	var throttle models.LoginThrottle
+	err := r.db.QueryRowContext(ctx, query, subjectType, subjectKey).Scan(
+		&throttle.SubjectType,
+		&throttle.SubjectKey,
+		&throttle.Failures,
+		&throttle.WindowStartedAt,
+		&throttle.LastFailureAt,
+		&throttle.LockedUntil,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &models.LoginThrottle{SubjectType: subjectType, SubjectKey: subjectKey}, nil
+		}
+		return nil, fmt.Errorf("failed to find login throttle: %w", err)
+	}
+
+	return &throttle, nil
+}
+
+// RecordLoginFailure counts one more failed login for the subject. Failures older than the window
+// are forgotten, the counter then starts over at one.
+func (r *loginThrottleRepo) RecordLoginFailure(ctx context.Context, subjectType, subjectKey string, window time.Duration) (*models.LoginThrottle, error) {
+	query := `
+		INSERT INTO login_throttles (subject_type, subject_key, failures, window_started_at, last_failure_at)
+		VALUES ($1, $2, 1, NOW(), NOW())
+		ON CONFLICT (subject_type, subject_key) DO UPDATE
+		SET failures = CASE
+				WHEN login_throttles.window_started_at < NOW() - make_interval(secs => $3) THEN 1
+				ELSE login_throttles.failures + 1
+			END,
+			window_started_at = CASE
+				WHEN login_throttles.window_started_at < NOW() - make_interval(secs => $3) THEN NOW()
+				ELSE login_throttles.window_started_at
+			END,
+			last_failure_at = NOW()
+		RETURNING subject_type, subject_key, failures, window_started_at, last_failure_at, locked_until
+	`
+
+	var throttle models.LoginThrottle
+	err := r.db.QueryRowContext(ctx, query, subjectType, subjectKey, window.Seconds()).Scan(
+		&throttle.SubjectType,
+		&throttle.SubjectKey,
+		&throttle.Failures,
+		&throttle.WindowStartedAt,
+		&throttle.LastFailureAt,
+		&throttle.LockedUntil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record login failure: %w", err)
+	}
+
+	return &throttle, nil
+}
+
+// LockLoginSubject locks the subject until the given time and starts a fresh failure window,
+// so the backoff starts over once the lock expired.
+func (r *loginThrottleRepo) LockLoginSubject(ctx context.Context, subjectType, subjectKey string, until time.Time) error {
+	query := `
+		UPDATE login_throttles
+		SET locked_until = $3, failures = 0, window_started_at = NOW()
+		WHERE subject_type = $1 AND subject_key = $2
+	`
+	_, err := r.db.ExecContext(ctx, query, subjectType, subjectKey, until)
+
+	return err
+}
+
+// ResetLoginThrottle forgets the failures and any lock of the subject.
+func (r *loginThrottleRepo) ResetLoginThrottle(ctx context.Context, subjectType, subjectKey string) error {
+	query := `DELETE FROM login_throttles WHERE subject_type = $1 AND subject_key = $2`
+	_, err := r.db.ExecContext(ctx, query, subjectType, subjectKey)
+
+	return err
+}
@@ -0,0 +1,65 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"log"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/repository"
+)
+
+// maintenanceLock is the advisory lock key held while a maintenance run is in progress.
+const maintenanceLock int64 = 0x6d61696e74656e63
+
+type maintenanceRepo struct {
+	db *sql.DB
+}
+
+func NewMaintenanceRepository(db *sql.DB) repository.MaintenanceRepository {
+	return &maintenanceRepo{db: db}
+}
+
+// TryMaintenanceLock takes the maintenance advisory lock without waiting. The lock belongs to a
+// database session, so a connection is reserved until release is called. acquired is false when
+// another replica holds the lock.
+func (r *maintenanceRepo) TryMaintenanceLock(ctx context.Context) (func(), bool, error) {
+	op := "maintenanceRepo.TryMaintenanceLock"
+
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, maintenanceLock).Scan(&acquired); err != nil {
+		// the lock may have been taken before the error, so the session is not reused
+		discardConn(conn)
+		return nil, false, fmt.Errorf("failed to take maintenance lock: %w", err)
+	}
+	if !acquired {
+		conn.Close()
+		return nil, false, nil
+	}
+
+	release := func() {
+		if _, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, maintenanceLock); err != nil {
+			// back in the pool the session would keep holding the lock and every replica would
+			// skip maintenance until the connection happens to be recycled
+			log.Printf("%s Error releasing maintenance lock, discarding the connection: %v", op, err)
+			discardConn(conn)
+			return
+		}
+		conn.Close()
+	}
+
+	return release, true, nil
+}
+
+// discardConn closes the connection's database session instead of returning it to the pool,
+// which ends the session and with it any advisory lock it holds.
+func discardConn(conn *sql.Conn) {
+	conn.Raw(func(any) error { return driver.ErrBadConn })
+	conn.Close()
+}
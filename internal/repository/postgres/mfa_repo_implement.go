@@ -0,0 +1,270 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/repository"
+	"github.com/google/uuid"
+)
+
+type mfaRepo struct {
+	db *sql.DB
+}
+
+func NewMFARepository(db *sql.DB) repository.MFARepository {
+	return &mfaRepo{db: db}
+}
+
+// SavePendingTOTP stores a new, not yet enabled TOTP secret for the user. It replaces a previous
+// pending enrollment but never an enabled one.
+func (r *mfaRepo) SavePendingTOTP(ctx context.Context, userID uuid.UUID, secret string) error {
+	query := `
+		INSERT INTO user_totp (user_id, secret)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, last_used_step = 0, created_at = NOW()
+		WHERE user_totp.enabled_at IS NULL
+	`
+	result, err := r.db.ExecContext(ctx, query, userID, secret)
+	if err != nil {
+		return err
+	}
+
+	affectedRows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affectedRows == 0 {
+		return fmt.Errorf("totp already enabled")
+	}
+
+	return nil
+}
+
+// FindTOTP returns the TOTP enrollment of the user, pending or enabled.
+func (r *mfaRepo) FindTOTP(ctx context.Context, userID uuid.UUID) (*models.UserTOTP, error) {
+	query := `
+		SELECT user_id, secret, enabled_at, last_used_step, created_at
+		FROM user_totp
+		WHERE user_id = $1
+	`
+
+	var totp models.UserTOTP
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(
+		&totp.UserID,
+		&totp.Secret,
+		&totp.EnabledAt,
+		&totp.LastUsedStep,
+		&totp.CreatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("totp not found")
+		}
+		return nil, fmt.Errorf("failed to find totp: %w", err)
+	}
+
+	return &totp, nil
+}
+
+// IsTOTPEnabled reports whether the user completed a TOTP enrollment.
+func (r *mfaRepo) IsTOTPEnabled(ctx context.Context, userID uuid.UUID) (bool, error) {
+	query := `
+		SELECT EXISTS(
+			SELECT 1 FROM user_totp WHERE user_id = $1 AND enabled_at IS NOT NULL
+		)
+	`
+	var enabled bool
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(&enabled)
+
+	return enabled, err
+}
+
+// EnableTOTP enables a pending enrollment and replaces the user's recovery codes in one transaction.
+func (r *mfaRepo) EnableTOTP(ctx context.Context, userID uuid.UUID, step int64, recoveryCodeHashes []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE user_totp
+		SET enabled_at = NOW(), last_used_step = $2
+		WHERE user_id = $1 AND enabled_at IS NULL
+	`, userID, step)
+	if err != nil {
+		return err
+	}
+
+	affectedRows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affectedRows == 0 {
+		return fmt.Errorf("no pending totp enrollment")
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	for _, codeHash := range recoveryCodeHashes {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO mfa_recovery_codes (user_id, code_hash)
+			VALUES ($1, $2)
+		`, userID, codeHash); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UseTOTPStep records the time step of an accepted code. It fails for the step that was already
+// used or an older one, which makes every code single use.
+func (r *mfaRepo) UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) error {
+	query := `
+		UPDATE user_totp
+		SET last_used_step = $2
+		WHERE user_id = $1 AND enabled_at IS NOT NULL AND last_used_step < $2
+	`
+	result, err := r.db.ExecContext(ctx, query, userID, step)
+	if err != nil {
+		return err
+	}
+
+	affectedRows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affectedRows == 0 {
+		return fmt.Errorf("totp code already used")
+	}
+
+	return nil
+}
+
+// UseRecoveryCode marks an unused recovery code of the user as used.
+func (r *mfaRepo) UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) error {
+	query := `
+		UPDATE mfa_recovery_codes
+		SET used_at = NOW()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`
+	result, err := r.db.ExecContext(ctx, query, userID, codeHash)
+	if err != nil {
+		return err
+	}
+
+	affectedRows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affectedRows == 0 {
+		return fmt.Errorf("recovery code not found")
+	}
+
+	return nil
+}
+
+// DeleteTOTP removes the TOTP enrollment and the recovery codes of the user.
+func (r *mfaRepo) DeleteTOTP(ctx context.Context, userID uuid.UUID) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_totp WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ClaimMFAChallenge claims the challenge for checking one code. It reports false while another
+// code is being checked against it, once it was used and once maxFailures codes were wrong, so
+// concurrent submissions cannot both use one challenge.
+func (r *mfaRepo) ClaimMFAChallenge(ctx context.Context, tokenID string, userID uuid.UUID, expiresAt time.Time, maxFailures int) (bool, error) {
+	query := `
+		INSERT INTO mfa_challenges (token_id, user_id, claimed_at, expires_at)
+		VALUES ($1, $2, NOW(), $3)
+		ON CONFLICT (token_id) DO UPDATE
+		SET claimed_at = NOW()
+		WHERE mfa_challenges.claimed_at IS NULL AND mfa_challenges.failures < $4
+		RETURNING token_id
+	`
+
+	var claimed string
+	err := r.db.QueryRowContext(ctx, query, tokenID, userID, expiresAt, maxFailures).Scan(&claimed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to claim mfa challenge: %w", err)
+	}
+
+	return true, nil
+}
+
+// ReleaseMFAChallenge counts a wrong code against a claimed challenge and releases it for the
+// next attempt. It returns the number of wrong codes so far.
+func (r *mfaRepo) ReleaseMFAChallenge(ctx context.Context, tokenID string) (int, error) {
+	query := `
+		UPDATE mfa_challenges
+		SET failures = failures + 1, claimed_at = NULL
+		WHERE token_id = $1
+		RETURNING failures
+	`
+
+	var failures int
+	if err := r.db.QueryRowContext(ctx, query, tokenID).Scan(&failures); err != nil {
+		return 0, fmt.Errorf("failed to release mfa challenge: %w", err)
+	}
+
+	return failures, nil
+}
+
+// CountExpiredMFAChallenges counts the challenges that expired before the cutoff.
+func (r *mfaRepo) CountExpiredMFAChallenges(ctx context.Context, cutoff time.Time) (int64, error) {
+	var count int64
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM mfa_challenges WHERE expires_at < $1`, cutoff).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count expired mfa challenges: %w", err)
+	}
+
+	return count, nil
+}
+
+// DeleteExpiredMFAChallenges deletes up to limit challenges that expired before the cutoff.
+func (r *mfaRepo) DeleteExpiredMFAChallenges(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	query := `
+		DELETE FROM mfa_challenges
+		WHERE token_id IN (
+			SELECT token_id FROM mfa_challenges
+			WHERE expires_at < $1
+			LIMIT $2
+		)
+	`
+
+	result, err := r.db.ExecContext(ctx, query, cutoff, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired mfa challenges: %w", err)
+	}
+
+	return result.RowsAffected()
+}
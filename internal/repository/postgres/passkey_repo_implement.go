@@ -0,0 +1,218 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/repository"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type passkeyRepo struct {
+	db *sql.DB
+}
+
+func NewPasskeyRepository(db *sql.DB) repository.PasskeyRepository {
+	return &passkeyRepo{db: db}
+}
+
+// CreatePasskey stores a credential that finished its registration ceremony.
+func (r *passkeyRepo) CreatePasskey(ctx context.Context, passkey *models.Passkey) error {
+	query := `
+		INSERT INTO passkeys (user_id, credential_id, public_key, sign_count, aaguid, transports, flags, attestation_type, name)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query,
+		passkey.UserID,
+		passkey.CredentialID,
+		passkey.PublicKey,
+		int64(passkey.SignCount),
+		passkey.AAGUID,
+		pq.Array(passkey.Transports),
+		int16(passkey.Flags),
+		passkey.AttestationType,
+		passkey.Name,
+	).Scan(&passkey.ID, &passkey.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create passkey: %w", err)
+	}
+
+	return nil
+}
+
+// ListUserPasskeys returns every passkey of the user, oldest first.
+func (r *passkeyRepo) ListUserPasskeys(ctx context.Context, userID uuid.UUID) ([]*models.Passkey, error) {
+	query := `
+		SELECT id, user_id, credential_id, public_key, sign_count, aaguid, transports, flags,
+			attestation_type, name, last_used_at, created_at
+		FROM passkeys
+		WHERE user_id = $1
+		ORDER BY created_at
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list passkeys: %w", err)
+	}
+	defer rows.Close()
+
+	var passkeys []*models.Passkey
+	for rows.Next() {
+		var (
+			passkey   models.Passkey
+			signCount int64
+			flags     int16
+		)
+		if err := rows.Scan(
+			&passkey.ID,
+			&passkey.UserID,
+			&passkey.CredentialID,
+			&passkey.PublicKey,
+			&signCount,
+			&passkey.AAGUID,
+			pq.Array(&passkey.Transports),
+			&flags,
+			&passkey.AttestationType,
+			&passkey.Name,
+			&passkey.LastUsedAt,
+			&passkey.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		passkey.SignCount = uint32(signCount)
+		passkey.Flags = uint8(flags)
+		passkeys = append(passkeys, &passkey)
+	}
+
+	return passkeys, rows.Err()
+}
+
+// UpdatePasskeyUsage records the signature counter and flags of a successful login.
+func (r *passkeyRepo) UpdatePasskeyUsage(ctx context.Context, id uuid.UUID, signCount uint32, flags uint8) error {
+	query := `
+		UPDATE passkeys
+		SET sign_count = $2, flags = $3, last_used_at = NOW()
+		WHERE id = $1
+	`
+	result, err := r.db.ExecContext(ctx, query, id, int64(signCount), int16(flags))
+	if err != nil {
+		return err
+	}
+
+	affectedRows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affectedRows == 0 {
+		return fmt.Errorf("passkey not found")
+	}
+
+	return nil
+}
+
+// DeleteUserPasskey removes a passkey, as long as it belongs to the given user.
+func (r *passkeyRepo) DeleteUserPasskey(ctx context.Context, userID, id uuid.UUID) error {
+	query := `DELETE FROM passkeys WHERE id = $1 AND user_id = $2`
+
+	result, err := r.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	affectedRows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affectedRows == 0 {
+		return fmt.Errorf("passkey not found")
+	}
+
+	return nil
+}
+
+// CreateCeremony stores the relying party state of a ceremony that was just started.
+func (r *passkeyRepo) CreateCeremony(ctx context.Context, ceremony *models.WebAuthnCeremony) error {
+	query := `
+		INSERT INTO webauthn_ceremonies (user_id, kind, session_data, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query,
+		ceremony.UserID,
+		ceremony.Kind,
+		ceremony.SessionData,
+		ceremony.ExpiresAt,
+	).Scan(&ceremony.ID, &ceremony.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create webauthn ceremony: %w", err)
+	}
+
+	return nil
+}
+
+// ConsumeCeremony deletes an unexpired ceremony of the given kind and returns it, so that
+// every challenge can only be answered once.
+func (r *passkeyRepo) ConsumeCeremony(ctx context.Context, id uuid.UUID, kind string) (*models.WebAuthnCeremony, error) {
+	query := `
+		DELETE FROM webauthn_ceremonies
+		WHERE id = $1 AND kind = $2 AND expires_at > NOW()
+		RETURNING id, user_id, kind, session_data, expires_at, created_at
+	`
+
+	var ceremony models.WebAuthnCeremony
+	err := r.db.QueryRowContext(ctx, query, id, kind).Scan(
+		&ceremony.ID,
+		&ceremony.UserID,
+		&ceremony.Kind,
+		&ceremony.SessionData,
+		&ceremony.ExpiresAt,
+		&ceremony.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("webauthn ceremony not found or expired")
+		}
+		return nil, err
+	}
+
+	return &ceremony, nil
+}
+
+// CountExpiredCeremonies counts the ceremonies that expired before the cutoff without being finished.
+func (r *passkeyRepo) CountExpiredCeremonies(ctx context.Context, cutoff time.Time) (int64, error) {
+	var count int64
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM webauthn_ceremonies WHERE expires_at < $1`, cutoff).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count expired webauthn ceremonies: %w", err)
+	}
+
+	return count, nil
+}
+
+// DeleteExpiredCeremonies deletes up to limit ceremonies that expired before the cutoff.
+func (r *passkeyRepo) DeleteExpiredCeremonies(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	query := `
+		DELETE FROM webauthn_ceremonies
+		WHERE id IN (
+			SELECT id FROM webauthn_ceremonies
+			WHERE expires_at < $1
+			LIMIT $2
+		)
+	`
+
+	result, err := r.db.ExecContext(ctx, query, cutoff, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired webauthn ceremonies: %w", err)
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,97 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/repository"
+	"github.com/google/uuid"
+)
+
+type passwordResetRepo struct {
+	db *sql.DB
+}
+
+func NewPasswordResetRepository(db *sql.DB) repository.PasswordResetRepository {
+	return &passwordResetRepo{db: db}
+}
+
+// CreatePasswordReset stores a new reset token hash for the user.
+func (r *passwordResetRepo) CreatePasswordReset(ctx context.Context, reset *models.PasswordReset) error {
+	query := `
+		INSERT INTO password_resets (token, user_id, expired_at)
+		VALUES ($1, $2, $3)
+		RETURNING update_time
+	`
+
+	err := r.db.QueryRowContext(ctx, query, reset.TokenHash, reset.UserID, reset.ExpiredAt).Scan(&reset.UpdateTime)
+	if err != nil {
+		return fmt.Errorf("failed to create password reset: %w", err)
+	}
+
+	return nil
+}
+
+// ConsumePasswordReset deletes an unexpired reset token and returns the user it belongs to.
+// Deleting the row is what makes every token single use.
+func (r *passwordResetRepo) ConsumePasswordReset(ctx context.Context, tokenHash string) (uuid.UUID, error) {
+	query := `
+		DELETE FROM password_resets
+		WHERE token = $1 AND expired_at > NOW()
+		RETURNING user_id
+	`
+
+	var userID uuid.UUID
+	err := r.db.QueryRowContext(ctx, query, tokenHash).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return uuid.Nil, fmt.Errorf("password reset not found or expired")
+		}
+		return uuid.Nil, err
+	}
+
+	return userID, nil
+}
+
+// DeleteUserPasswordResets removes every outstanding reset token of the user.
+func (r *passwordResetRepo) DeleteUserPasswordResets(ctx context.Context, userID uuid.UUID) error {
+	query := `
+		DELETE FROM password_resets
+		WHERE user_id = $1
+	`
+	_, err := r.db.ExecContext(ctx, query, userID)
+	return err
+}
+
+// CountExpiredPasswordResets counts the reset tokens that expired before the cutoff.
+func (r *passwordResetRepo) CountExpiredPasswordResets(ctx context.Context, cutoff time.Time) (int64, error) {
+	var count int64
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM password_resets WHERE expired_at < $1`, cutoff).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count expired password resets: %w", err)
+	}
+
+	return count, nil
+}
+
+// DeleteExpiredPasswordResets deletes up to limit reset tokens that expired before the cutoff.
+func (r *passwordResetRepo) DeleteExpiredPasswordResets(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	query := `
+		DELETE FROM password_resets
+		WHERE token IN (
+			SELECT token FROM password_resets
+			WHERE expired_at < $1
+			LIMIT $2
+		)
+	`
+
+	result, err := r.db.ExecContext(ctx, query, cutoff, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired password resets: %w", err)
+	}
+
+	return result.RowsAffected()
+}
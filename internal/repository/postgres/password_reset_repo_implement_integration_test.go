@@ -0,0 +1,36 @@
+package postgres_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/repository/postgres"
+	"github.com/stretchr/testify/require"
+
+	authpb "github.com/Nucleussss/hikayat-proto/gen/go/auth/v1"
+)
+
+func TestDeleteUserWithPasswordResets(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	users := postgres.NewUserRepository(db)
+	resets := postgres.NewPasswordResetRepository(db)
+	userID := createTestUser(t, db, "reset@example.com")
+
+	// a pending reset and one that expired but was not purged yet
+	require.NoError(t, resets.CreatePasswordReset(ctx, &models.PasswordReset{TokenHash: "pending", UserID: userID, ExpiredAt: time.Now().Add(time.Hour)}))
+	require.NoError(t, resets.CreatePasswordReset(ctx, &models.PasswordReset{TokenHash: "expired", UserID: userID, ExpiredAt: time.Now().Add(-time.Hour)}))
+
+	require.NoError(t, users.DeleteUser(ctx, &authpb.DeleteUserRequest{Id: userID.String()}))
+
+	var count int
+	require.NoError(t, db.QueryRowContext(ctx, `SELECT COUNT(*) FROM password_resets`).Scan(&count))
+	require.Zero(t, count)
+
+	_, err := resets.ConsumePasswordReset(ctx, "pending")
+	require.Error(t, err)
+}
@@ -0,0 +1,133 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/repository"
+	"github.com/google/uuid"
+)
+
+type permissionRepo struct {
+	db *sql.DB
+}
+
+func NewPermissionRepository(db *sql.DB) repository.PermissionRepository {
+	return &permissionRepo{db: db}
+}
+
+// ListPermissions returns every known permission ordered by name.
+func (r *permissionRepo) ListPermissions(ctx context.Context) ([]*models.Permission, error) {
+	query := `
+		SELECT id, permission_name, COALESCE(description, '')
+		FROM permissions
+		ORDER BY permission_name
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list permissions: %w", err)
+	}
+	defer rows.Close()
+
+	var permissions []*models.Permission
+	for rows.Next() {
+		var permission models.Permission
+		if err := rows.Scan(&permission.ID, &permission.Name, &permission.Description); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, &permission)
+	}
+
+	return permissions, rows.Err()
+}
+
+// GrantPermissionToRole adds a permission to a role and bumps the authorization version of the
+// role's users. Granting it twice is not an error.
+func (r *permissionRepo) GrantPermissionToRole(ctx context.Context, roleID uuid.UUID, permissionName string) error {
+	permissionID, err := r.findPermissionID(ctx, permissionName)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		WITH granted AS (
+			INSERT INTO role_permissions (role_id, permission_id)
+			VALUES ($1, $2)
+			ON CONFLICT DO NOTHING
+			RETURNING role_id
+		)
+		UPDATE users SET authz_version = authz_version + 1
+		WHERE id IN (SELECT ur.user_id FROM user_roles ur JOIN granted g ON g.role_id = ur.role_id)
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, roleID, permissionID); err != nil {
+		if isPQError(err, pqForeignKeyViolation) {
+			return fmt.Errorf("role not found")
+		}
+		return err
+	}
+
+	return nil
+}
+
+// RevokePermissionFromRole removes a permission from a role and bumps the authorization version
+// of the role's users.
+func (r *permissionRepo) RevokePermissionFromRole(ctx context.Context, roleID uuid.UUID, permissionName string) error {
+	query := `
+		WITH revoked AS (
+			DELETE FROM role_permissions
+			WHERE role_id = $1
+				AND permission_id = (SELECT id FROM permissions WHERE permission_name = $2)
+			RETURNING role_id
+		), bumped AS (
+			UPDATE users SET authz_version = authz_version + 1
+			WHERE id IN (SELECT ur.user_id FROM user_roles ur JOIN revoked rv ON rv.role_id = ur.role_id)
+		)
+		SELECT COUNT(*) FROM revoked
+	`
+
+	var revokedRows int
+	if err := r.db.QueryRowContext(ctx, query, roleID, permissionName).Scan(&revokedRows); err != nil {
+		return err
+	}
+
+	if revokedRows == 0 {
+		return fmt.Errorf("permission not granted to role")
+	}
+
+	return nil
+}
+
+func (r *permissionRepo) findPermissionID(ctx context.Context, permissionName string) (uuid.UUID, error) {
+	query := `SELECT id FROM permissions WHERE permission_name = $1`
+
+	var id uuid.UUID
+	if err := r.db.QueryRowContext(ctx, query, permissionName).Scan(&id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return uuid.Nil, fmt.Errorf("permission not found")
+		}
+		return uuid.Nil, err
+	}
+
+	return id, nil
+}
+
+// UserHasPermission reports whether any role assigned to the user grants the permission.
+func (r *permissionRepo) UserHasPermission(ctx context.Context, userID uuid.UUID, permissionName string) (bool, error) {
+	query := `
+		SELECT EXISTS(
+			SELECT 1 FROM user_roles ur
+			JOIN role_permissions rp ON rp.role_id = ur.role_id
+			JOIN permissions p ON p.id = rp.permission_id
+			WHERE ur.user_id = $1 AND p.permission_name = $2
+		)
+	`
+	var exists bool
+	err := r.db.QueryRowContext(ctx, query, userID, permissionName).Scan(&exists)
+
+	return exists, err
+}
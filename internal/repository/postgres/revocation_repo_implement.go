@@ -0,0 +1,72 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/repository"
+)
+
+type revocationRepo struct {
+	db *sql.DB
+}
+
+func NewRevocationRepository(db *sql.DB) repository.RevocationRepository {
+	return &revocationRepo{db: db}
+}
+
+// Revoke stores a revocation. Revoking the same subject again moves its revocation time
+// forward and keeps the latest expiry.
+func (r *revocationRepo) Revoke(ctx context.Context, revocation *models.TokenRevocation) error {
+	query := `
+		INSERT INTO token_revocations (subject_type, subject_id, expires_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (subject_type, subject_id) DO UPDATE
+		SET revoked_at = NOW(),
+			expires_at = GREATEST(token_revocations.expires_at, EXCLUDED.expires_at)
+		RETURNING revoked_at, expires_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query, revocation.SubjectType, revocation.SubjectID, revocation.ExpiresAt).Scan(
+		&revocation.RevokedAt,
+		&revocation.ExpiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to store revocation: %w", err)
+	}
+
+	return nil
+}
+
+// ListActiveRevocations returns every revocation that has not expired yet.
+func (r *revocationRepo) ListActiveRevocations(ctx context.Context) ([]*models.TokenRevocation, error) {
+	query := `
+		SELECT subject_type, subject_id, revoked_at, expires_at
+		FROM token_revocations
+		WHERE expires_at > NOW()
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list revocations: %w", err)
+	}
+	defer rows.Close()
+
+	var revocations []*models.TokenRevocation
+	for rows.Next() {
+		var revocation models.TokenRevocation
+		if err := rows.Scan(
+			&revocation.SubjectType,
+			&revocation.SubjectID,
+			&revocation.RevokedAt,
+			&revocation.ExpiresAt,
+		); err != nil {
+			return nil, err
+		}
+		revocations = append(revocations, &revocation)
+	}
+
+	return revocations, rows.Err()
+}
@@ -0,0 +1,279 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/repository"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Postgres error codes the role and permission repositories translate.
+const (
+	pqUniqueViolation     = "23505"
+	pqForeignKeyViolation = "23503"
+)
+
+type roleRepo struct {
+	db *sql.DB
+}
+
+func NewRoleRepository(db *sql.DB) repository.RoleRepository {
+	return &roleRepo{db: db}
+}
+
+// CreateRole inserts a role without permissions.
+func (r *roleRepo) CreateRole(ctx context.Context, name string) (*models.Role, error) {
+	query := `
+		INSERT INTO roles (role_name)
+		VALUES ($1)
+		RETURNING id, role_name
+	`
+
+	var role models.Role
+	err := r.db.QueryRowContext(ctx, query, name).Scan(&role.ID, &role.Name)
+	if err != nil {
+		if isPQError(err, pqUniqueViolation) {
+			return nil, repository.ErrRoleAlreadyExists
+		}
+		return nil, fmt.Errorf("failed to create role: %w", err)
+	}
+
+	return &role, nil
+}
+
+// DeleteRole removes a role. Its permission grants and user assignments cascade, and the
+// authorization version of every user that held it is bumped.
+func (r *roleRepo) DeleteRole(ctx context.Context, id uuid.UUID) error {
+	query := `
+		WITH holders AS (
+			UPDATE users SET authz_version = authz_version + 1
+			WHERE id IN (SELECT user_id FROM user_roles WHERE role_id = $1)
+		)
+		DELETE FROM roles WHERE id = $1
+	`
+
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	affectedRows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affectedRows == 0 {
+		return repository.ErrRoleNotFound
+	}
+
+	return nil
+}
+
+// FindRoleByID returns a role together with the names of its permissions.
+func (r *roleRepo) FindRoleByID(ctx context.Context, id uuid.UUID) (*models.Role, error) {
+	query := `
+		SELECT r.id, r.role_name,
+			COALESCE(array_agg(p.permission_name ORDER BY p.permission_name) FILTER (WHERE p.id IS NOT NULL), '{}')
+		FROM roles r
+		LEFT JOIN role_permissions rp ON rp.role_id = r.id
+		LEFT JOIN permissions p ON p.id = rp.permission_id
+		WHERE r.id = $1
+		GROUP BY r.id, r.role_name
+	`
+
+	return r.queryRole(ctx, query, id)
+}
+
+// FindRoleByName returns a role together with the names of its permissions.
+func (r *roleRepo) FindRoleByName(ctx context.Context, name string) (*models.Role, error) {
+	query := `
+		SELECT r.id, r.role_name,
+			COALESCE(array_agg(p.permission_name ORDER BY p.permission_name) FILTER (WHERE p.id IS NOT NULL), '{}')
+		FROM roles r
+		LEFT JOIN role_permissions rp ON rp.role_id = r.id
+		LEFT JOIN permissions p ON p.id = rp.permission_id
+		WHERE r.role_name = $1
+		GROUP BY r.id, r.role_name
+	`
+
+	return r.queryRole(ctx, query, name)
+}
+
+// ListRoles returns every role together with the names of its permissions.
+func (r *roleRepo) ListRoles(ctx context.Context) ([]*models.Role, error) {
+	query := `
+		SELECT r.id, r.role_name,
+			COALESCE(array_agg(p.permission_name ORDER BY p.permission_name) FILTER (WHERE p.id IS NOT NULL), '{}')
+		FROM roles r
+		LEFT JOIN role_permissions rp ON rp.role_id = r.id
+		LEFT JOIN permissions p ON p.id = rp.permission_id
+		GROUP BY r.id, r.role_name
+		ORDER BY r.role_name
+	`
+
+	return r.queryRoles(ctx, query)
+}
+
+// AssignRoleToUser gives the user a role and bumps their authorization version. Assigning a
+// role twice is not an error.
+func (r *roleRepo) AssignRoleToUser(ctx context.Context, userID, roleID uuid.UUID) error {
+	query := `
+		WITH assigned AS (
+			INSERT INTO user_roles (user_id, role_id)
+			VALUES ($1, $2)
+			ON CONFLICT DO NOTHING
+			RETURNING user_id
+		)
+		UPDATE users SET authz_version = authz_version + 1
+		WHERE id IN (SELECT user_id FROM assigned)
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, userID, roleID); err != nil {
+		if isPQError(err, pqForeignKeyViolation) {
+			return fmt.Errorf("user or role not found")
+		}
+		return err
+	}
+
+	return nil
+}
+
+// RemoveRoleFromUser takes a role away from the user and bumps their authorization version.
+func (r *roleRepo) RemoveRoleFromUser(ctx context.Context, userID, roleID uuid.UUID) error {
+	query := `
+		WITH removed AS (
+			DELETE FROM user_roles WHERE user_id = $1 AND role_id = $2
+			RETURNING user_id
+		)
+		UPDATE users SET authz_version = authz_version + 1
+		WHERE id IN (SELECT user_id FROM removed)
+	`
+
+	result, err := r.db.ExecContext(ctx, query, userID, roleID)
+	if err != nil {
+		return err
+	}
+
+	affectedRows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affectedRows == 0 {
+		return fmt.Errorf("role not assigned to user")
+	}
+
+	return nil
+}
+
+// ListUserRoles returns the roles assigned to the user together with the names of their permissions.
+func (r *roleRepo) ListUserRoles(ctx context.Context, userID uuid.UUID) ([]*models.Role, error) {
+	query := `
+		SELECT r.id, r.role_name,
+			COALESCE(array_agg(p.permission_name ORDER BY p.permission_name) FILTER (WHERE p.id IS NOT NULL), '{}')
+		FROM user_roles ur
+		JOIN roles r ON r.id = ur.role_id
+		LEFT JOIN role_permissions rp ON rp.role_id = r.id
+		LEFT JOIN permissions p ON p.id = rp.permission_id
+		WHERE ur.user_id = $1
+		GROUP BY r.id, r.role_name
+		ORDER BY r.role_name
+	`
+
+	return r.queryRoles(ctx, query, userID)
+}
+
+// UserHasRole reports whether the user was assigned the role with the given name.
+func (r *roleRepo) UserHasRole(ctx context.Context, userID uuid.UUID, roleName string) (bool, error) {
+	query := `
+		SELECT EXISTS(
+			SELECT 1 FROM user_roles ur
+			JOIN roles r ON r.id = ur.role_id
+			WHERE ur.user_id = $1 AND r.role_name = $2
+		)
+	`
+	var exists bool
+	err := r.db.QueryRowContext(ctx, query, userID, roleName).Scan(&exists)
+
+	return exists, err
+}
+
+// FindUserAuthorization returns the names of the user's roles, the distinct permissions they grant
+// and the user's authorization version.
+func (r *roleRepo) FindUserAuthorization(ctx context.Context, userID uuid.UUID) (*models.UserAuthorization, error) {
+	query := `
+		SELECT u.authz_version,
+			ARRAY(
+				SELECT r.role_name FROM user_roles ur
+				JOIN roles r ON r.id = ur.role_id
+				WHERE ur.user_id = u.id
+				ORDER BY r.role_name
+			),
+			ARRAY(
+				SELECT DISTINCT p.permission_name FROM user_roles ur
+				JOIN role_permissions rp ON rp.role_id = ur.role_id
+				JOIN permissions p ON p.id = rp.permission_id
+				WHERE ur.user_id = u.id
+				ORDER BY p.permission_name
+			)
+		FROM users u
+		WHERE u.id = $1
+	`
+
+	var authz models.UserAuthorization
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(
+		&authz.Version,
+		pq.Array(&authz.Roles),
+		pq.Array(&authz.Permissions),
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("user not found")
+		}
+		return nil, fmt.Errorf("failed to find user authorization: %w", err)
+	}
+
+	return &authz, nil
+}
+
+func (r *roleRepo) queryRoles(ctx context.Context, query string, args ...any) ([]*models.Role, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list roles: %w", err)
+	}
+	defer rows.Close()
+
+	var roles []*models.Role
+	for rows.Next() {
+		var role models.Role
+		if err := rows.Scan(&role.ID, &role.Name, pq.Array(&role.Permissions)); err != nil {
+			return nil, err
+		}
+		roles = append(roles, &role)
+	}
+
+	return roles, rows.Err()
+}
+
+func (r *roleRepo) queryRole(ctx context.Context, query string, args ...any) (*models.Role, error) {
+	roles, err := r.queryRoles(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(roles) == 0 {
+		return nil, repository.ErrRoleNotFound
+	}
+
+	return roles[0], nil
+}
+
+func isPQError(err error, code pq.ErrorCode) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == code
+}
@@ -0,0 +1,106 @@
+package postgres_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/repository"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/repository/postgres"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	authpb "github.com/Nucleussss/hikayat-proto/gen/go/auth/v1"
+)
+
+// createTestUser registers a user and returns their id.
+func createTestUser(t *testing.T, db *sql.DB, email string) uuid.UUID {
+	t.Helper()
+
+	ctx := context.Background()
+	users := postgres.NewUserRepository(db)
+
+	err := users.CreateNewUser(ctx, &authpb.RegisterRequest{Name: "test", Email: email, Password: "hash"})
+	require.NoError(t, err)
+
+	user, err := users.FindUserByEmail(ctx, email)
+	require.NoError(t, err)
+
+	return uuid.MustParse(user.Id)
+}
+
+func TestRoleRepository(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	roles := postgres.NewRoleRepository(db)
+	permissions := postgres.NewPermissionRepository(db)
+	userID := createTestUser(t, db, "roles@example.com")
+
+	// the migrations seed the admin role with every permission
+	admin, err := roles.FindRoleByName(ctx, models.RoleAdmin)
+	require.NoError(t, err)
+	require.Contains(t, admin.Permissions, "roles.manage")
+
+	_, err = roles.FindRoleByName(ctx, "missing")
+	require.ErrorIs(t, err, repository.ErrRoleNotFound)
+
+	editor, err := roles.CreateRole(ctx, "editor")
+	require.NoError(t, err)
+
+	_, err = roles.CreateRole(ctx, "editor")
+	require.ErrorIs(t, err, repository.ErrRoleAlreadyExists)
+
+	// grants and assignments bump the user's authorization version
+	before, err := roles.FindUserAuthorization(ctx, userID)
+	require.NoError(t, err)
+
+	require.NoError(t, roles.AssignRoleToUser(ctx, userID, editor.ID))
+	require.NoError(t, roles.AssignRoleToUser(ctx, userID, editor.ID))
+	require.NoError(t, permissions.GrantPermissionToRole(ctx, editor.ID, "posts.moderate"))
+	require.Error(t, permissions.GrantPermissionToRole(ctx, editor.ID, "missing.permission"))
+
+	after, err := roles.FindUserAuthorization(ctx, userID)
+	require.NoError(t, err)
+	require.Equal(t, []string{"editor"}, after.Roles)
+	require.Equal(t, []string{"posts.moderate"}, after.Permissions)
+	require.Equal(t, before.Version+2, after.Version)
+
+	found, err := roles.FindRoleByID(ctx, editor.ID)
+	require.NoError(t, err)
+	require.Equal(t, []string{"posts.moderate"}, found.Permissions)
+
+	hasRole, err := roles.UserHasRole(ctx, userID, "editor")
+	require.NoError(t, err)
+	require.True(t, hasRole)
+
+	hasPermission, err := permissions.UserHasPermission(ctx, userID, "posts.moderate")
+	require.NoError(t, err)
+	require.True(t, hasPermission)
+
+	// revoking and removing take it back, twice is an error
+	require.NoError(t, permissions.RevokePermissionFromRole(ctx, editor.ID, "posts.moderate"))
+	require.Error(t, permissions.RevokePermissionFromRole(ctx, editor.ID, "posts.moderate"))
+
+	require.NoError(t, roles.RemoveRoleFromUser(ctx, userID, editor.ID))
+	require.Error(t, roles.RemoveRoleFromUser(ctx, userID, editor.ID))
+
+	userRoles, err := roles.ListUserRoles(ctx, userID)
+	require.NoError(t, err)
+	require.Empty(t, userRoles)
+
+	// deleting a role takes it away from its holders
+	require.NoError(t, roles.AssignRoleToUser(ctx, userID, editor.ID))
+	require.NoError(t, roles.DeleteRole(ctx, editor.ID))
+	require.ErrorIs(t, roles.DeleteRole(ctx, editor.ID), repository.ErrRoleNotFound)
+
+	hasRole, err = roles.UserHasRole(ctx, userID, "editor")
+	require.NoError(t, err)
+	require.False(t, hasRole)
+
+	all, err := roles.ListRoles(ctx)
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+}
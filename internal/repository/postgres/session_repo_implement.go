@@ -0,0 +1,280 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/repository"
+	"github.com/google/uuid"
+)
+
+type sessionRepo struct {
+	db *sql.DB
+}
+
+func NewSessionRepository(db *sql.DB) repository.SessionRepository {
+	return &sessionRepo{db: db}
+}
+
+// CreateSession stores a new refresh token generation. When the family id is empty
+// the session starts a new family.
+func (r *sessionRepo) CreateSession(ctx context.Context, session *models.Session) error {
+	query := `
+		INSERT INTO sessions (user_id, family_id, session_token, user_agent, ip_address, authz_version, expires_at)
+		VALUES ($1, COALESCE($2, gen_random_uuid()), $3, $4, $5, $6, $7)
+		RETURNING id, family_id, created_at, last_seen_at
+	`
+
+	var familyID *uuid.UUID
+	if session.FamilyID != uuid.Nil {
+		familyID = &session.FamilyID
+	}
+
+	err := r.db.QueryRowContext(ctx, query,
+		session.UserID,
+		familyID,
+		session.TokenHash,
+		session.UserAgent,
+		session.IPAddress,
+		session.AuthzVersion,
+		session.ExpiresAt,
+	).Scan(
+		&session.ID,
+		&session.FamilyID,
+		&session.CreatedAt,
+		&session.LastSeenAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+
+	return nil
+}
+
+// FindSessionByTokenHash returns the session owning the given refresh token hash,
+// including sessions that were already rotated or revoked.
+func (r *sessionRepo) FindSessionByTokenHash(ctx context.Context, tokenHash string) (*models.Session, error) {
+	query := `
+		SELECT id, user_id, family_id, session_token, user_agent, ip_address, authz_version,
+			expires_at, created_at, last_seen_at, rotated_at, revoked_at
+		FROM sessions
+		WHERE session_token = $1
+	`
+
+	var session models.Session
+	err := r.db.QueryRowContext(ctx, query, tokenHash).Scan(
+		&session.ID,
+		&session.UserID,
+		&session.FamilyID,
+		&session.TokenHash,
+		&session.UserAgent,
+		&session.IPAddress,
+		&session.AuthzVersion,
+		&session.ExpiresAt,
+		&session.CreatedAt,
+		&session.LastSeenAt,
+		&session.RotatedAt,
+		&session.RevokedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("session not found")
+		}
+		return nil, fmt.Errorf("failed to find session: %w", err)
+	}
+
+	return &session, nil
+}
+
+// RotateSession marks the current session as rotated and inserts its successor in one
+// transaction. It fails when the current session was already rotated or revoked, so two
+// concurrent refreshes with the same token cannot both succeed.
+func (r *sessionRepo) RotateSession(ctx context.Context, currentID uuid.UUID, next *models.Session) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE sessions
+		SET rotated_at = NOW()
+		WHERE id = $1 AND rotated_at IS NULL AND revoked_at IS NULL
+	`, currentID)
+	if err != nil {
+		return err
+	}
+
+	affectedRows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affectedRows == 0 {
+		return repository.ErrSessionAlreadyRotated
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO sessions (user_id, family_id, session_token, user_agent, ip_address, authz_version, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at, last_seen_at
+	`, next.UserID, next.FamilyID, next.TokenHash, next.UserAgent, next.IPAddress, next.AuthzVersion, next.ExpiresAt).Scan(
+		&next.ID,
+		&next.CreatedAt,
+		&next.LastSeenAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create rotated session: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// RevokeSessionFamily revokes every refresh token generation of a session family.
+func (r *sessionRepo) RevokeSessionFamily(ctx context.Context, familyID uuid.UUID) error {
+	query := `
+		UPDATE sessions
+		SET revoked_at = NOW()
+		WHERE family_id = $1 AND revoked_at IS NULL
+	`
+	_, err := r.db.ExecContext(ctx, query, familyID)
+	return err
+}
+
+// IsSessionFamilyActive reports whether the family still has a live generation, one that was
+// neither rotated nor revoked and has not expired.
+func (r *sessionRepo) IsSessionFamilyActive(ctx context.Context, familyID uuid.UUID) (bool, error) {
+	query := `
+		SELECT EXISTS(
+			SELECT 1 FROM sessions
+			WHERE family_id = $1 AND rotated_at IS NULL AND revoked_at IS NULL AND expires_at > NOW()
+		)
+	`
+	var active bool
+	err := r.db.QueryRowContext(ctx, query, familyID).Scan(&active)
+
+	return active, err
+}
+
+// RevokeUserSessions revokes every session of a user.
+func (r *sessionRepo) RevokeUserSessions(ctx context.Context, userID uuid.UUID) error {
+	query := `
+		UPDATE sessions
+		SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`
+	_, err := r.db.ExecContext(ctx, query, userID)
+	return err
+}
+
+// RevokeUserSession revokes one session family of a user. It fails when the family does not
+// belong to the user or holds no live session.
+func (r *sessionRepo) RevokeUserSession(ctx context.Context, userID uuid.UUID, familyID uuid.UUID) error {
+	query := `
+		UPDATE sessions
+		SET revoked_at = NOW()
+		WHERE user_id = $1 AND family_id = $2 AND revoked_at IS NULL
+	`
+	result, err := r.db.ExecContext(ctx, query, userID, familyID)
+	if err != nil {
+		return err
+	}
+
+	affectedRows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affectedRows == 0 {
+		return fmt.Errorf("session not found")
+	}
+
+	return nil
+}
+
+// ListActiveSessions returns the live generation of every session family of a user.
+// CreatedAt holds the time the family was opened by its login, not the last rotation.
+func (r *sessionRepo) ListActiveSessions(ctx context.Context, userID uuid.UUID) ([]*models.Session, error) {
+	query := `
+		SELECT s.id, s.user_id, s.family_id, s.session_token, s.user_agent, s.ip_address, s.expires_at,
+			(SELECT MIN(f.created_at) FROM sessions f WHERE f.family_id = s.family_id),
+			s.last_seen_at
+		FROM sessions s
+		WHERE s.user_id = $1
+			AND s.rotated_at IS NULL
+			AND s.revoked_at IS NULL
+			AND s.expires_at > NOW()
+		ORDER BY s.last_seen_at DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []*models.Session
+	for rows.Next() {
+		var session models.Session
+		if err := rows.Scan(
+			&session.ID,
+			&session.UserID,
+			&session.FamilyID,
+			&session.TokenHash,
+			&session.UserAgent,
+			&session.IPAddress,
+			&session.ExpiresAt,
+			&session.CreatedAt,
+			&session.LastSeenAt,
+		); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, &session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// finishedFamilies selects the session families in which every generation expired or was revoked
+// before the cutoff. Families are purged as a whole: a rotated generation has to stay as long as
+// its family lives, presenting it again is how refresh token reuse is detected.
+const finishedFamilies = `
+	SELECT family_id FROM sessions
+	GROUP BY family_id
+	HAVING bool_and(expires_at < $1 OR COALESCE(revoked_at < $1, FALSE))
+`
+
+// CountPurgeableSessions counts the session rows PurgeSessions would delete.
+func (r *sessionRepo) CountPurgeableSessions(ctx context.Context, cutoff time.Time) (int64, error) {
+	query := `
+		SELECT COUNT(*) FROM sessions
+		WHERE family_id IN (` + finishedFamilies + `)
+	`
+
+	var count int64
+	if err := r.db.QueryRowContext(ctx, query, cutoff).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count purgeable sessions: %w", err)
+	}
+
+	return count, nil
+}
+
+// PurgeSessions deletes up to limit finished session families and returns the number of rows deleted.
+func (r *sessionRepo) PurgeSessions(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	query := `
+		DELETE FROM sessions
+		WHERE family_id IN (` + finishedFamilies + ` LIMIT $2)
+	`
+
+	result, err := r.db.ExecContext(ctx, query, cutoff, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge sessions: %w", err)
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,81 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/repository"
+)
+
+type signingKeyRepo struct {
+	db *sql.DB
+}
+
+func NewSigningKeyRepository(db *sql.DB) repository.SigningKeyRepository {
+	return &signingKeyRepo{db: db}
+}
+
+// ListSigningKeys returns every stored signing key, the most recently activated first.
+func (r *signingKeyRepo) ListSigningKeys(ctx context.Context) ([]*models.SigningKey, error) {
+	query := `
+		SELECT kid, algorithm, sealed_key, activates_at, retires_at, created_at
+		FROM signing_keys
+		ORDER BY activates_at DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list signing keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []*models.SigningKey
+	for rows.Next() {
+		var key models.SigningKey
+		if err := rows.Scan(
+			&key.ID,
+			&key.Algorithm,
+			&key.SealedKey,
+			&key.ActivatesAt,
+			&key.RetiresAt,
+			&key.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		keys = append(keys, &key)
+	}
+
+	return keys, rows.Err()
+}
+
+// RotateSigningKey stores the next signing key and schedules the retirement of every key without
+// a retirement time in one transaction.
+func (r *signingKeyRepo) RotateSigningKey(ctx context.Context, next *models.SigningKey, retireAt time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE signing_keys
+		SET retires_at = $1
+		WHERE retires_at IS NULL
+	`, retireAt); err != nil {
+		return fmt.Errorf("failed to retire signing keys: %w", err)
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO signing_keys (kid, algorithm, sealed_key, activates_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING created_at
+	`, next.ID, next.Algorithm, next.SealedKey, next.ActivatesAt).Scan(&next.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to store signing key: %w", err)
+	}
+
+	return tx.Commit()
+}
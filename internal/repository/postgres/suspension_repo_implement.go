@@ -0,0 +1,197 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/repository"
+	"github.com/google/uuid"
+)
+
+// suspensionColumns are the columns scanSuspension reads, in order.
+const suspensionColumns = `id, user_id, reason, suspended_by, created_at, expires_at, lifted_at, lifted_by, COALESCE(lift_reason, '')`
+
+// supersededReason is the lift reason of a suspension replaced by a newer one.
+const supersededReason = "superseded"
+
+type suspensionRepo struct {
+	db *sql.DB
+}
+
+func NewSuspensionRepository(db *sql.DB) repository.SuspensionRepository {
+	return &suspensionRepo{db: db}
+}
+
+// SuspendUser stores the suspension and deactivates the user in one transaction. A suspension
+// that was not lifted yet is lifted as superseded, so the new one replaces it.
+func (r *suspensionRepo) SuspendUser(ctx context.Context, suspension *models.UserSuspension) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE users SET is_active = FALSE, updated_at = NOW() WHERE id = $1
+	`, suspension.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to deactivate user: %w", err)
+	}
+
+	affectedRows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affectedRows == 0 {
+		return repository.ErrUserNotFound
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE user_suspensions
+		SET lifted_at = NOW(), lifted_by = $2, lift_reason = $3
+		WHERE user_id = $1 AND lifted_at IS NULL
+	`, suspension.UserID, suspension.SuspendedBy, supersededReason); err != nil {
+		return fmt.Errorf("failed to supersede suspension: %w", err)
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO user_suspensions (user_id, reason, suspended_by, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`, suspension.UserID, suspension.Reason, suspension.SuspendedBy, suspension.ExpiresAt).Scan(&suspension.ID, &suspension.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to store suspension: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// LiftSuspension lifts the user's suspension and reactivates the user in one transaction. It
+// returns ErrNotSuspended when there is nothing to lift.
+func (r *suspensionRepo) LiftSuspension(ctx context.Context, userID uuid.UUID, liftedBy *uuid.UUID, reason string) (*models.UserSuspension, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	suspension, err := scanSuspension(tx.QueryRowContext(ctx, `
+		UPDATE user_suspensions
+		SET lifted_at = NOW(), lifted_by = $2, lift_reason = NULLIF($3, '')
+		WHERE user_id = $1 AND lifted_at IS NULL
+		RETURNING `+suspensionColumns,
+		userID, liftedBy, reason))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE users SET is_active = TRUE, updated_at = NOW() WHERE id = $1
+	`, userID); err != nil {
+		return nil, fmt.Errorf("failed to reactivate user: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return suspension, nil
+}
+
+// FindOpenSuspension returns the user's suspension that was not lifted yet, which may have expired.
+// It returns ErrNotSuspended when there is none.
+func (r *suspensionRepo) FindOpenSuspension(ctx context.Context, userID uuid.UUID) (*models.UserSuspension, error) {
+	query := `SELECT ` + suspensionColumns + ` FROM user_suspensions WHERE user_id = $1 AND lifted_at IS NULL`
+	return scanSuspension(r.db.QueryRowContext(ctx, query, userID))
+}
+
+// EndExpiredSuspension closes the user's suspension once it expired, recording the expiry as the
+// time it was lifted, and reactivates the user. It reports whether a suspension was ended.
+func (r *suspensionRepo) EndExpiredSuspension(ctx context.Context, userID uuid.UUID) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE user_suspensions
+		SET lifted_at = expires_at, lift_reason = 'expired'
+		WHERE user_id = $1 AND lifted_at IS NULL AND expires_at <= NOW()
+	`, userID)
+	if err != nil {
+		return false, err
+	}
+
+	affectedRows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	if affectedRows == 0 {
+		return false, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE users SET is_active = TRUE, updated_at = NOW() WHERE id = $1
+	`, userID); err != nil {
+		return false, fmt.Errorf("failed to reactivate user: %w", err)
+	}
+
+	return true, tx.Commit()
+}
+
+// ListUserSuspensions returns every suspension of the user, most recent first.
+func (r *suspensionRepo) ListUserSuspensions(ctx context.Context, userID uuid.UUID) ([]*models.UserSuspension, error) {
+	query := `SELECT ` + suspensionColumns + ` FROM user_suspensions WHERE user_id = $1 ORDER BY created_at DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list suspensions: %w", err)
+	}
+	defer rows.Close()
+
+	var suspensions []*models.UserSuspension
+	for rows.Next() {
+		suspension, err := scanSuspension(rows)
+		if err != nil {
+			return nil, err
+		}
+		suspensions = append(suspensions, suspension)
+	}
+
+	return suspensions, rows.Err()
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSuspension(row rowScanner) (*models.UserSuspension, error) {
+	var suspension models.UserSuspension
+	err := row.Scan(
+		&suspension.ID,
+		&suspension.UserID,
+		&suspension.Reason,
+		&suspension.SuspendedBy,
+		&suspension.CreatedAt,
+		&suspension.ExpiresAt,
+		&suspension.LiftedAt,
+		&suspension.LiftedBy,
+		&suspension.LiftReason,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repository.ErrNotSuspended
+		}
+		return nil, err
+	}
+
+	return &suspension, nil
+}
@@ -25,7 +25,7 @@ func NewUserRepository(db *sql.DB) repository.UserRepository {
 // Register a new user in the database
 func (r *userRepo) FindUserByEmail(ctx context.Context, email string) (*authpb.User, error) {
 	query := `
-	SELECT id, name, email, is_active, created_at, updated_at  
+	SELECT id, name, email, is_active, locale, email_verified_at, created_at, updated_at
 	FROM users 
 	WHERE email = $1
 	`
@@ -36,6 +36,8 @@ func (r *userRepo) FindUserByEmail(ctx context.Context, email string) (*authpb.U
 		&user.Name,
 		&user.Email,
 		&user.IsActive,
+		&user.Locale,
+		&user.EmailVerifiedAt,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -56,7 +58,7 @@ func (r *userRepo) FindUserByEmail(ctx context.Context, email string) (*authpb.U
 // FindUserById function to find user by ID in database.
 func (r *userRepo) FindUserById(ctx context.Context, id string) (*authpb.User, error) {
 	query := `
-	SELECT id, name, email, is_active, created_at, updated_at 
+	SELECT id, name, email, is_active, locale, email_verified_at, created_at, updated_at
 	FROM users WHERE id = $1
 	`
 
@@ -66,6 +68,8 @@ func (r *userRepo) FindUserById(ctx context.Context, id string) (*authpb.User, e
 		&user.Name,
 		&user.Email,
 		&user.IsActive,
+		&user.Locale,
+		&user.EmailVerifiedAt,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -85,11 +89,11 @@ func (r *userRepo) FindUserById(ctx context.Context, id string) (*authpb.User, e
 // CreateNewUser function creates a new user in the database and returns an error if it fails to create the user.
 func (r *userRepo) CreateNewUser(ctx context.Context, req *authpb.RegisterRequest) error {
 	query := `
-		INSERT INTO users (name, email, password_hash) 
-		VALUES ($1, $2, $3)
+		INSERT INTO users (name, email, password_hash, locale)
+		VALUES ($1, $2, $3, COALESCE(NULLIF($4, ''), 'id'))
 	`
 
-	result, err := r.db.ExecContext(ctx, query, req.Name, req.Email, req.Password)
+	result, err := r.db.ExecContext(ctx, query, req.Name, req.Email, req.Password, req.Locale)
 	if err != nil {
 		return err
 	}
@@ -123,16 +127,18 @@ func (r *userRepo) ExistByEmail(ctx context.Context, email string) (bool, error)
 func (r *userRepo) UpdateUserProfile(ctx context.Context, req *authpb.UpdateUserProfileRequest) (*authpb.UpdateUserProfileResponse, error) {
 	query := `
 		UPDATE users 
-		SET name = $1 
+		SET name = $1, locale = COALESCE(NULLIF($3, ''), locale)
 		WHERE id = $2
-		RETURNING id, email, name, is_active, created_at, updated_at
+		RETURNING id, email, name, is_active, locale, email_verified_at, created_at, updated_at
 	`
 	var updatedUser models.User
-	err := r.db.QueryRowContext(ctx, query, req.Name, req.Id).Scan(
+	err := r.db.QueryRowContext(ctx, query, req.Name, req.Id, req.Locale).Scan(
 		&updatedUser.ID,
 		&updatedUser.Email,
 		&updatedUser.Name,
 		&updatedUser.IsActive,
+		&updatedUser.Locale,
+		&updatedUser.EmailVerifiedAt,
 		&updatedUser.CreatedAt,
 		&updatedUser.UpdatedAt,
 	)
@@ -178,11 +184,12 @@ func (r *userRepo) ChangeUserPassword(ctx context.Context, req *authpb.ChangeUse
 	return nil
 }
 
-// ChangeUserEmail changes the email of a user in
+// ChangeUserEmail changes the email of a user. It is only called once the new address was
+// confirmed, so the address is marked as verified as well.
 func (r *userRepo) ChangeUserEmail(ctx context.Context, req *authpb.ChangeUserEmailRequest) error {
 	query := `
 		UPDATE users 
-		SET email = $1 
+		SET email = $1, email_verified_at = NOW()
 		WHERE id = $2
 	`
 	result, err := r.db.ExecContext(ctx, query, req.Email, req.Id)
@@ -225,6 +232,44 @@ func (r *userRepo) DeleteUser(ctx context.Context, req *authpb.DeleteUserRequest
 	return nil
 }
 
+// MarkEmailVerified records that the user proved control over email. It fails when the
+// user's address is no longer the one the verification was issued for.
+func (r *userRepo) MarkEmailVerified(ctx context.Context, id uuid.UUID, email string) error {
+	query := `
+		UPDATE users
+		SET email_verified_at = COALESCE(email_verified_at, NOW())
+		WHERE id = $1 AND email = $2
+	`
+	result, err := r.db.ExecContext(ctx, query, id, email)
+	if err != nil {
+		return err
+	}
+
+	affectedRows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affectedRows == 0 {
+		return fmt.Errorf("error user not found")
+	}
+
+	return nil
+}
+
+// ReplacePasswordHash swaps the password hash of a user for an upgraded hash of the same password.
+// Nothing is changed when the stored hash is no longer oldHash, so a password change made in the
+// meantime is never overwritten.
+func (r *userRepo) ReplacePasswordHash(ctx context.Context, id uuid.UUID, oldHash, newHash string) error {
+	query := `
+		UPDATE users
+		SET password_hash = $1
+		WHERE id = $2 AND password_hash = $3
+	`
+	_, err := r.db.ExecContext(ctx, query, newHash, id, oldHash)
+	return err
+}
+
 func (r *userRepo) GetUserPasswordHash(ctx context.Context, identifier interface{}) (string, error) {
 	query := `
 		SELECT password_hash FROM users 
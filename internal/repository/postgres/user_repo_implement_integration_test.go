@@ -3,7 +3,7 @@ package postgres_test
 import (
 	"context"
 	"database/sql"
-	"embed"
+	"os"
 	"testing"
 	"time"
 
@@ -16,9 +16,13 @@ import (
 	"github.com/testcontainers/testcontainers-go/wait"
 )
 
-var migrationsFS embed.FS
+// migrationsDir is the migrations directory, relative to this package.
+const migrationsDir = "../../../db/migrations"
 
+// setupTestDB starts a migrated PostgreSQL container. The test is skipped without a container runtime.
 func setupTestDB(t *testing.T) (*sql.DB, func()) {
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+
 	ctx := context.Background()
 
 	pgContianer, err := postgres.Run(
@@ -59,7 +63,7 @@ func runMigrations(t *testing.T, db *sql.DB) {
 	driver, err := postgresdriver.WithInstance(db, &postgresdriver.Config{})
 	require.NoError(t, err)
 
-	source, err := iofs.New(migrationsFS, "db/migrations")
+	source, err := iofs.New(os.DirFS(migrationsDir), ".")
 	require.NoError(t, err)
 
 	m, err := migrate.NewWithInstance("iofs", source, "postgres", driver)
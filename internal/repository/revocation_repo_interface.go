@@ -0,0 +1,12 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+)
+
+type RevocationRepository interface {
+	Revoke(ctx context.Context, revocation *models.TokenRevocation) error
+	ListActiveRevocations(ctx context.Context) ([]*models.TokenRevocation, error)
+}
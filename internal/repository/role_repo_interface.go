@@ -0,0 +1,21 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/google/uuid"
+)
+
+type RoleRepository interface {
+	CreateRole(ctx context.Context, name string) (*models.Role, error)
+	DeleteRole(ctx context.Context, id uuid.UUID) error
+	FindRoleByID(ctx context.Context, id uuid.UUID) (*models.Role, error)
+	FindRoleByName(ctx context.Context, name string) (*models.Role, error)
+	ListRoles(ctx context.Context) ([]*models.Role, error)
+	AssignRoleToUser(ctx context.Context, userID, roleID uuid.UUID) error
+	RemoveRoleFromUser(ctx context.Context, userID, roleID uuid.UUID) error
+	ListUserRoles(ctx context.Context, userID uuid.UUID) ([]*models.Role, error)
+	UserHasRole(ctx context.Context, userID uuid.UUID, roleName string) (bool, error)
+	FindUserAuthorization(ctx context.Context, userID uuid.UUID) (*models.UserAuthorization, error)
+}
@@ -0,0 +1,22 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/google/uuid"
+)
+
+type SessionRepository interface {
+	CreateSession(ctx context.Context, session *models.Session) error
+	FindSessionByTokenHash(ctx context.Context, tokenHash string) (*models.Session, error)
+	RotateSession(ctx context.Context, currentID uuid.UUID, next *models.Session) error
+	RevokeSessionFamily(ctx context.Context, familyID uuid.UUID) error
+	RevokeUserSessions(ctx context.Context, userID uuid.UUID) error
+	RevokeUserSession(ctx context.Context, userID uuid.UUID, familyID uuid.UUID) error
+	ListActiveSessions(ctx context.Context, userID uuid.UUID) ([]*models.Session, error)
+	IsSessionFamilyActive(ctx context.Context, familyID uuid.UUID) (bool, error)
+	CountPurgeableSessions(ctx context.Context, cutoff time.Time) (int64, error)
+	PurgeSessions(ctx context.Context, cutoff time.Time, limit int) (int64, error)
+}
@@ -0,0 +1,13 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+)
+
+type SigningKeyRepository interface {
+	ListSigningKeys(ctx context.Context) ([]*models.SigningKey, error)
+	RotateSigningKey(ctx context.Context, next *models.SigningKey, retireAt time.Time) error
+}
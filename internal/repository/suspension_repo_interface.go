@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/google/uuid"
+)
+
+type SuspensionRepository interface {
+	SuspendUser(ctx context.Context, suspension *models.UserSuspension) error
+	LiftSuspension(ctx context.Context, userID uuid.UUID, liftedBy *uuid.UUID, reason string) (*models.UserSuspension, error)
+	FindOpenSuspension(ctx context.Context, userID uuid.UUID) (*models.UserSuspension, error)
+	EndExpiredSuspension(ctx context.Context, userID uuid.UUID) (bool, error)
+	ListUserSuspensions(ctx context.Context, userID uuid.UUID) ([]*models.UserSuspension, error)
+}
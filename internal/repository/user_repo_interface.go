@@ -3,6 +3,8 @@ package repository
 import (
 	"context"
 
+	"github.com/google/uuid"
+
 	authpb "github.com/Nucleussss/hikayat-proto/gen/go/auth/v1"
 )
 
@@ -16,4 +18,6 @@ type UserRepository interface {
 	ChangeUserEmail(ctx context.Context, req *authpb.ChangeUserEmailRequest) error
 	DeleteUser(ctx context.Context, user *authpb.DeleteUserRequest) error
 	GetUserPasswordHash(ctx context.Context, identifier interface{}) (string, error)
+	ReplacePasswordHash(ctx context.Context, id uuid.UUID, oldHash, newHash string) error
+	MarkEmailVerified(ctx context.Context, id uuid.UUID, email string) error
 }
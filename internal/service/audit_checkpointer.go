@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/auditlog"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/jwtkeys"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/repository"
+)
+
+// AuditCheckpointer periodically signs the head of the audit hash chain. Exported and kept
+// elsewhere, the checkpoints prove what the chain looked like, so rewriting the whole chain or
+// cutting off its end is detected by verify-audit.
+type AuditCheckpointer struct {
+	repo repository.AuditRepository
+	key  *jwtkeys.Key
+
+	mu      sync.Mutex
+	lastSeq int64
+}
+
+// NewAuditCheckpointer starts signing a checkpoint every interval, until ctx is done.
+func NewAuditCheckpointer(ctx context.Context, repo repository.AuditRepository, key *jwtkeys.Key, interval time.Duration) *AuditCheckpointer {
+	checkpointer := &AuditCheckpointer{repo: repo, key: key}
+
+	go checkpointer.run(ctx, interval)
+
+	return checkpointer
+}
+
+func (c *AuditCheckpointer) run(ctx context.Context, interval time.Duration) {
+	op := "auditCheckpointer.run"
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := c.Checkpoint(ctx); err != nil {
+				log.Printf("%s Error signing audit checkpoint: %v", op, err)
+			}
+		}
+	}
+}
+
+// Checkpoint signs and stores the current chain head. It returns nil when the chain did not grow
+// since the last checkpoint.
+func (c *AuditCheckpointer) Checkpoint(ctx context.Context) (*models.AuditCheckpoint, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	seq, hash, err := c.repo.AuditChainHead(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if seq == 0 || seq == c.lastSeq {
+		return nil, nil
+	}
+
+	checkpoint, err := auditlog.SignCheckpoint(c.key, seq, hash, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.repo.InsertAuditCheckpoint(ctx, checkpoint); err != nil {
+		return nil, err
+	}
+	c.lastSeq = seq
+
+	return checkpoint, nil
+}
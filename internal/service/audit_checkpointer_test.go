@@ -0,0 +1,48 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/auditlog"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/jwtkeys"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/service"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditCheckpointerSignsNewChainHeads(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	key, err := jwtkeys.GenerateKey(jwtkeys.AlgorithmEdDSA)
+	require.NoError(t, err)
+
+	repo := &fakeAuditRepo{}
+	checkpointer := service.NewAuditCheckpointer(ctx, repo, key, time.Hour)
+
+	// nothing to sign while the chain is empty
+	checkpoint, err := checkpointer.Checkpoint(ctx)
+	require.NoError(t, err)
+	require.Nil(t, checkpoint)
+
+	events := []*models.AuditEvent{
+		{Action: models.AuditActionLogin, Outcome: models.AuditOutcomeSuccess, CreatedAt: time.Now()},
+		{Action: models.AuditActionLogout, Outcome: models.AuditOutcomeSuccess, CreatedAt: time.Now()},
+	}
+	require.NoError(t, auditlog.Link(0, auditlog.GenesisHash, events))
+	require.NoError(t, repo.InsertAuditEvents(ctx, events))
+
+	checkpoint, err = checkpointer.Checkpoint(ctx)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, checkpoint.Seq)
+	require.Equal(t, events[1].Hash, checkpoint.RowHash)
+	require.NoError(t, auditlog.VerifyCheckpoint(key, checkpoint))
+
+	// the head did not move, so no second checkpoint is stored
+	checkpoint, err = checkpointer.Checkpoint(ctx)
+	require.NoError(t, err)
+	require.Nil(t, checkpoint)
+	require.Len(t, repo.checkpoints, 1)
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/repository"
+)
+
+const (
+	// auditBatchSize is the most events written in one transaction.
+	auditBatchSize = 100
+	// auditWriteTimeout bounds a single batch write, so a slow database cannot stall the writer.
+	auditWriteTimeout = 5 * time.Second
+)
+
+// AuditRecorder writes audit events to the database in the background. Record only queues the
+// event, so auditing never blocks or fails the request that caused it. Events are written in
+// batches every flushInterval or as soon as a batch is full. When the queue is full, or a batch
+// cannot be written, the events are logged instead so they are not lost silently.
+type AuditRecorder struct {
+	repo          repository.AuditRepository
+	events        chan *models.AuditEvent
+	flushInterval time.Duration
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewAuditRecorder starts the writer with a queue of bufferSize events.
+func NewAuditRecorder(repo repository.AuditRepository, bufferSize int, flushInterval time.Duration) *AuditRecorder {
+	recorder := &AuditRecorder{
+		repo:          repo,
+		events:        make(chan *models.AuditEvent, bufferSize),
+		flushInterval: flushInterval,
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+
+	go recorder.run()
+
+	return recorder
+}
+
+// Record queues an event. It never blocks.
+func (r *AuditRecorder) Record(event *models.AuditEvent) {
+	op := "auditRecorder.Record"
+
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+
+	select {
+	case r.events <- event:
+	default:
+		log.Printf("%s Audit queue full, dropping %s", op, describeAuditEvent(event))
+	}
+}
+
+// Close writes the queued events and stops the writer. Events recorded afterwards are dropped.
+func (r *AuditRecorder) Close() {
+	r.stopOnce.Do(func() { close(r.stop) })
+	<-r.done
+}
+
+func (r *AuditRecorder) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*models.AuditEvent, 0, auditBatchSize)
+	for {
+		select {
+		case event := <-r.events:
+			batch = append(batch, event)
+			if len(batch) == auditBatchSize {
+				batch = r.flush(batch)
+			}
+		case <-ticker.C:
+			batch = r.flush(batch)
+		case <-r.stop:
+			for {
+				select {
+				case event := <-r.events:
+					batch = append(batch, event)
+					if len(batch) == auditBatchSize {
+						batch = r.flush(batch)
+					}
+				default:
+					r.flush(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush writes the batch and returns it emptied for reuse.
+func (r *AuditRecorder) flush(batch []*models.AuditEvent) []*models.AuditEvent {
+	op := "auditRecorder.flush"
+
+	if len(batch) == 0 {
+		return batch
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), auditWriteTimeout)
+	defer cancel()
+
+	if err := r.repo.InsertAuditEvents(ctx, batch); err != nil {
+		log.Printf("%s Error writing %d audit events: %v", op, len(batch), err)
+		for _, event := range batch {
+			log.Printf("%s Unwritten %s", op, describeAuditEvent(event))
+		}
+	}
+
+	return batch[:0]
+}
+
+// describeAuditEvent renders an event for the service log.
+func describeAuditEvent(event *models.AuditEvent) string {
+	description := "audit event=" + event.Action + " outcome=" + event.Outcome
+	if event.ActorID != nil {
+		description += " actor=" + event.ActorID.String()
+	}
+	if event.TargetUserID != nil {
+		description += " target=" + event.TargetUserID.String()
+	}
+	if event.IPAddress != "" {
+		description += " ip=" + event.IPAddress
+	}
+
+	return description
+}
@@ -0,0 +1,194 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/auditlog"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/service"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeAuditRepo struct {
+	mu          sync.Mutex
+	written     []*models.AuditEvent
+	checkpoints []*models.AuditCheckpoint
+	// anchorSeq and anchorHash are the last pruned chained event.
+	anchorSeq  int64
+	anchorHash string
+	// release blocks writes until it is closed, when set.
+	release chan struct{}
+}
+
+func (r *fakeAuditRepo) InsertAuditEvents(ctx context.Context, events []*models.AuditEvent) error {
+	if r.release != nil {
+		<-r.release
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.written = append(r.written, events...)
+	return nil
+}
+
+func (r *fakeAuditRepo) ListAuditEvents(ctx context.Context, filter models.AuditEventFilter, after *models.AuditCursor, limit int) ([]*models.AuditEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// newest first, ties broken by id like the database does
+	newer := func(a, b *models.AuditEvent) int {
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
+		return bytes.Compare(b.ID[:], a.ID[:])
+	}
+	is := func(id *uuid.UUID, want uuid.UUID) bool { return id != nil && *id == want }
+
+	var events []*models.AuditEvent
+	for _, event := range r.written {
+		switch {
+		case filter.UserID != nil && !is(event.ActorID, *filter.UserID) && !is(event.TargetUserID, *filter.UserID),
+			filter.TargetUserID != nil && !is(event.TargetUserID, *filter.TargetUserID),
+			len(filter.Actions) > 0 && !slices.Contains(filter.Actions, event.Action),
+			filter.Outcome != "" && event.Outcome != filter.Outcome,
+			!filter.Since.IsZero() && event.CreatedAt.Before(filter.Since),
+			!filter.Until.IsZero() && !event.CreatedAt.Before(filter.Until),
+			after != nil && newer(event, &models.AuditEvent{CreatedAt: after.CreatedAt, ID: after.ID}) <= 0:
+			continue
+		}
+		copied := *event
+		events = append(events, &copied)
+	}
+	slices.SortFunc(events, newer)
+
+	return events[:min(limit, len(events))], nil
+}
+
+func (r *fakeAuditRepo) ListAuditChain(ctx context.Context, afterSeq int64, limit int) ([]*models.AuditEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var events []*models.AuditEvent
+	for _, event := range r.written {
+		if event.Seq > afterSeq && len(events) < limit {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (r *fakeAuditRepo) CountUnchainedAuditEvents(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+func (r *fakeAuditRepo) AuditChainHead(ctx context.Context) (int64, string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.written) == 0 {
+		return 0, auditlog.GenesisHash, nil
+	}
+	head := r.written[len(r.written)-1]
+	return head.Seq, head.Hash, nil
+}
+
+func (r *fakeAuditRepo) InsertAuditCheckpoint(ctx context.Context, checkpoint *models.AuditCheckpoint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checkpoints = append(r.checkpoints, checkpoint)
+	return nil
+}
+
+func (r *fakeAuditRepo) ListAuditCheckpoints(ctx context.Context) ([]*models.AuditCheckpoint, error) {
+	return r.checkpoints, nil
+}
+
+func (r *fakeAuditRepo) AuditChainAnchor(ctx context.Context) (int64, string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.anchorSeq == 0 {
+		return 0, auditlog.GenesisHash, nil
+	}
+	return r.anchorSeq, r.anchorHash, nil
+}
+
+func (r *fakeAuditRepo) CountAuditEventsBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var count int64
+	for _, event := range r.written {
+		if event.CreatedAt.Before(cutoff) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *fakeAuditRepo) ListOldestAuditEvents(ctx context.Context, limit int) ([]*models.AuditEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*models.AuditEvent(nil), r.written[:min(limit, len(r.written))]...), nil
+}
+
+func (r *fakeAuditRepo) DeleteAuditEvents(ctx context.Context, events []*models.AuditEvent, anchorCheckpoint *models.AuditCheckpoint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.written = r.written[len(events):]
+	if last := events[len(events)-1]; last.Seq > 0 {
+		r.anchorSeq, r.anchorHash = last.Seq, last.Hash
+		r.checkpoints = append(r.checkpoints, anchorCheckpoint)
+	}
+	return nil
+}
+
+func (r *fakeAuditRepo) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.written)
+}
+
+func TestAuditRecorderFlushesOnClose(t *testing.T) {
+	repo := &fakeAuditRepo{}
+	recorder := service.NewAuditRecorder(repo, 10, time.Hour)
+
+	for i := 0; i < 5; i++ {
+		recorder.Record(&models.AuditEvent{Action: models.AuditActionLogin, Outcome: models.AuditOutcomeSuccess})
+	}
+	recorder.Close()
+
+	require.Equal(t, 5, repo.count())
+	require.False(t, repo.written[0].CreatedAt.IsZero())
+}
+
+func TestAuditRecorderNeverBlocks(t *testing.T) {
+	repo := &fakeAuditRepo{release: make(chan struct{})}
+	recorder := service.NewAuditRecorder(repo, 2, time.Millisecond)
+
+	// the writer is stuck on the first batch, so the queue fills up and further events are dropped
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			recorder.Record(&models.AuditEvent{Action: models.AuditActionLogin, Outcome: models.AuditOutcomeFailure})
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Record blocked on a slow repository")
+	}
+
+	close(repo.release)
+	recorder.Close()
+
+	written := repo.count()
+	require.Positive(t, written)
+	require.Less(t, written, 50)
+}
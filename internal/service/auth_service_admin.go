@@ -0,0 +1,32 @@
+package service
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+
+	authpb "github.com/Nucleussss/hikayat-proto/gen/go/auth/v1"
+)
+
+// UnlockAccount lifts a login lockout of the account and forgets its failed attempts.
+func (s *authService) UnlockAccount(ctx context.Context, req *authpb.UnlockAccountRequest) error {
+	op := "authService.UnlockAccount"
+
+	user, err := s.userRepo.FindUserById(ctx, req.UserId)
+	if err != nil {
+		log.Printf("%s Error finding user by id: %s, error: %v", op, req.UserId, err)
+		return err
+	}
+
+	subjectKey := strings.ToLower(strings.TrimSpace(user.Email))
+	if err := s.loginThrottleRepo.ResetLoginThrottle(ctx, models.LoginThrottleAccount, subjectKey); err != nil {
+		log.Printf("%s Error unlocking user by id: %s, error: %v", op, req.UserId, err)
+		return err
+	}
+
+	s.recordAudit(ctx, models.AuditActionAccountUnlocked, models.AuditOutcomeSuccess, req.UserId, nil)
+
+	return nil
+}
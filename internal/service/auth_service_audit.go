@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	contextKey "github.com/Nucleussss/hikayat-forum/auth/internal/context"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/Nucleussss/hikayat-forum/auth/pkg/utils"
+	"github.com/google/uuid"
+
+	authpb "github.com/Nucleussss/hikayat-proto/gen/go/auth/v1"
+)
+
+// recordAudit queues an audit event for the request in ctx. The actor is the authenticated caller,
+// the IP address and user agent come from the request metadata. targetUserID is the account the
+// action concerned, empty when there is none.
+func (s *authService) recordAudit(ctx context.Context, action, outcome, targetUserID string, metadata map[string]any) {
+	if s.audit == nil {
+		return
+	}
+
+	userAgent, ipAddress := utils.ClientInfoFromContext(ctx)
+	event := &models.AuditEvent{
+		Action:    action,
+		Outcome:   outcome,
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+		Metadata:  metadata,
+	}
+
+	if actorID, ok := ctx.Value(contextKey.UserIDContextKey).(string); ok {
+		event.ActorID = parseAuditUserID(actorID)
+	}
+	event.TargetUserID = parseAuditUserID(targetUserID)
+
+	s.audit.Record(event)
+}
+
+// parseAuditUserID returns nil for an empty or malformed user ID.
+func parseAuditUserID(userID string) *uuid.UUID {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return nil
+	}
+
+	return &id
+}
+
+const (
+	// defaultAuditPageSize is used when a listing does not ask for a page size.
+	defaultAuditPageSize = 50
+	// maxAuditPageSize caps the page size a listing may ask for.
+	maxAuditPageSize = 200
+)
+
+// securityEventActions are the events users see about their own account in ListMySecurityEvents.
+var securityEventActions = []string{
+	models.AuditActionLogin,
+	models.AuditActionLoginLocked,
+	models.AuditActionAccountUnlocked,
+	models.AuditActionPasswordChange,
+	models.AuditActionPasswordReset,
+	models.AuditActionEmailChangeRequest,
+	models.AuditActionEmailChangeConfirm,
+	models.AuditActionEmailChangeCancel,
+	models.AuditActionLogout,
+	models.AuditActionSessionRevoked,
+	models.AuditActionAllSessionsRevoked,
+	models.AuditActionTokenReuse,
+	models.AuditActionUserSuspended,
+	models.AuditActionSuspensionLifted,
+}
+
+// ListAuditEvents searches the audit log, newest first.
+func (s *authService) ListAuditEvents(ctx context.Context, req *authpb.ListAuditEventsRequest) (*authpb.ListAuditEventsResponse, error) {
+	op := "authService.ListAuditEvents"
+
+	filter := models.AuditEventFilter{
+		Actions: req.Actions,
+		Outcome: req.Outcome,
+	}
+	if req.UserId != "" {
+		userID, err := uuid.Parse(req.UserId)
+		if err != nil {
+			return nil, fmt.Errorf("invalid user id")
+		}
+		filter.UserID = &userID
+	}
+	if req.Since != nil {
+		filter.Since = req.Since.AsTime()
+	}
+	if req.Until != nil {
+		filter.Until = req.Until.AsTime()
+	}
+
+	events, nextPageToken, err := s.listAuditEvents(ctx, filter, req.PageSize, req.PageToken)
+	if err != nil {
+		log.Printf("%s Error listing audit events: %v", op, err)
+		return nil, err
+	}
+
+	response := &authpb.ListAuditEventsResponse{NextPageToken: nextPageToken}
+	for _, event := range events {
+		response.Events = append(response.Events, utils.AuditEventModelToPB(event))
+	}
+
+	return response, nil
+}
+
+// ListMySecurityEvents returns the logins and security changes of the user's own account, newest
+// first. Events another user, like an admin, performed on the account only show what happened:
+// their actor, IP address, user agent and metadata are left out. Events without an actor come
+// from unauthenticated requests for the account, such as logins, and are shown in full.
+func (s *authService) ListMySecurityEvents(ctx context.Context, req *authpb.ListMySecurityEventsRequest) (*authpb.ListMySecurityEventsResponse, error) {
+	op := "authService.ListMySecurityEvents"
+
+	userID := uuid.MustParse(req.Id)
+	filter := models.AuditEventFilter{
+		TargetUserID: &userID,
+		Actions:      securityEventActions,
+	}
+
+	events, nextPageToken, err := s.listAuditEvents(ctx, filter, req.PageSize, req.PageToken)
+	if err != nil {
+		log.Printf("%s Error listing security events for user by id: %s, error: %v", op, req.Id, err)
+		return nil, err
+	}
+
+	response := &authpb.ListMySecurityEventsResponse{NextPageToken: nextPageToken}
+	for _, event := range events {
+		if event.ActorID != nil && *event.ActorID != userID {
+			event.ActorID = nil
+			event.IPAddress = ""
+			event.UserAgent = ""
+			event.Metadata = nil
+		}
+		response.Events = append(response.Events, utils.AuditEventModelToPB(event))
+	}
+
+	return response, nil
+}
+
+// listAuditEvents reads one page of events. One event more than the page holds is fetched to
+// tell whether another page follows.
+func (s *authService) listAuditEvents(ctx context.Context, filter models.AuditEventFilter, pageSize int32, pageToken string) ([]*models.AuditEvent, string, error) {
+	after, err := utils.DecodeAuditPageToken(pageToken)
+	if err != nil {
+		return nil, "", ErrInvalidPageToken
+	}
+
+	limit := int(pageSize)
+	if limit <= 0 {
+		limit = defaultAuditPageSize
+	}
+	limit = min(limit, maxAuditPageSize)
+
+	events, err := s.auditRepo.ListAuditEvents(ctx, filter, after, limit+1)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if len(events) <= limit {
+		return events, "", nil
+	}
+
+	events = events[:limit]
+	last := events[limit-1]
+
+	return events, utils.EncodeAuditPageToken(models.AuditCursor{CreatedAt: last.CreatedAt, ID: last.ID}), nil
+}
@@ -0,0 +1,128 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/service"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	authpb "github.com/Nucleussss/hikayat-proto/gen/go/auth/v1"
+)
+
+// addAuditEvent stores an event as if the recorder had written it.
+func (f *authFixture) addAuditEvent(action string, actorID, targetUserID *uuid.UUID, createdAt time.Time) *models.AuditEvent {
+	event := &models.AuditEvent{
+		ID:           uuid.New(),
+		Action:       action,
+		Outcome:      models.AuditOutcomeSuccess,
+		ActorID:      actorID,
+		TargetUserID: targetUserID,
+		IPAddress:    "203.0.113.7",
+		UserAgent:    "test-agent",
+		Metadata:     map[string]any{"reason": "test"},
+		CreatedAt:    createdAt,
+	}
+	f.audit.written = append(f.audit.written, event)
+
+	return event
+}
+
+func TestListAuditEventsPages(t *testing.T) {
+	ctx := context.Background()
+	f := newAuthFixture(t)
+	user := uuid.New()
+
+	// seven events, three of them in the same instant so the id has to order them
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for i := 0; i < 7; i++ {
+		createdAt := start.Add(time.Duration(min(i, 4)) * time.Minute)
+		f.addAuditEvent(models.AuditActionLogin, nil, &user, createdAt)
+	}
+	f.addAuditEvent(models.AuditActionLogout, &user, &user, start)
+	f.addAuditEvent(models.AuditActionLogin, nil, nil, start)
+
+	// the pages add up to what one unpaged listing returns
+	expected, err := f.audit.ListAuditEvents(ctx, models.AuditEventFilter{UserID: &user, Actions: []string{models.AuditActionLogin}}, nil, 100)
+	require.NoError(t, err)
+	require.Len(t, expected, 7)
+	var want []string
+	for _, event := range expected {
+		want = append(want, event.ID.String())
+	}
+
+	var got []string
+	pages := 0
+	req := &authpb.ListAuditEventsRequest{UserId: user.String(), Actions: []string{models.AuditActionLogin}, PageSize: 3}
+	for {
+		res, err := f.service.ListAuditEvents(ctx, req)
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(res.Events), 3)
+		pages++
+
+		for _, event := range res.Events {
+			got = append(got, event.Id)
+		}
+		if res.NextPageToken == "" {
+			break
+		}
+		req.PageToken = res.NextPageToken
+	}
+	require.Equal(t, want, got)
+	require.Equal(t, 3, pages)
+
+	_, err = f.service.ListAuditEvents(ctx, &authpb.ListAuditEventsRequest{PageToken: "not-a-token"})
+	require.ErrorIs(t, err, service.ErrInvalidPageToken)
+
+	_, err = f.service.ListAuditEvents(ctx, &authpb.ListAuditEventsRequest{UserId: "not-a-uuid"})
+	require.Error(t, err)
+}
+
+func TestListMySecurityEventsHidesOtherActors(t *testing.T) {
+	ctx := context.Background()
+	f := newAuthFixture(t)
+	user := uuid.New()
+	admin := uuid.New()
+	now := time.Now()
+
+	own := f.addAuditEvent(models.AuditActionPasswordChange, &user, &user, now.Add(-3*time.Minute))
+	login := f.addAuditEvent(models.AuditActionLogin, nil, &user, now.Add(-2*time.Minute))
+	suspended := f.addAuditEvent(models.AuditActionUserSuspended, &admin, &user, now.Add(-time.Minute))
+
+	// neither events about other users nor actions outside the security events are listed
+	f.addAuditEvent(models.AuditActionLogin, nil, &admin, now)
+	f.addAuditEvent(models.AuditActionRoleAssigned, &admin, &user, now)
+
+	res, err := f.service.ListMySecurityEvents(ctx, &authpb.ListMySecurityEventsRequest{Id: user.String()})
+	require.NoError(t, err)
+	require.Len(t, res.Events, 3)
+	require.Empty(t, res.NextPageToken)
+
+	byID := make(map[string]*authpb.AuditEvent)
+	for _, event := range res.Events {
+		byID[event.Id] = event
+	}
+
+	// the user's own events are shown in full
+	for _, event := range []*models.AuditEvent{own, login} {
+		listed := byID[event.ID.String()]
+		require.Equal(t, "203.0.113.7", listed.IpAddress)
+		require.Equal(t, "test-agent", listed.UserAgent)
+		require.Equal(t, "test", listed.Metadata.AsMap()["reason"])
+	}
+	require.Equal(t, user.String(), byID[own.ID.String()].ActorId)
+
+	// the admin's action only shows what happened
+	listed := byID[suspended.ID.String()]
+	require.Equal(t, models.AuditActionUserSuspended, listed.Action)
+	require.Empty(t, listed.ActorId)
+	require.Empty(t, listed.IpAddress)
+	require.Empty(t, listed.UserAgent)
+	require.Empty(t, listed.Metadata.AsMap())
+
+	// the stored event is left alone
+	require.Equal(t, "203.0.113.7", suspended.IPAddress)
+}
@@ -0,0 +1,123 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/service"
+	"github.com/stretchr/testify/require"
+
+	authpb "github.com/Nucleussss/hikayat-proto/gen/go/auth/v1"
+)
+
+const (
+	confirmEmailURL = "https://hikayat.test/email/confirm?token="
+	cancelEmailURL  = "https://hikayat.test/email/cancel?token="
+)
+
+// requestEmailChange asks to move the user to newEmail and returns the tokens of the confirmation
+// link sent to the new address and of the cancel link sent to the old one.
+func (f *authFixture) requestEmailChange(t *testing.T, user *authpb.User, newEmail string) (confirm, cancel string) {
+	t.Helper()
+
+	err := f.service.ChangeUserEmail(context.Background(), &authpb.ChangeUserEmailRequest{Id: user.Id, Email: newEmail, CurrentPassword: "correct horse"})
+	require.NoError(t, err)
+
+	// both mails are sent in the background, in no particular order
+	for range 2 {
+		select {
+		case msg := <-f.mailer.sent:
+			switch msg.To {
+			case newEmail:
+				confirm = mailToken(t, msg, confirmEmailURL)
+			case user.Email:
+				cancel = mailToken(t, msg, cancelEmailURL)
+			default:
+				t.Fatalf("unexpected mail to %s", msg.To)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("email change mails were not sent")
+		}
+	}
+
+	return confirm, cancel
+}
+
+func TestChangeUserEmail(t *testing.T) {
+	ctx := context.Background()
+	f := newAuthFixture(t)
+	user := f.addUser(t, "reader@example.com", "correct horse")
+	f.addUser(t, "taken@example.com", "correct horse")
+
+	err := f.service.ChangeUserEmail(ctx, &authpb.ChangeUserEmailRequest{Id: user.Id, Email: "new@example.com", CurrentPassword: "wrong horse"})
+	require.ErrorIs(t, err, service.ErrIncorrectPassword)
+
+	err = f.service.ChangeUserEmail(ctx, &authpb.ChangeUserEmailRequest{Id: user.Id, Email: "taken@example.com", CurrentPassword: "correct horse"})
+	require.ErrorIs(t, err, service.ErrEmailTaken)
+	require.Empty(t, f.emails.changes)
+
+	// the address only changes once the new one is confirmed
+	confirm, _ := f.requestEmailChange(t, user, "new@example.com")
+	require.Equal(t, "reader@example.com", f.users.users[user.Id].Email)
+
+	require.NoError(t, f.service.ConfirmEmailChange(ctx, &authpb.ConfirmEmailChangeRequest{Token: confirm}))
+	require.Equal(t, "new@example.com", f.users.users[user.Id].Email)
+
+	// the link is single use
+	require.Error(t, f.service.ConfirmEmailChange(ctx, &authpb.ConfirmEmailChangeRequest{Token: confirm}))
+}
+
+func TestChangeUserEmailSupersedesPendingChange(t *testing.T) {
+	ctx := context.Background()
+	f := newAuthFixture(t)
+	user := f.addUser(t, "reader@example.com", "correct horse")
+
+	first, _ := f.requestEmailChange(t, user, "first@example.com")
+	second, _ := f.requestEmailChange(t, user, "second@example.com")
+	require.Len(t, f.emails.changes, 1)
+
+	require.Error(t, f.service.ConfirmEmailChange(ctx, &authpb.ConfirmEmailChangeRequest{Token: first}))
+	require.NoError(t, f.service.ConfirmEmailChange(ctx, &authpb.ConfirmEmailChangeRequest{Token: second}))
+	require.Equal(t, "second@example.com", f.users.users[user.Id].Email)
+}
+
+func TestConfirmEmailChangeOfTakenAddress(t *testing.T) {
+	ctx := context.Background()
+	f := newAuthFixture(t)
+	user := f.addUser(t, "reader@example.com", "correct horse")
+
+	// someone else signs up with the address while the change is pending
+	confirm, _ := f.requestEmailChange(t, user, "new@example.com")
+	f.addUser(t, "new@example.com", "correct horse")
+
+	err := f.service.ConfirmEmailChange(ctx, &authpb.ConfirmEmailChangeRequest{Token: confirm})
+	require.ErrorIs(t, err, service.ErrEmailTaken)
+	require.Equal(t, "reader@example.com", f.users.users[user.Id].Email)
+}
+
+func TestCancelEmailChange(t *testing.T) {
+	ctx := context.Background()
+	f := newAuthFixture(t)
+	user := f.addUser(t, "reader@example.com", "correct horse")
+
+	login, err := f.service.Login(ctx, &authpb.LoginRequest{Email: user.Email, Password: "correct horse"})
+	require.NoError(t, err)
+	issuedAt := time.Now().Add(-time.Minute)
+
+	confirm, cancel := f.requestEmailChange(t, user, "attacker@example.com")
+	require.NoError(t, f.service.CancelEmailChange(ctx, &authpb.CancelEmailChangeRequest{Token: cancel}))
+
+	// the change can no longer be confirmed, nor cancelled twice
+	require.Error(t, f.service.ConfirmEmailChange(ctx, &authpb.ConfirmEmailChangeRequest{Token: confirm}))
+	require.Error(t, f.service.CancelEmailChange(ctx, &authpb.CancelEmailChangeRequest{Token: cancel}))
+	require.Equal(t, "reader@example.com", f.users.users[user.Id].Email)
+
+	// whoever asked for the change is logged out
+	_, err = f.service.RefreshToken(ctx, &authpb.RefreshTokenRequest{RefreshToken: login.RefreshToken})
+	require.Error(t, err)
+
+	revoked, err := f.revocations.IsRevoked(ctx, service.RevocationCheck{TokenID: "jti", UserID: user.Id, IssuedAt: issuedAt})
+	require.NoError(t, err)
+	require.True(t, revoked)
+}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"os"
 
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/passkey"
 	"github.com/Nucleussss/hikayat-forum/auth/internal/repository"
+	"github.com/Nucleussss/hikayat-forum/auth/pkg/config"
 	"github.com/Nucleussss/hikayat-forum/auth/pkg/utils"
 	"github.com/google/uuid"
 
@@ -14,16 +16,79 @@ import (
 )
 
 type authService struct {
-	userRepo repository.UserRepository
+	userRepo          repository.UserRepository
+	sessionRepo       repository.SessionRepository
+	passwordResetRepo repository.PasswordResetRepository
+	emailChangeRepo   repository.EmailChangeRepository
+	mfaRepo           repository.MFARepository
+	passkeyRepo       repository.PasskeyRepository
+	loginThrottleRepo repository.LoginThrottleRepository
+	roleRepo          repository.RoleRepository
+	permissionRepo    repository.PermissionRepository
+	auditRepo         repository.AuditRepository
+	suspensionRepo    repository.SuspensionRepository
+	relyingParty      *passkey.RelyingParty
+	passwords         PasswordHasher
+	dummyPassword     string
+	tokenKeys         *KeyManager
+	loginThrottle     LoginThrottleConfig
+	revocations       RevocationStore
+	mailer            Mailer
+	mailTemplates     *MailTemplates
+	audit             *AuditRecorder
+	cfg               *config.Config
 }
 
-func NewAuthService(userRepo repository.UserRepository) AuthService {
-	return &authService{userRepo: userRepo}
+func NewAuthService(
+	userRepo repository.UserRepository,
+	sessionRepo repository.SessionRepository,
+	passwordResetRepo repository.PasswordResetRepository,
+	emailChangeRepo repository.EmailChangeRepository,
+	mfaRepo repository.MFARepository,
+	passkeyRepo repository.PasskeyRepository,
+	loginThrottleRepo repository.LoginThrottleRepository,
+	roleRepo repository.RoleRepository,
+	permissionRepo repository.PermissionRepository,
+	auditRepo repository.AuditRepository,
+	suspensionRepo repository.SuspensionRepository,
+	relyingParty *passkey.RelyingParty,
+	passwords PasswordHasher,
+	tokenKeys *KeyManager,
+	loginThrottle LoginThrottleConfig,
+	revocations RevocationStore,
+	mailer Mailer,
+	mailTemplates *MailTemplates,
+	audit *AuditRecorder,
+	cfg *config.Config,
+) AuthService {
+	return &authService{
+		userRepo:          userRepo,
+		sessionRepo:       sessionRepo,
+		passwordResetRepo: passwordResetRepo,
+		emailChangeRepo:   emailChangeRepo,
+		mfaRepo:           mfaRepo,
+		passkeyRepo:       passkeyRepo,
+		loginThrottleRepo: loginThrottleRepo,
+		roleRepo:          roleRepo,
+		permissionRepo:    permissionRepo,
+		auditRepo:         auditRepo,
+		suspensionRepo:    suspensionRepo,
+		relyingParty:      relyingParty,
+		passwords:         passwords,
+		dummyPassword:     newDummyPasswordHash(passwords),
+		tokenKeys:         tokenKeys,
+		loginThrottle:     loginThrottle,
+		revocations:       revocations,
+		mailer:            mailer,
+		mailTemplates:     mailTemplates,
+		audit:             audit,
+		cfg:               cfg,
+	}
 }
 
 // Register handles new user registration. It first checks if the provided email already exists in the database.
 // If not, it hashes the user's password for security and then proceeds to create a new user entry in the database.
-// Upon successful creation, it returns a confirmation message.
+// Upon successful creation, it sends an email verification link and returns a confirmation message.
 func (s *authService) Register(ctx context.Context, req *authpb.RegisterRequest) (*authpb.RegisterResponse, error) {
 	op := "authService.Register"
 
@@ -36,11 +101,12 @@ func (s *authService) Register(ctx context.Context, req *authpb.RegisterRequest)
 
 	if exists {
 		log.Printf("%s Email already exists", op)
+		s.recordAudit(ctx, models.AuditActionRegister, models.AuditOutcomeFailure, "", map[string]any{"email": req.Email, "reason": "email_taken"})
 		return nil, fmt.Errorf("%v Email already exists", op)
 	}
 
 	// hash password
-	hashedPassword, err := utils.HashPassword(req.Password)
+	hashedPassword, err := s.passwords.Hash(req.Password)
 	if err != nil {
 		log.Printf("%s Error hashing password: %v ", op, err)
 		return nil, err
@@ -59,6 +125,18 @@ func (s *authService) Register(ctx context.Context, req *authpb.RegisterRequest)
 		return nil, err
 	}
 
+	// send the verification link for the new address
+	user, err := s.userRepo.FindUserByEmail(ctx, req.Email)
+	if err != nil {
+		log.Printf("%s Error finding new user: %v", op, err)
+		return nil, err
+	}
+	s.recordAudit(ctx, models.AuditActionRegister, models.AuditOutcomeSuccess, user.Id, map[string]any{"email": user.Email})
+
+	if err := s.sendVerificationEmail(user); err != nil {
+		log.Printf("%s Error issuing verification email: %v", op, err)
+	}
+
 	response := &authpb.RegisterResponse{
 		Message: "User created successfully",
 	}
@@ -69,15 +147,29 @@ func (s *authService) Register(ctx context.Context, req *authpb.RegisterRequest)
 }
 
 // Login authenticates a user by verifying their email and password against the database.
-// Upon successful verification, it generates a JSON Web Token (JWT) for the user, allowing them to access protected resources,
-// and returns this token along with a success message.
+// Upon successful verification, it opens a new session and returns a short lived JSON Web Token (JWT)
+// together with an opaque refresh token that can be exchanged through RefreshToken.
+// Users with TOTP enabled get an mfa_required challenge token instead, to be completed with VerifyMFA.
+// Failed attempts slow down and eventually lock both the account and the source IP, see LoginThrottlePolicy.
 func (s *authService) Login(ctx context.Context, req *authpb.LoginRequest) (*authpb.LoginResponse, error) {
 	op := "authService.Login"
 
+	// refuse attempts while the account or the source IP is backing off or locked
+	subjects := s.loginSubjects(ctx, req.Email)
+	if err := s.checkLoginThrottle(ctx, subjects); err != nil {
+		log.Printf("%s login throttled for email %v: %v", op, req.Email, err)
+		s.recordAudit(ctx, models.AuditActionLogin, models.AuditOutcomeFailure, "", map[string]any{"method": "password", "email": req.Email, "reason": "throttled"})
+		return nil, err
+	}
+
 	// find user by email
 	user, err := s.userRepo.FindUserByEmail(ctx, req.Email)
 	if err != nil {
 		log.Printf("%s Error finding user by email: %v", op, err)
+		s.recordAudit(ctx, models.AuditActionLogin, models.AuditOutcomeFailure, "", map[string]any{"method": "password", "email": req.Email, "reason": "unknown_account"})
+		s.recordLoginFailure(ctx, subjects, nil)
+		// spend the time checking a password takes, so the answer time does not reveal the account is unknown
+		s.passwords.Verify(s.dummyPassword, req.Password)
 		return nil, fmt.Errorf("%s Invalid credentials", op)
 	}
 
@@ -87,24 +179,69 @@ func (s *authService) Login(ctx context.Context, req *authpb.LoginRequest) (*aut
 		return nil, fmt.Errorf("%s Invalid credentials", op)
 	}
 
-	// verify password
-	if !utils.VerifyPassword(passHas, req.Password) {
+	// verify password, upgrading an outdated hash on success
+	if !s.verifyPassword(ctx, uuid.MustParse(user.Id), passHas, req.Password) {
 		log.Printf(" %s Error verifying password", op)
+		s.recordAudit(ctx, models.AuditActionLogin, models.AuditOutcomeFailure, user.Id, map[string]any{"method": "password", "reason": "invalid_password"})
+		s.recordLoginFailure(ctx, subjects, user)
 		return nil, fmt.Errorf("%s Invalid credentials", op)
 	}
 
-	// generate JWT token
-	generatedToken, err := utils.GenerateJWTToken(uuid.MustParse(user.Id), os.Getenv("JWT_SECRET"))
+	// suspended and deactivated accounts get no tokens
+	if err := s.checkAccountActive(ctx, user); err != nil {
+		log.Printf("%s login refused for user %v: %v", op, user.Id, err)
+		s.recordAudit(ctx, models.AuditActionLogin, models.AuditOutcomeFailure, user.Id, map[string]any{"method": "password", "reason": "suspended"})
+		return nil, err
+	}
+
+	// unverified accounts are either refused or get tokens carrying email_verified=false
+	if !user.EmailVerified && s.cfg.Security.UnverifiedLoginPolicy == UnverifiedLoginRefuse {
+		log.Printf("%s login refused for unverified email %v", op, user.Email)
+		s.recordAudit(ctx, models.AuditActionLogin, models.AuditOutcomeFailure, user.Id, map[string]any{"method": "password", "reason": "email_not_verified"})
+		return nil, ErrEmailNotVerified
+	}
+
+	// users with a second factor get a challenge that VerifyMFA exchanges for the tokens
+	mfaEnabled, err := s.mfaRepo.IsTOTPEnabled(ctx, uuid.MustParse(user.Id))
+	if err != nil {
+		log.Printf("%s Error checking totp for user %v: %v", op, user.Id, err)
+		return nil, err
+	}
+
+	if mfaEnabled {
+		mfaToken, err := utils.GenerateMFAChallengeToken(uuid.MustParse(user.Id), s.tokenKeys, s.cfg.JWT)
+		if err != nil {
+			log.Printf("%s Error generating mfa challenge: %v", op, err)
+			return nil, err
+		}
+
+		response := &authpb.LoginResponse{
+			Message:     "MFA required",
+			MfaRequired: true,
+			MfaToken:    mfaToken,
+		}
+
+		return response, nil
+	}
+
+	// the failures are only forgotten once the login is complete, VerifyMFA resets them otherwise
+	s.resetLoginThrottle(ctx, subjects)
+
+	// open a new session and generate the token pair
+	tokens, err := s.startSession(ctx, user)
 	if err != nil {
 		log.Printf("%s Error generating JWT token: % v", op, err)
 		return nil, err
 	}
 
 	log.Printf(" %s Login successful for user %v", op, user.Name)
+	s.recordAudit(ctx, models.AuditActionLogin, models.AuditOutcomeSuccess, user.Id, map[string]any{"method": "password"})
 
 	response := &authpb.LoginResponse{
-		Message: "Login successful",
-		Token:   generatedToken,
+		Message:      "Login successful",
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
 	}
 
 	return response, nil
@@ -155,13 +292,20 @@ func (s *authService) ChangeUserPassword(ctx context.Context, req *authpb.Change
 	}
 
 	// check if current password is correct
-	if !utils.VerifyPassword(CurrHashPass, req.Currentpassword) {
-		log.Printf("%s Error current password is incorrect for user by id: %s", op, req.Id)
-		return fmt.Errorf("current password is incorrect")
+	err = s.checkUserCredential(ctx, req.Id, func() error {
+		if !s.verifyPassword(ctx, uuid.MustParse(req.Id), CurrHashPass, req.Currentpassword) {
+			s.recordAudit(ctx, models.AuditActionPasswordChange, models.AuditOutcomeFailure, req.Id, map[string]any{"reason": "invalid_password"})
+			return fmt.Errorf("current password is incorrect")
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("%s Error checking current password for user by id: %s, error: %v", op, req.Id, err)
+		return err
 	}
 
 	// hash password
-	newHashedPassword, err := utils.HashPassword(req.Newpassword)
+	newHashedPassword, err := s.passwords.Hash(req.Newpassword)
 	if err != nil {
 		log.Printf("%s Error hashing password: %v ", op, err)
 		return err
@@ -179,33 +323,7 @@ func (s *authService) ChangeUserPassword(ctx context.Context, req *authpb.Change
 		return err
 	}
 
-	return nil
-}
-
-// ChangeUserEmail allows a user to update their registered email address.
-// This service first checks if the new email is already in use by another account.
-// If the email is unique, it proceeds to update the user's email in the database,
-// ensuring data integrity and preventing duplicate email addresses.
-func (s *authService) ChangeUserEmail(ctx context.Context, req *authpb.ChangeUserEmailRequest) error {
-	op := "authService.ChangeUserEmail"
-
-	// check if email was already used
-	exist, err := s.userRepo.ExistByEmail(ctx, req.Email)
-	if err != nil {
-		log.Printf("%s error check if email was exist: %s", op, req.Email)
-		return fmt.Errorf("error check if email was exist: %s, error : %v", req.Email, err)
-	}
-
-	if exist {
-		log.Printf("%s email was already exist: %s", op, req.Email)
-		return fmt.Errorf("email was already exist: %s, error : %v", req.Email, err)
-	}
-
-	err = s.userRepo.ChangeUserEmail(ctx, req)
-	if err != nil {
-		log.Printf("%s Error change email for user by id: %s, error: %v", op, req.Id, err)
-		return err
-	}
+	s.recordAudit(ctx, models.AuditActionPasswordChange, models.AuditOutcomeSuccess, req.Id, nil)
 
 	return nil
 }
@@ -222,6 +340,14 @@ func (s *authService) DeleteUser(ctx context.Context, user *authpb.DeleteUserReq
 		return err
 	}
 
+	// the sessions go with the user, the access tokens issued to it have to be revoked
+	if err := s.revocations.RevokeUser(ctx, user.Id); err != nil {
+		log.Printf("%s Error revoking access tokens for user by id: %s, error: %v", op, user.Id, err)
+		return err
+	}
+
+	s.recordAudit(ctx, models.AuditActionUserDeleted, models.AuditOutcomeSuccess, user.Id, nil)
+
 	return nil
 
 }
@@ -14,4 +14,42 @@ type AuthService interface {
 	ChangeUserPassword(ctx context.Context, req *authpb.ChangeUserPasswordRequest) error
 	ChangeUserEmail(ctx context.Context, req *authpb.ChangeUserEmailRequest) error
 	DeleteUser(ctx context.Context, user *authpb.DeleteUserRequest) error
+	RefreshToken(ctx context.Context, req *authpb.RefreshTokenRequest) (*authpb.RefreshTokenResponse, error)
+	Logout(ctx context.Context, req *authpb.LogoutRequest) error
+	LogoutAllDevices(ctx context.Context, req *authpb.LogoutAllDevicesRequest) error
+	ListSessions(ctx context.Context, req *authpb.ListSessionsRequest) (*authpb.ListSessionsResponse, error)
+	RevokeSession(ctx context.Context, req *authpb.RevokeSessionRequest) error
+	RequestPasswordReset(ctx context.Context, req *authpb.RequestPasswordResetRequest) error
+	ConfirmPasswordReset(ctx context.Context, req *authpb.ConfirmPasswordResetRequest) error
+	VerifyEmail(ctx context.Context, req *authpb.VerifyEmailRequest) error
+	ResendVerificationEmail(ctx context.Context, req *authpb.ResendVerificationEmailRequest) error
+	ConfirmEmailChange(ctx context.Context, req *authpb.ConfirmEmailChangeRequest) error
+	CancelEmailChange(ctx context.Context, req *authpb.CancelEmailChangeRequest) error
+	BeginTOTPEnrollment(ctx context.Context, req *authpb.BeginTOTPEnrollmentRequest) (*authpb.BeginTOTPEnrollmentResponse, error)
+	ConfirmTOTPEnrollment(ctx context.Context, req *authpb.ConfirmTOTPEnrollmentRequest) (*authpb.ConfirmTOTPEnrollmentResponse, error)
+	DisableTOTP(ctx context.Context, req *authpb.DisableTOTPRequest) error
+	VerifyMFA(ctx context.Context, req *authpb.VerifyMFARequest) (*authpb.VerifyMFAResponse, error)
+	BeginPasskeyRegistration(ctx context.Context, req *authpb.BeginPasskeyRegistrationRequest) (*authpb.BeginPasskeyRegistrationResponse, error)
+	FinishPasskeyRegistration(ctx context.Context, req *authpb.FinishPasskeyRegistrationRequest) (*authpb.FinishPasskeyRegistrationResponse, error)
+	BeginPasskeyLogin(ctx context.Context, req *authpb.BeginPasskeyLoginRequest) (*authpb.BeginPasskeyLoginResponse, error)
+	FinishPasskeyLogin(ctx context.Context, req *authpb.FinishPasskeyLoginRequest) (*authpb.FinishPasskeyLoginResponse, error)
+	ListPasskeys(ctx context.Context, req *authpb.ListPasskeysRequest) (*authpb.ListPasskeysResponse, error)
+	DeletePasskey(ctx context.Context, req *authpb.DeletePasskeyRequest) error
+	UnlockAccount(ctx context.Context, req *authpb.UnlockAccountRequest) error
+	CreateRole(ctx context.Context, req *authpb.CreateRoleRequest) (*authpb.CreateRoleResponse, error)
+	DeleteRole(ctx context.Context, req *authpb.DeleteRoleRequest) error
+	ListRoles(ctx context.Context, req *authpb.ListRolesRequest) (*authpb.ListRolesResponse, error)
+	GrantPermissionToRole(ctx context.Context, req *authpb.GrantPermissionToRoleRequest) error
+	RevokePermissionFromRole(ctx context.Context, req *authpb.RevokePermissionFromRoleRequest) error
+	AssignRoleToUser(ctx context.Context, req *authpb.AssignRoleToUserRequest) error
+	RemoveRoleFromUser(ctx context.Context, req *authpb.RemoveRoleFromUserRequest) error
+	ListUserRoles(ctx context.Context, req *authpb.ListUserRolesRequest) (*authpb.ListUserRolesResponse, error)
+	GetJWKS(ctx context.Context, req *authpb.GetJWKSRequest) (*authpb.GetJWKSResponse, error)
+	RotateSigningKey(ctx context.Context, req *authpb.RotateSigningKeyRequest) (*authpb.RotateSigningKeyResponse, error)
+	IntrospectToken(ctx context.Context, req *authpb.IntrospectTokenRequest) (*authpb.IntrospectTokenResponse, error)
+	ListAuditEvents(ctx context.Context, req *authpb.ListAuditEventsRequest) (*authpb.ListAuditEventsResponse, error)
+	ListMySecurityEvents(ctx context.Context, req *authpb.ListMySecurityEventsRequest) (*authpb.ListMySecurityEventsResponse, error)
+	SuspendUser(ctx context.Context, req *authpb.SuspendUserRequest) (*authpb.SuspendUserResponse, error)
+	LiftSuspension(ctx context.Context, req *authpb.LiftSuspensionRequest) (*authpb.LiftSuspensionResponse, error)
+	ListUserSuspensions(ctx context.Context, req *authpb.ListUserSuspensionsRequest) (*authpb.ListUserSuspensionsResponse, error)
 }
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/Nucleussss/hikayat-forum/auth/pkg/utils"
+	"github.com/google/uuid"
+
+	authpb "github.com/Nucleussss/hikayat-proto/gen/go/auth/v1"
+)
+
+// tokenTypeAccess is the token_type reported for access tokens, as registered by RFC 7662.
+const tokenTypeAccess = "access_token"
+
+// IntrospectToken tells a service client whether an access token is active and what it grants.
+// A token is active when its signature and lifetime are valid, it was not revoked and its session
+// is still live. Everything that makes a token inactive is reported as active = false, not as an
+// error, so callers cannot learn why a token was refused.
+func (s *authService) IntrospectToken(ctx context.Context, req *authpb.IntrospectTokenRequest) (*authpb.IntrospectTokenResponse, error) {
+	op := "authService.IntrospectToken"
+
+	inactive := &authpb.IntrospectTokenResponse{Active: false}
+
+	// only access tokens can be introspected
+	if req.TokenTypeHint != "" && req.TokenTypeHint != tokenTypeAccess {
+		return inactive, nil
+	}
+
+	claims, err := utils.ValidateJWTToken(req.Token, s.tokenKeys, s.cfg.JWT)
+	if err != nil {
+		return inactive, nil
+	}
+
+	if tokenUse, _ := (*claims)["token_use"].(string); tokenUse != utils.TokenUseAccess {
+		return inactive, nil
+	}
+
+	userID, _ := (*claims)["user_id"].(string)
+	tokenID, _ := (*claims)["jti"].(string)
+	sessionID, _ := (*claims)["sid"].(string)
+	issuedAt, err := claims.GetIssuedAt()
+	if err != nil || issuedAt == nil {
+		return inactive, nil
+	}
+	expiresAt, err := claims.GetExpirationTime()
+	if err != nil || expiresAt == nil {
+		return inactive, nil
+	}
+
+	revoked, err := s.revocations.IsRevoked(ctx, RevocationCheck{
+		TokenID:   tokenID,
+		SessionID: sessionID,
+		UserID:    userID,
+		IssuedAt:  issuedAt.Time,
+	})
+	if err != nil {
+		log.Printf("%s Error checking revocation of token %s: %v", op, tokenID, err)
+		return nil, err
+	}
+	if revoked {
+		return inactive, nil
+	}
+
+	// a session that was revoked or whose refresh token was reused takes its access tokens with it
+	familyID, err := uuid.Parse(sessionID)
+	if err != nil {
+		return inactive, nil
+	}
+	active, err := s.sessionRepo.IsSessionFamilyActive(ctx, familyID)
+	if err != nil {
+		log.Printf("%s Error checking session %s: %v", op, sessionID, err)
+		return nil, err
+	}
+	if !active {
+		return inactive, nil
+	}
+
+	response := &authpb.IntrospectTokenResponse{
+		Active:    true,
+		Sub:       userID,
+		Exp:       expiresAt.Unix(),
+		Iat:       issuedAt.Unix(),
+		Sid:       sessionID,
+		Jti:       tokenID,
+		TokenType: tokenTypeAccess,
+	}
+
+	if roles, ok := (*claims)["roles"].([]any); ok {
+		for _, role := range roles {
+			if name, ok := role.(string); ok {
+				response.Roles = append(response.Roles, name)
+			}
+		}
+	}
+	if scope, ok := (*claims)["perms"].(string); ok {
+		response.Scope = strings.TrimSpace(scope)
+	}
+
+	return response, nil
+}
@@ -0,0 +1,94 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/pkg/config"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	authpb "github.com/Nucleussss/hikayat-proto/gen/go/auth/v1"
+)
+
+// introspect introspects the token, with the optional token type hint.
+func (f *authFixture) introspect(t *testing.T, token, hint string) *authpb.IntrospectTokenResponse {
+	t.Helper()
+
+	res, err := f.service.IntrospectToken(context.Background(), &authpb.IntrospectTokenRequest{Token: token, TokenTypeHint: hint})
+	require.NoError(t, err)
+
+	return res
+}
+
+func TestIntrospectToken(t *testing.T) {
+	ctx := context.Background()
+	f := newAuthFixture(t)
+	user := f.addUser(t, "reader@example.com", "correct horse")
+
+	login, err := f.service.Login(ctx, &authpb.LoginRequest{Email: user.Email, Password: "correct horse"})
+	require.NoError(t, err)
+
+	res := f.introspect(t, login.Token, "")
+	require.True(t, res.Active)
+	require.Equal(t, user.Id, res.Sub)
+	require.Equal(t, f.sessions.sessions[0].FamilyID.String(), res.Sid)
+	require.NotEmpty(t, res.Jti)
+	require.Equal(t, "access_token", res.TokenType)
+	require.Greater(t, res.Exp, res.Iat)
+
+	require.True(t, f.introspect(t, login.Token, "access_token").Active)
+
+	// only access tokens are introspected
+	require.False(t, f.introspect(t, login.Token, "refresh_token").Active)
+	require.False(t, f.introspect(t, login.RefreshToken, "").Active)
+	require.False(t, f.introspect(t, "not-a-token", "").Active)
+}
+
+func TestIntrospectTokenOfMFAChallenge(t *testing.T) {
+	// with the access audience set to the issuer, the challenge passes the audience check and only
+	// its token_use tells it apart from an access token
+	f := newAuthFixture(t, func(cfg *config.Config) { cfg.JWT.Audience = cfg.JWT.Issuer })
+	user := f.addUser(t, "mfa@example.com", "correct horse")
+	f.enableTOTP(t, user)
+
+	challenge := loginForChallenge(t, f, user.Email, "correct horse")
+	require.False(t, f.introspect(t, challenge, "").Active)
+}
+
+func TestIntrospectRevokedToken(t *testing.T) {
+	ctx := context.Background()
+	f := newAuthFixture(t)
+	user := f.addUser(t, "reader@example.com", "correct horse")
+
+	login, err := f.service.Login(ctx, &authpb.LoginRequest{Email: user.Email, Password: "correct horse"})
+	require.NoError(t, err)
+	other, err := f.service.Login(ctx, &authpb.LoginRequest{Email: user.Email, Password: "correct horse"})
+	require.NoError(t, err)
+
+	res := f.introspect(t, login.Token, "")
+	require.True(t, res.Active)
+	require.NoError(t, f.revocations.RevokeToken(ctx, res.Jti, time.Unix(res.Exp, 0)))
+
+	require.False(t, f.introspect(t, login.Token, "").Active)
+	require.True(t, f.introspect(t, other.Token, "").Active)
+}
+
+func TestIntrospectTokenOfKilledSession(t *testing.T) {
+	ctx := context.Background()
+	f := newAuthFixture(t)
+	user := f.addUser(t, "reader@example.com", "correct horse")
+
+	login, err := f.service.Login(ctx, &authpb.LoginRequest{Email: user.Email, Password: "correct horse"})
+	require.NoError(t, err)
+	other, err := f.service.Login(ctx, &authpb.LoginRequest{Email: user.Email, Password: "correct horse"})
+	require.NoError(t, err)
+
+	// the access token dies with its session even though the token itself was never revoked
+	sid := f.introspect(t, login.Token, "").Sid
+	require.NoError(t, f.sessions.RevokeSessionFamily(ctx, uuid.MustParse(sid)))
+
+	require.False(t, f.introspect(t, login.Token, "").Active)
+	require.True(t, f.introspect(t, other.Token, "").Active)
+}
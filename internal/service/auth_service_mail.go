@@ -0,0 +1,31 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// mailSendTimeout bounds how long a background delivery may take.
+const mailSendTimeout = 30 * time.Second
+
+// sendMail renders the named template in the recipient's locale and hands it to the mailer in the
+// background, so a slow or failing mail server never delays or fails the request that triggered it.
+func (s *authService) sendMail(name, to, locale string, data any) {
+	op := "authService.sendMail"
+
+	msg, err := s.mailTemplates.Render(name, locale, to, data)
+	if err != nil {
+		log.Printf("%s Error rendering %s mail: %v", op, name, err)
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), mailSendTimeout)
+		defer cancel()
+
+		if err := s.mailer.Send(ctx, msg); err != nil {
+			log.Printf("%s Error sending %s mail: %v", op, name, err)
+		}
+	}()
+}
@@ -0,0 +1,232 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/Nucleussss/hikayat-forum/auth/pkg/utils"
+	"github.com/google/uuid"
+
+	authpb "github.com/Nucleussss/hikayat-proto/gen/go/auth/v1"
+)
+
+// recoveryCodeCount is the number of single use recovery codes handed out on TOTP enrollment.
+const recoveryCodeCount = 10
+
+// mfaChallengeMaxFailures is the number of wrong codes that use up an mfa challenge, the user has
+// to log in with the password again.
+const mfaChallengeMaxFailures = 3
+
+// BeginTOTPEnrollment creates a new TOTP secret for the user. The secret stays pending, and Login
+// keeps working with the password alone, until ConfirmTOTPEnrollment verified a first code.
+func (s *authService) BeginTOTPEnrollment(ctx context.Context, req *authpb.BeginTOTPEnrollmentRequest) (*authpb.BeginTOTPEnrollmentResponse, error) {
+	op := "authService.BeginTOTPEnrollment"
+
+	user, err := s.userRepo.FindUserById(ctx, req.Id)
+	if err != nil {
+		log.Printf("%s Error finding user by id: %s, error: %v", op, req.Id, err)
+		return nil, err
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		log.Printf("%s Error generating totp secret: %v", op, err)
+		return nil, err
+	}
+
+	if err := s.mfaRepo.SavePendingTOTP(ctx, uuid.MustParse(req.Id), secret); err != nil {
+		log.Printf("%s Error saving totp secret for user by id: %s, error: %v", op, req.Id, err)
+		return nil, err
+	}
+
+	response := &authpb.BeginTOTPEnrollmentResponse{
+		Secret:     secret,
+		OtpauthUri: utils.TOTPURI(s.cfg.Security.TOTPIssuer, user.Email, secret),
+	}
+
+	return response, nil
+}
+
+// ConfirmTOTPEnrollment enables TOTP once the user proved their authenticator produces valid codes,
+// and returns the recovery codes. The codes are only ever shown here, the database keeps their hashes.
+func (s *authService) ConfirmTOTPEnrollment(ctx context.Context, req *authpb.ConfirmTOTPEnrollmentRequest) (*authpb.ConfirmTOTPEnrollmentResponse, error) {
+	op := "authService.ConfirmTOTPEnrollment"
+
+	userID := uuid.MustParse(req.Id)
+
+	totp, err := s.mfaRepo.FindTOTP(ctx, userID)
+	if err != nil {
+		log.Printf("%s Error finding totp for user by id: %s, error: %v", op, req.Id, err)
+		return nil, fmt.Errorf("no pending totp enrollment")
+	}
+
+	if totp.EnabledAt != nil {
+		return nil, fmt.Errorf("totp already enabled")
+	}
+
+	var step int64
+	err = s.checkUserCredential(ctx, req.Id, func() error {
+		var ok bool
+		if step, ok = utils.ValidateTOTP(totp.Secret, req.Code, time.Now()); !ok {
+			return fmt.Errorf("invalid totp code")
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("%s Error checking totp code for user by id: %s, error: %v", op, req.Id, err)
+		return nil, err
+	}
+
+	recoveryCodes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		log.Printf("%s Error generating recovery codes: %v", op, err)
+		return nil, err
+	}
+
+	codeHashes := make([]string, 0, len(recoveryCodes))
+	for _, code := range recoveryCodes {
+		codeHashes = append(codeHashes, utils.HashToken(utils.NormalizeRecoveryCode(code)))
+	}
+
+	if err := s.mfaRepo.EnableTOTP(ctx, userID, step, codeHashes); err != nil {
+		log.Printf("%s Error enabling totp for user by id: %s, error: %v", op, req.Id, err)
+		return nil, err
+	}
+
+	response := &authpb.ConfirmTOTPEnrollmentResponse{
+		Message:       "TOTP enabled successfully",
+		RecoveryCodes: recoveryCodes,
+	}
+
+	return response, nil
+}
+
+// DisableTOTP turns off TOTP after checking both the password and a TOTP or recovery code.
+func (s *authService) DisableTOTP(ctx context.Context, req *authpb.DisableTOTPRequest) error {
+	op := "authService.DisableTOTP"
+
+	userID := uuid.MustParse(req.Id)
+
+	currHashPass, err := s.userRepo.GetUserPasswordHash(ctx, userID)
+	if err != nil {
+		log.Printf("%s Error getting user password hash for user by id: %s, error: %v", op, req.Id, err)
+		return err
+	}
+
+	err = s.checkUserCredential(ctx, req.Id, func() error {
+		if !s.verifyPassword(ctx, userID, currHashPass, req.Password) {
+			return fmt.Errorf("password is incorrect")
+		}
+		return s.verifySecondFactor(ctx, userID, req.Code)
+	})
+	if err != nil {
+		log.Printf("%s Error checking credentials for user by id: %s, error: %v", op, req.Id, err)
+		return err
+	}
+
+	if err := s.mfaRepo.DeleteTOTP(ctx, userID); err != nil {
+		log.Printf("%s Error disabling totp for user by id: %s, error: %v", op, req.Id, err)
+		return err
+	}
+
+	return nil
+}
+
+// VerifyMFA finishes a login that Login answered with an mfa_required challenge. The challenge token
+// is single use: it is used up as soon as a code was accepted, or after mfaChallengeMaxFailures
+// wrong codes. Wrong codes also count towards the account lockout of Login.
+func (s *authService) VerifyMFA(ctx context.Context, req *authpb.VerifyMFARequest) (*authpb.VerifyMFAResponse, error) {
+	op := "authService.VerifyMFA"
+
+	userID, tokenID, expiresAt, err := utils.ValidateMFAChallengeToken(req.MfaToken, s.tokenKeys, s.cfg.JWT)
+	if err != nil {
+		log.Printf("%s Error validating mfa challenge: %v", op, err)
+		return nil, fmt.Errorf("invalid or expired mfa token")
+	}
+
+	user, err := s.userRepo.FindUserById(ctx, userID.String())
+	if err != nil {
+		log.Printf("%s Error finding user by id: %s, error: %v", op, userID, err)
+		return nil, err
+	}
+
+	// refuse attempts while the account or the source IP is backing off or locked
+	subjects := s.loginSubjects(ctx, user.Email)
+	if err := s.checkLoginThrottle(ctx, subjects); err != nil {
+		log.Printf("%s mfa throttled for user by id: %s: %v", op, userID, err)
+		s.recordAudit(ctx, models.AuditActionLogin, models.AuditOutcomeFailure, user.Id, map[string]any{"method": "mfa", "reason": "throttled"})
+		return nil, err
+	}
+
+	// the challenge is claimed while the code is checked, so concurrent submissions cannot both use it
+	claimed, err := s.mfaRepo.ClaimMFAChallenge(ctx, tokenID, userID, expiresAt, mfaChallengeMaxFailures)
+	if err != nil {
+		log.Printf("%s Error claiming mfa challenge: %v", op, err)
+		return nil, err
+	}
+	if !claimed {
+		log.Printf("%s used mfa challenge presented for user by id: %s", op, userID)
+		return nil, fmt.Errorf("invalid or expired mfa token")
+	}
+
+	if err := s.verifySecondFactor(ctx, userID, req.Code); err != nil {
+		log.Printf("%s Error verifying second factor for user by id: %s, error: %v", op, userID, err)
+		s.recordAudit(ctx, models.AuditActionLogin, models.AuditOutcomeFailure, user.Id, map[string]any{"method": "mfa", "reason": "invalid_code"})
+		s.recordLoginFailure(ctx, subjects, user)
+
+		if failures, err := s.mfaRepo.ReleaseMFAChallenge(ctx, tokenID); err != nil {
+			log.Printf("%s Error releasing mfa challenge: %v", op, err)
+		} else if failures >= mfaChallengeMaxFailures {
+			log.Printf("%s mfa challenge used up after %d wrong codes for user by id: %s", op, failures, userID)
+		}
+		return nil, err
+	}
+
+	s.resetLoginThrottle(ctx, subjects)
+
+	// the user may have been suspended since the challenge was issued
+	if err := s.checkAccountActive(ctx, user); err != nil {
+		log.Printf("%s login refused for user %v: %v", op, user.Id, err)
+		s.recordAudit(ctx, models.AuditActionLogin, models.AuditOutcomeFailure, user.Id, map[string]any{"method": "mfa", "reason": "suspended"})
+		return nil, err
+	}
+
+	tokens, err := s.startSession(ctx, user)
+	if err != nil {
+		log.Printf("%s Error generating JWT token: %v", op, err)
+		return nil, err
+	}
+
+	s.recordAudit(ctx, models.AuditActionLogin, models.AuditOutcomeSuccess, user.Id, map[string]any{"method": "mfa"})
+
+	response := &authpb.VerifyMFAResponse{
+		Message:      "Login successful",
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+	}
+
+	return response, nil
+}
+
+// verifySecondFactor accepts either a TOTP code from the user's authenticator or one of their
+// unused recovery codes. Both are consumed, so neither can be replayed.
+func (s *authService) verifySecondFactor(ctx context.Context, userID uuid.UUID, code string) error {
+	totp, err := s.mfaRepo.FindTOTP(ctx, userID)
+	if err != nil || totp.EnabledAt == nil {
+		return fmt.Errorf("totp not enabled")
+	}
+
+	if step, ok := utils.ValidateTOTP(totp.Secret, code, time.Now()); ok {
+		return s.mfaRepo.UseTOTPStep(ctx, userID, step)
+	}
+
+	if err := s.mfaRepo.UseRecoveryCode(ctx, userID, utils.HashToken(utils.NormalizeRecoveryCode(code))); err != nil {
+		return fmt.Errorf("invalid code")
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package service_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/service"
+	"github.com/Nucleussss/hikayat-forum/auth/pkg/utils"
+	"github.com/stretchr/testify/require"
+
+	authpb "github.com/Nucleussss/hikayat-proto/gen/go/auth/v1"
+)
+
+// loginForChallenge logs in with the password and returns the mfa challenge token.
+func loginForChallenge(t *testing.T, f *authFixture, email, plain string) string {
+	t.Helper()
+
+	res, err := f.service.Login(context.Background(), &authpb.LoginRequest{Email: email, Password: plain})
+	require.NoError(t, err)
+	require.True(t, res.MfaRequired)
+
+	return res.MfaToken
+}
+
+func TestVerifyMFAUsesUpChallenges(t *testing.T) {
+	ctx := context.Background()
+	f := newAuthFixture(t)
+	user := f.addUser(t, "mfa@example.com", "correct horse")
+	secret := f.enableTOTP(t, user)
+
+	code, err := utils.TOTPCode(secret, time.Now())
+	require.NoError(t, err)
+
+	// three wrong codes use the challenge up, the right code is refused afterwards
+	challenge := loginForChallenge(t, f, user.Email, "correct horse")
+	for i := 0; i < 3; i++ {
+		_, err := f.service.VerifyMFA(ctx, &authpb.VerifyMFARequest{MfaToken: challenge, Code: "000000"})
+		require.Error(t, err)
+	}
+	_, err = f.service.VerifyMFA(ctx, &authpb.VerifyMFARequest{MfaToken: challenge, Code: code})
+	require.Error(t, err)
+
+	// a new challenge accepts the right code, and only once
+	challenge = loginForChallenge(t, f, user.Email, "correct horse")
+	res, err := f.service.VerifyMFA(ctx, &authpb.VerifyMFARequest{MfaToken: challenge, Code: code})
+	require.NoError(t, err)
+	require.NotEmpty(t, res.Token)
+
+	next, err := utils.TOTPCode(secret, time.Now().Add(30*time.Second))
+	require.NoError(t, err)
+	_, err = f.service.VerifyMFA(ctx, &authpb.VerifyMFARequest{MfaToken: challenge, Code: next})
+	require.Error(t, err)
+}
+
+func TestVerifyMFAFailuresLockTheAccount(t *testing.T) {
+	ctx := context.Background()
+	f := newAuthFixture(t)
+	user := f.addUser(t, "mfa@example.com", "correct horse")
+	secret := f.enableTOTP(t, user)
+
+	// the password alone does not forget the wrong codes, five of them lock the account
+	challenge := loginForChallenge(t, f, user.Email, "correct horse")
+	for i := 0; i < 3; i++ {
+		_, err := f.service.VerifyMFA(ctx, &authpb.VerifyMFARequest{MfaToken: challenge, Code: "000000"})
+		require.Error(t, err)
+	}
+	challenge = loginForChallenge(t, f, user.Email, "correct horse")
+	for i := 0; i < 2; i++ {
+		_, err := f.service.VerifyMFA(ctx, &authpb.VerifyMFARequest{MfaToken: challenge, Code: "000000"})
+		require.Error(t, err)
+	}
+
+	code, err := utils.TOTPCode(secret, time.Now())
+	require.NoError(t, err)
+
+	var throttled *service.ThrottledError
+	_, err = f.service.VerifyMFA(ctx, &authpb.VerifyMFARequest{MfaToken: challenge, Code: code})
+	require.ErrorAs(t, err, &throttled)
+
+	_, err = f.service.Login(ctx, &authpb.LoginRequest{Email: user.Email, Password: "correct horse"})
+	require.ErrorAs(t, err, &throttled)
+}
+
+func TestVerifyMFAWithRecoveryCode(t *testing.T) {
+	ctx := context.Background()
+	f := newAuthFixture(t)
+	user := f.addUser(t, "mfa@example.com", "correct horse")
+
+	enrollment, err := f.service.BeginTOTPEnrollment(ctx, &authpb.BeginTOTPEnrollmentRequest{Id: user.Id})
+	require.NoError(t, err)
+	code, err := utils.TOTPCode(enrollment.Secret, time.Now())
+	require.NoError(t, err)
+	confirmed, err := f.service.ConfirmTOTPEnrollment(ctx, &authpb.ConfirmTOTPEnrollmentRequest{Id: user.Id, Code: code})
+	require.NoError(t, err)
+	require.NotEmpty(t, confirmed.RecoveryCodes)
+
+	// a recovery code typed without its dashes is accepted, once
+	typed := strings.ToUpper(strings.ReplaceAll(confirmed.RecoveryCodes[0], "-", ""))
+
+	challenge := loginForChallenge(t, f, user.Email, "correct horse")
+	res, err := f.service.VerifyMFA(ctx, &authpb.VerifyMFARequest{MfaToken: challenge, Code: typed})
+	require.NoError(t, err)
+	require.NotEmpty(t, res.Token)
+
+	challenge = loginForChallenge(t, f, user.Email, "correct horse")
+	_, err = f.service.VerifyMFA(ctx, &authpb.VerifyMFARequest{MfaToken: challenge, Code: confirmed.RecoveryCodes[0]})
+	require.Error(t, err)
+}
@@ -0,0 +1,282 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/passkey"
+	"github.com/Nucleussss/hikayat-forum/auth/pkg/utils"
+	"github.com/google/uuid"
+
+	authpb "github.com/Nucleussss/hikayat-proto/gen/go/auth/v1"
+)
+
+// defaultPasskeyName is used when the user did not name a passkey on registration.
+const defaultPasskeyName = "Passkey"
+
+// BeginPasskeyRegistration starts a WebAuthn registration ceremony for the user. The returned
+// options go to navigator.credentials.create(), the ceremony id back to FinishPasskeyRegistration.
+func (s *authService) BeginPasskeyRegistration(ctx context.Context, req *authpb.BeginPasskeyRegistrationRequest) (*authpb.BeginPasskeyRegistrationResponse, error) {
+	op := "authService.BeginPasskeyRegistration"
+
+	userID := uuid.MustParse(req.Id)
+
+	_, account, err := s.passkeyAccount(ctx, userID)
+	if err != nil {
+		log.Printf("%s Error loading passkeys for user by id: %s, error: %v", op, req.Id, err)
+		return nil, err
+	}
+
+	options, session, err := s.relyingParty.BeginRegistration(account)
+	if err != nil {
+		log.Printf("%s Error beginning registration for user by id: %s, error: %v", op, req.Id, err)
+		return nil, err
+	}
+
+	ceremony := &models.WebAuthnCeremony{
+		UserID:      &userID,
+		Kind:        models.WebAuthnCeremonyRegistration,
+		SessionData: session,
+		ExpiresAt:   time.Now().Add(s.cfg.WebAuthn.CeremonyTTL),
+	}
+
+	if err := s.passkeyRepo.CreateCeremony(ctx, ceremony); err != nil {
+		log.Printf("%s Error storing ceremony for user by id: %s, error: %v", op, req.Id, err)
+		return nil, err
+	}
+
+	response := &authpb.BeginPasskeyRegistrationResponse{
+		CeremonyId:  ceremony.ID.String(),
+		OptionsJson: string(options),
+	}
+
+	return response, nil
+}
+
+// FinishPasskeyRegistration verifies the authenticator's response to a registration ceremony
+// and stores the new passkey.
+func (s *authService) FinishPasskeyRegistration(ctx context.Context, req *authpb.FinishPasskeyRegistrationRequest) (*authpb.FinishPasskeyRegistrationResponse, error) {
+	op := "authService.FinishPasskeyRegistration"
+
+	userID := uuid.MustParse(req.Id)
+
+	ceremonyID, err := uuid.Parse(req.CeremonyId)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ceremony id")
+	}
+
+	ceremony, err := s.passkeyRepo.ConsumeCeremony(ctx, ceremonyID, models.WebAuthnCeremonyRegistration)
+	if err != nil {
+		log.Printf("%s Error consuming ceremony %s: %v", op, req.CeremonyId, err)
+		return nil, err
+	}
+
+	if ceremony.UserID == nil || *ceremony.UserID != userID {
+		log.Printf("%s ceremony %s does not belong to user by id: %s", op, req.CeremonyId, req.Id)
+		return nil, fmt.Errorf("webauthn ceremony not found or expired")
+	}
+
+	_, account, err := s.passkeyAccount(ctx, userID)
+	if err != nil {
+		log.Printf("%s Error loading passkeys for user by id: %s, error: %v", op, req.Id, err)
+		return nil, err
+	}
+
+	registered, err := s.relyingParty.FinishRegistration(account, ceremony.SessionData, []byte(req.CredentialJson))
+	if err != nil {
+		log.Printf("%s Error finishing registration for user by id: %s, error: %v", op, req.Id, err)
+		return nil, err
+	}
+
+	registered.Name = req.Name
+	if registered.Name == "" {
+		registered.Name = defaultPasskeyName
+	}
+
+	if err := s.passkeyRepo.CreatePasskey(ctx, registered); err != nil {
+		log.Printf("%s Error storing passkey for user by id: %s, error: %v", op, req.Id, err)
+		return nil, err
+	}
+
+	response := &authpb.FinishPasskeyRegistrationResponse{
+		Message: "Passkey registered successfully",
+		Passkey: utils.PasskeyModelToPB(registered),
+	}
+
+	return response, nil
+}
+
+// BeginPasskeyLogin starts a WebAuthn login ceremony. No user is named up front, the
+// authenticator offers the passkeys it holds for this relying party.
+func (s *authService) BeginPasskeyLogin(ctx context.Context, req *authpb.BeginPasskeyLoginRequest) (*authpb.BeginPasskeyLoginResponse, error) {
+	op := "authService.BeginPasskeyLogin"
+
+	options, session, err := s.relyingParty.BeginLogin()
+	if err != nil {
+		log.Printf("%s Error beginning login: %v", op, err)
+		return nil, err
+	}
+
+	ceremony := &models.WebAuthnCeremony{
+		Kind:        models.WebAuthnCeremonyLogin,
+		SessionData: session,
+		ExpiresAt:   time.Now().Add(s.cfg.WebAuthn.CeremonyTTL),
+	}
+
+	if err := s.passkeyRepo.CreateCeremony(ctx, ceremony); err != nil {
+		log.Printf("%s Error storing ceremony: %v", op, err)
+		return nil, err
+	}
+
+	response := &authpb.BeginPasskeyLoginResponse{
+		CeremonyId:  ceremony.ID.String(),
+		OptionsJson: string(options),
+	}
+
+	return response, nil
+}
+
+// FinishPasskeyLogin verifies the authenticator's assertion and opens a session exactly like
+// Login does. The relying party requires user verification, so the passkey already combines
+// possession with a PIN or biometric and no TOTP challenge follows.
+func (s *authService) FinishPasskeyLogin(ctx context.Context, req *authpb.FinishPasskeyLoginRequest) (*authpb.FinishPasskeyLoginResponse, error) {
+	op := "authService.FinishPasskeyLogin"
+
+	ceremonyID, err := uuid.Parse(req.CeremonyId)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ceremony id")
+	}
+
+	// refuse attempts while the source IP is backing off or locked, the account is not known yet
+	if err := s.checkLoginThrottle(ctx, s.ipSubjects(ctx)); err != nil {
+		log.Printf("%s passkey login throttled: %v", op, err)
+		s.recordAudit(ctx, models.AuditActionLogin, models.AuditOutcomeFailure, "", map[string]any{"method": "passkey", "reason": "throttled"})
+		return nil, err
+	}
+
+	ceremony, err := s.passkeyRepo.ConsumeCeremony(ctx, ceremonyID, models.WebAuthnCeremonyLogin)
+	if err != nil {
+		log.Printf("%s Error consuming ceremony %s: %v", op, req.CeremonyId, err)
+		return nil, err
+	}
+
+	var user *authpb.User
+	lookup := func(userID uuid.UUID) (*passkey.Account, error) {
+		found, account, err := s.passkeyAccount(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		user = found
+		return account, nil
+	}
+
+	_, used, err := s.relyingParty.FinishLogin(ceremony.SessionData, []byte(req.CredentialJson), lookup)
+	if err != nil {
+		log.Printf("%s Error finishing login: %v", op, err)
+		targetUserID, subjects := "", s.ipSubjects(ctx)
+		if user != nil {
+			targetUserID, subjects = user.Id, s.loginSubjects(ctx, user.Email)
+		}
+		s.recordAudit(ctx, models.AuditActionLogin, models.AuditOutcomeFailure, targetUserID, map[string]any{"method": "passkey", "reason": "invalid_assertion"})
+		s.recordLoginFailure(ctx, subjects, user)
+		return nil, err
+	}
+
+	if err := s.passkeyRepo.UpdatePasskeyUsage(ctx, used.ID, used.SignCount, used.Flags); err != nil {
+		log.Printf("%s Error updating passkey %s: %v", op, used.ID, err)
+		return nil, err
+	}
+
+	// suspended and deactivated accounts get no tokens
+	if err := s.checkAccountActive(ctx, user); err != nil {
+		log.Printf("%s login refused for user %v: %v", op, user.Id, err)
+		s.recordAudit(ctx, models.AuditActionLogin, models.AuditOutcomeFailure, user.Id, map[string]any{"method": "passkey", "reason": "suspended"})
+		return nil, err
+	}
+
+	// the same policy as for password logins applies to unverified accounts
+	if !user.EmailVerified && s.cfg.Security.UnverifiedLoginPolicy == UnverifiedLoginRefuse {
+		log.Printf("%s login refused for unverified email %v", op, user.Email)
+		s.recordAudit(ctx, models.AuditActionLogin, models.AuditOutcomeFailure, user.Id, map[string]any{"method": "passkey", "reason": "email_not_verified"})
+		return nil, ErrEmailNotVerified
+	}
+
+	s.resetLoginThrottle(ctx, s.loginSubjects(ctx, user.Email))
+
+	tokens, err := s.startSession(ctx, user)
+	if err != nil {
+		log.Printf("%s Error generating JWT token: %v", op, err)
+		return nil, err
+	}
+
+	s.recordAudit(ctx, models.AuditActionLogin, models.AuditOutcomeSuccess, user.Id, map[string]any{"method": "passkey"})
+
+	response := &authpb.FinishPasskeyLoginResponse{
+		Message:      "Login successful",
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+	}
+
+	return response, nil
+}
+
+// ListPasskeys returns the passkeys registered by the user.
+func (s *authService) ListPasskeys(ctx context.Context, req *authpb.ListPasskeysRequest) (*authpb.ListPasskeysResponse, error) {
+	op := "authService.ListPasskeys"
+
+	passkeys, err := s.passkeyRepo.ListUserPasskeys(ctx, uuid.MustParse(req.Id))
+	if err != nil {
+		log.Printf("%s Error listing passkeys for user by id: %s, error: %v", op, req.Id, err)
+		return nil, err
+	}
+
+	response := &authpb.ListPasskeysResponse{}
+	for _, p := range passkeys {
+		response.Passkeys = append(response.Passkeys, utils.PasskeyModelToPB(p))
+	}
+
+	return response, nil
+}
+
+// DeletePasskey removes one of the user's passkeys.
+func (s *authService) DeletePasskey(ctx context.Context, req *authpb.DeletePasskeyRequest) error {
+	op := "authService.DeletePasskey"
+
+	passkeyID, err := uuid.Parse(req.PasskeyId)
+	if err != nil {
+		return fmt.Errorf("invalid passkey id")
+	}
+
+	if err := s.passkeyRepo.DeleteUserPasskey(ctx, uuid.MustParse(req.Id), passkeyID); err != nil {
+		log.Printf("%s Error deleting passkey %s for user by id: %s, error: %v", op, req.PasskeyId, req.Id, err)
+		return err
+	}
+
+	return nil
+}
+
+// passkeyAccount loads the user and their passkeys as the relying party sees them.
+func (s *authService) passkeyAccount(ctx context.Context, userID uuid.UUID) (*authpb.User, *passkey.Account, error) {
+	user, err := s.userRepo.FindUserById(ctx, userID.String())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	passkeys, err := s.passkeyRepo.ListUserPasskeys(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	account := &passkey.Account{
+		UserID:      userID,
+		Name:        user.Email,
+		DisplayName: user.Name,
+		Passkeys:    passkeys,
+	}
+
+	return user, account, nil
+}
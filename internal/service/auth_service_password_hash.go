@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"log"
+
+	"github.com/google/uuid"
+)
+
+// verifyPassword checks a password against the stored hash of the user. A hash made with an
+// outdated algorithm, cost or pepper is replaced while the plaintext is at hand, so accounts move
+// to the current settings as their owners log in.
+func (s *authService) verifyPassword(ctx context.Context, userID uuid.UUID, encoded, password string) bool {
+	op := "authService.verifyPassword"
+
+	match, needsRehash, err := s.passwords.Verify(encoded, password)
+	if err != nil {
+		log.Printf("%s Error verifying password hash of user %s: %v", op, userID, err)
+		return false
+	}
+	if !match || !needsRehash {
+		return match
+	}
+
+	// a failed upgrade leaves the old hash in place, which still verifies
+	rehashed, err := s.passwords.Hash(password)
+	if err != nil {
+		log.Printf("%s Error rehashing password of user %s: %v", op, userID, err)
+		return true
+	}
+	if err := s.userRepo.ReplacePasswordHash(ctx, userID, encoded, rehashed); err != nil {
+		log.Printf("%s Error storing rehashed password of user %s: %v", op, userID, err)
+	}
+
+	return true
+}
+
+// newDummyPasswordHash hashes a random password with the configured parameters. Logins for unknown
+// accounts are checked against it, so they take as long as a wrong password for a known account.
+func newDummyPasswordHash(passwords PasswordHasher) string {
+	op := "authService.newDummyPasswordHash"
+
+	if passwords == nil {
+		return ""
+	}
+
+	dummy, err := passwords.Hash(uuid.NewString())
+	if err != nil {
+		log.Printf("%s Error hashing the dummy password: %v", op, err)
+	}
+
+	return dummy
+}
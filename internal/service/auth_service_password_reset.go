@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/Nucleussss/hikayat-forum/auth/pkg/utils"
+	"github.com/google/uuid"
+
+	authpb "github.com/Nucleussss/hikayat-proto/gen/go/auth/v1"
+)
+
+// RequestPasswordReset issues a single use reset token for the account registered with the email.
+// It returns nil whether or not the email belongs to an account, so callers cannot use it to
+// discover registered addresses.
+func (s *authService) RequestPasswordReset(ctx context.Context, req *authpb.RequestPasswordResetRequest) error {
+	op := "authService.RequestPasswordReset"
+
+	// generate the token before the lookup so both outcomes do the same work
+	token, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		log.Printf("%s Error generating reset token: %v", op, err)
+		return err
+	}
+
+	user, err := s.userRepo.FindUserByEmail(ctx, req.Email)
+	if err != nil {
+		log.Printf("%s no account for password reset request: %v", op, err)
+		return nil
+	}
+
+	userID := uuid.MustParse(user.Id)
+
+	// only the latest reset link of a user stays valid
+	if err := s.passwordResetRepo.DeleteUserPasswordResets(ctx, userID); err != nil {
+		log.Printf("%s Error deleting previous resets for user by id: %s, error: %v", op, user.Id, err)
+		return err
+	}
+
+	reset := &models.PasswordReset{
+		TokenHash: utils.HashToken(token),
+		UserID:    userID,
+		ExpiredAt: time.Now().Add(s.cfg.Security.PasswordResetTTL),
+	}
+
+	if err := s.passwordResetRepo.CreatePasswordReset(ctx, reset); err != nil {
+		log.Printf("%s Error creating password reset for user by id: %s, error: %v", op, user.Id, err)
+		return err
+	}
+
+	s.sendMail("password_reset", user.Email, user.Locale, map[string]any{
+		"Name":             user.Name,
+		"Link":             s.cfg.Mail.PasswordResetURL + token,
+		"ExpiresInMinutes": int(s.cfg.Security.PasswordResetTTL.Minutes()),
+	})
+
+	return nil
+}
+
+// ConfirmPasswordReset consumes a reset token and sets the new password. Every session of the user
+// is revoked afterwards, so whoever held the account before the reset is logged out.
+func (s *authService) ConfirmPasswordReset(ctx context.Context, req *authpb.ConfirmPasswordResetRequest) error {
+	op := "authService.ConfirmPasswordReset"
+
+	userID, err := s.passwordResetRepo.ConsumePasswordReset(ctx, utils.HashToken(req.Token))
+	if err != nil {
+		log.Printf("%s Error consuming password reset: %v", op, err)
+		return fmt.Errorf("invalid or expired reset token")
+	}
+
+	// hash password
+	newHashedPassword, err := s.passwords.Hash(req.NewPassword)
+	if err != nil {
+		log.Printf("%s Error hashing password: %v ", op, err)
+		return err
+	}
+
+	usrNewPassword := &authpb.ChangeUserPasswordRequest{
+		Id:          userID.String(),
+		Newpassword: newHashedPassword,
+	}
+
+	if err := s.userRepo.ChangeUserPassword(ctx, usrNewPassword); err != nil {
+		log.Printf("%s Error change password for user by id: %s, error: %v", op, userID, err)
+		return err
+	}
+
+	if err := s.passwordResetRepo.DeleteUserPasswordResets(ctx, userID); err != nil {
+		log.Printf("%s Error deleting remaining resets for user by id: %s, error: %v", op, userID, err)
+	}
+
+	if err := s.revokeAllSessions(ctx, userID); err != nil {
+		log.Printf("%s Error revoking sessions for user by id: %s, error: %v", op, userID, err)
+		return err
+	}
+
+	s.recordAudit(ctx, models.AuditActionPasswordReset, models.AuditOutcomeSuccess, userID.String(), nil)
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/service"
+	"github.com/stretchr/testify/require"
+
+	authpb "github.com/Nucleussss/hikayat-proto/gen/go/auth/v1"
+)
+
+const resetURL = "https://hikayat.test/reset?token="
+
+func TestRequestPasswordReset(t *testing.T) {
+	ctx := context.Background()
+	f := newAuthFixture(t)
+	user := f.addUser(t, "reader@example.com", "correct horse")
+
+	// an unknown address gets the same answer and nothing is stored or sent
+	require.NoError(t, f.service.RequestPasswordReset(ctx, &authpb.RequestPasswordResetRequest{Email: "nobody@example.com"}))
+	require.Empty(t, f.resets.resets)
+
+	require.NoError(t, f.service.RequestPasswordReset(ctx, &authpb.RequestPasswordResetRequest{Email: user.Email}))
+	first := mailToken(t, f.nextMail(t, user.Email), resetURL)
+	require.Len(t, f.resets.resets, 1)
+	require.NotEqual(t, first, f.resets.resets[0].TokenHash)
+
+	// only the latest link stays valid
+	require.NoError(t, f.service.RequestPasswordReset(ctx, &authpb.RequestPasswordResetRequest{Email: user.Email}))
+	second := mailToken(t, f.nextMail(t, user.Email), resetURL)
+	require.Len(t, f.resets.resets, 1)
+
+	require.Error(t, f.service.ConfirmPasswordReset(ctx, &authpb.ConfirmPasswordResetRequest{Token: first, NewPassword: "battery staple"}))
+	require.NoError(t, f.service.ConfirmPasswordReset(ctx, &authpb.ConfirmPasswordResetRequest{Token: second, NewPassword: "battery staple"}))
+
+	select {
+	case msg := <-f.mailer.sent:
+		t.Fatalf("unexpected mail to %s", msg.To)
+	default:
+	}
+}
+
+func TestConfirmPasswordReset(t *testing.T) {
+	ctx := context.Background()
+	f := newAuthFixture(t)
+	user := f.addUser(t, "reader@example.com", "correct horse")
+
+	login, err := f.service.Login(ctx, &authpb.LoginRequest{Email: user.Email, Password: "correct horse"})
+	require.NoError(t, err)
+	issuedAt := time.Now().Add(-time.Minute)
+
+	require.NoError(t, f.service.RequestPasswordReset(ctx, &authpb.RequestPasswordResetRequest{Email: user.Email}))
+	token := mailToken(t, f.nextMail(t, user.Email), resetURL)
+
+	require.NoError(t, f.service.ConfirmPasswordReset(ctx, &authpb.ConfirmPasswordResetRequest{Token: token, NewPassword: "battery staple"}))
+
+	// the link is single use
+	require.Error(t, f.service.ConfirmPasswordReset(ctx, &authpb.ConfirmPasswordResetRequest{Token: token, NewPassword: "another staple"}))
+
+	// only the new password works
+	_, err = f.service.Login(ctx, &authpb.LoginRequest{Email: user.Email, Password: "correct horse"})
+	require.Error(t, err)
+	_, err = f.service.Login(ctx, &authpb.LoginRequest{Email: user.Email, Password: "battery staple"})
+	require.NoError(t, err)
+
+	// whoever held the account before the reset is logged out
+	_, err = f.service.RefreshToken(ctx, &authpb.RefreshTokenRequest{RefreshToken: login.RefreshToken})
+	require.Error(t, err)
+
+	revoked, err := f.revocations.IsRevoked(ctx, service.RevocationCheck{TokenID: "jti", UserID: user.Id, IssuedAt: issuedAt})
+	require.NoError(t, err)
+	require.True(t, revoked)
+}
@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/Nucleussss/hikayat-forum/auth/pkg/utils"
+	"github.com/google/uuid"
+
+	authpb "github.com/Nucleussss/hikayat-proto/gen/go/auth/v1"
+)
+
+// roleNamePattern restricts role names to lower case words joined by dashes or underscores.
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,63}$`)
+
+// CreateRole creates a role without permissions.
+func (s *authService) CreateRole(ctx context.Context, req *authpb.CreateRoleRequest) (*authpb.CreateRoleResponse, error) {
+	op := "authService.CreateRole"
+
+	if !roleNamePattern.MatchString(req.Name) {
+		return nil, fmt.Errorf("invalid role name")
+	}
+
+	role, err := s.roleRepo.CreateRole(ctx, req.Name)
+	if err != nil {
+		log.Printf("%s Error creating role %s: %v", op, req.Name, err)
+		return nil, err
+	}
+
+	s.recordAudit(ctx, models.AuditActionRoleCreated, models.AuditOutcomeSuccess, "", map[string]any{"role_id": role.ID.String(), "role": role.Name})
+
+	response := &authpb.CreateRoleResponse{
+		Message: "Role created successfully",
+		Role:    utils.RoleModelToPB(role),
+	}
+
+	return response, nil
+}
+
+// DeleteRole deletes a role, taking it away from every user that had it. The seeded admin and
+// moderator roles cannot be deleted.
+func (s *authService) DeleteRole(ctx context.Context, req *authpb.DeleteRoleRequest) error {
+	op := "authService.DeleteRole"
+
+	roleID, err := uuid.Parse(req.RoleId)
+	if err != nil {
+		return fmt.Errorf("invalid role id")
+	}
+
+	role, err := s.roleRepo.FindRoleByID(ctx, roleID)
+	if err != nil {
+		log.Printf("%s Error finding role %s: %v", op, req.RoleId, err)
+		return err
+	}
+
+	if role.Name == models.RoleAdmin || role.Name == models.RoleModerator {
+		log.Printf("%s refused to delete seeded role %s", op, role.Name)
+		return ErrProtectedRole
+	}
+
+	if err := s.roleRepo.DeleteRole(ctx, roleID); err != nil {
+		log.Printf("%s Error deleting role %s: %v", op, req.RoleId, err)
+		return err
+	}
+
+	s.recordAudit(ctx, models.AuditActionRoleDeleted, models.AuditOutcomeSuccess, "", map[string]any{"role_id": req.RoleId, "role": role.Name})
+
+	return nil
+}
+
+// ListRoles returns every role with its permissions.
+func (s *authService) ListRoles(ctx context.Context, req *authpb.ListRolesRequest) (*authpb.ListRolesResponse, error) {
+	op := "authService.ListRoles"
+
+	roles, err := s.roleRepo.ListRoles(ctx)
+	if err != nil {
+		log.Printf("%s Error listing roles: %v", op, err)
+		return nil, err
+	}
+
+	response := &authpb.ListRolesResponse{}
+	for _, role := range roles {
+		response.Roles = append(response.Roles, utils.RoleModelToPB(role))
+	}
+
+	return response, nil
+}
+
+// GrantPermissionToRole grants a known permission to a role.
+func (s *authService) GrantPermissionToRole(ctx context.Context, req *authpb.GrantPermissionToRoleRequest) error {
+	op := "authService.GrantPermissionToRole"
+
+	roleID, err := uuid.Parse(req.RoleId)
+	if err != nil {
+		return fmt.Errorf("invalid role id")
+	}
+
+	if err := s.permissionRepo.GrantPermissionToRole(ctx, roleID, req.Permission); err != nil {
+		log.Printf("%s Error granting %s to role %s: %v", op, req.Permission, req.RoleId, err)
+		return err
+	}
+
+	s.recordAudit(ctx, models.AuditActionPermissionGranted, models.AuditOutcomeSuccess, "", map[string]any{"role_id": req.RoleId, "permission": req.Permission})
+
+	return nil
+}
+
+// RevokePermissionFromRole takes a permission away from a role.
+func (s *authService) RevokePermissionFromRole(ctx context.Context, req *authpb.RevokePermissionFromRoleRequest) error {
+	op := "authService.RevokePermissionFromRole"
+
+	roleID, err := uuid.Parse(req.RoleId)
+	if err != nil {
+		return fmt.Errorf("invalid role id")
+	}
+
+	if err := s.permissionRepo.RevokePermissionFromRole(ctx, roleID, req.Permission); err != nil {
+		log.Printf("%s Error revoking %s from role %s: %v", op, req.Permission, req.RoleId, err)
+		return err
+	}
+
+	s.recordAudit(ctx, models.AuditActionPermissionRevoked, models.AuditOutcomeSuccess, "", map[string]any{"role_id": req.RoleId, "permission": req.Permission})
+
+	return nil
+}
+
+// AssignRoleToUser gives a user a role.
+func (s *authService) AssignRoleToUser(ctx context.Context, req *authpb.AssignRoleToUserRequest) error {
+	op := "authService.AssignRoleToUser"
+
+	userID, roleID, err := parseUserRole(req.UserId, req.RoleId)
+	if err != nil {
+		return err
+	}
+
+	if err := s.roleRepo.AssignRoleToUser(ctx, userID, roleID); err != nil {
+		log.Printf("%s Error assigning role %s to user %s: %v", op, req.RoleId, req.UserId, err)
+		return err
+	}
+
+	s.recordAudit(ctx, models.AuditActionRoleAssigned, models.AuditOutcomeSuccess, req.UserId, map[string]any{"role_id": req.RoleId})
+
+	return nil
+}
+
+// RemoveRoleFromUser takes a role away from a user.
+func (s *authService) RemoveRoleFromUser(ctx context.Context, req *authpb.RemoveRoleFromUserRequest) error {
+	op := "authService.RemoveRoleFromUser"
+
+	userID, roleID, err := parseUserRole(req.UserId, req.RoleId)
+	if err != nil {
+		return err
+	}
+
+	if err := s.roleRepo.RemoveRoleFromUser(ctx, userID, roleID); err != nil {
+		log.Printf("%s Error removing role %s from user %s: %v", op, req.RoleId, req.UserId, err)
+		return err
+	}
+
+	s.recordAudit(ctx, models.AuditActionRoleRemoved, models.AuditOutcomeSuccess, req.UserId, map[string]any{"role_id": req.RoleId})
+
+	return nil
+}
+
+// ListUserRoles returns the roles of a user. Users may list their own roles, admins anyone's.
+func (s *authService) ListUserRoles(ctx context.Context, req *authpb.ListUserRolesRequest) (*authpb.ListUserRolesResponse, error) {
+	op := "authService.ListUserRoles"
+
+	userID, err := uuid.Parse(req.UserId)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user id")
+	}
+
+	roles, err := s.roleRepo.ListUserRoles(ctx, userID)
+	if err != nil {
+		log.Printf("%s Error listing roles of user %s: %v", op, req.UserId, err)
+		return nil, err
+	}
+
+	response := &authpb.ListUserRolesResponse{}
+	for _, role := range roles {
+		response.Roles = append(response.Roles, utils.RoleModelToPB(role))
+	}
+
+	return response, nil
+}
+
+func parseUserRole(userID, roleID string) (uuid.UUID, uuid.UUID, error) {
+	parsedUserID, err := uuid.Parse(userID)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, fmt.Errorf("invalid user id")
+	}
+
+	parsedRoleID, err := uuid.Parse(roleID)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, fmt.Errorf("invalid role id")
+	}
+
+	return parsedUserID, parsedRoleID, nil
+}
@@ -0,0 +1,148 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/repository"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/service"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	authpb "github.com/Nucleussss/hikayat-proto/gen/go/auth/v1"
+)
+
+// seededRole returns the id of the admin or moderator role of the fixture.
+func seededRole(t *testing.T, f *authFixture, name string) string {
+	t.Helper()
+
+	role, err := f.roles.FindRoleByName(context.Background(), name)
+	require.NoError(t, err)
+
+	return role.ID.String()
+}
+
+func TestCreateRole(t *testing.T) {
+	ctx := context.Background()
+	f := newAuthFixture(t)
+
+	for _, name := range []string{"", "Editor", "x", "editor role", "1editor"} {
+		_, err := f.service.CreateRole(ctx, &authpb.CreateRoleRequest{Name: name})
+		require.Error(t, err, name)
+	}
+
+	res, err := f.service.CreateRole(ctx, &authpb.CreateRoleRequest{Name: "editor"})
+	require.NoError(t, err)
+	require.Equal(t, "editor", res.Role.Name)
+
+	_, err = f.service.CreateRole(ctx, &authpb.CreateRoleRequest{Name: "editor"})
+	require.ErrorIs(t, err, repository.ErrRoleAlreadyExists)
+
+	roles, err := f.service.ListRoles(ctx, &authpb.ListRolesRequest{})
+	require.NoError(t, err)
+	require.Len(t, roles.Roles, 3)
+}
+
+func TestDeleteRole(t *testing.T) {
+	ctx := context.Background()
+	f := newAuthFixture(t)
+	user := f.addUser(t, "editor@example.com", "correct horse")
+
+	// the seeded roles stay
+	for _, name := range []string{models.RoleAdmin, models.RoleModerator} {
+		err := f.service.DeleteRole(ctx, &authpb.DeleteRoleRequest{RoleId: seededRole(t, f, name)})
+		require.ErrorIs(t, err, service.ErrProtectedRole)
+
+		_, err = f.roles.FindRoleByName(ctx, name)
+		require.NoError(t, err)
+	}
+
+	// any other role is deleted and taken away from its holders
+	created, err := f.service.CreateRole(ctx, &authpb.CreateRoleRequest{Name: "editor"})
+	require.NoError(t, err)
+	require.NoError(t, f.service.AssignRoleToUser(ctx, &authpb.AssignRoleToUserRequest{UserId: user.Id, RoleId: created.Role.Id}))
+
+	require.NoError(t, f.service.DeleteRole(ctx, &authpb.DeleteRoleRequest{RoleId: created.Role.Id}))
+
+	roles, err := f.service.ListUserRoles(ctx, &authpb.ListUserRolesRequest{UserId: user.Id})
+	require.NoError(t, err)
+	require.Empty(t, roles.Roles)
+
+	err = f.service.DeleteRole(ctx, &authpb.DeleteRoleRequest{RoleId: created.Role.Id})
+	require.ErrorIs(t, err, repository.ErrRoleNotFound)
+
+	err = f.service.DeleteRole(ctx, &authpb.DeleteRoleRequest{RoleId: "not-a-uuid"})
+	require.Error(t, err)
+}
+
+func TestAssignAndRemoveRole(t *testing.T) {
+	ctx := context.Background()
+	f := newAuthFixture(t)
+	user := f.addUser(t, "moderator@example.com", "correct horse")
+	moderator := seededRole(t, f, models.RoleModerator)
+
+	require.NoError(t, f.service.AssignRoleToUser(ctx, &authpb.AssignRoleToUserRequest{UserId: user.Id, RoleId: moderator}))
+
+	roles, err := f.service.ListUserRoles(ctx, &authpb.ListUserRolesRequest{UserId: user.Id})
+	require.NoError(t, err)
+	require.Len(t, roles.Roles, 1)
+	require.Equal(t, models.RoleModerator, roles.Roles[0].Name)
+
+	require.NoError(t, f.service.RemoveRoleFromUser(ctx, &authpb.RemoveRoleFromUserRequest{UserId: user.Id, RoleId: moderator}))
+	require.Error(t, f.service.RemoveRoleFromUser(ctx, &authpb.RemoveRoleFromUserRequest{UserId: user.Id, RoleId: moderator}))
+
+	roles, err = f.service.ListUserRoles(ctx, &authpb.ListUserRolesRequest{UserId: user.Id})
+	require.NoError(t, err)
+	require.Empty(t, roles.Roles)
+
+	// unknown roles and malformed ids are refused
+	require.Error(t, f.service.AssignRoleToUser(ctx, &authpb.AssignRoleToUserRequest{UserId: user.Id, RoleId: uuid.NewString()}))
+	require.Error(t, f.service.AssignRoleToUser(ctx, &authpb.AssignRoleToUserRequest{UserId: "not-a-uuid", RoleId: moderator}))
+	require.Error(t, f.service.AssignRoleToUser(ctx, &authpb.AssignRoleToUserRequest{UserId: user.Id, RoleId: "not-a-uuid"}))
+}
+
+func TestGrantAndRevokePermission(t *testing.T) {
+	ctx := context.Background()
+	f := newAuthFixture(t)
+	moderator := seededRole(t, f, models.RoleModerator)
+
+	require.NoError(t, f.service.GrantPermissionToRole(ctx, &authpb.GrantPermissionToRoleRequest{RoleId: moderator, Permission: "users.read"}))
+
+	role, err := f.roles.FindRoleByName(ctx, models.RoleModerator)
+	require.NoError(t, err)
+	require.Contains(t, role.Permissions, "users.read")
+
+	require.NoError(t, f.service.RevokePermissionFromRole(ctx, &authpb.RevokePermissionFromRoleRequest{RoleId: moderator, Permission: "users.read"}))
+	require.NotContains(t, role.Permissions, "users.read")
+
+	require.Error(t, f.service.RevokePermissionFromRole(ctx, &authpb.RevokePermissionFromRoleRequest{RoleId: moderator, Permission: "users.read"}))
+	require.Error(t, f.service.GrantPermissionToRole(ctx, &authpb.GrantPermissionToRoleRequest{RoleId: "not-a-uuid", Permission: "users.read"}))
+}
+
+func TestBootstrapAdmin(t *testing.T) {
+	ctx := context.Background()
+	f := newAuthFixture(t)
+	user := f.addUser(t, "owner@example.com", "correct horse")
+	isAdmin := func() bool {
+		ok, err := f.roles.UserHasRole(ctx, uuid.MustParse(user.Id), models.RoleAdmin)
+		require.NoError(t, err)
+		return ok
+	}
+
+	// unknown and unverified addresses are not made admin
+	require.Error(t, service.BootstrapAdmin(ctx, f.users, f.roles, nil, "nobody@example.com"))
+
+	user.EmailVerified = false
+	require.Error(t, service.BootstrapAdmin(ctx, f.users, f.roles, nil, user.Email))
+	require.False(t, isAdmin())
+
+	// the verified owner is, and running it again on the next start changes nothing
+	user.EmailVerified = true
+	require.NoError(t, service.BootstrapAdmin(ctx, f.users, f.roles, nil, user.Email))
+	require.True(t, isAdmin())
+
+	require.NoError(t, service.BootstrapAdmin(ctx, f.users, f.roles, nil, user.Email))
+	require.True(t, isAdmin())
+	require.Len(t, f.roles.userRoles[uuid.MustParse(user.Id)], 1)
+}
@@ -0,0 +1,324 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	contextKey "github.com/Nucleussss/hikayat-forum/auth/internal/context"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/repository"
+	"github.com/Nucleussss/hikayat-forum/auth/pkg/utils"
+	"github.com/google/uuid"
+
+	authpb "github.com/Nucleussss/hikayat-proto/gen/go/auth/v1"
+)
+
+// sessionTokens is the pair of tokens handed to a client for one session generation.
+type sessionTokens struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int64
+}
+
+// startSession opens a new session family for the user and issues its first token pair.
+func (s *authService) startSession(ctx context.Context, user *authpb.User) (*sessionTokens, error) {
+	refreshToken, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	userAgent, ipAddress := utils.ClientInfoFromContext(ctx)
+
+	userID := uuid.MustParse(user.Id)
+
+	// load the roles and permissions embedded in the access tokens of this session
+	authz, err := s.roleRepo.FindUserAuthorization(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	session := &models.Session{
+		UserID:       userID,
+		TokenHash:    utils.HashToken(refreshToken),
+		UserAgent:    userAgent,
+		IPAddress:    ipAddress,
+		AuthzVersion: authz.Version,
+		ExpiresAt:    time.Now().Add(s.cfg.JWT.RefreshTTL),
+	}
+
+	if err := s.sessionRepo.CreateSession(ctx, session); err != nil {
+		return nil, err
+	}
+
+	return s.issueTokens(user, authz, session, refreshToken)
+}
+
+// issueTokens signs an access token bound to the session family and pairs it with the refresh token.
+func (s *authService) issueTokens(user *authpb.User, authz *models.UserAuthorization, session *models.Session, refreshToken string) (*sessionTokens, error) {
+	accessToken, err := utils.GenerateJWTToken(utils.AccessTokenClaims{
+		UserID:        session.UserID,
+		SessionID:     session.FamilyID,
+		EmailVerified: user.EmailVerified,
+		Roles:         authz.Roles,
+		Permissions:   authz.Permissions,
+		AuthzVersion:  authz.Version,
+	}, s.tokenKeys, s.cfg.JWT)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sessionTokens{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(s.cfg.JWT.AccessTTL.Seconds()),
+	}, nil
+}
+
+// RefreshToken exchanges a refresh token for a new token pair. Every refresh token is single use:
+// presenting one that was already rotated is treated as theft and revokes the whole session family.
+func (s *authService) RefreshToken(ctx context.Context, req *authpb.RefreshTokenRequest) (*authpb.RefreshTokenResponse, error) {
+	op := "authService.RefreshToken"
+
+	session, err := s.sessionRepo.FindSessionByTokenHash(ctx, utils.HashToken(req.RefreshToken))
+	if err != nil {
+		log.Printf("%s Error finding session: %v", op, err)
+		return nil, fmt.Errorf("%s invalid refresh token", op)
+	}
+
+	if session.RevokedAt != nil {
+		log.Printf("%s refresh token of revoked session family %s presented", op, session.FamilyID)
+		return nil, fmt.Errorf("%s refresh token revoked", op)
+	}
+
+	if session.RotatedAt != nil {
+		s.revokeReusedFamily(ctx, op, session)
+		return nil, fmt.Errorf("%s refresh token reused", op)
+	}
+
+	if time.Now().After(session.ExpiresAt) {
+		log.Printf("%s refresh token expired for session family %s", op, session.FamilyID)
+		return nil, fmt.Errorf("%s refresh token expired", op)
+	}
+
+	// refuse to refresh claims that no longer match the user's roles and permissions
+	authz, err := s.roleRepo.FindUserAuthorization(ctx, session.UserID)
+	if err != nil {
+		log.Printf("%s Error finding authorization of user %s: %v", op, session.UserID, err)
+		return nil, err
+	}
+
+	if authz.Version != session.AuthzVersion {
+		log.Printf("%s authorization of user %s changed from version %d to %d", op, session.UserID, session.AuthzVersion, authz.Version)
+		return nil, ErrAuthorizationStale
+	}
+
+	// load the user so the new access token reflects its current state
+	user, err := s.userRepo.FindUserById(ctx, session.UserID.String())
+	if err != nil {
+		log.Printf("%s Error finding user by id: %s, error: %v", op, session.UserID, err)
+		return nil, err
+	}
+
+	if err := s.checkAccountActive(ctx, user); err != nil {
+		log.Printf("%s refresh refused for user %s: %v", op, session.UserID, err)
+		return nil, err
+	}
+
+	refreshToken, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		log.Printf("%s Error generating refresh token: %v", op, err)
+		return nil, err
+	}
+
+	// the new generation records the client that refreshed, which also moves the last seen time
+	userAgent, ipAddress := utils.ClientInfoFromContext(ctx)
+
+	next := &models.Session{
+		UserID:       session.UserID,
+		FamilyID:     session.FamilyID,
+		TokenHash:    utils.HashToken(refreshToken),
+		UserAgent:    userAgent,
+		IPAddress:    ipAddress,
+		AuthzVersion: authz.Version,
+		ExpiresAt:    time.Now().Add(s.cfg.JWT.RefreshTTL),
+	}
+
+	// rotate the session, losing a race against another refresh with the same token counts as reuse
+	if err := s.sessionRepo.RotateSession(ctx, session.ID, next); err != nil {
+		if errors.Is(err, repository.ErrSessionAlreadyRotated) {
+			s.revokeReusedFamily(ctx, op, session)
+			return nil, fmt.Errorf("%s refresh token reused", op)
+		}
+		log.Printf("%s Error rotating session: %v", op, err)
+		return nil, err
+	}
+
+	tokens, err := s.issueTokens(user, authz, next, refreshToken)
+	if err != nil {
+		log.Printf("%s Error generating JWT token: %v", op, err)
+		return nil, err
+	}
+
+	response := &authpb.RefreshTokenResponse{
+		Message:      "Token refreshed successfully",
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+	}
+
+	return response, nil
+}
+
+// revokeReusedFamily revokes the session family of a refresh token that was presented after rotation.
+func (s *authService) revokeReusedFamily(ctx context.Context, op string, session *models.Session) {
+	log.Printf("%s refresh token reuse detected for user %s, revoking session family %s", op, session.UserID, session.FamilyID)
+
+	if err := s.sessionRepo.RevokeSessionFamily(ctx, session.FamilyID); err != nil {
+		log.Printf("%s Error revoking session family %s: %v", op, session.FamilyID, err)
+	}
+
+	s.recordAudit(ctx, models.AuditActionTokenReuse, models.AuditOutcomeFailure, session.UserID.String(), map[string]any{"session_id": session.FamilyID.String()})
+}
+
+// Logout ends the session the caller's access token belongs to. The access token itself is revoked
+// immediately, and so is the session of the refresh token in the request when one is given.
+func (s *authService) Logout(ctx context.Context, req *authpb.LogoutRequest) error {
+	op := "authService.Logout"
+
+	userID, _ := ctx.Value(contextKey.UserIDContextKey).(string)
+	sessionID, _ := ctx.Value(contextKey.SessionIDContextKey).(string)
+	tokenID, _ := ctx.Value(contextKey.TokenIDContextKey).(string)
+	expiresAt, _ := ctx.Value(contextKey.TokenExpiresAtContextKey).(time.Time)
+
+	// revoke the access token used for this request
+	if tokenID != "" {
+		if err := s.revocations.RevokeToken(ctx, tokenID, expiresAt); err != nil {
+			log.Printf("%s Error revoking token %s: %v", op, tokenID, err)
+			return err
+		}
+	}
+
+	// revoke the session the access token was issued for
+	if sessionID != "" {
+		if err := s.revokeSession(ctx, sessionID); err != nil {
+			log.Printf("%s Error revoking session %s: %v", op, sessionID, err)
+			return err
+		}
+	}
+
+	s.recordAudit(ctx, models.AuditActionLogout, models.AuditOutcomeSuccess, userID, map[string]any{"session_id": sessionID})
+
+	if req.RefreshToken == "" {
+		return nil
+	}
+
+	// revoke the session of the presented refresh token, it must belong to the caller
+	session, err := s.sessionRepo.FindSessionByTokenHash(ctx, utils.HashToken(req.RefreshToken))
+	if err != nil {
+		log.Printf("%s Error finding session: %v", op, err)
+		return fmt.Errorf("invalid refresh token")
+	}
+
+	if session.UserID.String() != userID {
+		log.Printf("%s refresh token of another user presented by %s", op, userID)
+		return fmt.Errorf("invalid refresh token")
+	}
+
+	if session.FamilyID.String() == sessionID {
+		return nil
+	}
+
+	if err := s.revokeSession(ctx, session.FamilyID.String()); err != nil {
+		log.Printf("%s Error revoking session %s: %v", op, session.FamilyID, err)
+		return err
+	}
+
+	s.recordAudit(ctx, models.AuditActionSessionRevoked, models.AuditOutcomeSuccess, userID, map[string]any{"session_id": session.FamilyID.String()})
+
+	return nil
+}
+
+// LogoutAllDevices revokes every session of the user together with all access tokens issued so far.
+func (s *authService) LogoutAllDevices(ctx context.Context, req *authpb.LogoutAllDevicesRequest) error {
+	op := "authService.LogoutAllDevices"
+
+	if err := s.revokeAllSessions(ctx, uuid.MustParse(req.Id)); err != nil {
+		log.Printf("%s Error revoking sessions for user by id: %s, error: %v", op, req.Id, err)
+		return err
+	}
+
+	s.recordAudit(ctx, models.AuditActionAllSessionsRevoked, models.AuditOutcomeSuccess, req.Id, nil)
+
+	return nil
+}
+
+// revokeAllSessions revokes every session of the user and every access token issued to it so far.
+func (s *authService) revokeAllSessions(ctx context.Context, userID uuid.UUID) error {
+	if err := s.sessionRepo.RevokeUserSessions(ctx, userID); err != nil {
+		return err
+	}
+
+	return s.revocations.RevokeUser(ctx, userID.String())
+}
+
+// ListSessions returns the sessions the user is currently logged in with, most recently used first.
+func (s *authService) ListSessions(ctx context.Context, req *authpb.ListSessionsRequest) (*authpb.ListSessionsResponse, error) {
+	op := "authService.ListSessions"
+
+	sessions, err := s.sessionRepo.ListActiveSessions(ctx, uuid.MustParse(req.Id))
+	if err != nil {
+		log.Printf("%s Error listing sessions for user by id: %s, error: %v", op, req.Id, err)
+		return nil, err
+	}
+
+	currentSessionID, _ := ctx.Value(contextKey.SessionIDContextKey).(string)
+
+	response := &authpb.ListSessionsResponse{}
+	for _, session := range sessions {
+		response.Sessions = append(response.Sessions, utils.SessionModelToPB(session, currentSessionID))
+	}
+
+	return response, nil
+}
+
+// RevokeSession ends one session of the user. Its refresh token stops working and the access
+// tokens issued for it are rejected by the interceptor right away.
+func (s *authService) RevokeSession(ctx context.Context, req *authpb.RevokeSessionRequest) error {
+	op := "authService.RevokeSession"
+
+	familyID, err := uuid.Parse(req.SessionId)
+	if err != nil {
+		return fmt.Errorf("invalid session id: %s", req.SessionId)
+	}
+
+	if err := s.sessionRepo.RevokeUserSession(ctx, uuid.MustParse(req.Id), familyID); err != nil {
+		log.Printf("%s Error revoking session %s for user by id: %s, error: %v", op, req.SessionId, req.Id, err)
+		return err
+	}
+
+	if err := s.revocations.RevokeSession(ctx, req.SessionId); err != nil {
+		log.Printf("%s Error revoking tokens of session %s: %v", op, req.SessionId, err)
+		return err
+	}
+
+	s.recordAudit(ctx, models.AuditActionSessionRevoked, models.AuditOutcomeSuccess, req.Id, map[string]any{"session_id": req.SessionId})
+
+	return nil
+}
+
+// revokeSession revokes a session family and every access token bound to it.
+func (s *authService) revokeSession(ctx context.Context, sessionID string) error {
+	familyID, err := uuid.Parse(sessionID)
+	if err != nil {
+		return fmt.Errorf("invalid session id: %s", sessionID)
+	}
+
+	if err := s.sessionRepo.RevokeSessionFamily(ctx, familyID); err != nil {
+		return err
+	}
+
+	return s.revocations.RevokeSession(ctx, sessionID)
+}
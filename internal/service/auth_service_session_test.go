@@ -0,0 +1,106 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/service"
+	"github.com/stretchr/testify/require"
+
+	authpb "github.com/Nucleussss/hikayat-proto/gen/go/auth/v1"
+)
+
+func TestLoginHashesThePasswordOfUnknownAccounts(t *testing.T) {
+	ctx := context.Background()
+	f := newAuthFixture(t)
+	user := f.addUser(t, "reader@example.com", "correct horse")
+
+	// a wrong password and an unknown account both cost one password hash check
+	_, err := f.service.Login(ctx, &authpb.LoginRequest{Email: user.Email, Password: "wrong horse"})
+	require.Error(t, err)
+	require.Equal(t, 1, f.hasher.verified)
+
+	_, err = f.service.Login(ctx, &authpb.LoginRequest{Email: "nobody@example.com", Password: "wrong horse"})
+	require.Error(t, err)
+	require.Equal(t, 2, f.hasher.verified)
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	ctx := context.Background()
+	f := newAuthFixture(t)
+	user := f.addUser(t, "reader@example.com", "correct horse")
+
+	login, err := f.service.Login(ctx, &authpb.LoginRequest{Email: user.Email, Password: "correct horse"})
+	require.NoError(t, err)
+
+	// every refresh hands out a new refresh token in the same session family
+	first, err := f.service.RefreshToken(ctx, &authpb.RefreshTokenRequest{RefreshToken: login.RefreshToken})
+	require.NoError(t, err)
+	require.NotEmpty(t, first.Token)
+	require.NotEqual(t, login.RefreshToken, first.RefreshToken)
+
+	second, err := f.service.RefreshToken(ctx, &authpb.RefreshTokenRequest{RefreshToken: first.RefreshToken})
+	require.NoError(t, err)
+	require.NotEqual(t, first.RefreshToken, second.RefreshToken)
+
+	require.Len(t, f.sessions.sessions, 3)
+	for _, session := range f.sessions.sessions {
+		require.Equal(t, f.sessions.sessions[0].FamilyID, session.FamilyID)
+	}
+
+	_, err = f.service.RefreshToken(ctx, &authpb.RefreshTokenRequest{RefreshToken: "unknown"})
+	require.Error(t, err)
+}
+
+func TestRefreshTokenReuseRevokesTheFamily(t *testing.T) {
+	ctx := context.Background()
+	f := newAuthFixture(t)
+	user := f.addUser(t, "reader@example.com", "correct horse")
+
+	login, err := f.service.Login(ctx, &authpb.LoginRequest{Email: user.Email, Password: "correct horse"})
+	require.NoError(t, err)
+	other, err := f.service.Login(ctx, &authpb.LoginRequest{Email: user.Email, Password: "correct horse"})
+	require.NoError(t, err)
+
+	rotated, err := f.service.RefreshToken(ctx, &authpb.RefreshTokenRequest{RefreshToken: login.RefreshToken})
+	require.NoError(t, err)
+
+	// presenting the rotated token again looks like theft, the thief and the owner both lose the session
+	_, err = f.service.RefreshToken(ctx, &authpb.RefreshTokenRequest{RefreshToken: login.RefreshToken})
+	require.ErrorContains(t, err, "reused")
+
+	_, err = f.service.RefreshToken(ctx, &authpb.RefreshTokenRequest{RefreshToken: rotated.RefreshToken})
+	require.ErrorContains(t, err, "revoked")
+
+	// the user's other sessions are left alone
+	_, err = f.service.RefreshToken(ctx, &authpb.RefreshTokenRequest{RefreshToken: other.RefreshToken})
+	require.NoError(t, err)
+}
+
+func TestRefreshTokenWithStaleAuthorization(t *testing.T) {
+	ctx := context.Background()
+	f := newAuthFixture(t)
+	user := f.addUser(t, "moderator@example.com", "correct horse")
+	moderator := seededRole(t, f, models.RoleModerator)
+
+	login, err := f.service.Login(ctx, &authpb.LoginRequest{Email: user.Email, Password: "correct horse"})
+	require.NoError(t, err)
+
+	// a new role is not picked up by refreshing, the user has to log in again
+	require.NoError(t, f.service.AssignRoleToUser(ctx, &authpb.AssignRoleToUserRequest{UserId: user.Id, RoleId: moderator}))
+
+	_, err = f.service.RefreshToken(ctx, &authpb.RefreshTokenRequest{RefreshToken: login.RefreshToken})
+	require.ErrorIs(t, err, service.ErrAuthorizationStale)
+
+	login, err = f.service.Login(ctx, &authpb.LoginRequest{Email: user.Email, Password: "correct horse"})
+	require.NoError(t, err)
+	refreshed, err := f.service.RefreshToken(ctx, &authpb.RefreshTokenRequest{RefreshToken: login.RefreshToken})
+	require.NoError(t, err)
+
+	// neither is a permission granted to a role the user holds
+	require.NoError(t, f.service.GrantPermissionToRole(ctx, &authpb.GrantPermissionToRoleRequest{RoleId: moderator, Permission: "users.read"}))
+
+	_, err = f.service.RefreshToken(ctx, &authpb.RefreshTokenRequest{RefreshToken: refreshed.RefreshToken})
+	require.ErrorIs(t, err, service.ErrAuthorizationStale)
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/jwtkeys"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+
+	authpb "github.com/Nucleussss/hikayat-proto/gen/go/auth/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// GetJWKS returns the public keys access tokens are currently validated with.
+func (s *authService) GetJWKS(ctx context.Context, req *authpb.GetJWKSRequest) (*authpb.GetJWKSResponse, error) {
+	response := &authpb.GetJWKSResponse{}
+
+	for _, key := range jwtkeys.PublicJWKS(s.tokenKeys).Keys {
+		response.Keys = append(response.Keys, &authpb.JsonWebKey{
+			Kid: key.KeyID,
+			Kty: key.KeyType,
+			Alg: key.Algorithm,
+			Use: key.Use,
+			Crv: key.Curve,
+			X:   key.X,
+			N:   key.N,
+			E:   key.E,
+		})
+	}
+
+	return response, nil
+}
+
+// RotateSigningKey generates the next signing key. The keys it replaces keep validating tokens
+// for the configured overlap.
+func (s *authService) RotateSigningKey(ctx context.Context, req *authpb.RotateSigningKeyRequest) (*authpb.RotateSigningKeyResponse, error) {
+	op := "authService.RotateSigningKey"
+
+	key, err := s.tokenKeys.Rotate(ctx)
+	if err != nil {
+		log.Printf("%s Error rotating signing key: %v", op, err)
+		return nil, err
+	}
+
+	s.recordAudit(ctx, models.AuditActionSigningKeyRotated, models.AuditOutcomeSuccess, "", map[string]any{
+		"kid":          key.ID,
+		"alg":          key.Algorithm,
+		"activates_at": key.ActivatesAt.Format(time.RFC3339),
+	})
+
+	response := &authpb.RotateSigningKeyResponse{
+		Message:     "Signing key rotated successfully",
+		Kid:         key.ID,
+		Algorithm:   key.Algorithm,
+		ActivatesAt: timestamppb.New(key.ActivatesAt),
+	}
+
+	return response, nil
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	contextKey "github.com/Nucleussss/hikayat-forum/auth/internal/context"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/repository"
+	"github.com/Nucleussss/hikayat-forum/auth/pkg/utils"
+	"github.com/google/uuid"
+
+	authpb "github.com/Nucleussss/hikayat-proto/gen/go/auth/v1"
+)
+
+// SuspendUser suspends the user until expires_at, or until the suspension is lifted when it is
+// unset. The user is deactivated, every session ends and the access tokens issued so far are
+// rejected by the interceptor as suspended.
+func (s *authService) SuspendUser(ctx context.Context, req *authpb.SuspendUserRequest) (*authpb.SuspendUserResponse, error) {
+	op := "authService.SuspendUser"
+
+	actorID := callerID(ctx)
+	if actorID != nil && actorID.String() == req.UserId {
+		return nil, ErrSelfSuspension
+	}
+
+	suspension := &models.UserSuspension{
+		UserID:      uuid.MustParse(req.UserId),
+		Reason:      req.Reason,
+		SuspendedBy: actorID,
+	}
+	if req.ExpiresAt != nil {
+		expiresAt := req.ExpiresAt.AsTime()
+		suspension.ExpiresAt = &expiresAt
+	}
+
+	if err := s.suspensionRepo.SuspendUser(ctx, suspension); err != nil {
+		log.Printf("%s Error suspending user by id: %s, error: %v", op, req.UserId, err)
+		return nil, err
+	}
+
+	if err := s.sessionRepo.RevokeUserSessions(ctx, suspension.UserID); err != nil {
+		log.Printf("%s Error revoking sessions for user by id: %s, error: %v", op, req.UserId, err)
+		return nil, err
+	}
+
+	if err := s.revocations.SuspendUser(ctx, req.UserId); err != nil {
+		log.Printf("%s Error revoking access tokens for user by id: %s, error: %v", op, req.UserId, err)
+		return nil, err
+	}
+
+	metadata := map[string]any{"reason": req.Reason, "suspension_id": suspension.ID.String()}
+	if suspension.ExpiresAt != nil {
+		metadata["expires_at"] = suspension.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	s.recordAudit(ctx, models.AuditActionUserSuspended, models.AuditOutcomeSuccess, req.UserId, metadata)
+
+	response := &authpb.SuspendUserResponse{
+		Message:    "User suspended successfully",
+		Suspension: utils.SuspensionModelToPB(suspension, time.Now()),
+	}
+
+	return response, nil
+}
+
+// LiftSuspension ends the user's suspension and reactivates the user. Sessions ended by the
+// suspension stay ended, the user logs in again.
+func (s *authService) LiftSuspension(ctx context.Context, req *authpb.LiftSuspensionRequest) (*authpb.LiftSuspensionResponse, error) {
+	op := "authService.LiftSuspension"
+
+	suspension, err := s.suspensionRepo.LiftSuspension(ctx, uuid.MustParse(req.UserId), callerID(ctx), req.Reason)
+	if err != nil {
+		log.Printf("%s Error lifting suspension of user by id: %s, error: %v", op, req.UserId, err)
+		return nil, err
+	}
+
+	s.recordAudit(ctx, models.AuditActionSuspensionLifted, models.AuditOutcomeSuccess, req.UserId, map[string]any{"reason": req.Reason, "suspension_id": suspension.ID.String()})
+
+	response := &authpb.LiftSuspensionResponse{
+		Message:    "Suspension lifted successfully",
+		Suspension: utils.SuspensionModelToPB(suspension, time.Now()),
+	}
+
+	return response, nil
+}
+
+// ListUserSuspensions returns the suspension history of the user, newest first.
+func (s *authService) ListUserSuspensions(ctx context.Context, req *authpb.ListUserSuspensionsRequest) (*authpb.ListUserSuspensionsResponse, error) {
+	op := "authService.ListUserSuspensions"
+
+	suspensions, err := s.suspensionRepo.ListUserSuspensions(ctx, uuid.MustParse(req.UserId))
+	if err != nil {
+		log.Printf("%s Error listing suspensions of user by id: %s, error: %v", op, req.UserId, err)
+		return nil, err
+	}
+
+	now := time.Now()
+	response := &authpb.ListUserSuspensionsResponse{}
+	for _, suspension := range suspensions {
+		response.Suspensions = append(response.Suspensions, utils.SuspensionModelToPB(suspension, now))
+	}
+
+	return response, nil
+}
+
+// checkAccountActive refuses users that are suspended or deactivated. A suspension that expired
+// is ended here, reactivating the user, so expiries need no background job.
+func (s *authService) checkAccountActive(ctx context.Context, user *authpb.User) error {
+	op := "authService.checkAccountActive"
+
+	if user.IsActive {
+		return nil
+	}
+
+	userID := uuid.MustParse(user.Id)
+
+	// an inactive user without a suspension was deactivated
+	suspension, err := s.suspensionRepo.FindOpenSuspension(ctx, userID)
+	if errors.Is(err, repository.ErrNotSuspended) {
+		return ErrAccountSuspended
+	}
+	if err != nil {
+		log.Printf("%s Error finding suspension of user by id: %s, error: %v", op, user.Id, err)
+		return err
+	}
+
+	if suspension.InForce(time.Now()) {
+		return ErrAccountSuspended
+	}
+
+	ended, err := s.suspensionRepo.EndExpiredSuspension(ctx, userID)
+	if err != nil {
+		log.Printf("%s Error ending expired suspension of user by id: %s, error: %v", op, user.Id, err)
+		return err
+	}
+	if !ended {
+		return ErrAccountSuspended
+	}
+
+	user.IsActive = true
+
+	return nil
+}
+
+// callerID returns the ID of the authenticated caller, nil when there is none.
+func callerID(ctx context.Context) *uuid.UUID {
+	userID, _ := ctx.Value(contextKey.UserIDContextKey).(string)
+	return parseAuditUserID(userID)
+}
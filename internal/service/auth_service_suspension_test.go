@@ -0,0 +1,188 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	contextKey "github.com/Nucleussss/hikayat-forum/auth/internal/context"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/repository"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/service"
+	"github.com/Nucleussss/hikayat-forum/auth/pkg/utils"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	authpb "github.com/Nucleussss/hikayat-proto/gen/go/auth/v1"
+)
+
+// asUser returns a context authenticated as the user, like the auth interceptor sets it up.
+func asUser(userID string) context.Context {
+	return context.WithValue(context.Background(), contextKey.UserIDContextKey, userID)
+}
+
+// suspend suspends the user on behalf of a new admin, until expiresAt unless it is zero.
+func (f *authFixture) suspend(t *testing.T, user *authpb.User, expiresAt time.Time) {
+	t.Helper()
+
+	req := &authpb.SuspendUserRequest{UserId: user.Id, Reason: "spam"}
+	if !expiresAt.IsZero() {
+		req.ExpiresAt = timestamppb.New(expiresAt)
+	}
+
+	_, err := f.service.SuspendUser(asUser(uuid.NewString()), req)
+	require.NoError(t, err)
+}
+
+func TestSuspendUser(t *testing.T) {
+	f := newAuthFixture(t)
+	admin := f.addUser(t, "admin@example.com", "correct horse")
+	user := f.addUser(t, "spammer@example.com", "correct horse")
+	ctx := asUser(admin.Id)
+
+	_, err := f.service.Login(context.Background(), &authpb.LoginRequest{Email: user.Email, Password: "correct horse"})
+	require.NoError(t, err)
+	issuedAt := time.Now().Add(-time.Second)
+
+	// admins cannot suspend themselves, nor users that do not exist
+	_, err = f.service.SuspendUser(ctx, &authpb.SuspendUserRequest{UserId: admin.Id, Reason: "spam"})
+	require.ErrorIs(t, err, service.ErrSelfSuspension)
+	require.True(t, f.users.users[admin.Id].IsActive)
+
+	_, err = f.service.SuspendUser(ctx, &authpb.SuspendUserRequest{UserId: uuid.NewString(), Reason: "spam"})
+	require.ErrorIs(t, err, repository.ErrUserNotFound)
+
+	// the user is deactivated, their sessions end and their access tokens are rejected as suspended
+	res, err := f.service.SuspendUser(ctx, &authpb.SuspendUserRequest{UserId: user.Id, Reason: "spam"})
+	require.NoError(t, err)
+	require.Equal(t, "spam", res.Suspension.Reason)
+	require.False(t, f.users.users[user.Id].IsActive)
+
+	for _, session := range f.sessions.sessions {
+		require.NotNil(t, session.RevokedAt)
+	}
+
+	suspended, err := f.revocations.IsSuspended(ctx, service.RevocationCheck{TokenID: "jti", UserID: user.Id, IssuedAt: issuedAt})
+	require.NoError(t, err)
+	require.True(t, suspended)
+
+	suspension, err := f.suspensions.FindOpenSuspension(ctx, uuid.MustParse(user.Id))
+	require.NoError(t, err)
+	require.Equal(t, admin.Id, suspension.SuspendedBy.String())
+	require.Nil(t, suspension.ExpiresAt)
+}
+
+func TestLiftSuspension(t *testing.T) {
+	f := newAuthFixture(t)
+	admin := f.addUser(t, "admin@example.com", "correct horse")
+	user := f.addUser(t, "spammer@example.com", "correct horse")
+	ctx := asUser(admin.Id)
+
+	// there is nothing to lift before the user is suspended
+	_, err := f.service.LiftSuspension(ctx, &authpb.LiftSuspensionRequest{UserId: user.Id, Reason: "appeal"})
+	require.ErrorIs(t, err, repository.ErrNotSuspended)
+
+	f.suspend(t, user, time.Time{})
+
+	res, err := f.service.LiftSuspension(ctx, &authpb.LiftSuspensionRequest{UserId: user.Id, Reason: "appeal"})
+	require.NoError(t, err)
+	require.Equal(t, "appeal", res.Suspension.LiftReason)
+	require.True(t, f.users.users[user.Id].IsActive)
+
+	// the user logs in again
+	_, err = f.service.Login(context.Background(), &authpb.LoginRequest{Email: user.Email, Password: "correct horse"})
+	require.NoError(t, err)
+
+	_, err = f.service.LiftSuspension(ctx, &authpb.LiftSuspensionRequest{UserId: user.Id, Reason: "appeal"})
+	require.ErrorIs(t, err, repository.ErrNotSuspended)
+}
+
+func TestCheckAccountActive(t *testing.T) {
+	login := func(f *authFixture, user *authpb.User) error {
+		_, err := f.service.Login(context.Background(), &authpb.LoginRequest{Email: user.Email, Password: "correct horse"})
+		return err
+	}
+
+	t.Run("suspension in force", func(t *testing.T) {
+		f := newAuthFixture(t)
+		user := f.addUser(t, "spammer@example.com", "correct horse")
+		f.suspend(t, user, time.Now().Add(time.Hour))
+
+		require.ErrorIs(t, login(f, user), service.ErrAccountSuspended)
+		require.False(t, f.users.users[user.Id].IsActive)
+	})
+
+	t.Run("expired suspension ends at login", func(t *testing.T) {
+		f := newAuthFixture(t)
+		user := f.addUser(t, "spammer@example.com", "correct horse")
+		f.suspend(t, user, time.Now().Add(time.Hour))
+
+		// nothing ends the suspension when it expires, the next login does
+		suspension, err := f.suspensions.FindOpenSuspension(context.Background(), uuid.MustParse(user.Id))
+		require.NoError(t, err)
+		expiredAt := time.Now().Add(-time.Minute)
+		suspension.ExpiresAt = &expiredAt
+		require.False(t, f.users.users[user.Id].IsActive)
+
+		require.NoError(t, login(f, user))
+		require.True(t, f.users.users[user.Id].IsActive)
+		require.Equal(t, &expiredAt, suspension.LiftedAt)
+		require.Equal(t, "expired", suspension.LiftReason)
+
+		_, err = f.suspensions.FindOpenSuspension(context.Background(), uuid.MustParse(user.Id))
+		require.ErrorIs(t, err, repository.ErrNotSuspended)
+	})
+
+	t.Run("deactivated without a suspension", func(t *testing.T) {
+		f := newAuthFixture(t)
+		user := f.addUser(t, "gone@example.com", "correct horse")
+		user.IsActive = false
+
+		require.ErrorIs(t, login(f, user), service.ErrAccountSuspended)
+		require.False(t, f.users.users[user.Id].IsActive)
+	})
+}
+
+func TestSuspendedUsersGetNoTokens(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("login", func(t *testing.T) {
+		f := newAuthFixture(t)
+		user := f.addUser(t, "spammer@example.com", "correct horse")
+		f.suspend(t, user, time.Time{})
+
+		_, err := f.service.Login(ctx, &authpb.LoginRequest{Email: user.Email, Password: "correct horse"})
+		require.ErrorIs(t, err, service.ErrAccountSuspended)
+	})
+
+	t.Run("refresh", func(t *testing.T) {
+		f := newAuthFixture(t)
+		user := f.addUser(t, "spammer@example.com", "correct horse")
+
+		res, err := f.service.Login(ctx, &authpb.LoginRequest{Email: user.Email, Password: "correct horse"})
+		require.NoError(t, err)
+
+		// a session that outlived the suspension, like one created while it was stored, is refused too
+		require.NoError(t, f.suspensions.SuspendUser(ctx, &models.UserSuspension{UserID: uuid.MustParse(user.Id), Reason: "spam"}))
+
+		_, err = f.service.RefreshToken(ctx, &authpb.RefreshTokenRequest{RefreshToken: res.RefreshToken})
+		require.ErrorIs(t, err, service.ErrAccountSuspended)
+	})
+
+	t.Run("mfa", func(t *testing.T) {
+		f := newAuthFixture(t)
+		user := f.addUser(t, "spammer@example.com", "correct horse")
+		secret := f.enableTOTP(t, user)
+
+		// the user is suspended between the password and the second factor
+		challenge := loginForChallenge(t, f, user.Email, "correct horse")
+		f.suspend(t, user, time.Time{})
+
+		code, err := utils.TOTPCode(secret, time.Now())
+		require.NoError(t, err)
+
+		_, err = f.service.VerifyMFA(ctx, &authpb.VerifyMFARequest{MfaToken: challenge, Code: code})
+		require.ErrorIs(t, err, service.ErrAccountSuspended)
+	})
+}
@@ -0,0 +1,751 @@
+package service_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/jwtkeys"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/password"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/repository"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/service"
+	"github.com/Nucleussss/hikayat-forum/auth/pkg/config"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	authpb "github.com/Nucleussss/hikayat-proto/gen/go/auth/v1"
+)
+
+type fakeUserRepo struct {
+	repository.UserRepository
+	users  map[string]*authpb.User
+	hashes map[string]string
+}
+
+func (r *fakeUserRepo) FindUserByEmail(ctx context.Context, email string) (*authpb.User, error) {
+	for _, user := range r.users {
+		if user.Email == email {
+			return proto.Clone(user).(*authpb.User), nil
+		}
+	}
+	return nil, fmt.Errorf("user not found")
+}
+
+func (r *fakeUserRepo) FindUserById(ctx context.Context, id string) (*authpb.User, error) {
+	user, ok := r.users[id]
+	if !ok {
+		return nil, fmt.Errorf("user not found")
+	}
+	return proto.Clone(user).(*authpb.User), nil
+}
+
+func (r *fakeUserRepo) ChangeUserPassword(ctx context.Context, req *authpb.ChangeUserPasswordRequest) error {
+	if _, ok := r.users[req.Id]; !ok {
+		return fmt.Errorf("user not found")
+	}
+	r.hashes[req.Id] = req.Newpassword
+	return nil
+}
+
+func (r *fakeUserRepo) ExistByEmail(ctx context.Context, email string) (bool, error) {
+	_, err := r.FindUserByEmail(ctx, email)
+	return err == nil, nil
+}
+
+func (r *fakeUserRepo) ChangeUserEmail(ctx context.Context, req *authpb.ChangeUserEmailRequest) error {
+	user, ok := r.users[req.Id]
+	if !ok {
+		return fmt.Errorf("user not found")
+	}
+	user.Email = req.Email
+	user.EmailVerified = true
+	return nil
+}
+
+func (r *fakeUserRepo) DeleteUser(ctx context.Context, req *authpb.DeleteUserRequest) error {
+	if _, ok := r.users[req.Id]; !ok {
+		return fmt.Errorf("user not found")
+	}
+	delete(r.users, req.Id)
+	delete(r.hashes, req.Id)
+	return nil
+}
+
+func (r *fakeUserRepo) GetUserPasswordHash(ctx context.Context, identifier interface{}) (string, error) {
+	switch identifier := identifier.(type) {
+	case uuid.UUID:
+		return r.hashes[identifier.String()], nil
+	case string:
+		user, err := r.FindUserByEmail(ctx, identifier)
+		if err != nil {
+			return "", err
+		}
+		return r.hashes[user.Id], nil
+	}
+	return "", fmt.Errorf("unsupported identifier %T", identifier)
+}
+
+type fakePasswordResetRepo struct {
+	repository.PasswordResetRepository
+	resets []*models.PasswordReset
+}
+
+func (r *fakePasswordResetRepo) CreatePasswordReset(ctx context.Context, reset *models.PasswordReset) error {
+	reset.UpdateTime = time.Now()
+	r.resets = append(r.resets, reset)
+	return nil
+}
+
+func (r *fakePasswordResetRepo) ConsumePasswordReset(ctx context.Context, tokenHash string) (uuid.UUID, error) {
+	for i, reset := range r.resets {
+		if reset.TokenHash == tokenHash && time.Now().Before(reset.ExpiredAt) {
+			r.resets = slices.Delete(r.resets, i, i+1)
+			return reset.UserID, nil
+		}
+	}
+	return uuid.Nil, fmt.Errorf("password reset not found or expired")
+}
+
+func (r *fakePasswordResetRepo) DeleteUserPasswordResets(ctx context.Context, userID uuid.UUID) error {
+	r.resets = slices.DeleteFunc(r.resets, func(reset *models.PasswordReset) bool { return reset.UserID == userID })
+	return nil
+}
+
+type fakeEmailChangeRepo struct {
+	repository.EmailChangeRepository
+	changes []*models.EmailChange
+}
+
+func (r *fakeEmailChangeRepo) CreateEmailChange(ctx context.Context, change *models.EmailChange) error {
+	change.ID = uuid.New()
+	change.CreatedAt = time.Now()
+	r.changes = append(r.changes, change)
+	return nil
+}
+
+func (r *fakeEmailChangeRepo) ConfirmEmailChange(ctx context.Context, confirmTokenHash string) (*models.EmailChange, error) {
+	change, err := r.pending(func(change *models.EmailChange) bool { return change.ConfirmTokenHash == confirmTokenHash })
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	change.ConfirmedAt = &now
+	return change, nil
+}
+
+func (r *fakeEmailChangeRepo) CancelEmailChange(ctx context.Context, cancelTokenHash string) (*models.EmailChange, error) {
+	change, err := r.pending(func(change *models.EmailChange) bool { return change.CancelTokenHash == cancelTokenHash })
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	change.CancelledAt = &now
+	return change, nil
+}
+
+func (r *fakeEmailChangeRepo) DeletePendingEmailChanges(ctx context.Context, userID uuid.UUID) error {
+	r.changes = slices.DeleteFunc(r.changes, func(change *models.EmailChange) bool {
+		return change.UserID == userID && change.ConfirmedAt == nil && change.CancelledAt == nil
+	})
+	return nil
+}
+
+// pending returns the change matching the token that is neither confirmed, cancelled nor expired.
+func (r *fakeEmailChangeRepo) pending(match func(*models.EmailChange) bool) (*models.EmailChange, error) {
+	for _, change := range r.changes {
+		if match(change) && change.ConfirmedAt == nil && change.CancelledAt == nil && time.Now().Before(change.ExpiresAt) {
+			return change, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// fakeMailer hands the messages the service sends in the background to the test.
+type fakeMailer struct {
+	sent chan *service.MailMessage
+}
+
+func (m *fakeMailer) Send(ctx context.Context, msg *service.MailMessage) error {
+	m.sent <- msg
+	return nil
+}
+
+type fakeSessionRepo struct {
+	repository.SessionRepository
+	sessions []*models.Session
+}
+
+func (r *fakeSessionRepo) CreateSession(ctx context.Context, session *models.Session) error {
+	session.ID = uuid.New()
+	if session.FamilyID == uuid.Nil {
+		session.FamilyID = session.ID
+	}
+	session.CreatedAt = time.Now()
+	r.sessions = append(r.sessions, session)
+	return nil
+}
+
+func (r *fakeSessionRepo) FindSessionByTokenHash(ctx context.Context, tokenHash string) (*models.Session, error) {
+	for _, session := range r.sessions {
+		if session.TokenHash == tokenHash {
+			return session, nil
+		}
+	}
+	return nil, fmt.Errorf("session not found")
+}
+
+func (r *fakeSessionRepo) RotateSession(ctx context.Context, currentID uuid.UUID, next *models.Session) error {
+	for _, session := range r.sessions {
+		if session.ID != currentID {
+			continue
+		}
+		if session.RotatedAt != nil || session.RevokedAt != nil {
+			return repository.ErrSessionAlreadyRotated
+		}
+		now := time.Now()
+		session.RotatedAt = &now
+		return r.CreateSession(ctx, next)
+	}
+	return fmt.Errorf("session not found")
+}
+
+func (r *fakeSessionRepo) RevokeSessionFamily(ctx context.Context, familyID uuid.UUID) error {
+	now := time.Now()
+	for _, session := range r.sessions {
+		if session.FamilyID == familyID && session.RevokedAt == nil {
+			session.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *fakeSessionRepo) IsSessionFamilyActive(ctx context.Context, familyID uuid.UUID) (bool, error) {
+	for _, session := range r.sessions {
+		if session.FamilyID == familyID && session.RotatedAt == nil && session.RevokedAt == nil && time.Now().Before(session.ExpiresAt) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeSessionRepo) RevokeUserSessions(ctx context.Context, userID uuid.UUID) error {
+	now := time.Now()
+	for _, session := range r.sessions {
+		if session.UserID == userID && session.RevokedAt == nil {
+			session.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+type fakeChallenge struct {
+	failures int
+	claimed  bool
+}
+
+type fakeMFARepo struct {
+	repository.MFARepository
+	mu            sync.Mutex
+	totp          map[uuid.UUID]*models.UserTOTP
+	recoveryCodes map[uuid.UUID]map[string]bool
+	challenges    map[string]*fakeChallenge
+}
+
+func (r *fakeMFARepo) FindTOTP(ctx context.Context, userID uuid.UUID) (*models.UserTOTP, error) {
+	totp, ok := r.totp[userID]
+	if !ok {
+		return nil, fmt.Errorf("totp not found")
+	}
+	return totp, nil
+}
+
+func (r *fakeMFARepo) IsTOTPEnabled(ctx context.Context, userID uuid.UUID) (bool, error) {
+	totp, ok := r.totp[userID]
+	return ok && totp.EnabledAt != nil, nil
+}
+
+func (r *fakeMFARepo) UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) error {
+	totp := r.totp[userID]
+	if totp.LastUsedStep >= step {
+		return fmt.Errorf("totp code already used")
+	}
+	totp.LastUsedStep = step
+	return nil
+}
+
+func (r *fakeMFARepo) SavePendingTOTP(ctx context.Context, userID uuid.UUID, secret string) error {
+	r.totp[userID] = &models.UserTOTP{UserID: userID, Secret: secret}
+	return nil
+}
+
+func (r *fakeMFARepo) EnableTOTP(ctx context.Context, userID uuid.UUID, step int64, recoveryCodeHashes []string) error {
+	enabledAt := time.Now()
+	r.totp[userID].EnabledAt = &enabledAt
+	r.totp[userID].LastUsedStep = step
+	r.recoveryCodes[userID] = make(map[string]bool)
+	for _, hash := range recoveryCodeHashes {
+		r.recoveryCodes[userID][hash] = true
+	}
+	return nil
+}
+
+func (r *fakeMFARepo) UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) error {
+	if !r.recoveryCodes[userID][codeHash] {
+		return fmt.Errorf("recovery code not found")
+	}
+	delete(r.recoveryCodes[userID], codeHash)
+	return nil
+}
+
+func (r *fakeMFARepo) ClaimMFAChallenge(ctx context.Context, tokenID string, userID uuid.UUID, expiresAt time.Time, maxFailures int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	challenge, ok := r.challenges[tokenID]
+	if !ok {
+		r.challenges[tokenID] = &fakeChallenge{claimed: true}
+		return true, nil
+	}
+	if challenge.claimed || challenge.failures >= maxFailures {
+		return false, nil
+	}
+	challenge.claimed = true
+	return true, nil
+}
+
+func (r *fakeMFARepo) ReleaseMFAChallenge(ctx context.Context, tokenID string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	challenge := r.challenges[tokenID]
+	challenge.failures++
+	challenge.claimed = false
+	return challenge.failures, nil
+}
+
+type fakeLoginThrottleRepo struct {
+	throttles map[string]*models.LoginThrottle
+}
+
+func (r *fakeLoginThrottleRepo) FindLoginThrottle(ctx context.Context, subjectType, subjectKey string) (*models.LoginThrottle, error) {
+	if throttle, ok := r.throttles[subjectType+"|"+subjectKey]; ok {
+		return throttle, nil
+	}
+	return &models.LoginThrottle{SubjectType: subjectType, SubjectKey: subjectKey}, nil
+}
+
+func (r *fakeLoginThrottleRepo) RecordLoginFailure(ctx context.Context, subjectType, subjectKey string, window time.Duration) (*models.LoginThrottle, error) {
+	now := time.Now()
+	throttle, _ := r.FindLoginThrottle(ctx, subjectType, subjectKey)
+	if throttle.Failures == 0 || now.Sub(throttle.WindowStartedAt) > window {
+		throttle.Failures = 0
+		throttle.WindowStartedAt = now
+	}
+	throttle.Failures++
+	throttle.LastFailureAt = now
+	r.throttles[subjectType+"|"+subjectKey] = throttle
+	return throttle, nil
+}
+
+func (r *fakeLoginThrottleRepo) LockLoginSubject(ctx context.Context, subjectType, subjectKey string, until time.Time) error {
+	r.throttles[subjectType+"|"+subjectKey].LockedUntil = &until
+	return nil
+}
+
+func (r *fakeLoginThrottleRepo) ResetLoginThrottle(ctx context.Context, subjectType, subjectKey string) error {
+	delete(r.throttles, subjectType+"|"+subjectKey)
+	return nil
+}
+
+type fakeRoleRepo struct {
+	repository.RoleRepository
+	roles     map[uuid.UUID]*models.Role
+	userRoles map[uuid.UUID]map[uuid.UUID]bool
+	versions  map[uuid.UUID]int64
+}
+
+// bumpHolders bumps the authorization version of every user that holds the role.
+func (r *fakeRoleRepo) bumpHolders(roleID uuid.UUID) {
+	for userID, roles := range r.userRoles {
+		if roles[roleID] {
+			r.versions[userID]++
+		}
+	}
+}
+
+func (r *fakeRoleRepo) CreateRole(ctx context.Context, name string) (*models.Role, error) {
+	if _, err := r.FindRoleByName(ctx, name); err == nil {
+		return nil, repository.ErrRoleAlreadyExists
+	}
+	role := &models.Role{ID: uuid.New(), Name: name}
+	r.roles[role.ID] = role
+	return role, nil
+}
+
+func (r *fakeRoleRepo) DeleteRole(ctx context.Context, id uuid.UUID) error {
+	if _, ok := r.roles[id]; !ok {
+		return repository.ErrRoleNotFound
+	}
+	r.bumpHolders(id)
+	delete(r.roles, id)
+	for _, roles := range r.userRoles {
+		delete(roles, id)
+	}
+	return nil
+}
+
+func (r *fakeRoleRepo) FindRoleByID(ctx context.Context, id uuid.UUID) (*models.Role, error) {
+	role, ok := r.roles[id]
+	if !ok {
+		return nil, repository.ErrRoleNotFound
+	}
+	return role, nil
+}
+
+func (r *fakeRoleRepo) FindRoleByName(ctx context.Context, name string) (*models.Role, error) {
+	for _, role := range r.roles {
+		if role.Name == name {
+			return role, nil
+		}
+	}
+	return nil, repository.ErrRoleNotFound
+}
+
+func (r *fakeRoleRepo) ListRoles(ctx context.Context) ([]*models.Role, error) {
+	var roles []*models.Role
+	for _, role := range r.roles {
+		roles = append(roles, role)
+	}
+	return roles, nil
+}
+
+func (r *fakeRoleRepo) AssignRoleToUser(ctx context.Context, userID, roleID uuid.UUID) error {
+	if _, ok := r.roles[roleID]; !ok {
+		return fmt.Errorf("user or role not found")
+	}
+	if r.userRoles[userID] == nil {
+		r.userRoles[userID] = make(map[uuid.UUID]bool)
+	}
+	if !r.userRoles[userID][roleID] {
+		r.userRoles[userID][roleID] = true
+		r.versions[userID]++
+	}
+	return nil
+}
+
+func (r *fakeRoleRepo) RemoveRoleFromUser(ctx context.Context, userID, roleID uuid.UUID) error {
+	if !r.userRoles[userID][roleID] {
+		return fmt.Errorf("role not assigned to user")
+	}
+	delete(r.userRoles[userID], roleID)
+	r.versions[userID]++
+	return nil
+}
+
+func (r *fakeRoleRepo) ListUserRoles(ctx context.Context, userID uuid.UUID) ([]*models.Role, error) {
+	var roles []*models.Role
+	for roleID := range r.userRoles[userID] {
+		roles = append(roles, r.roles[roleID])
+	}
+	return roles, nil
+}
+
+func (r *fakeRoleRepo) UserHasRole(ctx context.Context, userID uuid.UUID, roleName string) (bool, error) {
+	for _, role := range r.roles {
+		if role.Name == roleName && r.userRoles[userID][role.ID] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeRoleRepo) FindUserAuthorization(ctx context.Context, userID uuid.UUID) (*models.UserAuthorization, error) {
+	authz := &models.UserAuthorization{Version: r.versions[userID]}
+	for roleID := range r.userRoles[userID] {
+		role := r.roles[roleID]
+		authz.Roles = append(authz.Roles, role.Name)
+		for _, permission := range role.Permissions {
+			if !slices.Contains(authz.Permissions, permission) {
+				authz.Permissions = append(authz.Permissions, permission)
+			}
+		}
+	}
+	slices.Sort(authz.Roles)
+	slices.Sort(authz.Permissions)
+	return authz, nil
+}
+
+type fakePermissionRepo struct {
+	repository.PermissionRepository
+	roles *fakeRoleRepo
+}
+
+func (r *fakePermissionRepo) GrantPermissionToRole(ctx context.Context, roleID uuid.UUID, permissionName string) error {
+	role, err := r.roles.FindRoleByID(ctx, roleID)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(role.Permissions, permissionName) {
+		role.Permissions = append(role.Permissions, permissionName)
+		r.roles.bumpHolders(roleID)
+	}
+	return nil
+}
+
+func (r *fakePermissionRepo) RevokePermissionFromRole(ctx context.Context, roleID uuid.UUID, permissionName string) error {
+	role, err := r.roles.FindRoleByID(ctx, roleID)
+	if err != nil {
+		return err
+	}
+	i := slices.Index(role.Permissions, permissionName)
+	if i < 0 {
+		return fmt.Errorf("permission not granted to role")
+	}
+	role.Permissions = slices.Delete(role.Permissions, i, i+1)
+	r.roles.bumpHolders(roleID)
+	return nil
+}
+
+type fakeSuspensionRepo struct {
+	repository.SuspensionRepository
+	users       *fakeUserRepo
+	suspensions []*models.UserSuspension
+}
+
+func (r *fakeSuspensionRepo) SuspendUser(ctx context.Context, suspension *models.UserSuspension) error {
+	user, ok := r.users.users[suspension.UserID.String()]
+	if !ok {
+		return repository.ErrUserNotFound
+	}
+	user.IsActive = false
+
+	if open, err := r.FindOpenSuspension(ctx, suspension.UserID); err == nil {
+		now := time.Now()
+		open.LiftedAt = &now
+	}
+
+	suspension.ID = uuid.New()
+	suspension.CreatedAt = time.Now()
+	r.suspensions = append(r.suspensions, suspension)
+	return nil
+}
+
+func (r *fakeSuspensionRepo) LiftSuspension(ctx context.Context, userID uuid.UUID, liftedBy *uuid.UUID, reason string) (*models.UserSuspension, error) {
+	suspension, err := r.FindOpenSuspension(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	suspension.LiftedAt = &now
+	suspension.LiftedBy = liftedBy
+	suspension.LiftReason = reason
+	r.users.users[userID.String()].IsActive = true
+	return suspension, nil
+}
+
+func (r *fakeSuspensionRepo) FindOpenSuspension(ctx context.Context, userID uuid.UUID) (*models.UserSuspension, error) {
+	for _, suspension := range r.suspensions {
+		if suspension.UserID == userID && suspension.LiftedAt == nil {
+			return suspension, nil
+		}
+	}
+	return nil, repository.ErrNotSuspended
+}
+
+func (r *fakeSuspensionRepo) EndExpiredSuspension(ctx context.Context, userID uuid.UUID) (bool, error) {
+	suspension, err := r.FindOpenSuspension(ctx, userID)
+	if err != nil || suspension.ExpiresAt == nil || suspension.ExpiresAt.After(time.Now()) {
+		return false, nil
+	}
+	suspension.LiftedAt = suspension.ExpiresAt
+	suspension.LiftReason = "expired"
+	r.users.users[userID.String()].IsActive = true
+	return true, nil
+}
+
+// countingHasher counts the password hashes that were actually checked, not refused as malformed.
+type countingHasher struct {
+	*password.Hasher
+	verified int
+}
+
+func (h *countingHasher) Verify(encoded, plain string) (bool, bool, error) {
+	match, needsRehash, err := h.Hasher.Verify(encoded, plain)
+	if err == nil {
+		h.verified++
+	}
+	return match, needsRehash, err
+}
+
+// authFixture is an auth service on in-memory repositories. Failed logins lock an account after
+// five failures and never back off in between, so tests can retry right away. The admin and
+// moderator roles are seeded like the migrations do.
+type authFixture struct {
+	service     service.AuthService
+	users       *fakeUserRepo
+	sessions    *fakeSessionRepo
+	mfa         *fakeMFARepo
+	throttles   *fakeLoginThrottleRepo
+	roles       *fakeRoleRepo
+	audit       *fakeAuditRepo
+	suspensions *fakeSuspensionRepo
+	resets      *fakePasswordResetRepo
+	emails      *fakeEmailChangeRepo
+	mailer      *fakeMailer
+	revocations service.RevocationStore
+	hasher      *countingHasher
+}
+
+// newAuthFixture wires the service to in-memory fakes. configure adjusts the configuration of
+// the service before it is created.
+func newAuthFixture(t *testing.T, configure ...func(*config.Config)) *authFixture {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	cfg := config.Default()
+	cfg.JWT.Secret = "test-secret"
+	cfg.Mail.PasswordResetURL = "https://hikayat.test/reset?token="
+	cfg.Mail.ConfirmEmailChangeURL = "https://hikayat.test/email/confirm?token="
+	cfg.Mail.CancelEmailChangeURL = "https://hikayat.test/email/cancel?token="
+	for _, c := range configure {
+		c(&cfg)
+	}
+
+	secret, err := jwtkeys.NewHMACKey(jwtkeys.LegacyHMACKeyID, []byte(cfg.JWT.Secret))
+	require.NoError(t, err)
+	fallback, err := jwtkeys.NewStaticSet(secret)
+	require.NoError(t, err)
+	tokenKeys, err := service.NewKeyManager(ctx, &fakeSigningKeyRepo{}, fallback, service.KeyManagerConfig{
+		Algorithm:     jwtkeys.AlgorithmEdDSA,
+		Overlap:       time.Hour,
+		EncryptionKey: make([]byte, 32),
+	}, time.Hour)
+	require.NoError(t, err)
+
+	revocations, err := service.NewRevocationStore(ctx, &fakeRevocationRepo{}, cfg.JWT.AccessTTL, time.Hour)
+	require.NoError(t, err)
+
+	hasher, err := password.NewHasher(password.Params{Memory: 64, Iterations: 1, Parallelism: 1}, nil)
+	require.NoError(t, err)
+
+	templates, err := service.NewMailTemplates(service.DefaultMailTemplatesFS(), "en")
+	require.NoError(t, err)
+
+	policy := service.LoginThrottlePolicy{MaxFailures: 5, Window: time.Hour, LockoutDuration: time.Hour}
+	ipPolicy := policy
+	ipPolicy.MaxFailures = 100
+
+	f := &authFixture{
+		users:     &fakeUserRepo{users: make(map[string]*authpb.User), hashes: make(map[string]string)},
+		sessions:  &fakeSessionRepo{},
+		mfa:       &fakeMFARepo{totp: make(map[uuid.UUID]*models.UserTOTP), recoveryCodes: make(map[uuid.UUID]map[string]bool), challenges: make(map[string]*fakeChallenge)},
+		throttles: &fakeLoginThrottleRepo{throttles: make(map[string]*models.LoginThrottle)},
+		roles:     &fakeRoleRepo{roles: make(map[uuid.UUID]*models.Role), userRoles: make(map[uuid.UUID]map[uuid.UUID]bool), versions: make(map[uuid.UUID]int64)},
+		audit:     &fakeAuditRepo{},
+		hasher:    &countingHasher{Hasher: hasher},
+	}
+	f.suspensions = &fakeSuspensionRepo{users: f.users}
+	f.resets = &fakePasswordResetRepo{}
+	f.emails = &fakeEmailChangeRepo{}
+	f.mailer = &fakeMailer{sent: make(chan *service.MailMessage, 10)}
+	f.revocations = revocations
+	for _, name := range []string{models.RoleAdmin, models.RoleModerator} {
+		_, err := f.roles.CreateRole(ctx, name)
+		require.NoError(t, err)
+	}
+
+	f.service = service.NewAuthService(
+		f.users,
+		f.sessions,
+		f.resets,
+		f.emails,
+		f.mfa,
+		nil,
+		f.throttles,
+		f.roles,
+		&fakePermissionRepo{roles: f.roles},
+		f.audit,
+		f.suspensions,
+		nil,
+		f.hasher,
+		tokenKeys,
+		service.LoginThrottleConfig{Account: policy, IP: ipPolicy},
+		f.revocations,
+		f.mailer,
+		templates,
+		nil,
+		&cfg,
+	)
+
+	return f
+}
+
+// addUser stores an active, verified user with the given password.
+func (f *authFixture) addUser(t *testing.T, email, plain string) *authpb.User {
+	t.Helper()
+
+	hash, err := f.hasher.Hash(plain)
+	require.NoError(t, err)
+
+	user := &authpb.User{
+		Id:            uuid.NewString(),
+		Name:          strings.Split(email, "@")[0],
+		Email:         email,
+		IsActive:      true,
+		EmailVerified: true,
+	}
+	f.users.users[user.Id] = user
+	f.users.hashes[user.Id] = hash
+
+	return user
+}
+
+// enableTOTP enrolls the user in TOTP and returns the secret.
+func (f *authFixture) enableTOTP(t *testing.T, user *authpb.User) string {
+	t.Helper()
+
+	secret := "JBSWY3DPEHPK3PXP"
+	enabledAt := time.Now()
+	f.mfa.totp[uuid.MustParse(user.Id)] = &models.UserTOTP{UserID: uuid.MustParse(user.Id), Secret: secret, EnabledAt: &enabledAt}
+
+	return secret
+}
+
+// nextMail waits for the next message the service sends and checks its recipient.
+func (f *authFixture) nextMail(t *testing.T, to string) *service.MailMessage {
+	t.Helper()
+
+	select {
+	case msg := <-f.mailer.sent:
+		require.Equal(t, to, msg.To)
+		return msg
+	case <-time.After(time.Second):
+		t.Fatalf("no mail sent to %s", to)
+		return nil
+	}
+}
+
+// mailToken returns the token of the link starting with url in the mail.
+func mailToken(t *testing.T, msg *service.MailMessage, url string) string {
+	t.Helper()
+
+	i := strings.Index(msg.TextBody, url)
+	require.GreaterOrEqual(t, i, 0, "no %s link in the mail", url)
+
+	token := msg.TextBody[i+len(url):]
+	if end := strings.IndexFunc(token, unicode.IsSpace); end >= 0 {
+		token = token[:end]
+	}
+
+	return token
+}
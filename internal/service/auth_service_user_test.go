@@ -0,0 +1,28 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/service"
+	"github.com/stretchr/testify/require"
+
+	authpb "github.com/Nucleussss/hikayat-proto/gen/go/auth/v1"
+)
+
+func TestDeleteUserRevokesAccessTokens(t *testing.T) {
+	ctx := context.Background()
+	f := newAuthFixture(t)
+	user := f.addUser(t, "leaving@example.com", "correct horse")
+	issuedAt := time.Now().Add(-time.Minute)
+
+	require.NoError(t, f.service.DeleteUser(ctx, &authpb.DeleteUserRequest{Id: user.Id}))
+
+	// an admin deleting the account must not leave its tokens valid until they expire
+	revoked, err := f.revocations.IsRevoked(ctx, service.RevocationCheck{TokenID: "jti", UserID: user.Id, IssuedAt: issuedAt})
+	require.NoError(t, err)
+	require.True(t, revoked)
+
+	require.Error(t, f.service.DeleteUser(ctx, &authpb.DeleteUserRequest{Id: user.Id}))
+}
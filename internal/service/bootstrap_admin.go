@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/repository"
+	"github.com/google/uuid"
+)
+
+// BootstrapAdmin gives the admin role to the user registered with the given email, so a fresh
+// deployment has someone who can assign roles through the admin RPCs. The email has to be verified,
+// otherwise whoever registers the address first would become admin. Users that already are admins
+// are left alone, the grant is recorded in the audit log when audit is not nil.
+func BootstrapAdmin(ctx context.Context, users repository.UserRepository, roles repository.RoleRepository, audit *AuditRecorder, email string) error {
+	op := "service.BootstrapAdmin"
+
+	user, err := users.FindUserByEmail(ctx, email)
+	if err != nil {
+		return fmt.Errorf("finding bootstrap admin %s: %w", email, err)
+	}
+
+	if !user.EmailVerified {
+		return fmt.Errorf("bootstrap admin %s has not verified their email", email)
+	}
+
+	userID := uuid.MustParse(user.Id)
+
+	isAdmin, err := roles.UserHasRole(ctx, userID, models.RoleAdmin)
+	if err != nil {
+		return fmt.Errorf("checking roles of bootstrap admin %s: %w", email, err)
+	}
+	if isAdmin {
+		return nil
+	}
+
+	role, err := roles.FindRoleByName(ctx, models.RoleAdmin)
+	if err != nil {
+		return fmt.Errorf("finding the admin role: %w", err)
+	}
+
+	if err := roles.AssignRoleToUser(ctx, userID, role.ID); err != nil {
+		return fmt.Errorf("assigning the admin role to %s: %w", email, err)
+	}
+
+	log.Printf("%s Assigned the admin role to user %s", op, user.Id)
+
+	if audit != nil {
+		audit.Record(&models.AuditEvent{
+			Action:       models.AuditActionRoleAssigned,
+			Outcome:      models.AuditOutcomeSuccess,
+			TargetUserID: &userID,
+			Metadata:     map[string]any{"role_id": role.ID.String(), "role": role.Name, "bootstrap": true},
+		})
+	}
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrEmailNotVerified is returned by Login when unverified accounts are refused.
+var ErrEmailNotVerified = errors.New("email not verified")
+
+// ErrAuthorizationStale is returned by RefreshToken when the user's roles or permissions changed
+// after the session's tokens were issued. The user has to log in again to get fresh claims.
+var ErrAuthorizationStale = errors.New("authorization stale")
+
+// ErrAccountSuspended is returned by Login, RefreshToken and the other ways of getting tokens while
+// the user is suspended or deactivated.
+var ErrAccountSuspended = errors.New("account suspended")
+
+// ErrIncorrectPassword is returned by ChangeUserEmail when the current password does not match.
+var ErrIncorrectPassword = errors.New("current password is incorrect")
+
+// ErrEmailTaken is returned by ChangeUserEmail and ConfirmEmailChange when another account already uses
+// the new address.
+var ErrEmailTaken = errors.New("email address is already taken")
+
+// ErrSelfSuspension is returned by SuspendUser when the caller tries to suspend themselves.
+var ErrSelfSuspension = errors.New("you cannot suspend your own account")
+
+// ErrProtectedRole is returned by DeleteRole for the roles seeded by the migrations.
+var ErrProtectedRole = errors.New("the seeded admin and moderator roles cannot be deleted")
+
+// ErrInvalidPageToken is returned by the audit listings for a page token they did not hand out.
+var ErrInvalidPageToken = errors.New("invalid page token")
+
+// ThrottledError is returned by the logins and the password and code re-checks while the account
+// or the source IP is backing off or locked after failed attempts. RetryAfter is how long the
+// client has to wait.
+type ThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, retry in %s", e.RetryAfter.Round(time.Second))
+}
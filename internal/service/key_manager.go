@@ -0,0 +1,368 @@
+package service
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/jwtkeys"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/repository"
+	"github.com/Nucleussss/hikayat-forum/auth/pkg/config"
+)
+
+// KeyManagerConfig controls how signing keys are rotated.
+type KeyManagerConfig struct {
+	// Algorithm of the keys a rotation generates.
+	Algorithm string
+	// PublishDelay is how long a new key is only published before it signs tokens, so verifiers
+	// that cache the JWKS know it before they see tokens signed with it.
+	PublishDelay time.Duration
+	// Overlap is how long a replaced key keeps validating tokens after its successor activates.
+	// It must cover the lifetime of the longest lived token.
+	Overlap time.Duration
+	// EncryptionKey seals the private keys stored in the database (AES-256-GCM).
+	EncryptionKey []byte
+}
+
+// NewKeyManagerConfig derives the rotation settings from the JWT configuration. Without an
+// explicit overlap, replaced keys validate tokens for the lifetime of the longest lived token.
+func NewKeyManagerConfig(cfg config.JWTConfig) (KeyManagerConfig, error) {
+	keyConfig := KeyManagerConfig{
+		Algorithm:    cfg.RotationAlgorithm,
+		PublishDelay: cfg.KeyPublishDelay,
+		Overlap:      cfg.KeyOverlap,
+	}
+	if keyConfig.Overlap == 0 {
+		keyConfig.Overlap = cfg.LongestTokenTTL()
+	}
+
+	if cfg.KeyEncryptionKey != "" {
+		key, err := base64.StdEncoding.DecodeString(cfg.KeyEncryptionKey)
+		if err != nil || len(key) != 32 {
+			return KeyManagerConfig{}, fmt.Errorf("JWT_KEY_ENCRYPTION_KEY must be 32 base64 encoded bytes")
+		}
+		keyConfig.EncryptionKey = key
+	}
+
+	return keyConfig, nil
+}
+
+// managedKey is a stored signing key together with its validity window.
+type managedKey struct {
+	key         *jwtkeys.Key
+	activatesAt time.Time
+	retiresAt   *time.Time
+}
+
+func (k managedKey) validAt(now time.Time) bool {
+	return k.retiresAt == nil || now.Before(*k.retiresAt)
+}
+
+// KeyManager is a jwtkeys.Set over the signing keys stored in the database. Exactly one key signs
+// new tokens at a time, replaced keys keep validating tokens until they retire. Until the first
+// rotation, and for the overlap after it, the fallback keys from the environment are used as well.
+type KeyManager struct {
+	repo     repository.SigningKeyRepository
+	fallback jwtkeys.Set
+	config   KeyManagerConfig
+
+	mu                sync.RWMutex
+	keys              []managedKey
+	fallbackRetiresAt *time.Time
+}
+
+// NewKeyManager loads the stored keys and keeps them in sync with the database every
+// refreshInterval until ctx is cancelled, so rotations made by other replicas are picked up.
+func NewKeyManager(ctx context.Context, repo repository.SigningKeyRepository, fallback jwtkeys.Set, config KeyManagerConfig, refreshInterval time.Duration) (*KeyManager, error) {
+	manager := &KeyManager{
+		repo:     repo,
+		fallback: fallback,
+		config:   config,
+	}
+
+	if err := manager.reload(ctx); err != nil {
+		return nil, err
+	}
+
+	go manager.run(ctx, refreshInterval)
+
+	return manager, nil
+}
+
+func (m *KeyManager) run(ctx context.Context, refreshInterval time.Duration) {
+	op := "keyManager.run"
+
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.reload(ctx); err != nil {
+				log.Printf("%s Error reloading signing keys: %v", op, err)
+			}
+		}
+	}
+}
+
+// reload replaces the cached keys with the ones stored in the database.
+func (m *KeyManager) reload(ctx context.Context) error {
+	stored, err := m.repo.ListSigningKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]managedKey, 0, len(stored))
+	var firstActivation *time.Time
+	for _, s := range stored {
+		material, err := m.unseal(s)
+		if err != nil {
+			return err
+		}
+
+		key, err := jwtkeys.ParsePrivateKey(s.ID, s.Algorithm, material)
+		if err != nil {
+			return err
+		}
+
+		keys = append(keys, managedKey{key: key, activatesAt: s.ActivatesAt, retiresAt: s.RetiresAt})
+		if firstActivation == nil || s.ActivatesAt.Before(*firstActivation) {
+			firstActivation = &s.ActivatesAt
+		}
+	}
+
+	var fallbackRetiresAt *time.Time
+	if firstActivation != nil {
+		retiresAt := firstActivation.Add(m.config.Overlap)
+		fallbackRetiresAt = &retiresAt
+	}
+
+	m.mu.Lock()
+	m.keys = keys
+	m.fallbackRetiresAt = fallbackRetiresAt
+	m.mu.Unlock()
+
+	return nil
+}
+
+// fallbackValidAt reports whether the keys from the environment are still in their window. The
+// caller must hold the read lock.
+func (m *KeyManager) fallbackValidAt(now time.Time) bool {
+	return m.fallback != nil && (m.fallbackRetiresAt == nil || now.Before(*m.fallbackRetiresAt))
+}
+
+// SigningKey returns the most recently activated stored key, or the fallback signing key before
+// the first rotation activates.
+func (m *KeyManager) SigningKey() (*jwtkeys.Key, error) {
+	now := time.Now()
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, k := range m.keys {
+		if !now.Before(k.activatesAt) && k.validAt(now) {
+			return k.key, nil
+		}
+	}
+
+	if m.fallback == nil {
+		return nil, fmt.Errorf("no active signing key")
+	}
+
+	return m.fallback.SigningKey()
+}
+
+// VerificationKey returns the key with the given ID as long as it has not retired. Keys that are
+// not active yet are accepted too, they are already published.
+func (m *KeyManager) VerificationKey(kid string) (*jwtkeys.Key, error) {
+	now := time.Now()
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, k := range m.keys {
+		if k.key.ID == kid && k.validAt(now) {
+			return k.key, nil
+		}
+	}
+
+	if m.fallbackValidAt(now) {
+		return m.fallback.VerificationKey(kid)
+	}
+
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
+// VerificationKeys returns every key that has not retired.
+func (m *KeyManager) VerificationKeys() []*jwtkeys.Key {
+	now := time.Now()
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var keys []*jwtkeys.Key
+	for _, k := range m.keys {
+		if k.validAt(now) {
+			keys = append(keys, k.key)
+		}
+	}
+
+	if m.fallbackValidAt(now) {
+		keys = append(keys, m.fallback.VerificationKeys()...)
+	}
+
+	return keys
+}
+
+// Rotate generates a new signing key. It is published right away, signs tokens after the publish
+// delay and the keys it replaces retire one overlap after that.
+func (m *KeyManager) Rotate(ctx context.Context) (*models.SigningKey, error) {
+	if len(m.config.EncryptionKey) == 0 {
+		return nil, fmt.Errorf("JWT_KEY_ENCRYPTION_KEY is not set, signing keys cannot be stored")
+	}
+
+	key, err := jwtkeys.GenerateKey(m.config.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	material, err := jwtkeys.MarshalPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	next := &models.SigningKey{
+		ID:          key.ID,
+		Algorithm:   key.Algorithm,
+		ActivatesAt: time.Now().Add(m.config.PublishDelay),
+	}
+
+	next.SealedKey, err = m.seal(next, material)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.repo.RotateSigningKey(ctx, next, next.ActivatesAt.Add(m.config.Overlap)); err != nil {
+		return nil, err
+	}
+
+	if err := m.reload(ctx); err != nil {
+		return nil, err
+	}
+
+	return next, nil
+}
+
+// KeyMetric describes one signing key for monitoring.
+type KeyMetric struct {
+	ID          string     `json:"kid"`
+	Algorithm   string     `json:"alg"`
+	State       string     `json:"state"`
+	AgeSeconds  float64    `json:"age_seconds"`
+	ActivatesAt time.Time  `json:"activates_at"`
+	RetiresAt   *time.Time `json:"retires_at,omitempty"`
+}
+
+// KeyMetrics is the snapshot the key manager publishes through expvar.
+type KeyMetrics struct {
+	ActiveKeyID         string      `json:"active_kid"`
+	ActiveKeyAgeSeconds float64     `json:"active_key_age_seconds"`
+	Keys                []KeyMetric `json:"keys"`
+}
+
+// Metrics reports the stored keys with their state and age, the age being the time since the key
+// activated. Keys from the environment are not stored, their age is reported as zero.
+func (m *KeyManager) Metrics() KeyMetrics {
+	now := time.Now()
+
+	metrics := KeyMetrics{Keys: []KeyMetric{}}
+	if active, err := m.SigningKey(); err == nil {
+		metrics.ActiveKeyID = active.ID
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, k := range m.keys {
+		metric := KeyMetric{
+			ID:          k.key.ID,
+			Algorithm:   k.key.Algorithm,
+			AgeSeconds:  max(now.Sub(k.activatesAt).Seconds(), 0),
+			ActivatesAt: k.activatesAt,
+			RetiresAt:   k.retiresAt,
+		}
+
+		switch {
+		case !k.validAt(now):
+			metric.State = "retired"
+		case now.Before(k.activatesAt):
+			metric.State = "pending"
+		case k.key.ID == metrics.ActiveKeyID:
+			metric.State = "active"
+			metrics.ActiveKeyAgeSeconds = metric.AgeSeconds
+		default:
+			metric.State = "verify_only"
+		}
+
+		metrics.Keys = append(metrics.Keys, metric)
+	}
+
+	return metrics
+}
+
+// seal encrypts private key material with AES-256-GCM, binding it to the key ID.
+func (m *KeyManager) seal(key *models.SigningKey, material []byte) ([]byte, error) {
+	aead, err := m.aead()
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, material, []byte(key.ID)), nil
+}
+
+// unseal decrypts the private key material of a stored key.
+func (m *KeyManager) unseal(key *models.SigningKey) ([]byte, error) {
+	aead, err := m.aead()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(key.SealedKey) < aead.NonceSize() {
+		return nil, fmt.Errorf("sealed key %s is truncated", key.ID)
+	}
+
+	nonce, sealed := key.SealedKey[:aead.NonceSize()], key.SealedKey[aead.NonceSize():]
+	material, err := aead.Open(nil, nonce, sealed, []byte(key.ID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unseal signing key %s: %w", key.ID, err)
+	}
+
+	return material, nil
+}
+
+func (m *KeyManager) aead() (cipher.AEAD, error) {
+	if len(m.config.EncryptionKey) == 0 {
+		return nil, fmt.Errorf("JWT_KEY_ENCRYPTION_KEY is not set")
+	}
+
+	block, err := aes.NewCipher(m.config.EncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
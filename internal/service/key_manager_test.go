@@ -0,0 +1,109 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/jwtkeys"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/service"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSigningKeyRepo struct {
+	stored []*models.SigningKey
+}
+
+func (r *fakeSigningKeyRepo) ListSigningKeys(ctx context.Context) ([]*models.SigningKey, error) {
+	keys := make([]*models.SigningKey, len(r.stored))
+	for i, key := range r.stored {
+		keys[len(r.stored)-1-i] = key
+	}
+	return keys, nil
+}
+
+func (r *fakeSigningKeyRepo) RotateSigningKey(ctx context.Context, next *models.SigningKey, retireAt time.Time) error {
+	for _, key := range r.stored {
+		if key.RetiresAt == nil {
+			key.RetiresAt = &retireAt
+		}
+	}
+	next.CreatedAt = time.Now()
+	r.stored = append(r.stored, next)
+	return nil
+}
+
+func TestKeyManagerRotation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	secret, err := jwtkeys.NewHMACKey(jwtkeys.LegacyHMACKeyID, []byte("test-secret"))
+	require.NoError(t, err)
+	fallback, err := jwtkeys.NewStaticSet(secret)
+	require.NoError(t, err)
+
+	config := service.KeyManagerConfig{
+		Algorithm:     jwtkeys.AlgorithmEdDSA,
+		Overlap:       time.Hour,
+		EncryptionKey: make([]byte, 32),
+	}
+
+	repo := &fakeSigningKeyRepo{}
+	manager, err := service.NewKeyManager(ctx, repo, fallback, config, time.Hour)
+	require.NoError(t, err)
+
+	// before the first rotation the fallback signs
+	signing, err := manager.SigningKey()
+	require.NoError(t, err)
+	require.Equal(t, jwtkeys.LegacyHMACKeyID, signing.ID)
+
+	first, err := manager.Rotate(ctx)
+	require.NoError(t, err)
+
+	signing, err = manager.SigningKey()
+	require.NoError(t, err)
+	require.Equal(t, first.ID, signing.ID)
+
+	// the fallback keeps validating tokens for the overlap
+	_, err = manager.VerificationKey(jwtkeys.LegacyHMACKeyID)
+	require.NoError(t, err)
+
+	// the private key is stored sealed
+	require.NotContains(t, string(repo.stored[0].SealedKey), "PRIVATE")
+
+	second, err := manager.Rotate(ctx)
+	require.NoError(t, err)
+
+	// a second replica signs with the latest key and still accepts the replaced one
+	replica, err := service.NewKeyManager(ctx, repo, fallback, config, time.Hour)
+	require.NoError(t, err)
+	signing, err = replica.SigningKey()
+	require.NoError(t, err)
+	require.Equal(t, second.ID, signing.ID)
+	_, err = replica.VerificationKey(first.ID)
+	require.NoError(t, err)
+
+	metrics := replica.Metrics()
+	require.Equal(t, second.ID, metrics.ActiveKeyID)
+	require.Len(t, metrics.Keys, 2)
+	require.Equal(t, "verify_only", metrics.Keys[1].State)
+
+	// without an overlap replaced keys retire as soon as their successor activates
+	config.Overlap = 0
+	strict, err := service.NewKeyManager(ctx, repo, fallback, config, time.Hour)
+	require.NoError(t, err)
+	third, err := strict.Rotate(ctx)
+	require.NoError(t, err)
+	_, err = strict.VerificationKey(second.ID)
+	require.Error(t, err)
+	_, err = strict.VerificationKey(jwtkeys.LegacyHMACKeyID)
+	require.Error(t, err)
+	_, err = strict.VerificationKey(third.ID)
+	require.NoError(t, err)
+
+	// keys sealed with another encryption key are refused
+	config.EncryptionKey = []byte("0123456789abcdef0123456789abcdef")
+	_, err = service.NewKeyManager(ctx, repo, fallback, config, time.Hour)
+	require.Error(t, err)
+}
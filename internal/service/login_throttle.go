@@ -0,0 +1,227 @@
+package service
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/Nucleussss/hikayat-forum/auth/pkg/config"
+	"github.com/Nucleussss/hikayat-forum/auth/pkg/utils"
+
+	authpb "github.com/Nucleussss/hikayat-proto/gen/go/auth/v1"
+)
+
+// LoginThrottlePolicy decides how a subject is slowed down after failed logins. Every failure
+// within Window doubles the delay before the next attempt, starting at BaseDelay and capped at
+// MaxDelay. Reaching MaxFailures locks the subject for LockoutDuration.
+type LoginThrottlePolicy struct {
+	MaxFailures     int
+	Window          time.Duration
+	LockoutDuration time.Duration
+	BaseDelay       time.Duration
+	MaxDelay        time.Duration
+}
+
+// Delay returns the backoff after the given number of consecutive failures.
+func (p LoginThrottlePolicy) Delay(failures int) time.Duration {
+	if failures <= 0 || p.BaseDelay <= 0 {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := 1; i < failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, p.MaxDelay)
+}
+
+// RetryAfter returns how long the subject has to wait before its next attempt, zero if it may try now.
+func (p LoginThrottlePolicy) RetryAfter(throttle *models.LoginThrottle, now time.Time) time.Duration {
+	if throttle.LockedUntil != nil && now.Before(*throttle.LockedUntil) {
+		return throttle.LockedUntil.Sub(now)
+	}
+
+	if throttle.Failures == 0 || now.Sub(throttle.WindowStartedAt) > p.Window {
+		return 0
+	}
+
+	if next := throttle.LastFailureAt.Add(p.Delay(throttle.Failures)); now.Before(next) {
+		return next.Sub(now)
+	}
+
+	return 0
+}
+
+// ShouldLock reports whether the number of failures reached the lockout threshold.
+func (p LoginThrottlePolicy) ShouldLock(failures int) bool {
+	return p.MaxFailures > 0 && failures >= p.MaxFailures
+}
+
+// LoginThrottleConfig holds the policies for accounts and source IPs. An IP sees the failures
+// across every account it tries, so it usually gets a higher threshold.
+type LoginThrottleConfig struct {
+	Account     LoginThrottlePolicy
+	IP          LoginThrottlePolicy
+	NotifyOwner bool
+}
+
+// NewLoginThrottleConfig builds the account and IP policies from the security configuration.
+func NewLoginThrottleConfig(cfg config.SecurityConfig) LoginThrottleConfig {
+	return LoginThrottleConfig{
+		Account: LoginThrottlePolicy{
+			MaxFailures:     cfg.LoginMaxFailures,
+			Window:          cfg.LoginFailureWindow,
+			LockoutDuration: cfg.LoginLockoutDuration,
+			BaseDelay:       cfg.LoginBackoffBase,
+			MaxDelay:        cfg.LoginBackoffMax,
+		},
+		IP: LoginThrottlePolicy{
+			MaxFailures:     cfg.LoginIPMaxFailures,
+			Window:          cfg.LoginFailureWindow,
+			LockoutDuration: cfg.LoginLockoutDuration,
+			BaseDelay:       cfg.LoginBackoffBase,
+			MaxDelay:        cfg.LoginBackoffMax,
+		},
+		NotifyOwner: cfg.LoginLockoutEmail,
+	}
+}
+
+// loginSubject is one of the keys a login attempt is counted against.
+type loginSubject struct {
+	subjectType string
+	subjectKey  string
+	policy      LoginThrottlePolicy
+}
+
+// loginSubjects returns the account and, when known, the source IP of a login attempt.
+func (s *authService) loginSubjects(ctx context.Context, email string) []loginSubject {
+	subjects := []loginSubject{{
+		subjectType: models.LoginThrottleAccount,
+		subjectKey:  strings.ToLower(strings.TrimSpace(email)),
+		policy:      s.loginThrottle.Account,
+	}}
+
+	if _, ipAddress := utils.ClientInfoFromContext(ctx); ipAddress != "" {
+		subjects = append(subjects, loginSubject{
+			subjectType: models.LoginThrottleIP,
+			subjectKey:  ipAddress,
+			policy:      s.loginThrottle.IP,
+		})
+	}
+
+	return subjects
+}
+
+// ipSubjects returns the source IP of an attempt that names no account yet, if it is known.
+func (s *authService) ipSubjects(ctx context.Context) []loginSubject {
+	var subjects []loginSubject
+	for _, subject := range s.loginSubjects(ctx, "") {
+		if subject.subjectType == models.LoginThrottleIP {
+			subjects = append(subjects, subject)
+		}
+	}
+	return subjects
+}
+
+// checkUserCredential runs a credential check of a signed in user, like the current password
+// before a password change, behind the login throttle. It is refused while the account or the
+// source IP is locked, and a failed check counts towards the lockout like a failed login.
+func (s *authService) checkUserCredential(ctx context.Context, userID string, check func() error) error {
+	user, err := s.userRepo.FindUserById(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	subjects := s.loginSubjects(ctx, user.Email)
+	if err := s.checkLoginThrottle(ctx, subjects); err != nil {
+		return err
+	}
+
+	if err := check(); err != nil {
+		s.recordLoginFailure(ctx, subjects, user)
+		return err
+	}
+
+	return nil
+}
+
+// checkLoginThrottle fails with a ThrottledError while any of the subjects has to wait.
+func (s *authService) checkLoginThrottle(ctx context.Context, subjects []loginSubject) error {
+	now := time.Now()
+
+	var retryAfter time.Duration
+	for _, subject := range subjects {
+		throttle, err := s.loginThrottleRepo.FindLoginThrottle(ctx, subject.subjectType, subject.subjectKey)
+		if err != nil {
+			return err
+		}
+
+		retryAfter = max(retryAfter, subject.policy.RetryAfter(throttle, now))
+	}
+
+	if retryAfter > 0 {
+		return &ThrottledError{RetryAfter: retryAfter}
+	}
+
+	return nil
+}
+
+// recordLoginFailure counts a failed login against every subject and locks the ones that reached
+// their threshold. user is nil when the email does not belong to an account.
+func (s *authService) recordLoginFailure(ctx context.Context, subjects []loginSubject, user *authpb.User) {
+	op := "authService.recordLoginFailure"
+
+	for _, subject := range subjects {
+		throttle, err := s.loginThrottleRepo.RecordLoginFailure(ctx, subject.subjectType, subject.subjectKey, subject.policy.Window)
+		if err != nil {
+			log.Printf("%s Error recording failure for %s %s: %v", op, subject.subjectType, subject.subjectKey, err)
+			continue
+		}
+
+		if !subject.policy.ShouldLock(throttle.Failures) {
+			continue
+		}
+
+		lockedUntil := time.Now().Add(subject.policy.LockoutDuration)
+		if err := s.loginThrottleRepo.LockLoginSubject(ctx, subject.subjectType, subject.subjectKey, lockedUntil); err != nil {
+			log.Printf("%s Error locking %s %s: %v", op, subject.subjectType, subject.subjectKey, err)
+			continue
+		}
+
+		targetUserID := ""
+		if subject.subjectType == models.LoginThrottleAccount && user != nil {
+			targetUserID = user.Id
+		}
+		s.recordAudit(ctx, models.AuditActionLoginLocked, models.AuditOutcomeSuccess, targetUserID, map[string]any{
+			"subject_type": subject.subjectType,
+			"subject":      subject.subjectKey,
+			"failures":     throttle.Failures,
+			"locked_until": lockedUntil.Format(time.RFC3339),
+		})
+
+		if subject.subjectType == models.LoginThrottleAccount && user != nil && s.loginThrottle.NotifyOwner {
+			s.sendMail("account_locked", user.Email, user.Locale, map[string]any{
+				"Name":             user.Name,
+				"Failures":         throttle.Failures,
+				"LockedForMinutes": int(subject.policy.LockoutDuration.Minutes()),
+			})
+		}
+	}
+}
+
+// resetLoginThrottle forgets the failed logins of an account after a successful login. The
+// source IP keeps its counter, a valid password for one account says nothing about the others.
+func (s *authService) resetLoginThrottle(ctx context.Context, subjects []loginSubject) {
+	for _, subject := range subjects {
+		if subject.subjectType != models.LoginThrottleAccount {
+			continue
+		}
+
+		if err := s.loginThrottleRepo.ResetLoginThrottle(ctx, subject.subjectType, subject.subjectKey); err != nil {
+			log.Printf("authService.resetLoginThrottle Error resetting %s: %v", subject.subjectKey, err)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/service"
+	"github.com/stretchr/testify/require"
+
+	authpb "github.com/Nucleussss/hikayat-proto/gen/go/auth/v1"
+)
+
+func TestLoginThrottlePolicy(t *testing.T) {
+	policy := service.LoginThrottlePolicy{
+		MaxFailures:     5,
+		Window:          15 * time.Minute,
+		LockoutDuration: 15 * time.Minute,
+		BaseDelay:       time.Second,
+		MaxDelay:        5 * time.Second,
+	}
+
+	// the delay doubles with every failure up to the cap
+	require.Equal(t, time.Duration(0), policy.Delay(0))
+	require.Equal(t, time.Second, policy.Delay(1))
+	require.Equal(t, 4*time.Second, policy.Delay(3))
+	require.Equal(t, 5*time.Second, policy.Delay(10))
+
+	now := time.Now()
+
+	// no failures, no wait
+	require.Zero(t, policy.RetryAfter(&models.LoginThrottle{}, now))
+
+	// third failure a second ago: four seconds of backoff, three left
+	throttle := &models.LoginThrottle{Failures: 3, WindowStartedAt: now.Add(-time.Minute), LastFailureAt: now.Add(-time.Second)}
+	require.Equal(t, 3*time.Second, policy.RetryAfter(throttle, now))
+
+	// failures outside the window are forgotten
+	throttle = &models.LoginThrottle{Failures: 3, WindowStartedAt: now.Add(-time.Hour), LastFailureAt: now.Add(-time.Second)}
+	require.Zero(t, policy.RetryAfter(throttle, now))
+
+	// a lock wins over everything else until it expires
+	lockedUntil := now.Add(10 * time.Minute)
+	throttle = &models.LoginThrottle{LockedUntil: &lockedUntil}
+	require.Equal(t, 10*time.Minute, policy.RetryAfter(throttle, now))
+	require.Zero(t, policy.RetryAfter(throttle, lockedUntil.Add(time.Second)))
+
+	require.False(t, policy.ShouldLock(4))
+	require.True(t, policy.ShouldLock(5))
+}
+
+func TestLoginLockoutAndUnlock(t *testing.T) {
+	ctx := context.Background()
+	f := newAuthFixture(t)
+	user := f.addUser(t, "locked@example.com", "correct horse")
+
+	for i := 0; i < 5; i++ {
+		_, err := f.service.Login(ctx, &authpb.LoginRequest{Email: user.Email, Password: "wrong"})
+		require.Error(t, err)
+	}
+
+	// the locked account is refused even with the right password
+	var throttled *service.ThrottledError
+	_, err := f.service.Login(ctx, &authpb.LoginRequest{Email: user.Email, Password: "correct horse"})
+	require.ErrorAs(t, err, &throttled)
+	require.Greater(t, throttled.RetryAfter, 50*time.Minute)
+
+	require.NoError(t, f.service.UnlockAccount(ctx, &authpb.UnlockAccountRequest{UserId: user.Id}))
+
+	res, err := f.service.Login(ctx, &authpb.LoginRequest{Email: user.Email, Password: "correct horse"})
+	require.NoError(t, err)
+	require.NotEmpty(t, res.Token)
+}
+
+func TestPasswordRecheckCountsTowardsLockout(t *testing.T) {
+	ctx := context.Background()
+	f := newAuthFixture(t)
+	user := f.addUser(t, "recheck@example.com", "correct horse")
+
+	// a stolen access token cannot be used to guess the password through the re-checks
+	for i := 0; i < 5; i++ {
+		err := f.service.ChangeUserPassword(ctx, &authpb.ChangeUserPasswordRequest{Id: user.Id, Currentpassword: "wrong", Newpassword: "new password"})
+		require.Error(t, err)
+	}
+
+	var throttled *service.ThrottledError
+	err := f.service.ChangeUserPassword(ctx, &authpb.ChangeUserPasswordRequest{Id: user.Id, Currentpassword: "correct horse", Newpassword: "new password"})
+	require.ErrorAs(t, err, &throttled)
+
+	_, err = f.service.Login(ctx, &authpb.LoginRequest{Email: user.Email, Password: "correct horse"})
+	require.ErrorAs(t, err, &throttled)
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed templates/mail/*
+var defaultMailTemplates embed.FS
+
+// DefaultMailTemplatesFS returns the mail templates shipped with the service.
+func DefaultMailTemplatesFS() fs.FS {
+	sub, _ := fs.Sub(defaultMailTemplates, "templates/mail")
+	return sub
+}
+
+// mailTemplate is one locale variant of a message. The HTML body is optional.
+type mailTemplate struct {
+	subject *texttemplate.Template
+	text    *texttemplate.Template
+	html    *htmltemplate.Template
+}
+
+// MailTemplates renders email messages from template files with per-locale variants.
+// Files are named <name>.<locale>.subject.txt, <name>.<locale>.txt and <name>.<locale>.html.
+type MailTemplates struct {
+	defaultLocale string
+	templates     map[string]map[string]*mailTemplate
+}
+
+// NewMailTemplates parses every template in fsys. Messages requested in a locale without
+// a variant fall back to defaultLocale.
+func NewMailTemplates(fsys fs.FS, defaultLocale string) (*MailTemplates, error) {
+	t := &MailTemplates{
+		defaultLocale: defaultLocale,
+		templates:     make(map[string]map[string]*mailTemplate),
+	}
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mail templates: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		// split <name>.<locale>.<part>
+		fileName := entry.Name()
+		parts := strings.SplitN(fileName, ".", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid mail template file name: %s", fileName)
+		}
+		name, locale, part := parts[0], parts[1], parts[2]
+
+		content, err := fs.ReadFile(fsys, fileName)
+		if err != nil {
+			return nil, err
+		}
+
+		variant := t.variant(name, locale)
+		switch part {
+		case "subject.txt":
+			variant.subject, err = texttemplate.New(fileName).Parse(string(content))
+		case "txt":
+			variant.text, err = texttemplate.New(fileName).Parse(string(content))
+		case "html":
+			variant.html, err = htmltemplate.New(fileName).Parse(string(content))
+		default:
+			err = fmt.Errorf("unknown template part %q", part)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse mail template %s: %w", fileName, err)
+		}
+	}
+
+	for name, variants := range t.templates {
+		for locale, variant := range variants {
+			if variant.subject == nil || variant.text == nil {
+				return nil, fmt.Errorf("mail template %s.%s needs a subject and a text body", name, locale)
+			}
+		}
+		if _, ok := variants[defaultLocale]; !ok {
+			return nil, fmt.Errorf("mail template %s has no variant for default locale %s", name, defaultLocale)
+		}
+	}
+
+	return t, nil
+}
+
+func (t *MailTemplates) variant(name, locale string) *mailTemplate {
+	if t.templates[name] == nil {
+		t.templates[name] = make(map[string]*mailTemplate)
+	}
+	if t.templates[name][locale] == nil {
+		t.templates[name][locale] = &mailTemplate{}
+	}
+	return t.templates[name][locale]
+}
+
+// Render builds the message called name for the recipient in the given locale.
+func (t *MailTemplates) Render(name, locale, to string, data any) (*MailMessage, error) {
+	variants, ok := t.templates[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown mail template: %s", name)
+	}
+
+	variant, ok := variants[locale]
+	if !ok {
+		variant = variants[t.defaultLocale]
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := variant.subject.Execute(&subject, data); err != nil {
+		return nil, fmt.Errorf("failed to render subject of %s: %w", name, err)
+	}
+	if err := variant.text.Execute(&text, data); err != nil {
+		return nil, fmt.Errorf("failed to render text body of %s: %w", name, err)
+	}
+	if variant.html != nil {
+		if err := variant.html.Execute(&html, data); err != nil {
+			return nil, fmt.Errorf("failed to render html body of %s: %w", name, err)
+		}
+	}
+
+	return &MailMessage{
+		To:       to,
+		Subject:  strings.TrimSpace(subject.String()),
+		TextBody: text.String(),
+		HTMLBody: html.String(),
+	}, nil
+}
@@ -0,0 +1,16 @@
+package service
+
+import "context"
+
+// MailMessage is a rendered email ready to be handed to a Mailer transport.
+type MailMessage struct {
+	To       string
+	Subject  string
+	TextBody string
+	HTMLBody string
+}
+
+// Mailer delivers rendered email messages.
+type Mailer interface {
+	Send(ctx context.Context, msg *MailMessage) error
+}
@@ -0,0 +1,341 @@
+package service
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/auditlog"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/jwtkeys"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/repository"
+	"github.com/Nucleussss/hikayat-forum/auth/pkg/config"
+)
+
+// MaintenanceConfig is the retention the maintenance job enforces. A retention of 0 keeps the
+// rows of that table forever. In DryRun mode nothing is exported or deleted, the job only reports
+// what it would do.
+type MaintenanceConfig struct {
+	Interval               time.Duration
+	BatchSize              int
+	AuditRetention         time.Duration
+	ArchiveDir             string
+	SessionRetention       time.Duration
+	PasswordResetRetention time.Duration
+	DryRun                 bool
+}
+
+// NewMaintenanceConfig builds the maintenance configuration from the service configuration.
+func NewMaintenanceConfig(cfg config.MaintenanceConfig, dryRun bool) MaintenanceConfig {
+	return MaintenanceConfig{
+		Interval:               cfg.Interval,
+		BatchSize:              cfg.BatchSize,
+		AuditRetention:         cfg.AuditRetention,
+		ArchiveDir:             cfg.ArchiveDir,
+		SessionRetention:       cfg.SessionRetention,
+		PasswordResetRetention: cfg.PasswordResetRetention,
+		DryRun:                 dryRun,
+	}
+}
+
+// MaintenanceReport counts the rows a run removed, or would remove in a dry run. Skipped is set
+// when another replica held the maintenance lock.
+type MaintenanceReport struct {
+	Skipped        bool
+	DryRun         bool
+	AuditArchives  int
+	AuditEvents    int64
+	Sessions       int64
+	PasswordResets int64
+	MFAChallenges  int64
+	Ceremonies     int64
+}
+
+// Maintenance removes rows that outlived their retention. Old audit events are exported to gzipped
+// JSON lines files before they are deleted, oldest first, and a checkpoint signed with checkpointKey
+// at the last deleted event anchors what remains of the hash chain, so it stays verifiable. Expired
+// mfa challenges and passkey ceremonies are of no use to anyone and are always deleted. A run holds
+// a Postgres advisory lock, so replicas never run it concurrently.
+type Maintenance struct {
+	locks          repository.MaintenanceRepository
+	audit          repository.AuditRepository
+	sessions       repository.SessionRepository
+	passwordResets repository.PasswordResetRepository
+	mfa            repository.MFARepository
+	passkeys       repository.PasskeyRepository
+	checkpointKey  *jwtkeys.Key
+	config         MaintenanceConfig
+}
+
+// NewMaintenance creates the job. checkpointKey may be nil as long as no chained audit events are
+// pruned.
+func NewMaintenance(locks repository.MaintenanceRepository, audit repository.AuditRepository, sessions repository.SessionRepository, passwordResets repository.PasswordResetRepository, mfa repository.MFARepository, passkeys repository.PasskeyRepository, checkpointKey *jwtkeys.Key, config MaintenanceConfig) *Maintenance {
+	return &Maintenance{
+		locks:          locks,
+		audit:          audit,
+		sessions:       sessions,
+		passwordResets: passwordResets,
+		mfa:            mfa,
+		passkeys:       passkeys,
+		checkpointKey:  checkpointKey,
+		config:         config,
+	}
+}
+
+// Start runs the job every interval until ctx is done.
+func (m *Maintenance) Start(ctx context.Context) {
+	go m.run(ctx)
+}
+
+func (m *Maintenance) run(ctx context.Context) {
+	op := "maintenance.run"
+
+	ticker := time.NewTicker(m.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			report, err := m.Run(ctx)
+			if err != nil {
+				log.Printf("%s Error running maintenance: %v", op, err)
+				continue
+			}
+			LogMaintenanceReport(report)
+		}
+	}
+}
+
+// Run makes one maintenance pass. It returns right away with a skipped report when another
+// replica is already running one.
+func (m *Maintenance) Run(ctx context.Context) (*MaintenanceReport, error) {
+	report := &MaintenanceReport{DryRun: m.config.DryRun}
+
+	release, acquired, err := m.locks.TryMaintenanceLock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !acquired {
+		report.Skipped = true
+		return report, nil
+	}
+	defer release()
+
+	now := time.Now()
+
+	if m.config.AuditRetention > 0 {
+		if err := m.pruneAudit(ctx, now.Add(-m.config.AuditRetention), report); err != nil {
+			return report, err
+		}
+	}
+
+	if m.config.SessionRetention > 0 {
+		cutoff := now.Add(-m.config.SessionRetention)
+		if m.config.DryRun {
+			report.Sessions, err = m.sessions.CountPurgeableSessions(ctx, cutoff)
+		} else {
+			report.Sessions, err = deleteInBatches(ctx, m.config.BatchSize, func(limit int) (int64, error) {
+				return m.sessions.PurgeSessions(ctx, cutoff, limit)
+			})
+		}
+		if err != nil {
+			return report, err
+		}
+	}
+
+	if m.config.PasswordResetRetention > 0 {
+		cutoff := now.Add(-m.config.PasswordResetRetention)
+		if m.config.DryRun {
+			report.PasswordResets, err = m.passwordResets.CountExpiredPasswordResets(ctx, cutoff)
+		} else {
+			report.PasswordResets, err = deleteInBatches(ctx, m.config.BatchSize, func(limit int) (int64, error) {
+				return m.passwordResets.DeleteExpiredPasswordResets(ctx, cutoff, limit)
+			})
+		}
+		if err != nil {
+			return report, err
+		}
+	}
+
+	if m.config.DryRun {
+		report.MFAChallenges, err = m.mfa.CountExpiredMFAChallenges(ctx, now)
+	} else {
+		report.MFAChallenges, err = deleteInBatches(ctx, m.config.BatchSize, func(limit int) (int64, error) {
+			return m.mfa.DeleteExpiredMFAChallenges(ctx, now, limit)
+		})
+	}
+	if err != nil {
+		return report, err
+	}
+
+	if m.config.DryRun {
+		report.Ceremonies, err = m.passkeys.CountExpiredCeremonies(ctx, now)
+	} else {
+		report.Ceremonies, err = deleteInBatches(ctx, m.config.BatchSize, func(limit int) (int64, error) {
+			return m.passkeys.DeleteExpiredCeremonies(ctx, now, limit)
+		})
+	}
+	if err != nil {
+		return report, err
+	}
+
+	return report, nil
+}
+
+// deleteInBatches calls deleteBatch until a batch comes back short, and returns the total deleted.
+func deleteInBatches(ctx context.Context, batchSize int, deleteBatch func(limit int) (int64, error)) (int64, error) {
+	var total int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+
+		deleted, err := deleteBatch(batchSize)
+		total += deleted
+		if err != nil {
+			return total, err
+		}
+		if deleted < int64(batchSize) {
+			return total, nil
+		}
+	}
+}
+
+// pruneAudit archives and deletes the events created before the cutoff, oldest first. It stops
+// at the first event that is still within the retention, so only a prefix of the chain is ever
+// deleted. Each batch is written to its own archive file, which is synced before the events are
+// deleted together with a checkpoint signed at the new anchor.
+func (m *Maintenance) pruneAudit(ctx context.Context, cutoff time.Time, report *MaintenanceReport) error {
+	if m.config.DryRun {
+		count, err := m.audit.CountAuditEventsBefore(ctx, cutoff)
+		report.AuditEvents = count
+		return err
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		events, err := m.audit.ListOldestAuditEvents(ctx, m.config.BatchSize)
+		if err != nil {
+			return err
+		}
+
+		expired := events
+		for i, event := range events {
+			if !event.CreatedAt.Before(cutoff) {
+				expired = events[:i]
+				break
+			}
+		}
+		if len(expired) == 0 {
+			return nil
+		}
+
+		checkpoint, err := m.signAuditAnchor(expired)
+		if err != nil {
+			return err
+		}
+
+		if err := m.archiveAudit(expired); err != nil {
+			return err
+		}
+		report.AuditArchives++
+
+		if err := m.audit.DeleteAuditEvents(ctx, expired, checkpoint); err != nil {
+			return err
+		}
+		report.AuditEvents += int64(len(expired))
+
+		if len(expired) < len(events) || len(events) < m.config.BatchSize {
+			return nil
+		}
+	}
+}
+
+// signAuditAnchor signs a checkpoint at the last chained event of the batch, which becomes the
+// anchor of the chain once the batch is deleted. It returns nil for a batch of unchained events.
+func (m *Maintenance) signAuditAnchor(events []*models.AuditEvent) (*models.AuditCheckpoint, error) {
+	var anchor *models.AuditEvent
+	for _, event := range events {
+		if event.Seq > 0 && (anchor == nil || event.Seq > anchor.Seq) {
+			anchor = event
+		}
+	}
+	if anchor == nil {
+		return nil, nil
+	}
+
+	if m.checkpointKey == nil {
+		return nil, fmt.Errorf("pruning the audit chain needs a checkpoint key to sign its new anchor")
+	}
+
+	return auditlog.SignCheckpoint(m.checkpointKey, anchor.Seq, anchor.Hash, time.Now())
+}
+
+// archiveAudit writes the events to a new gzipped JSON lines file in the archive directory. The
+// file only appears under its final name once it is complete and synced.
+func (m *Maintenance) archiveAudit(events []*models.AuditEvent) error {
+	if err := os.MkdirAll(m.config.ArchiveDir, 0o750); err != nil {
+		return fmt.Errorf("failed to create audit archive directory: %w", err)
+	}
+
+	first := events[0]
+	name := fmt.Sprintf("audit_logs-%s-%s.jsonl.gz", first.CreatedAt.UTC().Format("20060102T150405Z"), first.ID)
+	if first.Seq > 0 {
+		name = fmt.Sprintf("audit_logs-seq%012d-%012d.jsonl.gz", first.Seq, events[len(events)-1].Seq)
+	}
+
+	file, err := os.CreateTemp(m.config.ArchiveDir, ".audit_logs-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create audit archive: %w", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	compressed := gzip.NewWriter(file)
+	if err := auditlog.WriteArchive(compressed, events); err != nil {
+		return err
+	}
+	if err := compressed.Close(); err != nil {
+		return fmt.Errorf("failed to write audit archive: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to write audit archive: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write audit archive: %w", err)
+	}
+
+	if err := os.Rename(file.Name(), filepath.Join(m.config.ArchiveDir, name)); err != nil {
+		return fmt.Errorf("failed to store audit archive: %w", err)
+	}
+
+	return nil
+}
+
+// LogMaintenanceReport writes the outcome of a run to the service log.
+func LogMaintenanceReport(report *MaintenanceReport) {
+	op := "maintenance.Run"
+
+	if report.Skipped {
+		log.Printf("%s Skipped, another replica holds the maintenance lock", op)
+		return
+	}
+
+	if report.DryRun {
+		log.Printf("%s Dry run, would delete audit events=%d sessions=%d password resets=%d mfa challenges=%d passkey ceremonies=%d",
+			op, report.AuditEvents, report.Sessions, report.PasswordResets, report.MFAChallenges, report.Ceremonies)
+		return
+	}
+
+	log.Printf("%s Archived and deleted audit events=%d in %d files, deleted sessions=%d password resets=%d mfa challenges=%d passkey ceremonies=%d",
+		op, report.AuditEvents, report.AuditArchives, report.Sessions, report.PasswordResets, report.MFAChallenges, report.Ceremonies)
+}
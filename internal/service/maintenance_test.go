@@ -0,0 +1,235 @@
+package service_test
+
+import (
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/auditlog"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/jwtkeys"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/repository"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/service"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeMaintenanceRepo struct {
+	held bool
+}
+
+func (r *fakeMaintenanceRepo) TryMaintenanceLock(ctx context.Context) (func(), bool, error) {
+	if r.held {
+		return nil, false, nil
+	}
+	r.held = true
+	return func() { r.held = false }, true, nil
+}
+
+// fakePurgeRepo stands in for the session, password reset, mfa and passkey repositories, holding
+// a number of rows past their retention.
+type fakePurgeRepo struct {
+	repository.SessionRepository
+	repository.PasswordResetRepository
+	repository.MFARepository
+	repository.PasskeyRepository
+	expired int64
+}
+
+func (r *fakePurgeRepo) purge(limit int) int64 {
+	deleted := min(r.expired, int64(limit))
+	r.expired -= deleted
+	return deleted
+}
+
+func (r *fakePurgeRepo) CountPurgeableSessions(ctx context.Context, cutoff time.Time) (int64, error) {
+	return r.expired, nil
+}
+
+func (r *fakePurgeRepo) PurgeSessions(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	return r.purge(limit), nil
+}
+
+func (r *fakePurgeRepo) CountExpiredPasswordResets(ctx context.Context, cutoff time.Time) (int64, error) {
+	return r.expired, nil
+}
+
+func (r *fakePurgeRepo) DeleteExpiredPasswordResets(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	return r.purge(limit), nil
+}
+
+func (r *fakePurgeRepo) CountExpiredMFAChallenges(ctx context.Context, cutoff time.Time) (int64, error) {
+	return r.expired, nil
+}
+
+func (r *fakePurgeRepo) DeleteExpiredMFAChallenges(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	return r.purge(limit), nil
+}
+
+func (r *fakePurgeRepo) CountExpiredCeremonies(ctx context.Context, cutoff time.Time) (int64, error) {
+	return r.expired, nil
+}
+
+func (r *fakePurgeRepo) DeleteExpiredCeremonies(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	return r.purge(limit), nil
+}
+
+func newMaintenanceFixture(t *testing.T, dryRun bool, checkpointKey *jwtkeys.Key) (*service.Maintenance, *fakeAuditRepo, *fakePurgeRepo, *fakePurgeRepo, service.MaintenanceConfig) {
+	t.Helper()
+
+	// seven events a month old and three recent ones
+	audit := &fakeAuditRepo{}
+	for i := 0; i < 10; i++ {
+		age := time.Hour
+		if i < 7 {
+			age = 30 * 24 * time.Hour
+		}
+		audit.written = append(audit.written, &models.AuditEvent{
+			ID:        uuid.New(),
+			Action:    models.AuditActionLogin,
+			Outcome:   models.AuditOutcomeSuccess,
+			CreatedAt: time.Now().Add(-age).UTC().Truncate(time.Microsecond),
+		})
+	}
+	require.NoError(t, auditlog.Link(0, auditlog.GenesisHash, audit.written))
+
+	sessions := &fakePurgeRepo{expired: 25}
+	resets := &fakePurgeRepo{expired: 3}
+
+	config := service.MaintenanceConfig{
+		Interval:               time.Hour,
+		BatchSize:              5,
+		AuditRetention:         7 * 24 * time.Hour,
+		ArchiveDir:             t.TempDir(),
+		SessionRetention:       24 * time.Hour,
+		PasswordResetRetention: time.Hour,
+		DryRun:                 dryRun,
+	}
+
+	challenges := &fakePurgeRepo{expired: 2}
+	ceremonies := &fakePurgeRepo{expired: 12}
+
+	return service.NewMaintenance(&fakeMaintenanceRepo{}, audit, sessions, resets, challenges, ceremonies, checkpointKey, config), audit, sessions, resets, config
+}
+
+// readAuditArchives reads the events of every archive in dir.
+func readAuditArchives(t *testing.T, dir string) []*models.AuditEvent {
+	t.Helper()
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.jsonl.gz"))
+	require.NoError(t, err)
+
+	var archived []*models.AuditEvent
+	for _, name := range files {
+		file, err := os.Open(name)
+		require.NoError(t, err)
+		compressed, err := gzip.NewReader(file)
+		require.NoError(t, err)
+		batch, err := auditlog.ReadArchive(compressed)
+		require.NoError(t, err)
+		require.NoError(t, file.Close())
+		archived = append(archived, batch...)
+	}
+
+	return archived
+}
+
+func TestMaintenanceArchivesAndPrunes(t *testing.T) {
+	key, err := jwtkeys.GenerateKey(jwtkeys.AlgorithmEdDSA)
+	require.NoError(t, err)
+	keys := map[string]*jwtkeys.Key{key.ID: key}
+
+	maintenance, audit, sessions, resets, config := newMaintenanceFixture(t, false, key)
+	events := append([]*models.AuditEvent(nil), audit.written...)
+
+	report, err := maintenance.Run(context.Background())
+	require.NoError(t, err)
+	require.False(t, report.Skipped)
+	require.EqualValues(t, 7, report.AuditEvents)
+	require.Equal(t, 2, report.AuditArchives)
+	require.EqualValues(t, 25, report.Sessions)
+	require.EqualValues(t, 3, report.PasswordResets)
+	require.EqualValues(t, 2, report.MFAChallenges)
+	require.EqualValues(t, 12, report.Ceremonies)
+
+	// only the recent events are left, and nothing expired remains
+	require.Equal(t, 3, audit.count())
+	require.Equal(t, events[7].ID, audit.written[0].ID)
+	require.Zero(t, sessions.expired)
+	require.Zero(t, resets.expired)
+
+	// the archives hold the pruned events, chain fields included
+	archived := readAuditArchives(t, config.ArchiveDir)
+	require.Len(t, archived, 7)
+
+	verifier := auditlog.NewVerifier()
+	for _, event := range archived {
+		require.Nil(t, verifier.Check(event))
+	}
+	seq, hash := verifier.Head()
+	require.EqualValues(t, 7, seq)
+	require.Equal(t, events[6].Hash, hash)
+
+	// each batch left a signed checkpoint at the anchor it moved to
+	require.Len(t, audit.checkpoints, 2)
+	require.EqualValues(t, 5, audit.checkpoints[0].Seq)
+	require.EqualValues(t, 7, audit.checkpoints[1].Seq)
+
+	verified, err := auditlog.VerifyChain(context.Background(), audit, audit.checkpoints, keys)
+	require.NoError(t, err)
+	require.Nil(t, verified.Broken)
+	require.EqualValues(t, 7, verified.StartSeq)
+
+	verified, err = auditlog.VerifyChain(context.Background(), auditlog.NewArchivedChain(audit, archived), audit.checkpoints, keys)
+	require.NoError(t, err)
+	require.Nil(t, verified.Broken)
+	require.EqualValues(t, 10, verified.Events)
+	require.Equal(t, 2, verified.Checkpoints)
+}
+
+func TestMaintenanceNeedsCheckpointKeyToPruneTheChain(t *testing.T) {
+	maintenance, audit, _, _, config := newMaintenanceFixture(t, false, nil)
+
+	_, err := maintenance.Run(context.Background())
+	require.Error(t, err)
+
+	// nothing was archived or deleted
+	require.Equal(t, 10, audit.count())
+	require.Empty(t, readAuditArchives(t, config.ArchiveDir))
+}
+
+func TestMaintenanceDryRunDeletesNothing(t *testing.T) {
+	maintenance, audit, sessions, resets, config := newMaintenanceFixture(t, true, nil)
+
+	report, err := maintenance.Run(context.Background())
+	require.NoError(t, err)
+	require.True(t, report.DryRun)
+	require.EqualValues(t, 7, report.AuditEvents)
+	require.EqualValues(t, 25, report.Sessions)
+	require.EqualValues(t, 3, report.PasswordResets)
+	require.EqualValues(t, 2, report.MFAChallenges)
+	require.EqualValues(t, 12, report.Ceremonies)
+
+	require.Equal(t, 10, audit.count())
+	require.EqualValues(t, 25, sessions.expired)
+	require.EqualValues(t, 3, resets.expired)
+
+	files, err := os.ReadDir(config.ArchiveDir)
+	require.NoError(t, err)
+	require.Empty(t, files)
+}
+
+func TestMaintenanceSkipsWhenLocked(t *testing.T) {
+	audit := &fakeAuditRepo{}
+	sessions := &fakePurgeRepo{expired: 4}
+	locks := &fakeMaintenanceRepo{held: true}
+	config := service.MaintenanceConfig{Interval: time.Hour, BatchSize: 10, SessionRetention: time.Hour}
+
+	report, err := service.NewMaintenance(locks, audit, sessions, sessions, sessions, sessions, nil, config).Run(context.Background())
+	require.NoError(t, err)
+	require.True(t, report.Skipped)
+	require.EqualValues(t, 4, sessions.expired)
+}
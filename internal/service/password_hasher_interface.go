@@ -0,0 +1,9 @@
+package service
+
+// PasswordHasher hashes passwords for storage and checks passwords against stored hashes.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	// Verify reports whether the password matches the encoded hash, and whether the hash should
+	// be replaced because it was made with an outdated algorithm, cost or pepper.
+	Verify(encoded, password string) (match bool, needsRehash bool, err error)
+}
@@ -0,0 +1,238 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/repository"
+)
+
+// RevocationCheck carries the token attributes a RevocationStore decides on.
+type RevocationCheck struct {
+	TokenID   string
+	SessionID string
+	UserID    string
+	IssuedAt  time.Time
+}
+
+// RevocationStore tracks access tokens that must be rejected before they expire.
+type RevocationStore interface {
+	RevokeToken(ctx context.Context, tokenID string, expiresAt time.Time) error
+	RevokeSession(ctx context.Context, sessionID string) error
+	RevokeUser(ctx context.Context, userID string) error
+	SuspendUser(ctx context.Context, userID string) error
+	IsRevoked(ctx context.Context, check RevocationCheck) (bool, error)
+	IsSuspended(ctx context.Context, check RevocationCheck) (bool, error)
+}
+
+// cachedRevocationStore persists revocations through the repository and answers lookups from
+// memory. The cache is reloaded periodically so revocations made by other replicas are picked up.
+type cachedRevocationStore struct {
+	repo           repository.RevocationRepository
+	accessTokenTTL time.Duration
+
+	mu          sync.RWMutex
+	tokens      map[string]time.Time
+	sessions    map[string]time.Time
+	users       map[string]*models.TokenRevocation
+	suspensions map[string]*models.TokenRevocation
+}
+
+// NewRevocationStore loads the active revocations and keeps them in sync with the database
+// every refreshInterval until ctx is cancelled. Session and user revocations are kept for
+// accessTokenTTL, after which every token they cover has expired.
+func NewRevocationStore(ctx context.Context, repo repository.RevocationRepository, accessTokenTTL, refreshInterval time.Duration) (RevocationStore, error) {
+	store := &cachedRevocationStore{
+		repo:           repo,
+		accessTokenTTL: accessTokenTTL,
+		tokens:         make(map[string]time.Time),
+		sessions:       make(map[string]time.Time),
+		users:          make(map[string]*models.TokenRevocation),
+		suspensions:    make(map[string]*models.TokenRevocation),
+	}
+
+	if err := store.reload(ctx); err != nil {
+		return nil, err
+	}
+
+	go store.run(ctx, refreshInterval)
+
+	return store, nil
+}
+
+func (s *cachedRevocationStore) run(ctx context.Context, refreshInterval time.Duration) {
+	op := "revocationStore.run"
+
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.reload(ctx); err != nil {
+				log.Printf("%s Error reloading revocations: %v", op, err)
+			}
+		}
+	}
+}
+
+// reload merges the revocations stored in the database into the cache and drops expired entries.
+// Entries are merged rather than replaced so a revocation made while the query runs is not lost.
+func (s *cachedRevocationStore) reload(ctx context.Context) error {
+	revocations, err := s.repo.ListActiveRevocations(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for key, expiresAt := range s.tokens {
+		if now.After(expiresAt) {
+			delete(s.tokens, key)
+		}
+	}
+	for key, expiresAt := range s.sessions {
+		if now.After(expiresAt) {
+			delete(s.sessions, key)
+		}
+	}
+	for key, revocation := range s.users {
+		if now.After(revocation.ExpiresAt) {
+			delete(s.users, key)
+		}
+	}
+	for key, revocation := range s.suspensions {
+		if now.After(revocation.ExpiresAt) {
+			delete(s.suspensions, key)
+		}
+	}
+
+	for _, revocation := range revocations {
+		s.add(revocation)
+	}
+
+	return nil
+}
+
+// add stores a revocation in the cache. The caller must hold the write lock.
+func (s *cachedRevocationStore) add(revocation *models.TokenRevocation) {
+	switch revocation.SubjectType {
+	case models.RevocationSubjectToken:
+		s.tokens[revocation.SubjectID] = revocation.ExpiresAt
+	case models.RevocationSubjectSession:
+		s.sessions[revocation.SubjectID] = revocation.ExpiresAt
+	case models.RevocationSubjectUser:
+		if current, ok := s.users[revocation.SubjectID]; ok && current.RevokedAt.After(revocation.RevokedAt) {
+			return
+		}
+		s.users[revocation.SubjectID] = revocation
+	case models.RevocationSubjectSuspension:
+		if current, ok := s.suspensions[revocation.SubjectID]; ok && current.RevokedAt.After(revocation.RevokedAt) {
+			return
+		}
+		s.suspensions[revocation.SubjectID] = revocation
+	}
+}
+
+func (s *cachedRevocationStore) revoke(ctx context.Context, revocation *models.TokenRevocation) error {
+	if err := s.repo.Revoke(ctx, revocation); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.add(revocation)
+	s.mu.Unlock()
+
+	return nil
+}
+
+// RevokeToken revokes a single access token until it expires.
+func (s *cachedRevocationStore) RevokeToken(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	return s.revoke(ctx, &models.TokenRevocation{
+		SubjectType: models.RevocationSubjectToken,
+		SubjectID:   tokenID,
+		ExpiresAt:   expiresAt,
+	})
+}
+
+// RevokeSession revokes every access token bound to a session family. Access tokens never
+// outlive the access token lifetime, so neither does the revocation.
+func (s *cachedRevocationStore) RevokeSession(ctx context.Context, sessionID string) error {
+	return s.revoke(ctx, &models.TokenRevocation{
+		SubjectType: models.RevocationSubjectSession,
+		SubjectID:   sessionID,
+		ExpiresAt:   time.Now().Add(s.accessTokenTTL),
+	})
+}
+
+// RevokeUser revokes every access token of a user issued up to now.
+func (s *cachedRevocationStore) RevokeUser(ctx context.Context, userID string) error {
+	return s.revoke(ctx, &models.TokenRevocation{
+		SubjectType: models.RevocationSubjectUser,
+		SubjectID:   userID,
+		ExpiresAt:   time.Now().Add(s.accessTokenTTL),
+	})
+}
+
+// SuspendUser revokes every access token of a suspended user issued up to now. Suspended users
+// cannot get new tokens, so the revocation only has to outlive the tokens issued before it.
+func (s *cachedRevocationStore) SuspendUser(ctx context.Context, userID string) error {
+	return s.revoke(ctx, &models.TokenRevocation{
+		SubjectType: models.RevocationSubjectSuspension,
+		SubjectID:   userID,
+		ExpiresAt:   time.Now().Add(s.accessTokenTTL),
+	})
+}
+
+// IsRevoked reports whether a token was revoked directly, through its session or through its
+// user, a suspension of the user included.
+func (s *cachedRevocationStore) IsRevoked(ctx context.Context, check RevocationCheck) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.tokens[check.TokenID]; ok && check.TokenID != "" {
+		return true, nil
+	}
+
+	if _, ok := s.sessions[check.SessionID]; ok && check.SessionID != "" {
+		return true, nil
+	}
+
+	if revocation, ok := s.users[check.UserID]; ok && revokedBefore(revocation, check.IssuedAt) {
+		return true, nil
+	}
+
+	return s.isSuspended(check), nil
+}
+
+// IsSuspended reports whether a token was revoked because its user was suspended.
+func (s *cachedRevocationStore) IsSuspended(ctx context.Context, check RevocationCheck) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.isSuspended(check), nil
+}
+
+// isSuspended checks the suspensions. The caller must hold the read lock. Suspended users cannot
+// get new tokens, so unlike the user revocations no token issued up to the suspension is spared,
+// the ones with an iat in the suspension's own second included.
+func (s *cachedRevocationStore) isSuspended(check RevocationCheck) bool {
+	revocation, ok := s.suspensions[check.UserID]
+	return ok && !check.IssuedAt.After(revocation.RevokedAt)
+}
+
+// revokedBefore reports whether a token issued at issuedAt is covered by the revocation. Token
+// issue times have whole-second precision, so the cutoff is the revocation time rounded up to the
+// next second: a token minted in the same second, just before the revocation, is covered too. A
+// login within that second has to be repeated, which beats leaving a token valid for its lifetime.
+func revokedBefore(revocation *models.TokenRevocation, issuedAt time.Time) bool {
+	cutoff := revocation.RevokedAt.Truncate(time.Second).Add(time.Second)
+	return issuedAt.Before(cutoff)
+}
@@ -0,0 +1,113 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/Nucleussss/hikayat-forum/auth/internal/service"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeRevocationRepo struct {
+	stored []*models.TokenRevocation
+}
+
+func (r *fakeRevocationRepo) Revoke(ctx context.Context, revocation *models.TokenRevocation) error {
+	revocation.RevokedAt = time.Now()
+	r.stored = append(r.stored, revocation)
+	return nil
+}
+
+func (r *fakeRevocationRepo) ListActiveRevocations(ctx context.Context) ([]*models.TokenRevocation, error) {
+	return r.stored, nil
+}
+
+func TestRevocationStore(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	repo := &fakeRevocationRepo{}
+	store, err := service.NewRevocationStore(ctx, repo, 15*time.Minute, time.Hour)
+	require.NoError(t, err)
+
+	issuedAt := time.Now().Add(-time.Minute)
+
+	require.NoError(t, store.RevokeToken(ctx, "jti-1", time.Now().Add(time.Minute)))
+	revoked, err := store.IsRevoked(ctx, service.RevocationCheck{TokenID: "jti-1", IssuedAt: issuedAt})
+	require.NoError(t, err)
+	require.True(t, revoked)
+
+	require.NoError(t, store.RevokeSession(ctx, "session-1"))
+	revoked, err = store.IsRevoked(ctx, service.RevocationCheck{TokenID: "jti-2", SessionID: "session-1", IssuedAt: issuedAt})
+	require.NoError(t, err)
+	require.True(t, revoked)
+
+	mintedAt := time.Now()
+	require.NoError(t, store.RevokeUser(ctx, "user-1"))
+	revoked, err = store.IsRevoked(ctx, service.RevocationCheck{TokenID: "jti-3", UserID: "user-1", IssuedAt: issuedAt})
+	require.NoError(t, err)
+	require.True(t, revoked)
+
+	// tokens issued after the user revocation stay valid
+	revoked, err = store.IsRevoked(ctx, service.RevocationCheck{TokenID: "jti-4", UserID: "user-1", IssuedAt: time.Now().Add(time.Second)})
+	require.NoError(t, err)
+	require.False(t, revoked)
+
+	// a token minted just before the revocation, in the same second, is revoked too even though
+	// iat cannot tell it apart from one minted just after
+	revoked, err = store.IsRevoked(ctx, service.RevocationCheck{TokenID: "jti-4", UserID: "user-1", IssuedAt: mintedAt.Truncate(time.Second)})
+	require.NoError(t, err)
+	require.True(t, revoked)
+
+	// a second replica picks up the revocations from the repository
+	replica, err := service.NewRevocationStore(ctx, repo, 15*time.Minute, time.Hour)
+	require.NoError(t, err)
+	revoked, err = replica.IsRevoked(ctx, service.RevocationCheck{TokenID: "jti-1", IssuedAt: issuedAt})
+	require.NoError(t, err)
+	require.True(t, revoked)
+}
+
+func TestRevocationStoreSuspensions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	repo := &fakeRevocationRepo{}
+	store, err := service.NewRevocationStore(ctx, repo, 15*time.Minute, time.Hour)
+	require.NoError(t, err)
+
+	issuedAt := time.Now().Add(-time.Minute)
+
+	// a suspension revokes the tokens issued before it and tells them apart from other revocations
+	mintedAt := time.Now()
+	require.NoError(t, store.SuspendUser(ctx, "user-2"))
+	suspended, err := store.IsSuspended(ctx, service.RevocationCheck{TokenID: "jti-5", UserID: "user-2", IssuedAt: issuedAt})
+	require.NoError(t, err)
+	require.True(t, suspended)
+	revoked, err := store.IsRevoked(ctx, service.RevocationCheck{TokenID: "jti-5", UserID: "user-2", IssuedAt: issuedAt})
+	require.NoError(t, err)
+	require.True(t, revoked)
+
+	require.NoError(t, store.RevokeUser(ctx, "user-1"))
+	suspended, err = store.IsSuspended(ctx, service.RevocationCheck{TokenID: "jti-3", UserID: "user-1", IssuedAt: issuedAt})
+	require.NoError(t, err)
+	require.False(t, suspended)
+
+	// so is a token issued in the suspension's own second
+	suspended, err = store.IsSuspended(ctx, service.RevocationCheck{TokenID: "jti-7", UserID: "user-2", IssuedAt: mintedAt.Truncate(time.Second)})
+	require.NoError(t, err)
+	require.True(t, suspended)
+
+	// tokens issued after the suspension, once the user can log in again, are not caught by it
+	suspended, err = store.IsSuspended(ctx, service.RevocationCheck{TokenID: "jti-6", UserID: "user-2", IssuedAt: time.Now().Add(time.Second)})
+	require.NoError(t, err)
+	require.False(t, suspended)
+
+	// a second replica picks up the suspensions from the repository
+	replica, err := service.NewRevocationStore(ctx, repo, 15*time.Minute, time.Hour)
+	require.NoError(t, err)
+	suspended, err = replica.IsSuspended(ctx, service.RevocationCheck{TokenID: "jti-5", UserID: "user-2", IssuedAt: issuedAt})
+	require.NoError(t, err)
+	require.True(t, suspended)
+}
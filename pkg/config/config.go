@@ -1,39 +1,236 @@
 package config
 
-import (
-	"log"
-	"os"
-
-	"github.com/joho/godotenv"
-)
+import "time"
 
+// Config is the configuration of the auth service. It is loaded once at startup by Load and handed
+// to the components that need it, nothing below main reads the environment itself.
 type Config struct {
-	DBHost    string `env:"DB_HOST"`
-	DBUser    string `env:"DB_USER"`
-	DBPass    string `env:"DB_PASSWORD"`
-	DBName    string `env:"DB_NAME"`
-	DBPort    string `env:"DB_PORT"`
-	DBsslMode string `env:"DB_SSL_MODE"`
-
-	GRPCPort string `env:"GRPC_PORT"`
-}
-
-func LoadConfig() *Config {
-	// load configurations from .env file
-	err := godotenv.Load()
-	if err != nil {
-		log.Fatalf("Error loading environment variables: %v ", err)
-		return nil
-	}
+	DB       DBConfig       `yaml:"db"`
+	Server   ServerConfig   `yaml:"server"`
+	JWT      JWTConfig      `yaml:"jwt"`
+	Mail     MailConfig     `yaml:"mail"`
+	WebAuthn WebAuthnConfig `yaml:"webauthn"`
+	Security SecurityConfig `yaml:"security"`
+	Password PasswordConfig `yaml:"password"`
+	Audit    AuditConfig    `yaml:"audit"`
+
+	Maintenance MaintenanceConfig `yaml:"maintenance"`
+
+	// RateLimits replaces the whole built-in rate limit table when it is set.
+	RateLimits []RateLimit `yaml:"rate_limits"`
+}
+
+// DBConfig holds the PostgreSQL connection settings.
+type DBConfig struct {
+	Host     string `yaml:"host" env:"DB_HOST"`
+	Port     string `yaml:"port" env:"DB_PORT"`
+	User     string `yaml:"user" env:"DB_USER"`
+	Password string `yaml:"password" env:"DB_PASSWORD"`
+	Name     string `yaml:"name" env:"DB_NAME"`
+	SSLMode  string `yaml:"ssl_mode" env:"DB_SSL_MODE"`
+}
+
+// ServerConfig holds the ports the service listens on.
+type ServerConfig struct {
+	GRPCPort string `yaml:"grpc_port" env:"AUTH_GRPC_PORT"`
+	// HTTPPort serves the JWKS document, it is public.
+	HTTPPort string `yaml:"http_port" env:"AUTH_HTTP_PORT"`
+	// MetricsAddr is the internal host:port the expvar metrics are served on, empty disables them.
+	// It must not be reachable from outside, the metrics describe the signing keys.
+	MetricsAddr string `yaml:"metrics_addr" env:"AUTH_METRICS_ADDR"`
+	// TrustedProxies are the IP addresses or CIDR ranges of the proxies in front of the service,
+	// like hikayat-gateway. Only their x-forwarded-for and x-real-ip headers are honored, the
+	// client address of any other connection is the peer address.
+	TrustedProxies []string `yaml:"trusted_proxies" env:"TRUSTED_PROXIES"`
+}
+
+// JWTConfig holds the settings tokens are signed, issued and validated with.
+type JWTConfig struct {
+	// Secret signs HS256 tokens when no SigningKeyFile is set, otherwise it only keeps older
+	// HS256 tokens valid.
+	Secret string `yaml:"secret" env:"JWT_SECRET"`
+	// SigningKeyFile is a PEM encoded Ed25519 or RSA private key new tokens are signed with.
+	SigningKeyFile string `yaml:"signing_key_file" env:"JWT_SIGNING_KEY_FILE"`
+	// VerifyKeyFiles are further PEM keys that are only used for validation.
+	VerifyKeyFiles []string `yaml:"verify_key_files" env:"JWT_VERIFY_KEY_FILES"`
+
+	Issuer   string `yaml:"issuer" env:"JWT_ISSUER"`
+	Audience string `yaml:"audience" env:"JWT_AUDIENCE"`
+	// Leeway is the clock skew tolerated when checking exp, nbf and iat.
+	Leeway time.Duration `yaml:"leeway" env:"JWT_LEEWAY"`
+
+	AccessTTL            time.Duration `yaml:"access_ttl" env:"JWT_ACCESS_TTL"`
+	RefreshTTL           time.Duration `yaml:"refresh_ttl" env:"JWT_REFRESH_TTL"`
+	EmailVerificationTTL time.Duration `yaml:"email_verification_ttl" env:"EMAIL_VERIFICATION_TTL"`
+	MFAChallengeTTL      time.Duration `yaml:"mfa_challenge_ttl" env:"MFA_CHALLENGE_TTL"`
+
+	// RotationAlgorithm is the algorithm of the keys a rotation generates.
+	RotationAlgorithm string `yaml:"rotation_algorithm" env:"JWT_ROTATION_ALGORITHM"`
+	// KeyPublishDelay is how long a rotated key is only published before it signs tokens.
+	KeyPublishDelay time.Duration `yaml:"key_publish_delay" env:"JWT_KEY_PUBLISH_DELAY"`
+	// KeyOverlap is how long a replaced key keeps validating tokens. Zero uses the lifetime of
+	// the longest lived token.
+	KeyOverlap time.Duration `yaml:"key_overlap" env:"JWT_KEY_OVERLAP"`
+	// KeyEncryptionKey is the base64 encoded AES-256 key sealing the stored private keys.
+	KeyEncryptionKey string `yaml:"key_encryption_key" env:"JWT_KEY_ENCRYPTION_KEY"`
+}
+
+// LongestTokenTTL returns the lifetime of the longest lived token signed with the JWT keys.
+func (c JWTConfig) LongestTokenTTL() time.Duration {
+	return max(c.AccessTTL, c.EmailVerificationTTL, c.MFAChallengeTTL)
+}
+
+// MailConfig holds the mail transport, the templates and the links put into mails.
+type MailConfig struct {
+	// Transport is smtp, file or log.
+	Transport    string `yaml:"transport" env:"MAIL_TRANSPORT"`
+	From         string `yaml:"from" env:"MAIL_FROM"`
+	SMTPHost     string `yaml:"smtp_host" env:"SMTP_HOST"`
+	SMTPPort     string `yaml:"smtp_port" env:"SMTP_PORT"`
+	SMTPUsername string `yaml:"smtp_username" env:"SMTP_USERNAME"`
+	SMTPPassword string `yaml:"smtp_password" env:"SMTP_PASSWORD"`
+	DropDir      string `yaml:"drop_dir" env:"MAIL_DROP_DIR"`
 
-	return &Config{
-		DBHost:    os.Getenv("DB_HOST"),
-		DBUser:    os.Getenv("DB_USER"),
-		DBPass:    os.Getenv("DB_PASSWORD"),
-		DBName:    os.Getenv("DB_NAME"),
-		DBPort:    os.Getenv("DB_PORT"),
-		DBsslMode: os.Getenv("DB_SSL_MODE"),
+	// TemplateDir overrides the templates shipped with the service.
+	TemplateDir   string `yaml:"template_dir" env:"MAIL_TEMPLATE_DIR"`
+	DefaultLocale string `yaml:"default_locale" env:"MAIL_DEFAULT_LOCALE"`
+
+	// The links are completed by appending the token.
+	VerifyEmailURL        string `yaml:"verify_email_url" env:"VERIFY_EMAIL_URL"`
+	PasswordResetURL      string `yaml:"password_reset_url" env:"PASSWORD_RESET_URL"`
+	ConfirmEmailChangeURL string `yaml:"confirm_email_change_url" env:"CONFIRM_EMAIL_CHANGE_URL"`
+	CancelEmailChangeURL  string `yaml:"cancel_email_change_url" env:"CANCEL_EMAIL_CHANGE_URL"`
+}
+
+// WebAuthnConfig holds the relying party passkeys are bound to.
+type WebAuthnConfig struct {
+	RPID   string `yaml:"rp_id" env:"WEBAUTHN_RP_ID"`
+	RPName string `yaml:"rp_name" env:"WEBAUTHN_RP_NAME"`
+	// RPOrigins are the origins passkey ceremonies are accepted from.
+	RPOrigins   []string      `yaml:"rp_origins" env:"WEBAUTHN_RP_ORIGINS"`
+	CeremonyTTL time.Duration `yaml:"ceremony_ttl" env:"WEBAUTHN_CEREMONY_TTL"`
+}
+
+// SecurityConfig holds the account security policies.
+type SecurityConfig struct {
+	// UnverifiedLoginPolicy is restrict or refuse.
+	UnverifiedLoginPolicy string `yaml:"unverified_login_policy" env:"UNVERIFIED_LOGIN_POLICY"`
+	TOTPIssuer            string `yaml:"totp_issuer" env:"TOTP_ISSUER"`
+
+	PasswordResetTTL time.Duration `yaml:"password_reset_ttl" env:"PASSWORD_RESET_TTL"`
+	EmailChangeTTL   time.Duration `yaml:"email_change_ttl" env:"EMAIL_CHANGE_TTL"`
+
+	LoginMaxFailures     int           `yaml:"login_max_failures" env:"LOGIN_MAX_FAILURES"`
+	LoginIPMaxFailures   int           `yaml:"login_ip_max_failures" env:"LOGIN_IP_MAX_FAILURES"`
+	LoginFailureWindow   time.Duration `yaml:"login_failure_window" env:"LOGIN_FAILURE_WINDOW"`
+	LoginLockoutDuration time.Duration `yaml:"login_lockout_duration" env:"LOGIN_LOCKOUT_DURATION"`
+	LoginBackoffBase     time.Duration `yaml:"login_backoff_base" env:"LOGIN_BACKOFF_BASE"`
+	LoginBackoffMax      time.Duration `yaml:"login_backoff_max" env:"LOGIN_BACKOFF_MAX"`
+	LoginLockoutEmail    bool          `yaml:"login_lockout_email" env:"LOGIN_LOCKOUT_EMAIL"`
+
+	// ServiceClients are the backend services that may call the service-only RPCs, as
+	// client_id:sha256_hex entries.
+	ServiceClients []string `yaml:"service_clients" env:"SERVICE_CLIENTS"`
+
+	// BootstrapAdminEmail is given the admin role on startup once its owner registered and verified
+	// it. Unset it after the first admin exists, or removing their role does not stick.
+	BootstrapAdminEmail string `yaml:"bootstrap_admin_email" env:"BOOTSTRAP_ADMIN_EMAIL"`
+}
+
+// PasswordConfig holds the argon2id costs of new password hashes and the optional pepper.
+type PasswordConfig struct {
+	// Argon2Memory is in KiB.
+	Argon2Memory      int `yaml:"argon2_memory" env:"PASSWORD_ARGON2_MEMORY"`
+	Argon2Iterations  int `yaml:"argon2_iterations" env:"PASSWORD_ARGON2_ITERATIONS"`
+	Argon2Parallelism int `yaml:"argon2_parallelism" env:"PASSWORD_ARGON2_PARALLELISM"`
+	// PepperFile holds a server-side secret mixed into every new hash. Once set it has to be kept,
+	// hashes made with it cannot be verified without it.
+	PepperFile string `yaml:"pepper_file" env:"PASSWORD_PEPPER_FILE"`
+}
+
+// AuditConfig holds the signing of audit log checkpoints. Without a key no checkpoints are made.
+type AuditConfig struct {
+	// CheckpointKeyFile is a PEM encoded Ed25519 or RSA private key.
+	CheckpointKeyFile  string        `yaml:"checkpoint_key_file" env:"AUDIT_CHECKPOINT_KEY_FILE"`
+	CheckpointInterval time.Duration `yaml:"checkpoint_interval" env:"AUDIT_CHECKPOINT_INTERVAL"`
+}
+
+// MaintenanceConfig holds the schedule of the maintenance job and how long rows are kept. A
+// retention of 0 keeps the rows of that table forever.
+type MaintenanceConfig struct {
+	Interval time.Duration `yaml:"interval" env:"MAINTENANCE_INTERVAL"`
+	// BatchSize is the most rows deleted in one statement.
+	BatchSize int `yaml:"batch_size" env:"MAINTENANCE_BATCH_SIZE"`
+	// AuditRetention is how long audit events are kept. Older ones are exported to ArchiveDir
+	// before they are deleted, which needs the audit checkpoint key to sign the new chain anchor.
+	AuditRetention time.Duration `yaml:"audit_retention" env:"MAINTENANCE_AUDIT_RETENTION"`
+	ArchiveDir     string        `yaml:"archive_dir" env:"MAINTENANCE_ARCHIVE_DIR"`
+	// SessionRetention is how long sessions are kept after they expired or were revoked.
+	SessionRetention time.Duration `yaml:"session_retention" env:"MAINTENANCE_SESSION_RETENTION"`
+	// PasswordResetRetention is how long reset tokens are kept after they expired.
+	PasswordResetRetention time.Duration `yaml:"password_reset_retention" env:"MAINTENANCE_PASSWORD_RESET_RETENTION"`
+}
 
-		GRPCPort: os.Getenv("GRPC_PORT"),
+// Default returns the configuration used for every setting that is not configured.
+func Default() Config {
+	return Config{
+		DB: DBConfig{
+			Port:    "5432",
+			SSLMode: "require",
+		},
+		Server: ServerConfig{
+			GRPCPort:    "50051",
+			HTTPPort:    "8080",
+			MetricsAddr: "127.0.0.1:9090",
+		},
+		JWT: JWTConfig{
+			Issuer:               "hikayat-auth",
+			Audience:             "hikayat-forum",
+			Leeway:               30 * time.Second,
+			AccessTTL:            15 * time.Minute,
+			RefreshTTL:           30 * 24 * time.Hour,
+			EmailVerificationTTL: 48 * time.Hour,
+			MFAChallengeTTL:      5 * time.Minute,
+			RotationAlgorithm:    "EdDSA",
+			KeyPublishDelay:      10 * time.Minute,
+		},
+		Mail: MailConfig{
+			Transport:     "log",
+			DefaultLocale: "id",
+		},
+		WebAuthn: WebAuthnConfig{
+			RPID:        "localhost",
+			RPName:      "Hikayat",
+			RPOrigins:   []string{"http://localhost"},
+			CeremonyTTL: 5 * time.Minute,
+		},
+		Security: SecurityConfig{
+			UnverifiedLoginPolicy: "restrict",
+			TOTPIssuer:            "Hikayat",
+			PasswordResetTTL:      time.Hour,
+			EmailChangeTTL:        24 * time.Hour,
+			LoginMaxFailures:      5,
+			LoginIPMaxFailures:    20,
+			LoginFailureWindow:    15 * time.Minute,
+			LoginLockoutDuration:  15 * time.Minute,
+			LoginBackoffBase:      time.Second,
+			LoginBackoffMax:       30 * time.Second,
+			LoginLockoutEmail:     true,
+		},
+		Password: PasswordConfig{
+			Argon2Memory:      64 * 1024,
+			Argon2Iterations:  3,
+			Argon2Parallelism: 2,
+		},
+		Audit: AuditConfig{
+			CheckpointInterval: time.Hour,
+		},
+		Maintenance: MaintenanceConfig{
+			Interval:               time.Hour,
+			BatchSize:              1000,
+			SessionRetention:       7 * 24 * time.Hour,
+			PasswordResetRetention: 24 * time.Hour,
+		},
+		RateLimits: defaultRateLimits(),
 	}
 }
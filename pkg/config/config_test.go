@@ -0,0 +1,115 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	return path
+}
+
+func TestLoadLayersYAMLAndEnvironment(t *testing.T) {
+	path := writeConfigFile(t, `
+db:
+  host: db.internal
+  user: auth
+  name: auth
+jwt:
+  secret: yaml-secret
+  access_ttl: 10m
+webauthn:
+  rp_origins: [https://hikayat.example, https://admin.hikayat.example]
+`)
+
+	// the environment wins over the file
+	t.Setenv("JWT_ACCESS_TTL", "5m")
+	t.Setenv("LOGIN_MAX_FAILURES", "3")
+
+	cfg, err := config.Load(path)
+	require.NoError(t, err)
+
+	require.Equal(t, "db.internal", cfg.DB.Host)
+	require.Equal(t, "yaml-secret", cfg.JWT.Secret)
+	require.Equal(t, 5*time.Minute, cfg.JWT.AccessTTL)
+	require.Equal(t, 3, cfg.Security.LoginMaxFailures)
+	require.Equal(t, []string{"https://hikayat.example", "https://admin.hikayat.example"}, cfg.WebAuthn.RPOrigins)
+	require.Equal(t, config.Default().JWT.RefreshTTL, cfg.JWT.RefreshTTL)
+}
+
+func TestLoadReportsEveryProblem(t *testing.T) {
+	path := writeConfigFile(t, `
+db:
+  host: db.internal
+  user: auth
+  name: auth
+mail:
+  transport: smtp
+`)
+	t.Setenv("JWT_LEEWAY", "soon")
+
+	_, err := config.Load(path)
+	require.ErrorContains(t, err, "JWT_LEEWAY: invalid duration")
+
+	t.Setenv("JWT_LEEWAY", "")
+	t.Setenv("AUTH_METRICS_ADDR", "9090")
+	_, err = config.Load(path)
+	require.ErrorContains(t, err, "JWT_SIGNING_KEY_FILE or JWT_SECRET must be set")
+	require.ErrorContains(t, err, "SMTP_HOST is required")
+	require.ErrorContains(t, err, "AUTH_METRICS_ADDR must be host:port")
+}
+
+func TestLoadRejectsUnknownKeys(t *testing.T) {
+	path := writeConfigFile(t, `
+jwt:
+  acess_ttl: 10m
+`)
+
+	_, err := config.Load(path)
+	require.ErrorContains(t, err, "acess_ttl")
+}
+
+func TestLoadRateLimits(t *testing.T) {
+	t.Setenv("DB_HOST", "db.internal")
+	t.Setenv("DB_USER", "auth")
+	t.Setenv("DB_NAME", "auth")
+	t.Setenv("JWT_SECRET", "secret")
+
+	// without a table of its own the built-in one applies
+	cfg, err := config.Load("")
+	require.NoError(t, err)
+	require.Equal(t, config.Default().RateLimits, cfg.RateLimits)
+	require.NotEmpty(t, cfg.RateLimits)
+
+	path := writeConfigFile(t, `
+rate_limits:
+  - {method: /hikayat.forum.v1.AuthService/Login, key: ip, requests: 3, per: 1m, burst: 2}
+`)
+	cfg, err = config.Load(path)
+	require.NoError(t, err)
+	require.Equal(t, []config.RateLimit{{
+		Method:   "/hikayat.forum.v1.AuthService/Login",
+		Key:      config.RateLimitByIP,
+		Requests: 3,
+		Per:      time.Minute,
+		Burst:    2,
+	}}, cfg.RateLimits)
+
+	path = writeConfigFile(t, `
+rate_limits:
+  - {method: /hikayat.forum.v1.AuthService/Login, key: cookie, requests: 0, per: 1m}
+`)
+	_, err = config.Load(path)
+	require.ErrorContains(t, err, "rate_limits[0].key must be one of")
+	require.ErrorContains(t, err, "rate_limits[0].requests must be positive")
+}
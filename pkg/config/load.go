@@ -0,0 +1,128 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Load builds the configuration from, in increasing order of precedence, the defaults, the YAML
+// file at path, a .env file in the working directory and the environment. An empty path skips the
+// YAML file, a missing .env file is ignored. The result is validated, so every problem with the
+// configuration is reported at once instead of surfacing on the first request that hits it.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+
+		// unknown keys are rejected so a misspelled setting does not silently keep its default
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	}
+
+	// .env never overrides variables already set in the environment
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to load .env file: %w", err)
+	}
+
+	if err := applyEnv(reflect.ValueOf(&cfg).Elem()); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	return &cfg, nil
+}
+
+// applyEnv overrides every field carrying an env tag whose variable is set, recursing into the
+// sections. Lists are comma separated.
+func applyEnv(v reflect.Value) error {
+	var errs []error
+
+	for i := 0; i < v.NumField(); i++ {
+		field, value := v.Type().Field(i), v.Field(i)
+
+		if field.Type.Kind() == reflect.Struct {
+			if err := applyEnv(value); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+
+		key := field.Tag.Get("env")
+		raw, ok := os.LookupEnv(key)
+		if key == "" || !ok || raw == "" {
+			continue
+		}
+
+		if err := setField(value, raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setField parses raw into the field according to its type.
+func setField(value reflect.Value, raw string) error {
+	switch {
+	case value.Type() == durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", raw)
+		}
+		value.SetInt(int64(d))
+
+	case value.Kind() == reflect.String:
+		value.SetString(raw)
+
+	case value.Kind() == reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		value.SetInt(int64(n))
+
+	case value.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		value.SetBool(b)
+
+	case value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.String:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		value.Set(reflect.ValueOf(items))
+
+	default:
+		return fmt.Errorf("unsupported field type %s", value.Type())
+	}
+
+	return nil
+}
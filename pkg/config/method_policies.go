@@ -0,0 +1,92 @@
+package config
+
+// MethodAccess names the rule the auth interceptor applies to a method.
+type MethodAccess string
+
+const (
+	// AccessPublic lets anyone call the method without a token.
+	AccessPublic MethodAccess = "public"
+	// AccessAuthenticated requires a valid access token and nothing else.
+	AccessAuthenticated MethodAccess = "authenticated"
+	// AccessSelf requires the caller to be the user the request targets.
+	AccessSelf MethodAccess = "self"
+	// AccessPermission requires the caller to hold the policy's permission through one of their roles.
+	AccessPermission MethodAccess = "permission"
+	// AccessSelfOrPermission lets the targeted user through, and everyone else who holds the permission.
+	AccessSelfOrPermission MethodAccess = "self_or_permission"
+	// AccessServiceClient requires the client credentials of a backend service instead of a user token.
+	AccessServiceClient MethodAccess = "service_client"
+)
+
+// MethodPolicy is the authorization rule of one method. Permission is only used by the
+// AccessPermission and AccessSelfOrPermission rules.
+type MethodPolicy struct {
+	Access     MethodAccess
+	Permission string
+}
+
+// Permissions checked by the method policies, seeded by the roles and permissions migration.
+const (
+	PermissionUsersRead    = "users.read"
+	PermissionUsersDelete  = "users.delete"
+	PermissionUsersUnlock  = "users.unlock"
+	PermissionRolesManage  = "roles.manage"
+	PermissionKeysRotate   = "keys.rotate"
+	PermissionAuditRead    = "audit.read"
+	PermissionUsersSuspend = "users.suspend"
+)
+
+// MethodPolicies maps every full method name to its rule. Methods missing from the registry are
+// refused, so a new RPC stays closed until it is given a policy here.
+var MethodPolicies = map[string]MethodPolicy{
+	"/hikayat.forum.v1.AuthService/Register":                {Access: AccessPublic},
+	"/hikayat.forum.v1.AuthService/Login":                   {Access: AccessPublic},
+	"/hikayat.forum.v1.AuthService/RefreshToken":            {Access: AccessPublic},
+	"/hikayat.forum.v1.AuthService/RequestPasswordReset":    {Access: AccessPublic},
+	"/hikayat.forum.v1.AuthService/ConfirmPasswordReset":    {Access: AccessPublic},
+	"/hikayat.forum.v1.AuthService/VerifyEmail":             {Access: AccessPublic},
+	"/hikayat.forum.v1.AuthService/ResendVerificationEmail": {Access: AccessPublic},
+	"/hikayat.forum.v1.AuthService/ConfirmEmailChange":      {Access: AccessPublic},
+	"/hikayat.forum.v1.AuthService/CancelEmailChange":       {Access: AccessPublic},
+	"/hikayat.forum.v1.AuthService/VerifyMFA":               {Access: AccessPublic},
+	"/hikayat.forum.v1.AuthService/BeginPasskeyLogin":       {Access: AccessPublic},
+	"/hikayat.forum.v1.AuthService/FinishPasskeyLogin":      {Access: AccessPublic},
+	"/hikayat.forum.v1.AuthService/GetJWKS":                 {Access: AccessPublic},
+
+	"/hikayat.forum.v1.AuthService/Logout": {Access: AccessAuthenticated},
+
+	"/hikayat.forum.v1.AuthService/IntrospectToken": {Access: AccessServiceClient},
+
+	"/hikayat.forum.v1.AuthService/UpdateUserProfile":         {Access: AccessSelf},
+	"/hikayat.forum.v1.AuthService/ChangeUserEmail":           {Access: AccessSelf},
+	"/hikayat.forum.v1.AuthService/ChangeUserPassword":        {Access: AccessSelf},
+	"/hikayat.forum.v1.AuthService/LogoutAllDevices":          {Access: AccessSelf},
+	"/hikayat.forum.v1.AuthService/ListSessions":              {Access: AccessSelf},
+	"/hikayat.forum.v1.AuthService/RevokeSession":             {Access: AccessSelf},
+	"/hikayat.forum.v1.AuthService/BeginTOTPEnrollment":       {Access: AccessSelf},
+	"/hikayat.forum.v1.AuthService/ConfirmTOTPEnrollment":     {Access: AccessSelf},
+	"/hikayat.forum.v1.AuthService/DisableTOTP":               {Access: AccessSelf},
+	"/hikayat.forum.v1.AuthService/BeginPasskeyRegistration":  {Access: AccessSelf},
+	"/hikayat.forum.v1.AuthService/FinishPasskeyRegistration": {Access: AccessSelf},
+	"/hikayat.forum.v1.AuthService/ListPasskeys":              {Access: AccessSelf},
+	"/hikayat.forum.v1.AuthService/DeletePasskey":             {Access: AccessSelf},
+	"/hikayat.forum.v1.AuthService/ListMySecurityEvents":      {Access: AccessSelf},
+
+	"/hikayat.forum.v1.AuthService/GetUser":       {Access: AccessSelfOrPermission, Permission: PermissionUsersRead},
+	"/hikayat.forum.v1.AuthService/DeleteUser":    {Access: AccessSelfOrPermission, Permission: PermissionUsersDelete},
+	"/hikayat.forum.v1.AuthService/ListUserRoles": {Access: AccessSelfOrPermission, Permission: PermissionRolesManage},
+
+	"/hikayat.forum.v1.AuthService/UnlockAccount":            {Access: AccessPermission, Permission: PermissionUsersUnlock},
+	"/hikayat.forum.v1.AuthService/CreateRole":               {Access: AccessPermission, Permission: PermissionRolesManage},
+	"/hikayat.forum.v1.AuthService/DeleteRole":               {Access: AccessPermission, Permission: PermissionRolesManage},
+	"/hikayat.forum.v1.AuthService/ListRoles":                {Access: AccessPermission, Permission: PermissionRolesManage},
+	"/hikayat.forum.v1.AuthService/GrantPermissionToRole":    {Access: AccessPermission, Permission: PermissionRolesManage},
+	"/hikayat.forum.v1.AuthService/RevokePermissionFromRole": {Access: AccessPermission, Permission: PermissionRolesManage},
+	"/hikayat.forum.v1.AuthService/AssignRoleToUser":         {Access: AccessPermission, Permission: PermissionRolesManage},
+	"/hikayat.forum.v1.AuthService/RemoveRoleFromUser":       {Access: AccessPermission, Permission: PermissionRolesManage},
+	"/hikayat.forum.v1.AuthService/RotateSigningKey":         {Access: AccessPermission, Permission: PermissionKeysRotate},
+	"/hikayat.forum.v1.AuthService/ListAuditEvents":          {Access: AccessPermission, Permission: PermissionAuditRead},
+	"/hikayat.forum.v1.AuthService/SuspendUser":              {Access: AccessPermission, Permission: PermissionUsersSuspend},
+	"/hikayat.forum.v1.AuthService/LiftSuspension":           {Access: AccessPermission, Permission: PermissionUsersSuspend},
+	"/hikayat.forum.v1.AuthService/ListUserSuspensions":      {Access: AccessPermission, Permission: PermissionUsersSuspend},
+}
@@ -0,0 +1,26 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/Nucleussss/hikayat-forum/auth/pkg/config"
+	"github.com/stretchr/testify/require"
+
+	authpb "github.com/Nucleussss/hikayat-proto/gen/go/auth/v1"
+)
+
+func TestMethodPoliciesCoverEveryMethod(t *testing.T) {
+	desc := authpb.AuthService_ServiceDesc
+
+	for _, method := range desc.Methods {
+		fullMethod := "/" + desc.ServiceName + "/" + method.MethodName
+
+		policy, ok := config.MethodPolicies[fullMethod]
+		require.True(t, ok, "no policy for %s", fullMethod)
+
+		switch policy.Access {
+		case config.AccessPermission, config.AccessSelfOrPermission:
+			require.NotEmpty(t, policy.Permission, "%s needs a permission", fullMethod)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package config
+
+import "time"
+
+// RateLimitKey names what a rate limit counts requests against.
+type RateLimitKey string
+
+const (
+	// RateLimitByIP counts requests per client IP, as forwarded by hikayat-gateway.
+	RateLimitByIP RateLimitKey = "ip"
+	// RateLimitByUser counts requests per authenticated user. Unauthenticated requests are not counted.
+	RateLimitByUser RateLimitKey = "user"
+	// RateLimitByEmail counts requests per email address in the request. Requests without one are not counted.
+	RateLimitByEmail RateLimitKey = "email"
+	// RateLimitByChallenge counts requests per mfa challenge token in the request. Requests without one are not counted.
+	RateLimitByChallenge RateLimitKey = "challenge"
+)
+
+// RateLimitAnyMethod matches every method that has no rule of its own.
+const RateLimitAnyMethod = "*"
+
+// RateLimit allows Requests per Per for every key of one method, with bursts of up to Burst requests.
+type RateLimit struct {
+	Method   string        `yaml:"method"`
+	Key      RateLimitKey  `yaml:"key"`
+	Requests int           `yaml:"requests"`
+	Per      time.Duration `yaml:"per"`
+	Burst    int           `yaml:"burst"`
+}
+
+// defaultRateLimits is the table the rate limiting interceptor enforces unless the configuration
+// replaces it. A request has to pass every rule of its method; methods without rules fall back to
+// the RateLimitAnyMethod rules.
+func defaultRateLimits() []RateLimit {
+	return []RateLimit{
+		{Method: "/hikayat.forum.v1.AuthService/Register", Key: RateLimitByIP, Requests: 5, Per: time.Hour, Burst: 3},
+
+		{Method: "/hikayat.forum.v1.AuthService/Login", Key: RateLimitByIP, Requests: 30, Per: time.Minute, Burst: 10},
+		{Method: "/hikayat.forum.v1.AuthService/Login", Key: RateLimitByEmail, Requests: 10, Per: time.Minute, Burst: 5},
+		{Method: "/hikayat.forum.v1.AuthService/VerifyMFA", Key: RateLimitByIP, Requests: 10, Per: time.Minute, Burst: 5},
+		{Method: "/hikayat.forum.v1.AuthService/VerifyMFA", Key: RateLimitByChallenge, Requests: 3, Per: time.Minute, Burst: 3},
+		{Method: "/hikayat.forum.v1.AuthService/BeginPasskeyLogin", Key: RateLimitByIP, Requests: 30, Per: time.Minute, Burst: 10},
+		{Method: "/hikayat.forum.v1.AuthService/FinishPasskeyLogin", Key: RateLimitByIP, Requests: 30, Per: time.Minute, Burst: 10},
+		{Method: "/hikayat.forum.v1.AuthService/RefreshToken", Key: RateLimitByIP, Requests: 60, Per: time.Minute, Burst: 20},
+
+		{Method: "/hikayat.forum.v1.AuthService/RequestPasswordReset", Key: RateLimitByIP, Requests: 10, Per: time.Hour, Burst: 5},
+		{Method: "/hikayat.forum.v1.AuthService/RequestPasswordReset", Key: RateLimitByEmail, Requests: 3, Per: time.Hour, Burst: 3},
+		{Method: "/hikayat.forum.v1.AuthService/ResendVerificationEmail", Key: RateLimitByEmail, Requests: 3, Per: time.Hour, Burst: 3},
+		{Method: "/hikayat.forum.v1.AuthService/ChangeUserEmail", Key: RateLimitByUser, Requests: 5, Per: time.Hour, Burst: 3},
+
+		// introspection is called by backend services on behalf of every forum request
+		{Method: "/hikayat.forum.v1.AuthService/IntrospectToken", Key: RateLimitByIP, Requests: 6000, Per: time.Minute, Burst: 1000},
+
+		{Method: RateLimitAnyMethod, Key: RateLimitByIP, Requests: 300, Per: time.Minute, Burst: 100},
+	}
+}
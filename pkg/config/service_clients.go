@@ -0,0 +1,27 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseServiceClients reads the backend services that may call the service-only RPCs from
+// client_id:secret_hash entries. The hash is the hex encoded SHA-256 of the client secret, so the
+// configuration never holds the secret itself.
+func ParseServiceClients(entries []string) (map[string]string, error) {
+	clients := make(map[string]string)
+
+	for _, entry := range entries {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+
+		clientID, secretHash, ok := strings.Cut(entry, ":")
+		if !ok || clientID == "" || len(secretHash) != 64 {
+			return nil, fmt.Errorf("invalid SERVICE_CLIENTS entry %q, expected client_id:sha256_hex", entry)
+		}
+		clients[clientID] = strings.ToLower(secretHash)
+	}
+
+	return clients, nil
+}
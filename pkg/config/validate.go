@@ -0,0 +1,178 @@
+package config
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Validate checks the configuration and returns every problem it finds, joined into one error.
+// Settings are named by their environment variable.
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	v.require("DB_HOST", c.DB.Host)
+	v.require("DB_USER", c.DB.User)
+	v.require("DB_NAME", c.DB.Name)
+	v.port("DB_PORT", c.DB.Port)
+	v.oneOf("DB_SSL_MODE", c.DB.SSLMode, "disable", "allow", "prefer", "require", "verify-ca", "verify-full")
+
+	v.port("AUTH_GRPC_PORT", c.Server.GRPCPort)
+	v.port("AUTH_HTTP_PORT", c.Server.HTTPPort)
+	if c.Server.MetricsAddr != "" {
+		if _, port, err := net.SplitHostPort(c.Server.MetricsAddr); err != nil {
+			v.fail("AUTH_METRICS_ADDR must be host:port, got %q", c.Server.MetricsAddr)
+		} else {
+			v.port("AUTH_METRICS_ADDR", port)
+		}
+	}
+	if _, err := ParseTrustedProxies(c.Server.TrustedProxies); err != nil {
+		v.fail("%v", err)
+	}
+
+	if c.JWT.Secret == "" && c.JWT.SigningKeyFile == "" {
+		v.fail("JWT_SIGNING_KEY_FILE or JWT_SECRET must be set")
+	}
+	v.require("JWT_ISSUER", c.JWT.Issuer)
+	v.require("JWT_AUDIENCE", c.JWT.Audience)
+	if c.JWT.Leeway < 0 {
+		v.fail("JWT_LEEWAY must not be negative")
+	}
+	v.positive("JWT_ACCESS_TTL", c.JWT.AccessTTL)
+	v.positive("JWT_REFRESH_TTL", c.JWT.RefreshTTL)
+	v.positive("EMAIL_VERIFICATION_TTL", c.JWT.EmailVerificationTTL)
+	v.positive("MFA_CHALLENGE_TTL", c.JWT.MFAChallengeTTL)
+	v.oneOf("JWT_ROTATION_ALGORITHM", c.JWT.RotationAlgorithm, "EdDSA", "RS256", "HS256")
+	v.positive("JWT_KEY_PUBLISH_DELAY", c.JWT.KeyPublishDelay)
+	if c.JWT.KeyOverlap != 0 && c.JWT.KeyOverlap < c.JWT.LongestTokenTTL() {
+		v.fail("JWT_KEY_OVERLAP must be at least %s, the lifetime of the longest lived token", c.JWT.LongestTokenTTL())
+	}
+	if c.JWT.KeyEncryptionKey != "" {
+		if key, err := base64.StdEncoding.DecodeString(c.JWT.KeyEncryptionKey); err != nil || len(key) != 32 {
+			v.fail("JWT_KEY_ENCRYPTION_KEY must be 32 base64 encoded bytes")
+		}
+	}
+
+	v.oneOf("MAIL_TRANSPORT", c.Mail.Transport, "smtp", "file", "log")
+	switch c.Mail.Transport {
+	case "smtp":
+		v.require("MAIL_FROM", c.Mail.From)
+		v.require("SMTP_HOST", c.Mail.SMTPHost)
+		v.port("SMTP_PORT", c.Mail.SMTPPort)
+	case "file":
+		v.require("MAIL_DROP_DIR", c.Mail.DropDir)
+	}
+	v.require("MAIL_DEFAULT_LOCALE", c.Mail.DefaultLocale)
+
+	v.require("WEBAUTHN_RP_ID", c.WebAuthn.RPID)
+	v.require("WEBAUTHN_RP_NAME", c.WebAuthn.RPName)
+	if len(c.WebAuthn.RPOrigins) == 0 {
+		v.fail("WEBAUTHN_RP_ORIGINS must list at least one origin")
+	}
+	v.positive("WEBAUTHN_CEREMONY_TTL", c.WebAuthn.CeremonyTTL)
+
+	v.oneOf("UNVERIFIED_LOGIN_POLICY", c.Security.UnverifiedLoginPolicy, "restrict", "refuse")
+	v.require("TOTP_ISSUER", c.Security.TOTPIssuer)
+	v.positive("PASSWORD_RESET_TTL", c.Security.PasswordResetTTL)
+	v.positive("EMAIL_CHANGE_TTL", c.Security.EmailChangeTTL)
+	if c.Security.LoginMaxFailures <= 0 {
+		v.fail("LOGIN_MAX_FAILURES must be positive")
+	}
+	if c.Security.LoginIPMaxFailures <= 0 {
+		v.fail("LOGIN_IP_MAX_FAILURES must be positive")
+	}
+	v.positive("LOGIN_FAILURE_WINDOW", c.Security.LoginFailureWindow)
+	v.positive("LOGIN_LOCKOUT_DURATION", c.Security.LoginLockoutDuration)
+	v.positive("LOGIN_BACKOFF_BASE", c.Security.LoginBackoffBase)
+	v.positive("LOGIN_BACKOFF_MAX", c.Security.LoginBackoffMax)
+	if _, err := ParseServiceClients(c.Security.ServiceClients); err != nil {
+		v.fail("%v", err)
+	}
+
+	if c.Password.Argon2Memory <= 0 || c.Password.Argon2Memory > math.MaxUint32 {
+		v.fail("PASSWORD_ARGON2_MEMORY must be a positive number of KiB")
+	}
+	if c.Password.Argon2Iterations <= 0 || c.Password.Argon2Iterations > math.MaxUint32 {
+		v.fail("PASSWORD_ARGON2_ITERATIONS must be positive")
+	}
+	if c.Password.Argon2Parallelism <= 0 || c.Password.Argon2Parallelism > math.MaxUint8 {
+		v.fail("PASSWORD_ARGON2_PARALLELISM must be between 1 and 255")
+	} else if c.Password.Argon2Memory < 8*c.Password.Argon2Parallelism {
+		v.fail("PASSWORD_ARGON2_MEMORY must be at least 8 KiB per unit of parallelism")
+	}
+
+	v.positive("AUDIT_CHECKPOINT_INTERVAL", c.Audit.CheckpointInterval)
+
+	v.positive("MAINTENANCE_INTERVAL", c.Maintenance.Interval)
+	if c.Maintenance.BatchSize <= 0 {
+		v.fail("MAINTENANCE_BATCH_SIZE must be positive")
+	}
+	if c.Maintenance.AuditRetention < 0 {
+		v.fail("MAINTENANCE_AUDIT_RETENTION must not be negative")
+	}
+	if c.Maintenance.AuditRetention > 0 && c.Maintenance.ArchiveDir == "" {
+		v.fail("MAINTENANCE_ARCHIVE_DIR is required to prune audit events")
+	}
+	if c.Maintenance.AuditRetention > 0 && c.Audit.CheckpointKeyFile == "" {
+		v.fail("AUDIT_CHECKPOINT_KEY_FILE is required to prune audit events, pruning signs the new chain anchor")
+	}
+	if c.Maintenance.SessionRetention < 0 {
+		v.fail("MAINTENANCE_SESSION_RETENTION must not be negative")
+	}
+	if c.Maintenance.PasswordResetRetention < 0 {
+		v.fail("MAINTENANCE_PASSWORD_RESET_RETENTION must not be negative")
+	}
+
+	for i, limit := range c.RateLimits {
+		key := fmt.Sprintf("rate_limits[%d]", i)
+		v.require(key+".method", limit.Method)
+		v.oneOf(key+".key", string(limit.Key), string(RateLimitByIP), string(RateLimitByUser), string(RateLimitByEmail), string(RateLimitByChallenge))
+		if limit.Requests <= 0 {
+			v.fail("%s.requests must be positive", key)
+		}
+		v.positive(key+".per", limit.Per)
+		if limit.Burst < 0 {
+			v.fail("%s.burst must not be negative", key)
+		}
+	}
+
+	return errors.Join(v.errs...)
+}
+
+// validator collects the problems found by Validate.
+type validator struct {
+	errs []error
+}
+
+func (v *validator) fail(format string, args ...any) {
+	v.errs = append(v.errs, fmt.Errorf(format, args...))
+}
+
+func (v *validator) require(key, value string) {
+	if value == "" {
+		v.fail("%s is required", key)
+	}
+}
+
+func (v *validator) positive(key string, value time.Duration) {
+	if value <= 0 {
+		v.fail("%s must be a positive duration", key)
+	}
+}
+
+func (v *validator) port(key, value string) {
+	if n, err := strconv.Atoi(value); err != nil || n <= 0 || n > 65535 {
+		v.fail("%s must be a port number, got %q", key, value)
+	}
+}
+
+func (v *validator) oneOf(key, value string, allowed ...string) {
+	if !slices.Contains(allowed, value) {
+		v.fail("%s must be one of %v, got %q", key, allowed, value)
+	}
+}
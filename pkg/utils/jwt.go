@@ -3,42 +3,200 @@ package utils
 import (
 	"fmt"
 	"log"
-	"os"
-	"strconv"
+	"strings"
 	"time"
 
+	"github.com/Nucleussss/hikayat-forum/auth/internal/jwtkeys"
+	"github.com/Nucleussss/hikayat-forum/auth/pkg/config"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
-func GenerateJWTToken(userId uuid.UUID, secretKey string) (string, error) {
-	jwtExp := os.Getenv("JWT_EXPIRED")
+// Values of the token_use claim, which keeps tokens of one kind from being accepted as another.
+const (
+	TokenUseAccess            = "access"
+	TokenUseEmailVerification = "email_verification"
+	TokenUseMFAChallenge      = "mfa_challenge"
+)
+
+// AccessTokenClaims holds the application claims of an access token. Roles, Permissions and
+// AuthzVersion let downstream services authorize the user without calling back.
+type AccessTokenClaims struct {
+	UserID        uuid.UUID
+	SessionID     uuid.UUID
+	EmailVerified bool
+	Roles         []string
+	Permissions   []string
+	AuthzVersion  int64
+}
+
+// GenerateJWTToken issues a short lived access token for the user, bound to the session
+// family it was issued for. The lifetime is the configured access TTL and every token
+// carries a unique jti so it can be revoked on its own. Permissions are packed into the
+// space separated "perms" claim, in the style of the OAuth scope claim.
+func GenerateJWTToken(claims AccessTokenClaims, keys jwtkeys.Set, cfg config.JWTConfig) (string, error) {
+	now := time.Now()
 
-	// convert jwtexp string to int
-	jwtExpInt, err := strconv.Atoi(jwtExp)
+	roles := claims.Roles
+	if roles == nil {
+		roles = []string{}
+	}
+
+	// create a new token with claims and sign it with the active key
+	return signToken(jwt.MapClaims{
+		"token_use":      TokenUseAccess,
+		"user_id":        claims.UserID.String(),
+		"sid":            claims.SessionID.String(),
+		"email_verified": claims.EmailVerified,
+		"roles":          roles,
+		"perms":          strings.Join(claims.Permissions, " "),
+		"authz_ver":      claims.AuthzVersion,
+		"jti":            uuid.NewString(),
+		"iat":            now.Unix(),
+		"exp":            now.Add(cfg.AccessTTL).Unix(),
+	}, cfg.Audience, keys, cfg)
+}
+
+// GenerateEmailVerificationToken issues a signed token proving control over email for the user.
+// The token is bound to the address, so it stops working once the user's email changes.
+func GenerateEmailVerificationToken(userId uuid.UUID, email string, keys jwtkeys.Set, cfg config.JWTConfig) (string, error) {
+	now := time.Now()
+
+	return signToken(jwt.MapClaims{
+		"token_use": TokenUseEmailVerification,
+		"user_id":   userId.String(),
+		"email":     email,
+		"iat":       now.Unix(),
+		"exp":       now.Add(cfg.EmailVerificationTTL).Unix(),
+	}, cfg.Issuer, keys, cfg)
+}
+
+// ValidateEmailVerificationToken checks a token from GenerateEmailVerificationToken and returns
+// the user ID and email it was issued for.
+func ValidateEmailVerificationToken(tokenString string, keys jwtkeys.Set, cfg config.JWTConfig) (uuid.UUID, string, error) {
+	claims, userID, err := validateTokenOfUse(tokenString, keys, cfg, TokenUseEmailVerification)
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+
+	email, ok := (*claims)["email"].(string)
+	if !ok || email == "" {
+		return uuid.Nil, "", fmt.Errorf("missing email in token claims")
+	}
+
+	return userID, email, nil
+}
+
+// GenerateMFAChallengeToken issues the intermediate token Login returns to users with a second
+// factor. It only proves the password step and cannot be used as an access token.
+func GenerateMFAChallengeToken(userId uuid.UUID, keys jwtkeys.Set, cfg config.JWTConfig) (string, error) {
+	now := time.Now()
+
+	return signToken(jwt.MapClaims{
+		"token_use": TokenUseMFAChallenge,
+		"user_id":   userId.String(),
+		"jti":       uuid.NewString(),
+		"iat":       now.Unix(),
+		"exp":       now.Add(cfg.MFAChallengeTTL).Unix(),
+	}, cfg.Issuer, keys, cfg)
+}
+
+// ValidateMFAChallengeToken checks a token from GenerateMFAChallengeToken and returns the user ID,
+// the token ID and the expiry of the challenge.
+func ValidateMFAChallengeToken(tokenString string, keys jwtkeys.Set, cfg config.JWTConfig) (uuid.UUID, string, time.Time, error) {
+	claims, userID, err := validateTokenOfUse(tokenString, keys, cfg, TokenUseMFAChallenge)
+	if err != nil {
+		return uuid.Nil, "", time.Time{}, err
+	}
+
+	tokenID, ok := (*claims)["jti"].(string)
+	if !ok || tokenID == "" {
+		return uuid.Nil, "", time.Time{}, fmt.Errorf("missing jti in token claims")
+	}
+
+	expiresAt, err := claims.GetExpirationTime()
+	if err != nil || expiresAt == nil {
+		return uuid.Nil, "", time.Time{}, fmt.Errorf("missing exp in token claims")
+	}
+
+	return userID, tokenID, expiresAt.Time, nil
+}
+
+// validateTokenOfUse validates a token this service issued for itself and makes sure it was
+// issued for the given use.
+func validateTokenOfUse(tokenString string, keys jwtkeys.Set, cfg config.JWTConfig, use string) (*jwt.MapClaims, uuid.UUID, error) {
+	claims, err := validateToken(tokenString, keys, cfg, cfg.Issuer)
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+
+	if tokenUse, _ := (*claims)["token_use"].(string); tokenUse != use {
+		return nil, uuid.Nil, fmt.Errorf("token_use %q is not %q", tokenUse, use)
+	}
+
+	userID, err := uuid.Parse(fmt.Sprint((*claims)["user_id"]))
+	if err != nil {
+		return nil, uuid.Nil, fmt.Errorf("invalid user_id in token claims")
+	}
+
+	return claims, userID, nil
+}
+
+// signToken adds the registered claims for the audience, signs the claims with the signing key of
+// the set and names the key in the kid header. The subject is the user_id claim, which is kept for
+// existing consumers.
+func signToken(claims jwt.MapClaims, audience string, keys jwtkeys.Set, cfg config.JWTConfig) (string, error) {
+	key, err := keys.SigningKey()
 	if err != nil {
-		log.Println("Error converting JWT expiration time to integer:", err)
 		return "", err
 	}
 
-	// create a new token with claims and secret key
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": userId.String(),
-		"exp":     time.Now().Add(time.Hour * time.Duration(jwtExpInt)).Unix(),
-	})
+	claims["iss"] = cfg.Issuer
+	claims["aud"] = []string{audience}
+	claims["sub"] = claims["user_id"]
+	claims["nbf"] = claims["iat"]
+	if _, ok := claims["jti"]; !ok {
+		claims["jti"] = uuid.NewString()
+	}
+
+	token := jwt.NewWithClaims(key.SigningMethod(), claims)
+	token.Header["kid"] = key.ID
+
+	return token.SignedString(key.SignKey())
+}
 
-	// sign the token with secret key and return it
-	return token.SignedString([]byte(secretKey))
+// ValidateJWTToken checks an access token and returns its claims.
+func ValidateJWTToken(tokenString string, keys jwtkeys.Set, cfg config.JWTConfig) (*jwt.MapClaims, error) {
+	return validateToken(tokenString, keys, cfg, cfg.Audience)
 }
 
-func ValidateJWTToken(tokenString string, secretKey string) (*jwt.MapClaims, error) {
-	// parse the token using the secret key
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.MapClaims{}, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+// validateToken checks the signature of a token against the key named by its kid header, makes
+// sure it was issued by this service for the given audience and is within its lifetime, allowing
+// for the configured clock skew, and returns its claims.
+func validateToken(tokenString string, keys jwtkeys.Set, cfg config.JWTConfig, audience string) (*jwt.MapClaims, error) {
+	// look up the key the token was signed with
+	keyFunc := func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, err := keys.VerificationKey(kid)
+		if err != nil {
+			return nil, err
+		}
+
+		// the algorithm is fixed by the key, never by the token
+		if t.Method.Alg() != key.Algorithm {
 			return nil, jwt.ErrSignatureInvalid
 		}
-		return []byte(secretKey), nil
-	})
+		return key.VerifyKey(), nil
+	}
+
+	// parse the token, checking the registered claims along with the signature
+	token, err := jwt.ParseWithClaims(tokenString, &jwt.MapClaims{}, keyFunc,
+		jwt.WithIssuer(cfg.Issuer),
+		jwt.WithAudience(audience),
+		jwt.WithLeeway(cfg.Leeway),
+		jwt.WithIssuedAt(),
+		jwt.WithExpirationRequired(),
+	)
 
 	// handle any errors that occur during parsing
 	if err != nil {
@@ -55,6 +213,9 @@ func ValidateJWTToken(tokenString string, secretKey string) (*jwt.MapClaims, err
 	// check if the token is valid and has not expired
 	// Safe to assert as *jwt.MapClaims
 	if claims, ok := token.Claims.(*jwt.MapClaims); ok {
+		if subject, _ := claims.GetSubject(); subject == "" {
+			return nil, fmt.Errorf("missing sub in token claims")
+		}
 		return claims, nil
 	}
 
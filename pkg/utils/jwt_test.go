@@ -0,0 +1,115 @@
+package utils_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/jwtkeys"
+	"github.com/Nucleussss/hikayat-forum/auth/pkg/config"
+	"github.com/Nucleussss/hikayat-forum/auth/pkg/utils"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+var jwtConfig = config.Default().JWT
+
+func newEd25519Set(t *testing.T) *jwtkeys.StaticSet {
+	t.Helper()
+
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	key, err := jwtkeys.NewKey(private)
+	require.NoError(t, err)
+	set, err := jwtkeys.NewStaticSet(key)
+	require.NoError(t, err)
+
+	return set
+}
+
+func TestGenerateJWTTokenEmbedsAuthorization(t *testing.T) {
+	keys := newEd25519Set(t)
+
+	token, err := utils.GenerateJWTToken(utils.AccessTokenClaims{
+		UserID:       uuid.New(),
+		SessionID:    uuid.New(),
+		Roles:        []string{"moderator"},
+		Permissions:  []string{"comments.moderate", "posts.moderate"},
+		AuthzVersion: 3,
+	}, keys, jwtConfig)
+	require.NoError(t, err)
+
+	claims, err := utils.ValidateJWTToken(token, keys, jwtConfig)
+	require.NoError(t, err)
+	require.Equal(t, []any{"moderator"}, (*claims)["roles"])
+	require.Equal(t, "comments.moderate posts.moderate", (*claims)["perms"])
+	require.EqualValues(t, 3, (*claims)["authz_ver"])
+}
+
+func TestValidateJWTTokenRejectsForeignKeys(t *testing.T) {
+	token, err := utils.GenerateJWTToken(utils.AccessTokenClaims{UserID: uuid.New()}, newEd25519Set(t), jwtConfig)
+	require.NoError(t, err)
+
+	_, err = utils.ValidateJWTToken(token, newEd25519Set(t), jwtConfig)
+	require.Error(t, err)
+}
+
+func TestValidateJWTTokenAcceptsLegacyHS256(t *testing.T) {
+	legacy, err := jwtkeys.NewHMACKey(jwtkeys.LegacyHMACKeyID, []byte("test-secret"))
+	require.NoError(t, err)
+	legacySet, err := jwtkeys.NewStaticSet(legacy)
+	require.NoError(t, err)
+
+	token, err := utils.GenerateJWTToken(utils.AccessTokenClaims{UserID: uuid.New()}, legacySet, jwtConfig)
+	require.NoError(t, err)
+
+	// after the switch to Ed25519 the secret keeps validating tokens it signed
+	signing, err := newEd25519Set(t).SigningKey()
+	require.NoError(t, err)
+	keys, err := jwtkeys.NewStaticSet(signing, legacy)
+	require.NoError(t, err)
+
+	_, err = utils.ValidateJWTToken(token, keys, jwtConfig)
+	require.NoError(t, err)
+}
+
+func TestGenerateJWTTokenIssuesRegisteredClaims(t *testing.T) {
+	keys := newEd25519Set(t)
+	userID := uuid.New()
+
+	token, err := utils.GenerateJWTToken(utils.AccessTokenClaims{UserID: userID}, keys, jwtConfig)
+	require.NoError(t, err)
+
+	claims, err := utils.ValidateJWTToken(token, keys, jwtConfig)
+	require.NoError(t, err)
+
+	subject, err := claims.GetSubject()
+	require.NoError(t, err)
+	require.Equal(t, userID.String(), subject)
+
+	issuer, err := claims.GetIssuer()
+	require.NoError(t, err)
+	require.Equal(t, jwtConfig.Issuer, issuer)
+
+	for _, name := range []string{"iat", "nbf", "exp", "jti"} {
+		require.Contains(t, *claims, name)
+	}
+}
+
+func TestValidateJWTTokenRejectsOtherAudience(t *testing.T) {
+	keys := newEd25519Set(t)
+
+	web := jwtConfig
+	web.Audience = "hikayat-web"
+	token, err := utils.GenerateJWTToken(utils.AccessTokenClaims{UserID: uuid.New()}, keys, web)
+	require.NoError(t, err)
+
+	// the admin API only accepts tokens minted for it
+	admin := jwtConfig
+	admin.Audience = "hikayat-admin"
+	_, err = utils.ValidateJWTToken(token, keys, admin)
+	require.Error(t, err)
+
+	_, err = utils.ValidateJWTToken(token, keys, web)
+	require.NoError(t, err)
+}
@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/google/uuid"
+)
+
+// EncodeAuditPageToken turns the position of the last event of a page into an opaque page token.
+func EncodeAuditPageToken(cursor models.AuditCursor) string {
+	raw := strconv.FormatInt(cursor.CreatedAt.UnixMicro(), 10) + ":" + cursor.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeAuditPageToken reverses EncodeAuditPageToken. An empty token is the first page and
+// decodes to a nil cursor.
+func DecodeAuditPageToken(token string) (*models.AuditCursor, error) {
+	if token == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid page token")
+	}
+
+	micros, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return nil, fmt.Errorf("invalid page token")
+	}
+
+	createdAt, err := strconv.ParseInt(micros, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid page token")
+	}
+
+	eventID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid page token")
+	}
+
+	return &models.AuditCursor{CreatedAt: time.UnixMicro(createdAt), ID: eventID}, nil
+}
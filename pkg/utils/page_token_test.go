@@ -0,0 +1,31 @@
+package utils_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
+	"github.com/Nucleussss/hikayat-forum/auth/pkg/utils"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditPageTokenRoundTrip(t *testing.T) {
+	cursor := models.AuditCursor{
+		CreatedAt: time.Date(2024, 5, 1, 12, 30, 0, 123456000, time.UTC),
+		ID:        uuid.New(),
+	}
+
+	decoded, err := utils.DecodeAuditPageToken(utils.EncodeAuditPageToken(cursor))
+	require.NoError(t, err)
+	require.True(t, cursor.CreatedAt.Equal(decoded.CreatedAt))
+	require.Equal(t, cursor.ID, decoded.ID)
+
+	// the first page has no cursor
+	decoded, err = utils.DecodeAuditPageToken("")
+	require.NoError(t, err)
+	require.Nil(t, decoded)
+
+	_, err = utils.DecodeAuditPageToken("not-a-token")
+	require.Error(t, err)
+}
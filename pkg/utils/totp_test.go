@@ -0,0 +1,73 @@
+package utils_test
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Nucleussss/hikayat-forum/auth/pkg/utils"
+	"github.com/stretchr/testify/require"
+)
+
+// rfc6238Secret is the SHA1 test key of RFC 6238 appendix B.
+var rfc6238Secret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCodeMatchesRFC6238Vectors(t *testing.T) {
+	// RFC 6238 lists 8 digit values, the 6 digit codes are their last six digits
+	vectors := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+
+	for unix, want := range vectors {
+		got, err := utils.TOTPCode(rfc6238Secret, time.Unix(unix, 0))
+		require.NoError(t, err)
+		require.Equal(t, want, got, "time %d", unix)
+	}
+}
+
+func TestValidateTOTPAcceptsAdjacentSteps(t *testing.T) {
+	secret, err := utils.GenerateTOTPSecret()
+	require.NoError(t, err)
+
+	now := time.Now()
+	previous, err := utils.TOTPCode(secret, now.Add(-30*time.Second))
+	require.NoError(t, err)
+
+	step, ok := utils.ValidateTOTP(secret, previous, now)
+	require.True(t, ok)
+	require.Equal(t, now.Unix()/30-1, step)
+
+	stale, err := utils.TOTPCode(secret, now.Add(-2*time.Minute))
+	require.NoError(t, err)
+	_, ok = utils.ValidateTOTP(secret, stale, now)
+	require.False(t, ok)
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := utils.GenerateRecoveryCodes(10)
+	require.NoError(t, err)
+	require.Len(t, codes, 10)
+
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		require.Regexp(t, `^[a-z2-7]{8}(-[a-z2-7]{8}){3}$`, code)
+		require.False(t, seen[code])
+		seen[code] = true
+	}
+
+	// the code is recognized however the user types it
+	normalized := utils.NormalizeRecoveryCode(codes[0])
+	require.Len(t, normalized, 32)
+	for _, typed := range []string{
+		strings.ReplaceAll(codes[0], "-", ""),
+		strings.ToUpper(codes[0]),
+		" " + strings.ReplaceAll(codes[0], "-", " ") + "\n",
+	} {
+		require.Equal(t, normalized, utils.NormalizeRecoveryCode(typed), typed)
+	}
+}
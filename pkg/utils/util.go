@@ -2,13 +2,18 @@ package utils
 
 import (
 	"context"
+	"net"
+	"net/netip"
 	"regexp"
+	"strings"
+	"time"
 
 	contextKey "github.com/Nucleussss/hikayat-forum/auth/internal/context"
 	"github.com/Nucleussss/hikayat-forum/auth/internal/models"
 	authpb "github.com/Nucleussss/hikayat-proto/gen/go/auth/v1"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/protobuf/types/known/structpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -22,21 +27,107 @@ func IsValidPassword(password string) bool {
 	return len(password) >= 8
 }
 
-func EnsureUserAuthorized(ctx context.Context, reqID string) error {
-	// get the user ID from the context
-	userIDFromToken, ok := ctx.Value(contextKey.UserIDContextKey).(string)
+// ClientInfo is the user agent and IP address of the calling client.
+type ClientInfo struct {
+	UserAgent string
+	IPAddress string
+}
 
-	// check if the user ID is present in the context and is valid
+// ClientInfoFromContext returns the user agent and IP address of the calling client, as resolved
+// by the client info interceptor. Without it the values of the direct gRPC connection are used.
+func ClientInfoFromContext(ctx context.Context) (userAgent string, ipAddress string) {
+	info, ok := ctx.Value(contextKey.ClientInfoContextKey).(ClientInfo)
 	if !ok {
-		return status.Error(codes.Internal, "user ID not found in context")
+		info = ResolveClientInfo(ctx, nil)
+	}
+	return info.UserAgent, info.IPAddress
+}
+
+// ResolveClientInfo returns the user agent and IP address of the calling client. The headers
+// forwarded by hikayat-gateway are only honored when the peer is one of the trusted proxies, then
+// the client is the last x-forwarded-for hop that is not a trusted proxy itself. Any other peer
+// is the client, whatever headers it sends.
+func ResolveClientInfo(ctx context.Context, trustedProxies []netip.Prefix) ClientInfo {
+	var info ClientInfo
+
+	peerAddr := peerAddress(ctx)
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	if peerAddr.IsValid() && isTrustedProxy(peerAddr, trustedProxies) {
+		info.UserAgent = firstMetadataValue(md, "grpcgateway-user-agent", "user-agent")
+		info.IPAddress = forwardedClient(md, trustedProxies)
+	} else {
+		info.UserAgent = firstMetadataValue(md, "user-agent")
+	}
+
+	if info.IPAddress == "" && peerAddr.IsValid() {
+		info.IPAddress = peerAddr.String()
+	}
+
+	return info
+}
+
+// peerAddress returns the address of the direct gRPC connection, the zero Addr when it has none.
+func peerAddress(ctx context.Context) netip.Addr {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return netip.Addr{}
+	}
+
+	address := p.Addr.String()
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		address = host
+	}
+
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addr.Unmap()
+}
+
+// forwardedClient walks x-forwarded-for from the nearest hop and returns the first address that
+// is not a trusted proxy, falling back to x-real-ip.
+func forwardedClient(md metadata.MD, trustedProxies []netip.Prefix) string {
+	hops := strings.Split(strings.Join(md.Get("x-forwarded-for"), ","), ",")
+
+	var client string
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			continue
+		}
+		client = addr.Unmap().String()
+		if !isTrustedProxy(addr.Unmap(), trustedProxies) {
+			return client
+		}
+	}
+	if client != "" {
+		return client
 	}
 
-	// check if the user ID in the token matches the user ID in the request	if not, return an error
-	if reqID != userIDFromToken {
-		return status.Error(codes.PermissionDenied, "cannot update another user's profile")
+	if addr, err := netip.ParseAddr(strings.TrimSpace(firstMetadataValue(md, "x-real-ip"))); err == nil {
+		return addr.Unmap().String()
+	}
+	return ""
+}
+
+func isTrustedProxy(addr netip.Addr, trustedProxies []netip.Prefix) bool {
+	for _, proxy := range trustedProxies {
+		if proxy.Contains(addr) {
+			return true
+		}
 	}
+	return false
+}
 
-	return nil
+func firstMetadataValue(md metadata.MD, keys ...string) string {
+	for _, key := range keys {
+		if values := md.Get(key); len(values) > 0 && values[0] != "" {
+			return values[0]
+		}
+	}
+	return ""
 }
 
 func AuthModelToPB(p *models.User) *authpb.User {
@@ -45,11 +136,119 @@ func AuthModelToPB(p *models.User) *authpb.User {
 	}
 
 	return &authpb.User{
+		Id:       p.ID.String(),
+		Name:     p.Name,
+		Email:    p.Email,
+		IsActive: p.IsActive,
+		Locale:   p.Locale,
+
+		EmailVerified: p.EmailVerifiedAt != nil,
+		CreatedAt:     timestamppb.New(p.CreatedAt),
+		UpdatedAt:     timestamppb.New(p.UpdatedAt),
+	}
+}
+
+func SessionModelToPB(p *models.Session, currentSessionID string) *authpb.Session {
+	if p == nil {
+		return nil
+	}
+
+	return &authpb.Session{
+		Id:         p.FamilyID.String(),
+		UserAgent:  p.UserAgent,
+		IpAddress:  p.IPAddress,
+		CreatedAt:  timestamppb.New(p.CreatedAt),
+		LastSeenAt: timestamppb.New(p.LastSeenAt),
+		ExpiresAt:  timestamppb.New(p.ExpiresAt),
+		Current:    p.FamilyID.String() == currentSessionID,
+	}
+}
+
+func PasskeyModelToPB(p *models.Passkey) *authpb.Passkey {
+	if p == nil {
+		return nil
+	}
+
+	passkey := &authpb.Passkey{
+		Id:         p.ID.String(),
+		Name:       p.Name,
+		Transports: p.Transports,
+		CreatedAt:  timestamppb.New(p.CreatedAt),
+	}
+
+	if p.LastUsedAt != nil {
+		passkey.LastUsedAt = timestamppb.New(*p.LastUsedAt)
+	}
+
+	return passkey
+}
+
+func RoleModelToPB(p *models.Role) *authpb.Role {
+	if p == nil {
+		return nil
+	}
+
+	return &authpb.Role{
+		Id:          p.ID.String(),
+		Name:        p.Name,
+		Permissions: p.Permissions,
+	}
+}
+
+func AuditEventModelToPB(p *models.AuditEvent) *authpb.AuditEvent {
+	if p == nil {
+		return nil
+	}
+
+	event := &authpb.AuditEvent{
 		Id:        p.ID.String(),
-		Name:      p.Name,
-		Email:     p.Email,
-		IsActive:  p.IsActive,
+		Action:    p.Action,
+		Outcome:   p.Outcome,
+		IpAddress: p.IPAddress,
+		UserAgent: p.UserAgent,
 		CreatedAt: timestamppb.New(p.CreatedAt),
-		UpdatedAt: timestamppb.New(p.UpdatedAt),
 	}
+
+	if p.ActorID != nil {
+		event.ActorId = p.ActorID.String()
+	}
+	if p.TargetUserID != nil {
+		event.TargetUserId = p.TargetUserID.String()
+	}
+	// metadata read back from the database is plain JSON, which always converts
+	if metadata, err := structpb.NewStruct(p.Metadata); err == nil {
+		event.Metadata = metadata
+	}
+
+	return event
+}
+
+func SuspensionModelToPB(p *models.UserSuspension, now time.Time) *authpb.Suspension {
+	if p == nil {
+		return nil
+	}
+
+	suspension := &authpb.Suspension{
+		Id:         p.ID.String(),
+		UserId:     p.UserID.String(),
+		Reason:     p.Reason,
+		CreatedAt:  timestamppb.New(p.CreatedAt),
+		LiftReason: p.LiftReason,
+		Active:     p.InForce(now),
+	}
+
+	if p.SuspendedBy != nil {
+		suspension.SuspendedBy = p.SuspendedBy.String()
+	}
+	if p.ExpiresAt != nil {
+		suspension.ExpiresAt = timestamppb.New(*p.ExpiresAt)
+	}
+	if p.LiftedAt != nil {
+		suspension.LiftedAt = timestamppb.New(*p.LiftedAt)
+	}
+	if p.LiftedBy != nil {
+		suspension.LiftedBy = p.LiftedBy.String()
+	}
+
+	return suspension
 }
@@ -0,0 +1,46 @@
+package utils_test
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/Nucleussss/hikayat-forum/auth/pkg/utils"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func clientContext(peerAddress string, headers ...string) context.Context {
+	ctx := peer.NewContext(context.Background(), &peer.Peer{
+		Addr: &net.TCPAddr{IP: net.ParseIP(peerAddress), Port: 41234},
+	})
+	return metadata.NewIncomingContext(ctx, metadata.Pairs(headers...))
+}
+
+func TestResolveClientInfoHonorsTrustedProxiesOnly(t *testing.T) {
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+
+	// a direct client cannot claim another address
+	info := utils.ResolveClientInfo(clientContext("203.0.113.7", "x-forwarded-for", "198.51.100.1", "x-real-ip", "198.51.100.2", "grpcgateway-user-agent", "spoofed", "user-agent", "grpc-go"), trusted)
+	require.Equal(t, "203.0.113.7", info.IPAddress)
+	require.Equal(t, "grpc-go", info.UserAgent)
+
+	// behind the gateway the nearest hop that is not a trusted proxy is the client, whatever the
+	// client prepended itself
+	info = utils.ResolveClientInfo(clientContext("10.0.0.2", "x-forwarded-for", "198.51.100.1, 203.0.113.7, 10.0.0.3", "grpcgateway-user-agent", "Firefox"), trusted)
+	require.Equal(t, "203.0.113.7", info.IPAddress)
+	require.Equal(t, "Firefox", info.UserAgent)
+
+	info = utils.ResolveClientInfo(clientContext("10.0.0.2", "x-real-ip", "203.0.113.9"), trusted)
+	require.Equal(t, "203.0.113.9", info.IPAddress)
+
+	// without forwarded headers the proxy itself is the client
+	info = utils.ResolveClientInfo(clientContext("10.0.0.2"), trusted)
+	require.Equal(t, "10.0.0.2", info.IPAddress)
+
+	// without trusted proxies the headers are never honored
+	info = utils.ResolveClientInfo(clientContext("10.0.0.2", "x-forwarded-for", "203.0.113.7"), nil)
+	require.Equal(t, "10.0.0.2", info.IPAddress)
+}